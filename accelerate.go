@@ -0,0 +1,350 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/vector"
+)
+
+// Rasterizer turns filled polygons into coverage, the part of drawing that
+// operations bound by rasterizing (rather than IO) spend their time on, so
+// the part worth handing to a GPU (eg. with OpenGL or Vulkan compute, bound
+// to in a module of its own so mimage stays pure Go). See AcceleratedRenderer.
+type Rasterizer interface {
+	// Rasterize returns the coverage of the polygons (closed, in pixels of
+	// a w x h chunk) under the given fill rule, as a w x h mask. An error
+	// (eg. for a rule it doesn't handle) has the path drawn by gg instead.
+	Rasterize(w, h int, polygons [][]Point, rule FillRule) (*image.Alpha, error)
+}
+
+// SoftwareRasterizer returns a Rasterizer built on golang.org/x/image/vector,
+// the fallback where there's no GPU. It handles FillRuleWinding only.
+func SoftwareRasterizer() Rasterizer { return vectorRasterizer{} }
+
+// vectorRasterizer rasterizes with golang.org/x/image/vector.
+type vectorRasterizer struct{}
+
+// Rasterize returns the coverage of the polygons.
+func (vectorRasterizer) Rasterize(w, h int, polygons [][]Point, rule FillRule) (*image.Alpha, error) {
+	if rule != FillRuleWinding {
+		return nil, fmt.Errorf("the software rasterizer only fills with FillRuleWinding")
+	}
+	z := vector.NewRasterizer(w, h)
+	z.DrawOp = draw.Src
+	for _, poly := range polygons {
+		z.MoveTo(float32(poly[0].X), float32(poly[0].Y))
+		for _, p := range poly[1:] {
+			z.LineTo(float32(p.X), float32(p.Y))
+		}
+		z.ClosePath()
+	}
+	mask := image.NewAlpha(image.Rect(0, 0, w, h))
+	z.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+	return mask, nil
+}
+
+// AcceleratedRenderer returns a Renderer that hands the paths filled &
+// stroked on each chunk to r (SoftwareRasterizer if nil) & blends the
+// coverage it returns, eg.
+//
+//	im, _ := mimage.New(bounds, mimage.RenderWith(mimage.AcceleratedRenderer(gpu)))
+//
+// Everything else (images, pixels, masks ..) is drawn with gg, as are paths
+// filled or stroked with a pattern (see SetFillStyle) or r can't rasterize.
+// Lines are stroked with round caps & joins, as gg strokes them.
+func AcceleratedRenderer(r Rasterizer) Renderer {
+	if r == nil {
+		r = SoftwareRasterizer()
+	}
+	return acceleratedRenderer{r}
+}
+
+// acceleratedRenderer makes canvases rasterizing with a Rasterizer.
+type acceleratedRenderer struct {
+	raster Rasterizer
+}
+
+// NewCanvas returns a canvas drawing onto img.
+func (a acceleratedRenderer) NewCanvas(img *image.RGBA) Canvas {
+	return &acceleratedCanvas{
+		ggCanvas: ggRenderer{}.NewCanvas(img).(ggCanvas),
+		raster:   a.raster,
+		img:      img,
+		paint:    paintState{fill: color.White, stroke: color.Black, width: 1}, // as gg starts
+	}
+}
+
+// acceleratedCanvas is a gg canvas that also keeps the current path (in
+// pixels of the chunk), so fills & strokes can be rasterized elsewhere.
+type acceleratedCanvas struct {
+	ggCanvas
+	raster Rasterizer
+	img    *image.RGBA
+
+	paths   [][]Point // the current path, the last being the open subpath
+	current bool      // if there's a current point
+	mask    *image.Alpha
+
+	// paint is saved & restored by Push & Pop, which (as with gg) leave the
+	// path & mask as they are
+	paint paintState
+	stack []paintState
+}
+
+// paintState is how paths are painted, fill & stroke being nil when drawn
+// with a pattern.
+type paintState struct {
+	fill   color.Color
+	stroke color.Color
+	width  float64
+	rule   FillRule
+}
+
+// Push saves the drawing state.
+func (c *acceleratedCanvas) Push() {
+	c.stack = append(c.stack, c.paint)
+	c.ggCanvas.Push()
+}
+
+// Pop restores the last saved drawing state.
+func (c *acceleratedCanvas) Pop() {
+	if len(c.stack) > 0 {
+		c.paint = c.stack[len(c.stack)-1]
+		c.stack = c.stack[:len(c.stack)-1]
+	}
+	c.ggCanvas.Pop()
+}
+
+// SetColor sets the color paths are filled & stroked with.
+func (c *acceleratedCanvas) SetColor(col color.Color) {
+	c.paint.fill, c.paint.stroke = col, col
+	c.ggCanvas.SetColor(col)
+}
+
+// SetFillStyle sets the pattern paths are filled with.
+func (c *acceleratedCanvas) SetFillStyle(p Pattern) {
+	c.paint.fill = nil
+	c.ggCanvas.SetFillStyle(p)
+}
+
+// SetStrokeStyle sets the pattern paths are stroked with.
+func (c *acceleratedCanvas) SetStrokeStyle(p Pattern) {
+	c.paint.stroke = nil
+	c.ggCanvas.SetStrokeStyle(p)
+}
+
+// SetLineWidth sets the width lines are stroked at.
+func (c *acceleratedCanvas) SetLineWidth(w float64) {
+	c.paint.width = w
+	c.ggCanvas.SetLineWidth(w)
+}
+
+// SetFillRule sets the rule paths are filled with.
+func (c *acceleratedCanvas) SetFillRule(r FillRule) {
+	c.paint.rule = r
+	c.ggCanvas.SetFillRule(r)
+}
+
+// SetMask sets the mask drawing is clipped to.
+func (c *acceleratedCanvas) SetMask(mask *image.Alpha) error {
+	err := c.ggCanvas.SetMask(mask)
+	if err == nil {
+		c.mask = mask
+	}
+	return err
+}
+
+// InvertMask inverts the mask, gg inverts the one it was given in place.
+func (c *acceleratedCanvas) InvertMask() {
+	c.ggCanvas.InvertMask()
+	if c.mask == nil {
+		c.mask = image.NewAlpha(c.img.Bounds()) // as gg makes it
+	}
+}
+
+// ResetClip removes the mask.
+func (c *acceleratedCanvas) ResetClip() {
+	c.mask = nil
+	c.ggCanvas.ResetClip()
+}
+
+// ClearPath clears the current path.
+func (c *acceleratedCanvas) ClearPath() {
+	c.paths = nil
+	c.current = false
+	c.ggCanvas.ClearPath()
+}
+
+// MoveTo starts a new subpath at (x,y).
+func (c *acceleratedCanvas) MoveTo(x, y float64) {
+	c.moveTo(x, y)
+	c.ggCanvas.MoveTo(x, y)
+}
+
+// LineTo adds a line to (x,y) to the current subpath.
+func (c *acceleratedCanvas) LineTo(x, y float64) {
+	c.lineTo(x, y)
+	c.ggCanvas.LineTo(x, y)
+}
+
+// ClosePath adds a line back to the start of the current subpath.
+func (c *acceleratedCanvas) ClosePath() {
+	c.closePath()
+	c.ggCanvas.ClosePath()
+}
+
+// DrawRectangle adds a rectangle to the path.
+func (c *acceleratedCanvas) DrawRectangle(x, y, w, h float64) {
+	c.current = false
+	c.moveTo(x, y)
+	c.lineTo(x+w, y)
+	c.lineTo(x+w, y+h)
+	c.lineTo(x, y+h)
+	c.closePath()
+	c.ggCanvas.DrawRectangle(x, y, w, h)
+}
+
+// DrawEllipse adds an ellipse to the path, as a polygon fine enough that
+// its sides are no more than a pixel or so long.
+func (c *acceleratedCanvas) DrawEllipse(x, y, rx, ry float64) {
+	cx, cy := c.TransformPoint(x, y)
+	ax, ay := c.TransformPoint(x+rx, y)
+	bx, by := c.TransformPoint(x, y+ry)
+	reach := math.Max(math.Hypot(ax-cx, ay-cy), math.Hypot(bx-cx, by-cy))
+	steps := clampInt(int(math.Ceil(2*math.Pi*reach)), 16, 4096)
+
+	c.current = false
+	for i := 0; i < steps; i++ {
+		a := 2 * math.Pi * float64(i) / float64(steps)
+		c.lineTo(x+rx*math.Cos(a), y+ry*math.Sin(a))
+	}
+	c.closePath()
+	c.ggCanvas.DrawEllipse(x, y, rx, ry)
+}
+
+// Fill fills the path & clears it.
+func (c *acceleratedCanvas) Fill() {
+	if c.paint.fill == nil || !c.blend(c.fillPolygons(), c.paint.fill, c.paint.rule) {
+		c.ggCanvas.Fill()
+	}
+	c.ClearPath()
+}
+
+// Stroke strokes the path & clears it.
+func (c *acceleratedCanvas) Stroke() {
+	if c.paint.stroke == nil || !c.blend(c.strokePolygons(), c.paint.stroke, FillRuleWinding) {
+		c.ggCanvas.Stroke()
+	}
+	c.ClearPath()
+}
+
+// moveTo starts a new subpath at (x,y), in user space.
+func (c *acceleratedCanvas) moveTo(x, y float64) {
+	px, py := c.TransformPoint(x, y)
+	c.paths = append(c.paths, []Point{{X: px, Y: py}})
+	c.current = true
+}
+
+// lineTo adds (x,y), in user space, to the current subpath.
+func (c *acceleratedCanvas) lineTo(x, y float64) {
+	if !c.current {
+		c.moveTo(x, y)
+		return
+	}
+	px, py := c.TransformPoint(x, y)
+	last := len(c.paths) - 1
+	c.paths[last] = append(c.paths[last], Point{X: px, Y: py})
+}
+
+// closePath adds the start of the current subpath to its end.
+func (c *acceleratedCanvas) closePath() {
+	if !c.current {
+		return
+	}
+	last := len(c.paths) - 1
+	c.paths[last] = append(c.paths[last], c.paths[last][0])
+}
+
+// fillPolygons returns the subpaths enclosing anything.
+func (c *acceleratedCanvas) fillPolygons() [][]Point {
+	out := [][]Point{}
+	for _, p := range c.paths {
+		if len(p) > 2 {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// strokePolygons returns polygons covering the path stroked at the line
+// width; a rectangle along each line & a disc at each point (for round caps
+// & joins), all wound the same way so their union is filled.
+func (c *acceleratedCanvas) strokePolygons() [][]Point {
+	half := c.paint.width / 2
+	if half <= 0 {
+		return nil
+	}
+	steps := clampInt(int(math.Ceil(2*math.Pi*half)), 8, 1024)
+
+	out := [][]Point{}
+	for _, path := range c.paths {
+		if len(path) < 2 {
+			continue
+		}
+		for i, p := range path {
+			disc := make([]Point, steps)
+			for j := range disc {
+				a := 2 * math.Pi * float64(j) / float64(steps)
+				disc[j] = Point{X: p.X + half*math.Cos(a), Y: p.Y + half*math.Sin(a)}
+			}
+			out = append(out, disc)
+			if i == 0 {
+				continue
+			}
+
+			q := path[i-1]
+			length := math.Hypot(p.X-q.X, p.Y-q.Y)
+			if length == 0 {
+				continue
+			}
+			nx, ny := -(p.Y-q.Y)/length*half, (p.X-q.X)/length*half
+			out = append(out, []Point{
+				{X: q.X - nx, Y: q.Y - ny},
+				{X: p.X - nx, Y: p.Y - ny},
+				{X: p.X + nx, Y: p.Y + ny},
+				{X: q.X + nx, Y: q.Y + ny},
+			})
+		}
+	}
+	return out
+}
+
+// blend rasterizes the polygons & blends col over the chunk where they
+// cover it (within the mask), returning false if they couldn't be
+// rasterized.
+func (c *acceleratedCanvas) blend(polygons [][]Point, col color.Color, rule FillRule) bool {
+	if len(polygons) == 0 {
+		return true // nothing to draw
+	}
+	w, h := c.img.Bounds().Dx(), c.img.Bounds().Dy()
+	coverage, err := c.raster.Rasterize(w, h, polygons, rule)
+	if err != nil || coverage.Bounds().Size() != image.Pt(w, h) {
+		return false
+	}
+	if c.mask != nil {
+		mb := c.mask.Bounds()
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				a := c.mask.Pix[c.mask.PixOffset(mb.Min.X+x, mb.Min.Y+y)]
+				i := coverage.PixOffset(x, y)
+				coverage.Pix[i] = uint8((uint32(coverage.Pix[i])*uint32(a) + 127) / 255)
+			}
+		}
+	}
+	draw.DrawMask(c.img, c.img.Bounds(), image.NewUniform(col), image.Point{}, coverage, coverage.Bounds().Min, draw.Over)
+	return true
+}
@@ -0,0 +1,82 @@
+package mimage_test
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+// failingRasterizer can't rasterize anything.
+type failingRasterizer struct{}
+
+func (failingRasterizer) Rasterize(w, h int, polygons [][]mimage.Point, rule mimage.FillRule) (*image.Alpha, error) {
+	return nil, fmt.Errorf("no gpu")
+}
+
+// maxDiff returns the biggest difference in any channel of any pixel of the
+// two images within r.
+func maxDiff(a, b *mimage.Mimage, r image.Rectangle) uint8 {
+	diff := uint8(0)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			ca, cb := a.At(x, y).(color.RGBA), b.At(x, y).(color.RGBA)
+			for _, d := range []uint8{absDiff(ca.R, cb.R), absDiff(ca.G, cb.G), absDiff(ca.B, cb.B), absDiff(ca.A, cb.A)} {
+				if d > diff {
+					diff = d
+				}
+			}
+		}
+	}
+	return diff
+}
+
+func TestAcceleratedRenderer(t *testing.T) {
+	draw := func(m *mimage.Mimage) {
+		op := m.Draw()
+		op.SetColor(color.RGBA{200, 40, 40, 255})
+		op.DrawEllipse(60, 60, 40, 40)
+		op.Fill()
+		op.SetColor(color.RGBA{0, 0, 128, 128})
+		op.SetLineWidth(6)
+		op.MoveTo(10, 110)
+		op.LineTo(60, 40)
+		op.LineTo(110, 90)
+		op.Stroke()
+		err := op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := image.Rect(0, 0, 120, 120)
+
+	// gg leaves seams along the edges of chunks paths cross, so compare a
+	// single chunk; the rasterizers differ a little in anti-aliasing along
+	// the edges of caps & joins
+	want := newImage(t, r, mimage.ChunkSize(128))
+	draw(want)
+	got := newImage(t, r, mimage.ChunkSize(128), mimage.RenderWith(mimage.AcceleratedRenderer(nil)))
+	draw(got)
+	if d := maxDiff(got, want, r); d > 64 {
+		t.Errorf("accelerated drawing differs from gg by up to %d", d)
+	}
+	if got.At(60, 60) != (color.RGBA{200, 40, 40, 255}) {
+		t.Errorf("centre of the ellipse is %v", got.At(60, 60))
+	}
+
+	// & chunks join seamlessly, to within the rasterizer's fixed point precision
+	chunked := newImage(t, r, mimage.ChunkSize(32), mimage.RenderWith(mimage.AcceleratedRenderer(nil)))
+	draw(chunked)
+	if d := maxDiff(chunked, got, r); d > 12 {
+		t.Errorf("accelerated drawing in chunks differs by up to %d", d)
+	}
+
+	// what can't be rasterized is drawn by gg
+	fallback := newImage(t, r, mimage.ChunkSize(128), mimage.RenderWith(mimage.AcceleratedRenderer(failingRasterizer{})))
+	draw(fallback)
+	if d := maxDiff(fallback, want, r); d != 0 {
+		t.Errorf("drawing falling back to gg differs by up to %d", d)
+	}
+}
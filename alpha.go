@@ -0,0 +1,172 @@
+package mimage
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// AlphaMode determines how transparent colors are stored.
+type AlphaMode int
+
+const (
+	// AlphaPremultiplied stores colors premultiplied by their alpha, as
+	// image.RGBA does. This is the fastest mode, but colors of mostly
+	// transparent pixels lose precision (at alpha 10 there are only 11
+	// levels of each color channel) which can show up as dark fringes
+	// after repeated compositing & exporting.
+	AlphaPremultiplied AlphaMode = iota
+
+	// AlphaStraight additionally keeps each chunk with straight (not
+	// premultiplied) alpha, as image.NRGBA does, which is what is saved to
+	// disk. Drawing still happens premultiplied but pixels that an operation
+	// doesn't touch keep their full precision, images drawn with DrawImage
+	// are composited with straight alpha and exports read straight colors.
+	// This costs extra memory per chunk & time converting between the two.
+	AlphaStraight
+)
+
+// Alpha sets how transparent colors are stored, the default is
+// AlphaPremultiplied.
+func Alpha(mode AlphaMode) Option {
+	return func(m *Mimage) error {
+		m.alpha = mode
+		return nil
+	}
+}
+
+// AlphaMode returns how transparent colors are stored in this image.
+func (m *Mimage) AlphaMode() AlphaMode { return m.alpha }
+
+// premultiply returns c with its colors multiplied by alpha, exactly as the
+// image/draw package would convert it.
+func premultiply(c color.NRGBA) color.RGBA {
+	a := uint32(c.A) * 0x101
+	return color.RGBA{
+		R: uint8(uint32(c.R) * a / 0xff >> 8),
+		G: uint8(uint32(c.G) * a / 0xff >> 8),
+		B: uint8(uint32(c.B) * a / 0xff >> 8),
+		A: c.A,
+	}
+}
+
+// unpremultiply returns c with its colors divided by alpha (rounded).
+func unpremultiply(c color.RGBA) color.NRGBA {
+	if c.A == 0 {
+		return color.NRGBA{}
+	}
+	a := uint32(c.A)
+	div := func(v uint8) uint8 {
+		return uint8(minInt(0xff, int((uint32(v)*0xff+a/2)/a)))
+	}
+	return color.NRGBA{R: div(c.R), G: div(c.G), B: div(c.B), A: c.A}
+}
+
+// reconcileAt returns the straight color for pixel i (a Pix offset, which
+// is the same for both images of the same size). If the premultiplied pixel
+// still matches the straight one, nothing has drawn over it and the
+// straight color is returned at full precision.
+func reconcileAt(straight *image.NRGBA, rgba *image.RGBA, i int) color.NRGBA {
+	s := straight.Pix[i : i+4 : i+4]
+	p := rgba.Pix[i : i+4 : i+4]
+	sc := color.NRGBA{R: s[0], G: s[1], B: s[2], A: s[3]}
+	pc := color.RGBA{R: p[0], G: p[1], B: p[2], A: p[3]}
+	if premultiply(sc) == pc {
+		return sc
+	}
+	return unpremultiply(pc)
+}
+
+// reconcile updates straight to match rgba (both with the same bounds),
+// keeping the straight colors of pixels that haven't been drawn over.
+func reconcile(straight *image.NRGBA, rgba *image.RGBA) {
+	for i := 0; i < len(rgba.Pix); i += 4 {
+		c := reconcileAt(straight, rgba, i)
+		straight.Pix[i], straight.Pix[i+1], straight.Pix[i+2], straight.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+}
+
+// ImageNRGBA returns a selected piece of the massive image with straight (not
+// premultiplied) alpha, where the top left of the returned image is r.Min.
+//
+// With AlphaStraight the colors are read at full precision, otherwise they're
+// converted from the premultiplied colors.
+func (m *Mimage) ImageNRGBA(r image.Rectangle) (*image.NRGBA, error) {
+	out := image.NewNRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	err := m.eachChunk(r, func(ctx *context) error {
+		cb := m.chunkBounds(ctx.X, ctx.Y)
+		area := r.Intersect(cb)
+		rgba := ctx.Img.Image().(*image.RGBA)
+
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				i := rgba.PixOffset(x-cb.Min.X, y-cb.Min.Y)
+				var c color.NRGBA
+				if ctx.straight != nil {
+					c = reconcileAt(ctx.straight, rgba, i)
+				} else {
+					p := rgba.Pix[i : i+4 : i+4]
+					c = unpremultiply(color.RGBA{R: p[0], G: p[1], B: p[2], A: p[3]})
+				}
+				out.SetNRGBA(x-r.Min.X, y-r.Min.Y, c)
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
+// compositeStraight draws src over the chunk with its top left at (x,y) (in
+// chunk space), blending with straight alpha & updating both the straight
+// and premultiplied copies of the chunk.
+func compositeStraight(ctx *context, src image.Image, x, y int) {
+	rgba := ctx.Img.Image().(*image.RGBA)
+	sb := src.Bounds()
+	area := sb.Add(image.Pt(x, y)).Intersect(rgba.Bounds())
+
+	for dy := area.Min.Y; dy < area.Max.Y; dy++ {
+		for dx := area.Min.X; dx < area.Max.X; dx++ {
+			s := color.NRGBAModel.Convert(src.At(dx-x, dy-y)).(color.NRGBA)
+			if s.A == 0 {
+				continue
+			}
+
+			i := rgba.PixOffset(dx, dy)
+			d := reconcileAt(ctx.straight, rgba, i)
+
+			out := s
+			if s.A < 0xff && d.A > 0 {
+				sa := float64(s.A) / 0xff
+				da := float64(d.A) / 0xff * (1 - sa)
+				oa := sa + da
+				mix := func(sc, dc uint8) uint8 {
+					return uint8((float64(sc)*sa+float64(dc)*da)/oa + 0.5)
+				}
+				out = color.NRGBA{R: mix(s.R, d.R), G: mix(s.G, d.G), B: mix(s.B, d.B), A: uint8(oa*0xff + 0.5)}
+			}
+
+			ctx.straight.SetNRGBA(dx, dy, out)
+			rgba.SetRGBA(dx, dy, premultiply(out))
+		}
+	}
+}
+
+// isIdentity returns if the context has no transform applied.
+func isIdentity(dc Canvas) bool {
+	for _, pt := range []Point{{0, 0}, {1, 0}, {0, 1}} {
+		x, y := dc.TransformPoint(pt.X, pt.Y)
+		if x != pt.X || y != pt.Y {
+			return false
+		}
+	}
+	return true
+}
+
+// pasteStraight copies part of img onto the straight copy of a chunk, as
+// paste does for the premultiplied copy.
+func pasteStraight(ctx *context, r image.Rectangle, img image.Image, sp image.Point) {
+	if ctx.straight == nil {
+		return
+	}
+	draw.Draw(ctx.straight, r, img, sp, draw.Src)
+}
@@ -0,0 +1,88 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestAlphaStraight(t *testing.T) {
+	faint := color.NRGBA{200, 100, 50, 10}
+	src := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	draw.Draw(src, src.Bounds(), image.NewUniform(faint), image.Point{}, draw.Src)
+
+	for mode, want := range map[mimage.AlphaMode]color.NRGBA{
+		mimage.AlphaStraight:      faint,
+		mimage.AlphaPremultiplied: {179, 51, 0, 10}, // the precision lost premultiplying
+	} {
+		dir := t.TempDir()
+		m, err := mimage.New(image.Rect(0, 0, 64, 64), mimage.Directory(dir), mimage.ChunkSize(32), mimage.Alpha(mode))
+		if err != nil {
+			t.Fatal(err)
+		}
+		op := m.Draw()
+		op.DrawImage(src, 24, 24) // across chunks
+		err = op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = m.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the mode & colors survive being written to disk
+		loaded, err := mimage.Load(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded.AlphaMode() != mode {
+			t.Errorf("reloaded alpha mode is %v, want %v", loaded.AlphaMode(), mode)
+		}
+		img, err := loaded.ImageNRGBA(image.Rect(20, 20, 44, 44))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range []image.Point{{6, 6}, {15, 15}} {
+			if got := img.NRGBAAt(p.X, p.Y); got != want {
+				t.Errorf("mode %v: pixel %v is %v, want %v", mode, p, got, want)
+			}
+		}
+		if got := img.NRGBAAt(0, 0); got != (color.NRGBA{}) {
+			t.Errorf("mode %v: pixel outside the drawn image is %v", mode, got)
+		}
+		loaded.Close()
+	}
+}
+
+func TestAlphaStraightDrawing(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.Alpha(mimage.AlphaStraight))
+	faint := color.NRGBA{200, 100, 50, 10}
+	src := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	draw.Draw(src, src.Bounds(), image.NewUniform(faint), image.Point{}, draw.Src)
+
+	// drawing over part of the chunk leaves the rest at full precision
+	op := m.Draw()
+	op.DrawImage(src, 0, 0)
+	op.SetColor(color.RGBA{0, 0, 255, 255})
+	op.DrawRectangle(0, 0, 10, 10)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := m.ImageNRGBA(m.Bounds())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.NRGBAAt(5, 5); got != (color.NRGBA{0, 0, 255, 255}) {
+		t.Errorf("drawn over pixel is %v, want blue", got)
+	}
+	if got := img.NRGBAAt(40, 40); got != faint {
+		t.Errorf("untouched pixel is %v, want %v", got, faint)
+	}
+}
@@ -0,0 +1,182 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+)
+
+// Annotation is a named vector shape and/or text label that is kept in the
+// Mimage metadata rather than drawn into the chunks. Since the pixels are never
+// touched annotations can be edited or removed freely, and are rendered only
+// when asked for (see AnnotatedImage).
+type Annotation struct {
+	// Name uniquely identifies the annotation within an Mimage.
+	Name string
+
+	// Points describes a path in world space, if any.
+	Points []Point
+
+	// Closed joins the last point back to the first.
+	Closed bool
+
+	// Fill the path rather than stroking it (implies Closed).
+	Fill bool
+
+	// Label is optional text centered on LabelAt.
+	Label   string
+	LabelAt Point
+
+	Color     color.NRGBA
+	LineWidth float64
+}
+
+// lineWidth returns the width of the line to draw, which defaults to 1.
+func (a *Annotation) lineWidth() float64 {
+	if a.LineWidth <= 0 {
+		return 1
+	}
+	return a.LineWidth
+}
+
+// bounds returns the area (in world space) that this annotation may draw on.
+func (a *Annotation) bounds() image.Rectangle {
+	r := image.Rectangle{}
+
+	if len(a.Points) > 0 {
+		minX, minY := math.Inf(1), math.Inf(1)
+		maxX, maxY := math.Inf(-1), math.Inf(-1)
+		for _, p := range a.Points {
+			minX = math.Min(minX, p.X)
+			minY = math.Min(minY, p.Y)
+			maxX = math.Max(maxX, p.X)
+			maxY = math.Max(maxY, p.Y)
+		}
+		w := a.lineWidth()
+		r = image.Rect(
+			int(math.Floor(minX-w)),
+			int(math.Floor(minY-w)),
+			int(math.Ceil(maxX+w))+1,
+			int(math.Ceil(maxY+w))+1,
+		)
+	}
+
+	if a.Label != "" {
+		face := basicfont.Face7x13
+		w := font.MeasureString(face, a.Label).Ceil()
+		h := face.Metrics().Height.Ceil()
+		x, y := int(a.LabelAt.X), int(a.LabelAt.Y)
+		r = r.Union(image.Rect(x-w/2-1, y-h/2-1, x+w/2+2, y+h/2+2))
+	}
+
+	return r
+}
+
+// draw renders the annotation onto the given context, where the context
+// origin is at (offX, offY) in world space.
+func (a *Annotation) draw(dc *gg.Context, offX, offY float64) {
+	dc.SetColor(a.Color)
+	dc.SetLineWidth(a.lineWidth())
+
+	if len(a.Points) > 0 {
+		dc.NewSubPath()
+		for _, p := range a.Points {
+			dc.LineTo(p.X-offX, p.Y-offY)
+		}
+		if a.Closed || a.Fill {
+			dc.ClosePath()
+		}
+		if a.Fill {
+			dc.Fill()
+		} else {
+			dc.Stroke()
+		}
+	}
+
+	if a.Label != "" {
+		dc.DrawStringAnchored(a.Label, a.LabelAt.X-offX, a.LabelAt.Y-offY, 0.5, 0.5)
+	}
+}
+
+// Annotate adds the given annotation, replacing any existing annotation with
+// the same name. The change is written to the metadata file immediately.
+func (m *Mimage) Annotate(a Annotation) error {
+	if a.Name == "" {
+		return fmt.Errorf("annotation requires a name")
+	}
+	a.Points = append([]Point{}, a.Points...)
+
+	m.metaLock.Lock()
+	defer m.metaLock.Unlock()
+
+	for i, existing := range m.annotations {
+		if existing.Name == a.Name {
+			m.annotations[i] = &a
+			return m.writeMetadata()
+		}
+	}
+	m.annotations = append(m.annotations, &a)
+
+	return m.writeMetadata()
+}
+
+// RemoveAnnotation deletes the named annotation, if it exists.
+func (m *Mimage) RemoveAnnotation(name string) error {
+	m.metaLock.Lock()
+	defer m.metaLock.Unlock()
+
+	for i, existing := range m.annotations {
+		if existing.Name == name {
+			m.annotations = append(m.annotations[:i], m.annotations[i+1:]...)
+			return m.writeMetadata()
+		}
+	}
+
+	return nil
+}
+
+// Annotations returns a copy of all annotations, in the order they're drawn.
+func (m *Mimage) Annotations() []Annotation {
+	m.metaLock.Lock()
+	defer m.metaLock.Unlock()
+
+	out := make([]Annotation, len(m.annotations))
+	for i, a := range m.annotations {
+		out[i] = *a
+		out[i].Points = append([]Point{}, a.Points...)
+	}
+
+	return out
+}
+
+// RenderAnnotations draws all annotations that fall within r onto dst, where
+// the origin of dst corresponds to r.Min in world space.
+func (m *Mimage) RenderAnnotations(dst *image.RGBA, r image.Rectangle) {
+	dc := gg.NewContextForRGBA(dst)
+	offX, offY := float64(r.Min.X), float64(r.Min.Y)
+
+	for _, a := range m.Annotations() {
+		if !a.bounds().Overlaps(r) {
+			continue
+		}
+		a.draw(dc, offX, offY)
+	}
+}
+
+// AnnotatedImage returns a selected piece of the massive image (see Image) with
+// any annotations drawn on top. The chunks on disk are not altered.
+func (m *Mimage) AnnotatedImage(r image.Rectangle) (image.Image, error) {
+	img, err := m.Image(r)
+	if err != nil {
+		return img, err
+	}
+
+	m.RenderAnnotations(img.(*image.RGBA), r)
+
+	return img, nil
+}
@@ -0,0 +1,69 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestAnnotations(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 100, 100), mimage.ChunkSize(32))
+	red := color.NRGBA{255, 0, 0, 255}
+	square := mimage.Annotation{
+		Name:   "square",
+		Points: []mimage.Point{{X: 10, Y: 10}, {X: 40, Y: 10}, {X: 40, Y: 40}, {X: 10, Y: 40}},
+		Fill:   true,
+		Color:  red,
+	}
+	for _, a := range []mimage.Annotation{square, {Name: "label", Label: "hi", LabelAt: mimage.Point{X: 70, Y: 70}}} {
+		err := m.Annotate(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Annotate(mimage.Annotation{}); err == nil {
+		t.Error("annotating without a name got no error")
+	}
+
+	// replaced by name, in place
+	square.Color = color.NRGBA{0, 0, 255, 255}
+	err := m.Annotate(square)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := m.Annotations()
+	if len(got) != 2 || got[0].Name != "square" || got[0].Color != square.Color {
+		t.Fatalf("got annotations %+v", got)
+	}
+
+	// drawn on request, never into the chunks
+	img, err := m.AnnotatedImage(image.Rect(0, 0, 50, 50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := color.RGBAModel.Convert(img.At(25, 25)); c != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("annotated pixel is %v, want blue", c)
+	}
+	if c := m.At(25, 25); c != (color.RGBA{}) {
+		t.Errorf("chunk pixel is %v, want it untouched", c)
+	}
+
+	// kept in the metadata
+	loaded, err := mimage.Load(m.Directory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Annotations(); len(got) != 2 || got[1].Label != "hi" {
+		t.Errorf("reloaded annotations %+v", got)
+	}
+
+	err = m.RemoveAnnotation("square")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Annotations(); len(got) != 1 || got[0].Name != "label" {
+		t.Errorf("after removing got %+v", got)
+	}
+}
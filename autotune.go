@@ -0,0 +1,194 @@
+package mimage
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// tuneProbeSize is how much is written & read back to time the disk
+	tuneProbeSize = 16 << 20
+
+	// defaultTuneMemory is assumed to be available when it can't be found
+	// out (eg. off Linux)
+	defaultTuneMemory = 2 << 30
+
+	// slowDisk is the speed (bytes per second) below which chunks are made
+	// bigger, so fewer are read & written
+	slowDisk = 100 << 20
+)
+
+// TuneHints tells AutoTune what an image will be used for.
+type TuneHints struct {
+	// Workload is the kind of drawing mostly done, WorkloadFill if not set.
+	Workload Workload
+
+	// Dir is where the image will be kept, to time reading & writing
+	// there; the temporary directory if empty.
+	Dir string
+
+	// Memory is how many bytes chunks may take up between them, half of
+	// the memory available if zero.
+	Memory uint64
+
+	// Straight is set if the image will keep straight alpha (see Alpha),
+	// which takes twice the memory.
+	Straight bool
+}
+
+// Tuning is what AutoTune found out & the settings it suggests.
+type Tuning struct {
+	// CPUs that can draw at once.
+	CPUs int
+
+	// Memory available, in bytes (a guess if it can't be found out).
+	Memory uint64
+
+	// DiskBytesPerSec is how fast a file was written & read back in the
+	// hinted directory.
+	DiskBytesPerSec float64
+
+	// ChunkSize, Routines & MaxChunks suggested, MaxChunks being the chunks
+	// that fit in the memory hinted (or half that available).
+	ChunkSize int
+	Routines  int
+	MaxChunks int
+}
+
+// AutoTune looks at the machine (CPUs, memory available & how fast the disk
+// is) & suggests a chunk size, routines & how many chunks to keep in memory
+// for an image with the given bounds, used as hinted. Apply the suggestions
+// with Options:
+//
+//	tuning, _ := mimage.AutoTune(bounds, mimage.TuneHints{Workload: mimage.WorkloadStroke, Dir: dir})
+//	im, _ := mimage.New(bounds, append(tuning.Options(), mimage.Directory(dir))...)
+//
+// Suggestions are rules of thumb, Benchmark measures what suits the machine
+// best.
+func AutoTune(bounds image.Rectangle, hints TuneHints) (*Tuning, error) {
+	t := &Tuning{CPUs: runtime.GOMAXPROCS(0), Memory: availableMemory()}
+	speed, err := diskSpeed(hints.Dir)
+	if err != nil {
+		return nil, err
+	}
+	t.DiskBytesPerSec = speed
+
+	budget := hints.Memory
+	if budget == 0 {
+		budget = t.Memory / 2
+	}
+	pixelBytes := 4
+	if hints.Straight {
+		pixelBytes = 8
+	}
+
+	// big chunks have less overhead each, small ones waste less on what
+	// sparse drawing doesn't touch
+	t.ChunkSize = 1024
+	if hints.Workload == WorkloadStamp {
+		t.ChunkSize = 512
+	}
+	if speed < slowDisk {
+		t.ChunkSize *= 2
+	}
+	t.Routines = t.CPUs
+
+	// no bigger than needed to cover the image
+	for t.ChunkSize > 256 && t.ChunkSize/2 >= maxInt(bounds.Dx(), bounds.Dy()) {
+		t.ChunkSize /= 2
+	}
+	// each routine wants a few chunks loaded at once, the one it draws on &
+	// neighbours (eg. filters padding it)
+	for t.ChunkSize > 256 && uint64(t.ChunkSize*t.ChunkSize*pixelBytes*t.Routines*4) > budget {
+		t.ChunkSize /= 2
+	}
+
+	t.MaxChunks = int(budget / uint64(t.ChunkSize*t.ChunkSize*pixelBytes))
+	if t.MaxChunks < t.Routines*2 {
+		t.MaxChunks = t.Routines * 2
+	}
+	return t, nil
+}
+
+// Options returns the options applying the suggestions, keeping at most
+// MaxChunks chunks in memory with EvictLRU.
+func (t *Tuning) Options() []Option {
+	return []Option{
+		ChunkSize(t.ChunkSize),
+		OperationRoutines(t.Routines),
+		MaxChunks(t.MaxChunks),
+		Eviction(EvictLRU),
+	}
+}
+
+// String returns the suggestions & what they're based on.
+func (t *Tuning) String() string {
+	return fmt.Sprintf("chunk size %d, routines %d, max chunks %d (%d cpus, %d MiB available, disk %.0f MiB/s)",
+		t.ChunkSize, t.Routines, t.MaxChunks, t.CPUs, t.Memory>>20, t.DiskBytesPerSec/(1<<20))
+}
+
+// availableMemory returns the bytes of memory available, from /proc/meminfo
+// where there is one & a guess otherwise.
+func availableMemory() uint64 {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return defaultTuneMemory
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text()) // eg. MemAvailable: 1234 kB
+		if len(fields) < 2 || fields[0] != "MemAvailable:" {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			break
+		}
+		return kb << 10
+	}
+	return defaultTuneMemory
+}
+
+// diskSpeed writes a file to dir (the temporary directory if empty), syncs
+// it & reads it back, returning the bytes per second. Reading likely comes
+// from the page cache, so it's mostly the write that's timed, as with
+// chunks.
+func diskSpeed(dir string) (float64, error) {
+	f, err := ioutil.TempFile(dir, "mimage-tune")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+
+	data := make([]byte, tuneProbeSize)
+	for i := range data {
+		data[i] = byte(i * 7919 >> 3) // not all zeros, for file systems that compress
+	}
+	start := time.Now()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	err = f.Close()
+	if err != nil {
+		return 0, err
+	}
+	_, err = ioutil.ReadFile(f.Name())
+	if err != nil {
+		return 0, err
+	}
+	return float64(2*tuneProbeSize) / time.Since(start).Seconds(), nil
+}
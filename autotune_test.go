@@ -0,0 +1,45 @@
+package mimage_test
+
+import (
+	"image"
+	"path/filepath"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestAutoTune(t *testing.T) {
+	dir := t.TempDir()
+	tuning, err := mimage.AutoTune(image.Rect(0, 0, 100000, 100000), mimage.TuneHints{Dir: dir, Memory: 256 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tuning.CPUs < 1 || tuning.Memory == 0 || tuning.DiskBytesPerSec <= 0 {
+		t.Errorf("found %d cpus, %d bytes of memory & %v bytes a second of disk", tuning.CPUs, tuning.Memory, tuning.DiskBytesPerSec)
+	}
+	if tuning.Routines != tuning.CPUs || tuning.ChunkSize < 256 {
+		t.Errorf("suggested %s", tuning)
+	}
+	// the chunks kept fit in the memory hinted
+	if used := tuning.MaxChunks * tuning.ChunkSize * tuning.ChunkSize * 4; tuning.MaxChunks > tuning.Routines*2 && used > 256<<20 {
+		t.Errorf("%d chunks of %d take %d bytes, hinted %d", tuning.MaxChunks, tuning.ChunkSize, used, 256<<20)
+	}
+
+	// chunks are no bigger than the image needs, within reason
+	small, err := mimage.AutoTune(image.Rect(0, 0, 200, 200), mimage.TuneHints{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if small.ChunkSize != 256 {
+		t.Errorf("suggested chunk size %d for a small image, want 256", small.ChunkSize)
+	}
+	m, err := mimage.New(image.Rect(0, 0, 200, 200), append(small.Options(), mimage.Directory(dir))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	if _, err := mimage.AutoTune(image.Rect(0, 0, 10, 10), mimage.TuneHints{Dir: filepath.Join(dir, "missing")}); err == nil {
+		t.Error("tuning for a missing directory got no error")
+	}
+}
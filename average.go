@@ -0,0 +1,23 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// AverageColor returns the average color (premultiplied, so transparent
+// pixels count towards it) over the region r (in world space), eg. to pick a
+// fill that matches its surroundings. Chunks are summed in parallel.
+func (m *Mimage) AverageColor(r image.Rectangle) (color.RGBA, error) {
+	r = r.Intersect(m.bounds)
+	if r.Empty() {
+		return color.RGBA{}, fmt.Errorf("region %v is outside of the image", r)
+	}
+
+	img, err := m.downsample(r, maxInt(r.Dx(), r.Dy()))
+	if err != nil {
+		return color.RGBA{}, err
+	}
+	return img.RGBAAt(0, 0), nil
+}
@@ -0,0 +1,40 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestAverageColor(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(color.RGBA{255, 0, 0, 255})
+	op.DrawRectangle(0, 0, 32, 64)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.AverageColor(image.Rect(100, 100, 200, 200)); err == nil {
+		t.Error("region outside of the image got no error")
+	}
+
+	for r, want := range map[image.Rectangle]color.RGBA{
+		image.Rect(0, 0, 32, 64):   {255, 0, 0, 255},
+		image.Rect(0, 0, 64, 64):   {128, 0, 0, 128},
+		image.Rect(0, 0, 40, 20):   {204, 0, 0, 204}, // not square
+		image.Rect(-8, 0, 40, 100): {204, 0, 0, 204}, // cropped to the image
+	} {
+		got, err := m.AverageColor(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("average over %v is %v, want %v", r, got, want)
+		}
+	}
+}
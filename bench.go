@@ -0,0 +1,257 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"runtime"
+	"time"
+)
+
+// Workload is a standard set of drawing run by Benchmark, each standing in
+// for a kind of work an image might be put to.
+type Workload int
+
+const (
+	// WorkloadFill fills the image then thousands of rectangles & ellipses
+	WorkloadFill Workload = iota
+
+	// WorkloadStroke strokes thousands of long polylines, eg. roads
+	WorkloadStroke
+
+	// WorkloadStamp scatters a small image all over, eg. trees
+	WorkloadStamp
+
+	// WorkloadFilter blurs the whole image (once filled with noise)
+	WorkloadFilter
+)
+
+// String returns the name of the workload.
+func (w Workload) String() string {
+	switch w {
+	case WorkloadFill:
+		return "fill"
+	case WorkloadStroke:
+		return "stroke"
+	case WorkloadStamp:
+		return "stamp"
+	case WorkloadFilter:
+		return "filter"
+	}
+	return fmt.Sprintf("workload(%d)", int(w))
+}
+
+// BenchmarkBackend is somewhere images are kept for Benchmark, eg. a
+// ChunkStore over network storage.
+type BenchmarkBackend struct {
+	Name string
+
+	// Options returns the options for a new image on the backend, called
+	// for each run so each starts afresh. Without a Directory or Storage
+	// option the image is kept in a new temporary directory, which is
+	// removed afterwards.
+	Options func() ([]Option, error)
+}
+
+// BenchmarkOptions says what Benchmark runs, each workload is run once with
+// each combination of chunk size, routines & backend.
+type BenchmarkOptions struct {
+	// Bounds of the images drawn on, 4096x4096 if empty.
+	Bounds image.Rectangle
+
+	// Workloads to run, all of them if empty.
+	Workloads []Workload
+
+	// ChunkSizes to try, 256, 512 & 1024 if empty.
+	ChunkSizes []int
+
+	// Routines to try, one per CPU if empty.
+	Routines []int
+
+	// Backends to try, a temporary directory if empty.
+	Backends []BenchmarkBackend
+}
+
+// BenchmarkResult is how one run of a workload went.
+type BenchmarkResult struct {
+	Workload  Workload
+	Backend   string
+	ChunkSize int
+	Routines  int
+
+	// Duration of the drawing & flushing the image to its backend.
+	Duration time.Duration
+
+	// Allocs & AllocBytes made by the whole process meanwhile.
+	Allocs     uint64
+	AllocBytes uint64
+
+	// Err the run failed with, if any.
+	Err error
+}
+
+// String returns the result as one line.
+func (r BenchmarkResult) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("%s %s chunk %d routines %d: %v", r.Workload, r.Backend, r.ChunkSize, r.Routines, r.Err)
+	}
+	return fmt.Sprintf("%s %s chunk %d routines %d: %v, %d allocs (%d MiB)",
+		r.Workload, r.Backend, r.ChunkSize, r.Routines, r.Duration.Round(time.Millisecond), r.Allocs, r.AllocBytes>>20)
+}
+
+// Benchmark runs standard workloads on images with different chunk sizes,
+// routines & backends, returning how long each took & what was allocated, to
+// help choose settings for some hardware. Runs are one after another, so
+// each has the machine to itself (give or take), but they take a while.
+//
+//	results, _ := mimage.Benchmark(mimage.BenchmarkOptions{Workloads: []mimage.Workload{mimage.WorkloadStroke}})
+//	for _, r := range results {
+//		fmt.Println(r)
+//	}
+//
+// Runs that fail are returned with their error, the error returned is for
+// the options themselves.
+func Benchmark(opts BenchmarkOptions) ([]BenchmarkResult, error) {
+	if opts.Bounds.Empty() {
+		opts.Bounds = image.Rect(0, 0, 4096, 4096)
+	}
+	if len(opts.Workloads) == 0 {
+		opts.Workloads = []Workload{WorkloadFill, WorkloadStroke, WorkloadStamp, WorkloadFilter}
+	}
+	if len(opts.ChunkSizes) == 0 {
+		opts.ChunkSizes = []int{256, 512, 1024}
+	}
+	if len(opts.Routines) == 0 {
+		opts.Routines = []int{runtime.NumCPU()}
+	}
+	if len(opts.Backends) == 0 {
+		opts.Backends = []BenchmarkBackend{{Name: "tmpdir"}}
+	}
+	for _, w := range opts.Workloads {
+		if w < WorkloadFill || w > WorkloadFilter {
+			return nil, fmt.Errorf("unknown workload %v", w)
+		}
+	}
+
+	results := []BenchmarkResult{}
+	for _, w := range opts.Workloads {
+		for _, b := range opts.Backends {
+			for _, size := range opts.ChunkSizes {
+				for _, routines := range opts.Routines {
+					res := BenchmarkResult{Workload: w, Backend: b.Name, ChunkSize: size, Routines: routines}
+					res.Duration, res.Allocs, res.AllocBytes, res.Err = benchmarkRun(w, b, opts.Bounds, size, routines)
+					results = append(results, res)
+				}
+			}
+		}
+	}
+	return results, nil
+}
+
+// benchmarkRun runs the workload on a new image, returning how long it took &
+// what was allocated.
+func benchmarkRun(w Workload, b BenchmarkBackend, bounds image.Rectangle, chunkSize, routines int) (time.Duration, uint64, uint64, error) {
+	opts := []Option{}
+	if b.Options != nil {
+		more, err := b.Options()
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		opts = append(opts, more...)
+	}
+	opts = append(opts, ChunkSize(chunkSize), OperationRoutines(routines))
+	m, err := New(bounds, opts...)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer m.Close()
+
+	// the filter has something to blur, which isn't timed
+	if w == WorkloadFilter {
+		op := m.Draw()
+		op.SetColor(color.NRGBA{128, 128, 128, 255})
+		op.Clear()
+		op.AddNoise(bounds, 0.5)
+		err = op.Do()
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		err = m.Flush()
+		if err != nil {
+			return 0, 0, 0, err
+		}
+	}
+
+	runtime.GC()
+	before := &runtime.MemStats{}
+	runtime.ReadMemStats(before)
+	start := time.Now()
+
+	err = benchmarkWorkload(m, w, bounds)
+	if err == nil {
+		err = m.Flush()
+	}
+
+	took := time.Since(start)
+	after := &runtime.MemStats{}
+	runtime.ReadMemStats(after)
+	return took, after.Mallocs - before.Mallocs, after.TotalAlloc - before.TotalAlloc, err
+}
+
+// benchmarkWorkload draws the workload on m, the same each time.
+func benchmarkWorkload(m *Mimage, w Workload, bounds image.Rectangle) error {
+	rng := rand.New(rand.NewSource(1))
+	at := func() (float64, float64) {
+		return float64(bounds.Min.X) + rng.Float64()*float64(bounds.Dx()), float64(bounds.Min.Y) + rng.Float64()*float64(bounds.Dy())
+	}
+	randomColor := func() color.Color {
+		return color.NRGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(128 + rng.Intn(128))}
+	}
+	size := float64(bounds.Dx()+bounds.Dy()) / 2
+
+	op := m.Draw()
+	switch w {
+	case WorkloadFill:
+		op.SetColor(color.NRGBA{255, 255, 255, 255})
+		op.Clear()
+		for i := 0; i < 2000; i++ {
+			x, y := at()
+			op.SetColor(randomColor())
+			if i%2 == 0 {
+				op.DrawRectangle(x, y, rng.Float64()*size/8, rng.Float64()*size/8)
+			} else {
+				op.DrawEllipse(x, y, rng.Float64()*size/16, rng.Float64()*size/16)
+			}
+			op.Fill()
+		}
+	case WorkloadStroke:
+		for i := 0; i < 500; i++ {
+			points := make([]Point, 50)
+			x, y := at()
+			for j := range points {
+				x += (rng.Float64() - 0.5) * size / 20
+				y += (rng.Float64() - 0.5) * size / 20
+				points[j] = Point{X: x, Y: y}
+			}
+			op.SetColor(randomColor())
+			op.DrawPolyline(points)
+			op.Stroke()
+		}
+	case WorkloadStamp:
+		stamp := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+		for i := range stamp.Pix {
+			stamp.Pix[i] = uint8(rng.Intn(256))
+		}
+		region := Path{
+			{X: float64(bounds.Min.X), Y: float64(bounds.Min.Y)},
+			{X: float64(bounds.Max.X), Y: float64(bounds.Min.Y)},
+			{X: float64(bounds.Max.X), Y: float64(bounds.Max.Y)},
+			{X: float64(bounds.Min.X), Y: float64(bounds.Max.Y)},
+		}
+		op.Scatter(stamp, region, 0.0005, 1)
+	case WorkloadFilter:
+		return m.Blur(4)
+	}
+	return op.Do()
+}
@@ -0,0 +1,57 @@
+package mimage_test
+
+import (
+	"errors"
+	"image"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/voidshard/mimage"
+	"github.com/voidshard/mimage/mimagetest"
+)
+
+func TestBenchmark(t *testing.T) {
+	before := runtime.NumGoroutine()
+	broken := errors.New("no such bucket")
+	results, err := mimage.Benchmark(mimage.BenchmarkOptions{
+		Bounds:     image.Rect(0, 0, 128, 128),
+		ChunkSizes: []int{32, 64},
+		Routines:   []int{2},
+		Backends: []mimage.BenchmarkBackend{
+			{Name: "tmpdir"},
+			{Name: "mem", Options: func() ([]mimage.Option, error) {
+				return []mimage.Option{mimage.Storage(mimagetest.NewMemStore())}, nil
+			}},
+			{Name: "broken", Options: func() ([]mimage.Option, error) { return nil, broken }},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4*3*2 {
+		t.Fatalf("got %d results, want one per workload, backend & chunk size", len(results))
+	}
+	for _, r := range results {
+		if r.Backend == "broken" {
+			if !errors.Is(r.Err, broken) {
+				t.Errorf("%v, want %v", r, broken)
+			}
+			continue
+		}
+		if r.Err != nil || r.Duration <= 0 {
+			t.Errorf("%v", r)
+		}
+		if s := r.String(); !strings.HasPrefix(s, r.Workload.String()+" "+r.Backend) {
+			t.Errorf("result is written as %q", s)
+		}
+	}
+	// the images run on are closed
+	if n := settledGoroutines(before); n > before {
+		t.Errorf("%d goroutines running after benchmarking, %d before", n, before)
+	}
+
+	if _, err := mimage.Benchmark(mimage.BenchmarkOptions{Workloads: []mimage.Workload{42}}); err == nil {
+		t.Error("unknown workload got no error")
+	}
+}
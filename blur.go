@@ -0,0 +1,136 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// blurPasses is the number of box blurs used to approximate a gaussian blur.
+const blurPasses = 3
+
+// boxSizes returns the widths of the box blurs that (applied one after the
+// other) approximate a gaussian blur with the given sigma.
+//
+// See http://blog.ivank.net/fastest-gaussian-blur.html
+func boxSizes(sigma float64, n int) []int {
+	wIdeal := math.Sqrt(12*sigma*sigma/float64(n) + 1)
+	wl := int(math.Floor(wIdeal))
+	if wl%2 == 0 {
+		wl--
+	}
+	wu := wl + 2
+
+	mIdeal := (12*sigma*sigma - float64(n*wl*wl) - 4*float64(n*wl) - 3*float64(n)) / (-4*float64(wl) - 4)
+	m := int(math.Round(mIdeal))
+
+	sizes := make([]int, n)
+	for i := range sizes {
+		if i < m {
+			sizes[i] = wl
+		} else {
+			sizes[i] = wu
+		}
+	}
+	return sizes
+}
+
+// blurRadius returns roughly how far (in pixels) a gaussian blur with the
+// given sigma reaches. Reading this far beyond an area is enough for blurring
+// it to give the same result as blurring the whole image.
+func blurRadius(sigma float64) int {
+	r := 0
+	for _, s := range boxSizes(sigma, blurPasses) {
+		r += s / 2
+	}
+	return r
+}
+
+// clampInt returns i clamped to [min, max].
+func clampInt(i, min, max int) int {
+	if i < min {
+		return min
+	} else if i > max {
+		return max
+	}
+	return i
+}
+
+// boxBlur runs a single box blur of radius r over a w x h plane, reading from
+// src and writing to dst. The stride is the distance between neighbouring
+// values and the step the distance between lines, so this is used for both
+// horizontal & vertical passes. Edge values are repeated beyond the plane.
+func boxBlur(src, dst []float32, lines, length, stride, step, r int) {
+	scale := 1 / float64(2*r+1)
+	for l := 0; l < lines; l++ {
+		base := l * step
+		at := func(i int) float64 { return float64(src[base+clampInt(i, 0, length-1)*stride]) }
+
+		sum := 0.0
+		for i := -r; i <= r; i++ {
+			sum += at(i)
+		}
+		for i := 0; i < length; i++ {
+			dst[base+i*stride] = float32(sum * scale)
+			sum += at(i+r+1) - at(i-r)
+		}
+	}
+}
+
+// gaussianBlur approximates a gaussian blur over the w x h plane in place.
+func gaussianBlur(pix []float32, w, h int, sigma float64) {
+	if sigma <= 0 || w == 0 || h == 0 {
+		return
+	}
+
+	tmp := make([]float32, len(pix))
+	for _, size := range boxSizes(sigma, blurPasses) {
+		r := size / 2
+		boxBlur(pix, tmp, h, w, 1, w, r) // horizontal
+		boxBlur(tmp, pix, w, h, w, 1, r) // vertical
+	}
+}
+
+// blurred returns a blurred copy of the given plane.
+func blurred(pix []float32, w, h int, sigma float64) []float32 {
+	out := append([]float32{}, pix...)
+	gaussianBlur(out, w, h, sigma)
+	return out
+}
+
+// Blur applies a gaussian blur (of sigma pixels) to the image in place, eg.
+// with SetFilterMask to blur only the background. Chunks are blurred in
+// parallel, each reading a halo wide enough for the blur so there are no
+// seams.
+func (m *Mimage) Blur(sigma float64) error {
+	if sigma <= 0 {
+		return fmt.Errorf("sigma must be greater than zero, given %v", sigma)
+	}
+
+	return m.filterRGBA(m.bounds, blurRadius(sigma)+1, func(src, dst *image.RGBA) {
+		w, h := src.Rect.Dx(), src.Rect.Dy()
+		planes := [4][]float32{}
+		for c := range planes {
+			planes[c] = make([]float32, w*h)
+			for i := range planes[c] {
+				planes[c][i] = float32(src.Pix[i*4+c])
+			}
+			gaussianBlur(planes[c], w, h, sigma)
+		}
+
+		for y := dst.Rect.Min.Y; y < dst.Rect.Max.Y; y++ {
+			for x := dst.Rect.Min.X; x < dst.Rect.Max.X; x++ {
+				d := dst.Pix[dst.PixOffset(x, y):]
+				i := (y-src.Rect.Min.Y)*w + x - src.Rect.Min.X
+				for c := 0; c < 4; c++ {
+					d[c] = uint8(math.Max(0, math.Min(255, math.Round(float64(planes[c][i])))))
+				}
+				for c := 0; c < 3; c++ {
+					if d[c] > d[3] {
+						d[c] = d[3] // stay premultiplied
+					}
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,273 @@
+package mimage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// branchDir holds the chunks of every branch by checksum, so branches
+	// share the chunks they have in common, & what each branch is made of
+	branchDir = ".mimage_branches"
+
+	// branchChunks is the directory within branchDir holding the chunks
+	branchChunks = "chunks"
+
+	// branchfile names the branch currently checked out, within branchDir
+	branchfile = "CURRENT"
+
+	// defaultBranch is the branch an image starts on
+	defaultBranch = "main"
+)
+
+// branch is what a branch is made of, the checksums of its files (by name)
+// as kept in branchDir.
+type branch struct {
+	Metadata string
+	Chunks   map[string]string
+}
+
+// Branch saves the image as it is as the current branch (see CurrentBranch),
+// & as a new branch with the given name, which is checked out. So drawing
+// from now on changes the new branch, & the old is left as it was.
+//
+// Branches keep one copy of each distinct chunk between them (& the image
+// itself), so many revisions of a large image that differ in a few places
+// take little more room than one.
+//
+// Like Checkout, nothing else should be reading or drawing on the image while
+// this is called.
+func (m *Mimage) Branch(name string) error {
+	path, err := m.branchPath(name)
+	if err != nil {
+		return err
+	}
+	if _, err = os.Stat(path); err == nil {
+		return fmt.Errorf("branch %s already exists", name)
+	}
+	current, err := m.CurrentBranch()
+	if err != nil {
+		return err
+	}
+
+	b, err := m.saveBranch(current)
+	if err != nil {
+		return err
+	}
+	err = m.writeBranch(name, b)
+	if err != nil {
+		return err
+	}
+	return m.setCurrentBranch(name)
+}
+
+// Checkout saves the image as it is as the current branch, then replaces the
+// chunks, bounds & annotations of the image with those of the named branch
+// (see Branch), which is checked out.
+//
+// Nothing else should be reading or drawing on the image while this is
+// called.
+func (m *Mimage) Checkout(name string) error {
+	to, err := m.readBranch(name)
+	if err != nil {
+		return err
+	}
+	current, err := m.CurrentBranch()
+	if err != nil {
+		return err
+	}
+	now, err := m.saveBranch(current) // flushes everything to disk
+	if err != nil {
+		return err
+	}
+	if name == current {
+		return nil
+	}
+	// chunks still in memory are the current branch's, not the one checked out
+	m.cache.drop()
+
+	for chunk := range now.Chunks {
+		if _, ok := to.Chunks[chunk]; ok {
+			continue
+		}
+		err = os.Remove(filepath.Join(m.root, chunk))
+		if err != nil {
+			return err
+		}
+	}
+	for chunk, sum := range to.Chunks {
+		if now.Chunks[chunk] == sum {
+			continue
+		}
+		data, err := ioutil.ReadFile(m.branchChunkPath(sum))
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(filepath.Join(m.root, chunk), data, 0640)
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := ioutil.ReadFile(m.branchChunkPath(to.Metadata))
+	if err != nil {
+		return err
+	}
+	err = m.applyMetadata(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	return m.setCurrentBranch(name)
+}
+
+// CurrentBranch returns the name of the branch checked out, "main" unless
+// another has been.
+func (m *Mimage) CurrentBranch() (string, error) {
+	err := m.needsDirectory("a branch")
+	if err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(m.root, branchDir, branchfile))
+	if os.IsNotExist(err) {
+		return defaultBranch, nil
+	}
+	return strings.TrimSpace(string(data)), err
+}
+
+// Branches returns the names of the branches saved, sorted.
+func (m *Mimage) Branches() ([]string, error) {
+	err := m.needsDirectory("a branch")
+	if err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(filepath.Join(m.root, branchDir))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".json") {
+			names = append(names, strings.TrimSuffix(info.Name(), ".json"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// saveBranch flushes the image & saves it as the named branch, keeping a copy
+// of any chunks not already kept.
+func (m *Mimage) saveBranch(name string) (*branch, error) {
+	err := m.Flush()
+	if err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(m.root)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(filepath.Join(m.root, branchDir, branchChunks), 0750)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &branch{Chunks: map[string]string{}}
+	for _, info := range infos {
+		_, _, ok := parseChunkName(info.Name())
+		if info.IsDir() || (!ok && info.Name() != metafile) {
+			continue
+		}
+		sum, err := m.keepBranchChunk(filepath.Join(m.root, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			b.Chunks[info.Name()] = sum
+		} else {
+			b.Metadata = sum
+		}
+	}
+	return b, m.writeBranch(name, b)
+}
+
+// keepBranchChunk copies the given file into branchDir, if there isn't a copy
+// already, returning its checksum.
+func (m *Mimage) keepBranchChunk(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := checksum(data)
+	kept := m.branchChunkPath(sum)
+	if _, err = os.Stat(kept); err == nil {
+		return sum, nil
+	}
+
+	// write it whole before it's found by checksum
+	err = ioutil.WriteFile(kept+".tmp", data, 0640)
+	if err != nil {
+		return "", err
+	}
+	return sum, os.Rename(kept+".tmp", kept)
+}
+
+// readBranch returns what the named branch is made of.
+func (m *Mimage) readBranch(name string) (*branch, error) {
+	path, err := m.branchPath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("branch %s not found", name)
+	} else if err != nil {
+		return nil, err
+	}
+	b := &branch{}
+	return b, json.Unmarshal(data, b)
+}
+
+// writeBranch saves what the named branch is made of.
+func (m *Mimage) writeBranch(name string, b *branch) error {
+	path, err := m.branchPath(name)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0640)
+}
+
+// setCurrentBranch records the named branch as checked out.
+func (m *Mimage) setCurrentBranch(name string) error {
+	return ioutil.WriteFile(filepath.Join(m.root, branchDir, branchfile), []byte(name), 0640)
+}
+
+// branchPath returns where what the named branch is made of is kept.
+func (m *Mimage) branchPath(name string) (string, error) {
+	err := m.needsDirectory("a branch")
+	if err != nil {
+		return "", err
+	}
+	err = checkName("branch", name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(m.root, branchDir, name+".json"), nil
+}
+
+// branchChunkPath returns where the file with the given checksum is kept in
+// branchDir.
+func (m *Mimage) branchChunkPath(sum string) string {
+	return filepath.Join(m.root, branchDir, branchChunks, sum)
+}
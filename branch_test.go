@@ -0,0 +1,72 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestBranch(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	fill := func(c color.Color, x, y, w, h float64) {
+		t.Helper()
+		op := m.Draw()
+		op.SetColor(c)
+		op.DrawRectangle(x, y, w, h)
+		op.Fill()
+		err := op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(branch string, want color.Color) {
+		t.Helper()
+		if got, _ := m.CurrentBranch(); got != branch {
+			t.Errorf("current branch is %q, want %q", got, branch)
+		}
+		if got := m.At(10, 10); got != want {
+			t.Errorf("pixel on %s is %v, want %v", branch, got, want)
+		}
+	}
+	fill(red, 0, 0, 64, 64)
+	check("main", red)
+
+	err := m.Branch("rivers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fill(blue, 0, 0, 20, 20) // loaded in memory when checking out
+	check("rivers", blue)
+
+	err = m.Checkout("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("main", red)
+	err = m.Checkout("rivers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("rivers", blue)
+
+	names, err := m.Branches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"main", "rivers"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("branches are %v, want %v", names, want)
+	}
+	if err := m.Branch("rivers"); err == nil {
+		t.Error("branching to an existing name got no error")
+	}
+	if err := m.Checkout("lakes"); err == nil {
+		t.Error("checking out a missing branch got no error")
+	}
+	if err := m.Branch("../x"); err == nil {
+		t.Error("invalid branch name got no error")
+	}
+}
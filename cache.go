@@ -2,8 +2,16 @@ package mimage
 
 import (
 	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
 	"path/filepath"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // cache is a simple struct to help enforce we only have one
@@ -13,15 +21,46 @@ type cache struct {
 	chunkLock *sync.Mutex
 	chunks    map[string]*context
 	chunkSize int
+	alpha     AlphaMode
+	fetch     Fetcher
+	renderer  Renderer
+	codec     Codec
+	clock     Clock
+
+	// store is where chunks are read from & written to
+	store ChunkStore
+
+	// policy is when idle chunks are unloaded (see EvictPolicy); with
+	// EvictIdle after going unused for evictAfter, with EvictLRU when there
+	// are more than maxChunks, the least recently used (by the tick they
+	// were last loaded at) first
+	policy     EvictPolicy
+	evictAfter time.Duration
+	maxChunks  int
+	tick       uint64
+
+	// writes are chunks evicted to be written out behind (see WriteBehind)
+	writes chan *context
+
+	// stop is closed to stop the routines unloading & writing chunks behind
+	// (see Close)
+	stop   chan struct{}
+	closed bool
 }
 
 // newCache prepares a new mimage chunk cache
-func newCache(root string, chunkSize int) *cache {
+func newCache(root string, chunkSize int, alpha AlphaMode) *cache {
 	c := &cache{
-		root:      root,
-		chunkLock: &sync.Mutex{},
-		chunks:    map[string]*context{},
-		chunkSize: chunkSize,
+		root:       root,
+		chunkLock:  &sync.Mutex{},
+		chunks:     map[string]*context{},
+		chunkSize:  chunkSize,
+		alpha:      alpha,
+		store:      DirStore(root),
+		clock:      realClock{},
+		policy:     EvictIdle,
+		evictAfter: defaultEvictAfter,
+		stop:       make(chan struct{}),
 	}
 	return c
 }
@@ -50,27 +89,285 @@ func (c *cache) Flush() error {
 	return nil
 }
 
+// close stops the routines unloading & writing chunks behind.
+func (c *cache) close() {
+	c.chunkLock.Lock()
+	defer c.chunkLock.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.stop)
+	}
+}
+
+// drop forgets all chunks without writing them out, waiting for any being
+// written (eg. behind) to finish.
+func (c *cache) drop() {
+	c.chunkLock.Lock()
+	defer c.chunkLock.Unlock()
+
+	for _, ctx := range c.chunks {
+		ctx.unloadLock.Lock()
+		ctx.loadLock.Lock()
+		ctx.Img, ctx.straight = nil, nil
+		ctx.edited = false
+		ctx.loadLock.Unlock()
+		ctx.unloadLock.Unlock()
+	}
+	c.chunks = map[string]*context{}
+}
+
 // Load a chunk by its x-y coords.
 //
 // Any chunks returned this way should have Done() called on them
 // when the user no longer needs them in memory.
 func (c *cache) Load(x, y int) (*context, error) {
-	// TODO: we probably can work with other image types
-	key := filepath.Join(c.root, fmt.Sprintf("%d.%d.png", x, y))
+	key := c.chunkPath(x, y)
 
 	c.chunkLock.Lock()
 
 	ctx, ok := c.chunks[key]
 	if ok {
+		c.use(ctx)
 		c.chunkLock.Unlock()
-		return ctx, ctx.with()
+		err := ctx.with()
+		if err != nil {
+			return ctx, err
+		}
+		return ctx, c.evict()
 	}
 
-	ctx = newContext(key, x, y, c.chunkSize)
-	c.chunks[key] = ctx
+	ctx = c.newContext(key, x, y)
 	err := ctx.with()
 	c.chunkLock.Unlock()
+	if err != nil {
+		return ctx, err
+	}
+	return ctx, c.evict()
+}
+
+// Replace sets the whole of a chunk to img (chunk sized, & kept rather than
+// copied), without reading what was there before. If straight is nil &
+// we're keeping straight alpha it's worked out from img.
+//
+// As with Load, Done() should be called on the returned chunk.
+func (c *cache) Replace(x, y int, img *image.RGBA, straight *image.NRGBA) *context {
+	key := c.chunkPath(x, y)
+
+	c.chunkLock.Lock()
+
+	ctx, ok := c.chunks[key]
+	if ok {
+		c.use(ctx)
+		c.chunkLock.Unlock()
+		ctx.withImage(img, straight)
+	} else {
+		ctx = c.newContext(key, x, y)
+		ctx.withImage(img, straight)
+		c.chunkLock.Unlock()
+	}
+
+	err := c.evict()
+	if err != nil {
+		log.Printf("failed to unload image: %v\n", err)
+	}
+	return ctx
+}
+
+// newContext adds a context for the chunk at (x,y) to the cache, in use by
+// the caller. The caller is expected to hold chunkLock.
+func (c *cache) newContext(key string, x, y int) *context {
+	ctx := newContext(key, x, y, c.chunkSize, c.alpha == AlphaStraight)
+	ctx.stop = c.stop
+	ctx.fetch = c.fetch
+	ctx.renderer = c.renderer
+	ctx.codec = chunkCodec(c.codec)
+	ctx.store = c.store
+	ctx.clock = c.clock
+	c.chunks[key] = ctx
+	c.use(ctx)
+
+	if c.policy == EvictIdle {
+		go ctx.unload(c.evictAfter)
+	}
+	return ctx
+}
+
+// use notes that a chunk is about to be used. The caller is expected to hold
+// chunkLock, so that evict never picks a chunk someone is about to use.
+func (c *cache) use(ctx *context) {
+	c.tick++
+	ctx.used = c.tick
+	atomic.AddInt32(&ctx.users, 1)
+}
+
+// evict writes out & unloads the least recently used idle chunks while there
+// are more than maxChunks loaded, with EvictLRU.
+func (c *cache) evict() error {
+	if c.policy != EvictLRU {
+		return nil
+	}
+	c.chunkLock.Lock()
+
+	loaded := 0
+	idle := []*context{}
+	for _, ctx := range c.chunks {
+		if atomic.LoadInt32(&ctx.users) > 0 {
+			loaded++
+			continue
+		}
+		if atomic.LoadInt32(&ctx.queued) > 0 {
+			continue // to be written behind
+		}
+		ctx.loadLock.Lock()
+		if ctx.Img != nil {
+			loaded++
+			idle = append(idle, ctx)
+		}
+		ctx.loadLock.Unlock()
+	}
+	if loaded <= c.maxChunks || len(idle) == 0 {
+		c.chunkLock.Unlock()
+		return nil
+	}
+
+	sort.Slice(idle, func(i, j int) bool { return idle[i].used < idle[j].used })
+	victims := idle[:minInt(len(idle), loaded-c.maxChunks)]
+	if c.writes != nil {
+		c.queueWrites(victims)
+		return nil
+	}
+	for _, ctx := range victims {
+		ctx.unloadLock.Lock() // no one is using it, so this doesn't wait
+		defer ctx.unloadLock.Unlock()
+	}
+	c.chunkLock.Unlock() // anyone wanting a victim waits until it's written
+
+	for _, ctx := range victims {
+		err := ctx.unloadImage()
+		if err != nil {
+			return fmt.Errorf("unloading %s: %v", ctx.key, err)
+		}
+	}
+	return nil
+}
+
+// chunkPath returns the file a chunk is stored in.
+func (c *cache) chunkPath(x, y int) string {
+	// TODO: we probably can work with other image types
+	return filepath.Join(c.root, chunkName(x, y))
+}
+
+// chunkName returns the name of the file a chunk is stored in, within the
+// Mimage directory.
+func chunkName(x, y int) string {
+	return fmt.Sprintf("%d.%d.png", x, y)
+}
+
+// loaded returns if the given chunk is currently held in memory.
+func (c *cache) loaded(x, y int) bool {
+	c.chunkLock.Lock()
+	ctx, ok := c.chunks[c.chunkPath(x, y)]
+	c.chunkLock.Unlock()
+	if !ok {
+		return false
+	}
+
+	ctx.loadLock.Lock()
+	defer ctx.loadLock.Unlock()
+	return ctx.Img != nil
+}
+
+// exists returns if the given chunk has been drawn on or saved, chunks that
+// don't exist are entirely transparent. Chunks that have only been read are
+// held in memory but don't exist. With a remote source (see Remote) any
+// chunk may have something in it.
+func (c *cache) exists(x, y int) bool {
+	path := c.chunkPath(x, y)
+
+	c.chunkLock.Lock()
+	ctx, ok := c.chunks[path]
+	fetch := c.fetch
+	c.chunkLock.Unlock()
+	if fetch != nil {
+		return true
+	}
+	if ok {
+		// wait for anyone drawing on the chunk to finish
+		ctx.unloadLock.Lock()
+		edited := ctx.edited
+		ctx.unloadLock.Unlock()
+		if edited {
+			return true
+		}
+	}
+
+	f, err := c.store.Open(chunkName(x, y))
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
+
+// size returns the size of a chunk as kept in the store, or 0 if it isn't
+// there.
+func (c *cache) size(x, y int) int64 {
+	f, err := c.store.Open(chunkName(x, y))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	if s, ok := f.(interface{ Stat() (os.FileInfo, error) }); ok {
+		info, err := s.Stat()
+		if err == nil {
+			return info.Size()
+		}
+	}
+	n, _ := io.Copy(ioutil.Discard, f) // no way to ask, so count it
+	return n
+}
+
+// setFetcher sets where chunks not yet on disk are fetched from, for chunks
+// loaded from now on.
+func (c *cache) setFetcher(f Fetcher) {
+	c.chunkLock.Lock()
+	defer c.chunkLock.Unlock()
+	c.fetch = f
+}
+
+// setStore sets where chunks are kept, if not the cache's directory, & how
+// many idle chunks to keep loaded (see MaxChunks). It's expected to be called
+// before any chunks are loaded.
+func (c *cache) setStore(store ChunkStore, maxChunks int) {
+	c.chunkLock.Lock()
+	defer c.chunkLock.Unlock()
+	if store != nil {
+		c.store = store
+	}
+	c.maxChunks = maxChunks
+}
+
+// setEviction sets when idle chunks are unloaded (see EvictPolicy). It's
+// expected to be called before any chunks are loaded.
+func (c *cache) setEviction(policy EvictPolicy, after time.Duration) {
+	c.chunkLock.Lock()
+	defer c.chunkLock.Unlock()
+	c.policy = policy
+	c.evictAfter = after
+}
 
-	go ctx.unload()
-	return ctx, err
+// setRenderer sets the renderer that chunks loaded from now on are drawn
+// with.
+func (c *cache) setRenderer(r Renderer) {
+	c.chunkLock.Lock()
+	defer c.chunkLock.Unlock()
+	c.renderer = r
+}
+
+// setCodec sets the codec that chunks loaded from now on are written & read
+// with.
+func (c *cache) setCodec(codec Codec) {
+	c.chunkLock.Lock()
+	defer c.chunkLock.Unlock()
+	c.codec = codec
 }
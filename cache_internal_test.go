@@ -0,0 +1,82 @@
+package mimage
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestCacheExists(t *testing.T) {
+	dir := t.TempDir()
+	m, err := New(image.Rect(0, 0, 64, 64), Directory(dir), ChunkSize(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reading a chunk holds it in memory, but it's still empty
+	_, err = m.Image(image.Rect(0, 0, 64, 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range [][2]int{{0, 0}, {1, 1}} {
+		if m.cache.exists(c[0], c[1]) {
+			t.Errorf("chunk %v exists after only being read", c)
+		}
+	}
+
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.DrawRectangle(40, 40, 4, 4)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.cache.exists(1, 1) || m.cache.exists(0, 0) {
+		t.Errorf("chunk (1,1) exists %v & (0,0) %v, want true & false", m.cache.exists(1, 1), m.cache.exists(0, 0))
+	}
+
+	// & once saved
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	if !loaded.cache.exists(1, 1) || loaded.cache.exists(0, 0) {
+		t.Errorf("reloaded chunk (1,1) exists %v & (0,0) %v, want true & false", loaded.cache.exists(1, 1), loaded.cache.exists(0, 0))
+	}
+}
+
+func TestCheckoutDropsChunks(t *testing.T) {
+	m, err := New(image.Rect(0, 0, 64, 64), Directory(t.TempDir()), ChunkSize(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	err = m.Branch("rivers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.DrawRectangle(40, 40, 4, 4)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the chunk was only drawn on the branch checked out before
+	err = m.Checkout("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.cache.exists(1, 1) {
+		t.Error("chunk drawn on another branch exists after checking out main")
+	}
+}
@@ -0,0 +1,53 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestOnChunkDone(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 128, 128), mimage.ChunkSize(32), mimage.OperationRoutines(4))
+	red := color.RGBA{255, 0, 0, 255}
+
+	op := m.Draw()
+	op.SetColor(red)
+	op.DrawRectangle(0, 0, 64, 64)
+	op.Fill()
+
+	// called from many routines, each chunk can be read as soon as it's done
+	lock := &sync.Mutex{}
+	done := map[image.Point]color.Color{}
+	op.OnChunkDone(func(cx, cy int, err error) {
+		if err != nil {
+			t.Error(err)
+		}
+		r := m.ChunkBounds(cx, cy)
+		img, err := m.Image(r)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		done[image.Pt(cx, cy)] = img.At(img.Bounds().Min.X+1, img.Bounds().Min.Y+1)
+	})
+	want := op.Plan().Chunks
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(done) != len(want) {
+		t.Errorf("%d chunks reported done, want %d", len(done), len(want))
+	}
+	if got := done[image.Pt(0, 0)]; got != red {
+		t.Errorf("chunk (0,0) read when done has %v, want %v", got, red)
+	}
+	if b := m.ChunkBounds(1, 2); b != image.Rect(32, 64, 64, 96) {
+		t.Errorf("chunk (1,2) bounds are %v", b)
+	}
+}
@@ -0,0 +1,48 @@
+package mimage
+
+import (
+	"time"
+)
+
+// Clock tells the time & waits, for unloading idle chunks (see EvictAfter).
+// Tests can use a clock that only moves when told to (eg. mimagetest.Clock),
+// so chunks are never unloaded behind their backs.
+type Clock interface {
+	Now() time.Time
+
+	// After returns a channel the time is sent on once d has passed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the system clock.
+type realClock struct{}
+
+// Now returns the current time.
+func (realClock) Now() time.Time { return time.Now() }
+
+// After returns a channel the time is sent on after d.
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// TimeWith sets the Clock idle chunks are unloaded by, the default being the
+// system clock.
+//
+// Clocks can't be saved with the image, so a loaded image uses the system
+// clock.
+func TimeWith(c Clock) Option {
+	return func(m *Mimage) error {
+		m.clock = c
+		return nil
+	}
+}
+
+// setClock sets the clock chunks loaded from now on are unloaded by, or with
+// nil, the system clock. It's expected to be called before any chunks are
+// loaded.
+func (c *cache) setClock(clock Clock) {
+	if clock == nil {
+		clock = realClock{}
+	}
+	c.chunkLock.Lock()
+	defer c.chunkLock.Unlock()
+	c.clock = clock
+}
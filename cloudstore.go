@@ -0,0 +1,357 @@
+package mimage
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultStoreRetries     = 3
+	defaultStoreConcurrency = 8
+	defaultGCSEndpoint      = "https://storage.googleapis.com"
+
+	// azureVersion is the Blob service REST API version we speak
+	azureVersion = "2021-08-06"
+)
+
+// StoreOption configures a cloud ChunkStore (see GCSStore, AzureBlobStore).
+type StoreOption func(*storeConfig)
+
+// storeConfig is what is shared by cloud stores
+type storeConfig struct {
+	prefix   string
+	retries  int
+	requests chan struct{}
+	endpoint string
+}
+
+// StorePrefix keeps files under the given prefix (eg. "renders/map/") rather
+// than at the top of the bucket / container.
+func StorePrefix(prefix string) StoreOption {
+	return func(c *storeConfig) {
+		c.prefix = prefix
+	}
+}
+
+// StoreConcurrency limits how many requests are made to the store at once,
+// the default is 8.
+func StoreConcurrency(n int) StoreOption {
+	return func(c *storeConfig) {
+		if n < 1 {
+			n = 1
+		}
+		c.requests = make(chan struct{}, n)
+	}
+}
+
+// StoreRetries sets how many times a request is retried (with a growing wait
+// between) after failing to connect, being throttled or a server error, the
+// default is 3.
+func StoreRetries(n int) StoreOption {
+	return func(c *storeConfig) {
+		if n < 0 {
+			n = 0
+		}
+		c.retries = n
+	}
+}
+
+// StoreEndpoint sets the URL of the service, for emulators & private
+// endpoints. Only used by GCSStore, for Azure the container URL is given.
+func StoreEndpoint(u string) StoreOption {
+	return func(c *storeConfig) {
+		c.endpoint = strings.TrimSuffix(u, "/")
+	}
+}
+
+// newStoreConfig returns the config with the given options applied.
+func newStoreConfig(opts []StoreOption) *storeConfig {
+	c := &storeConfig{
+		retries:  defaultStoreRetries,
+		requests: make(chan struct{}, defaultStoreConcurrency),
+		endpoint: defaultGCSEndpoint,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// checkStoreURL parses the URL of a store, which must be absolute.
+func checkStoreURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("store URL %q must include the scheme & host", s)
+	}
+	return u, nil
+}
+
+// do sends the request made by newReq, retrying as configured, & returns the
+// status & body of the response. 404 is returned as an error satisfying
+// os.IsNotExist.
+func (c *storeConfig) do(client *http.Client, newReq func() (*http.Request, error)) (int, []byte, error) {
+	c.requests <- struct{}{}
+	defer func() { <-c.requests }()
+
+	wait := 250 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		status, body, err := send(client, req)
+		retry := err != nil || status == http.StatusTooManyRequests || status >= 500
+		if !retry || attempt >= c.retries {
+			if err != nil {
+				return 0, nil, err
+			}
+			if status == http.StatusNotFound {
+				return status, nil, &os.PathError{Op: req.Method, Path: req.URL.Path, Err: os.ErrNotExist}
+			} else if status < 200 || status > 299 {
+				return status, nil, fmt.Errorf("%s %s: %d %s", req.Method, req.URL.Path, status, strings.TrimSpace(string(body)))
+			}
+			return status, body, nil
+		}
+
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// send makes a request & reads the whole response.
+func send(client *http.Client, req *http.Request) (int, []byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, body, err
+}
+
+// storeWriter buffers a file & uploads it on Close.
+type storeWriter struct {
+	bytes.Buffer
+	upload func([]byte) error
+}
+
+// Close uploads what has been written.
+func (w *storeWriter) Close() error {
+	return w.upload(w.Bytes())
+}
+
+// gcsStore is a ChunkStore in a Google Cloud Storage bucket
+type gcsStore struct {
+	client *http.Client
+	bucket string
+	cfg    *storeConfig
+}
+
+// GCSStore returns a ChunkStore of objects in a Google Cloud Storage bucket.
+// The client is expected to authenticate requests, eg. one from
+// golang.org/x/oauth2/google's DefaultClient with the devstorage.read_write
+// scope.
+func GCSStore(client *http.Client, bucket string, opts ...StoreOption) (ChunkStore, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("a bucket is required")
+	}
+	cfg := newStoreConfig(opts)
+	_, err := checkStoreURL(cfg.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &gcsStore{client: client, bucket: bucket, cfg: cfg}, nil
+}
+
+// objectURL returns the XML API URL of the named file.
+func (s *gcsStore) objectURL(name string) string {
+	return fmt.Sprintf("%s/%s/%s", s.cfg.endpoint, url.PathEscape(s.bucket), escapePath(s.cfg.prefix+name))
+}
+
+// Open downloads the named object.
+func (s *gcsStore) Open(name string) (io.ReadCloser, error) {
+	_, body, err := s.cfg.do(s.client, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, s.objectURL(name), nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(body)), nil
+}
+
+// Create returns a writer that uploads the named object when closed.
+func (s *gcsStore) Create(name string) (io.WriteCloser, error) {
+	return &storeWriter{upload: func(data []byte) error {
+		_, _, err := s.cfg.do(s.client, func() (*http.Request, error) {
+			return http.NewRequest(http.MethodPut, s.objectURL(name), bytes.NewReader(data))
+		})
+		return err
+	}}, nil
+}
+
+// List the objects under the prefix with the JSON API.
+func (s *gcsStore) List() ([]string, error) {
+	names := []string{}
+	token := ""
+	for {
+		q := url.Values{"prefix": {s.cfg.prefix}, "fields": {"items(name),nextPageToken"}}
+		if token != "" {
+			q.Set("pageToken", token)
+		}
+		u := fmt.Sprintf("%s/storage/v1/b/%s/o?%s", s.cfg.endpoint, url.PathEscape(s.bucket), q.Encode())
+		_, body, err := s.cfg.do(s.client, func() (*http.Request, error) {
+			return http.NewRequest(http.MethodGet, u, nil)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		page := struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}{}
+		err = json.Unmarshal(body, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			names = append(names, strings.TrimPrefix(item.Name, s.cfg.prefix))
+		}
+		if page.NextPageToken == "" {
+			return names, nil
+		}
+		token = page.NextPageToken
+	}
+}
+
+// azureStore is a ChunkStore in an Azure Blob Storage container
+type azureStore struct {
+	client    *http.Client
+	container *url.URL
+	cfg       *storeConfig
+}
+
+// AzureBlobStore returns a ChunkStore of blobs in an Azure Blob Storage
+// container, given the container URL including a SAS token with read, write
+// & list permissions, eg.
+//
+//	https://account.blob.core.windows.net/renders?sv=...&sig=...
+//
+// Shared key authorization isn't supported.
+func AzureBlobStore(client *http.Client, containerURL string, opts ...StoreOption) (ChunkStore, error) {
+	u, err := checkStoreURL(containerURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return &azureStore{client: client, container: u, cfg: newStoreConfig(opts)}, nil
+}
+
+// url returns the container URL with the given path appended & query added.
+func (s *azureStore) url(path string, query url.Values) string {
+	u := *s.container
+	u.Path += path
+	u.RawPath = ""
+	q := u.Query()
+	for k, v := range query {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// request returns a new request with the headers Azure wants.
+func (s *azureStore) request(method, u string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", azureVersion)
+	return req, nil
+}
+
+// Open downloads the named blob.
+func (s *azureStore) Open(name string) (io.ReadCloser, error) {
+	_, body, err := s.cfg.do(s.client, func() (*http.Request, error) {
+		return s.request(http.MethodGet, s.url("/"+s.cfg.prefix+name, nil), nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(body)), nil
+}
+
+// Create returns a writer that uploads the named blob when closed.
+func (s *azureStore) Create(name string) (io.WriteCloser, error) {
+	return &storeWriter{upload: func(data []byte) error {
+		_, _, err := s.cfg.do(s.client, func() (*http.Request, error) {
+			req, err := s.request(http.MethodPut, s.url("/"+s.cfg.prefix+name, nil), data)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("x-ms-blob-type", "BlockBlob")
+			return req, nil
+		})
+		return err
+	}}, nil
+}
+
+// List the blobs under the prefix.
+func (s *azureStore) List() ([]string, error) {
+	names := []string{}
+	marker := ""
+	for {
+		q := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {s.cfg.prefix}}
+		if marker != "" {
+			q.Set("marker", marker)
+		}
+		u := s.url("", q)
+		_, body, err := s.cfg.do(s.client, func() (*http.Request, error) {
+			return s.request(http.MethodGet, u, nil)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		page := struct {
+			Names      []string `xml:"Blobs>Blob>Name"`
+			NextMarker string   `xml:"NextMarker"`
+		}{}
+		err = xml.Unmarshal(body, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range page.Names {
+			names = append(names, strings.TrimPrefix(name, s.cfg.prefix))
+		}
+		if page.NextMarker == "" {
+			return names, nil
+		}
+		marker = page.NextMarker
+	}
+}
+
+// escapePath escapes each part of a slash separated path.
+func escapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
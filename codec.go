@@ -0,0 +1,67 @@
+package mimage
+
+import (
+	"image"
+	"image/png"
+	"io"
+)
+
+// Codec writes chunks out & reads them back, eg. to keep chunks in another
+// format, compress them harder or do something extra as each is saved (such
+// as writing a thumbnail of it elsewhere). Chunks keep their names (see
+// ChunkBounds) whatever the format.
+//
+// Codecs are used from many routines at once, so must be safe for
+// concurrent use.
+type Codec interface {
+	// Encode writes the chunk at (x,y), with straight alpha if the image
+	// keeps it (see Alpha) & premultiplied otherwise.
+	Encode(w io.Writer, img image.Image, x, y int) error
+
+	// Decode reads the chunk at (x,y) as written by Encode.
+	Decode(r io.Reader, x, y int) (image.Image, error)
+}
+
+// PNGCodec keeps chunks as PNGs, the default (with png.DefaultCompression).
+type PNGCodec struct {
+	Level png.CompressionLevel
+}
+
+// Encode writes the chunk as a PNG.
+func (c PNGCodec) Encode(w io.Writer, img image.Image, x, y int) error {
+	enc := png.Encoder{CompressionLevel: c.Level}
+	return enc.Encode(w, img)
+}
+
+// Decode reads the chunk from a PNG.
+func (c PNGCodec) Decode(r io.Reader, x, y int) (image.Image, error) {
+	return png.Decode(r)
+}
+
+// EncodeWith sets the Codec chunks are written & read with, the default
+// being PNGCodec.
+//
+// Codecs can't be saved with the image, so after Load call SetCodec again if
+// it isn't the default.
+func EncodeWith(c Codec) Option {
+	return func(m *Mimage) error {
+		m.codec = c
+		return nil
+	}
+}
+
+// SetCodec sets the Codec chunks are written & read with (see EncodeWith),
+// or with nil, the default. It's intended to be called right after Load,
+// before anything is read or drawn.
+func (m *Mimage) SetCodec(c Codec) {
+	m.codec = c
+	m.cache.setCodec(c)
+}
+
+// chunkCodec returns c, or the default if it's nil.
+func chunkCodec(c Codec) Codec {
+	if c == nil {
+		return PNGCodec{}
+	}
+	return c
+}
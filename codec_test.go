@@ -0,0 +1,91 @@
+package mimage_test
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+// taggedCodec writes PNGs after a tag, counting the chunks it reads.
+type taggedCodec struct {
+	decoded int32
+}
+
+func (c *taggedCodec) Encode(w io.Writer, img image.Image, x, y int) error {
+	_, err := fmt.Fprintf(w, "chunk %d %d\n", x, y)
+	if err != nil {
+		return err
+	}
+	return mimage.PNGCodec{}.Encode(w, img, x, y)
+}
+
+func (c *taggedCodec) Decode(r io.Reader, x, y int) (image.Image, error) {
+	var cx, cy int
+	_, err := fmt.Fscanf(r, "chunk %d %d\n", &cx, &cy)
+	if err != nil {
+		return nil, err
+	}
+	if cx != x || cy != y {
+		return nil, fmt.Errorf("chunk %d,%d read as %d,%d", cx, cy, x, y)
+	}
+	atomic.AddInt32(&c.decoded, 1)
+	return mimage.PNGCodec{}.Decode(r, x, y)
+}
+
+func TestEncodeWith(t *testing.T) {
+	dir := t.TempDir()
+	codec := &taggedCodec{}
+	m, err := mimage.New(image.Rect(0, 0, 64, 64), mimage.Directory(dir), mimage.ChunkSize(32), mimage.EncodeWith(codec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.DrawRectangle(40, 40, 10, 10)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "1.1.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("chunk 1 1\n")) {
+		t.Errorf("chunk written as %q.., want it tagged", data[:10])
+	}
+
+	white := color.RGBA{255, 255, 255, 255}
+	loaded, err := mimage.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	loaded.SetCodec(codec)
+	if got := loaded.At(45, 45); got != white || codec.decoded != 1 {
+		t.Errorf("reloaded pixel is %v after %d chunks decoded, want %v from 1", got, codec.decoded, white)
+	}
+
+	// chunks fetched from a store are decoded the same way
+	fetched, err := mimage.LoadStore(mimage.DirStore(dir), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fetched.Close()
+	fetched.SetCodec(codec)
+	if got := fetched.At(45, 45); got != white || codec.decoded != 2 {
+		t.Errorf("fetched pixel is %v after %d chunks decoded, want %v from 2", got, codec.decoded, white)
+	}
+}
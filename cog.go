@@ -0,0 +1,354 @@
+package mimage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// TIFF tags & types for tiled, multi resolution files.
+const (
+	tiffLong8 = 16
+
+	tagNewSubfileType  = 254
+	tagTileWidth       = 322
+	tagTileLength      = 323
+	tagTileOffsets     = 324
+	tagTileByteCounts  = 325
+	tiffDeflate        = 8
+	tiffReducedImage   = 1
+	defaultCOGTileSize = 512
+)
+
+// COGOptions configures writing Cloud Optimized GeoTIFFs.
+type COGOptions struct {
+	// TileSize is the width & height of tiles, a multiple of 16 that
+	// defaults to 512.
+	TileSize int
+}
+
+// cogLevel is one resolution of a COG.
+type cogLevel struct {
+	factor        int // world pixels per level pixel
+	width, height int
+	across, down  int // tiles
+
+	offsets, counts []uint64 // of each tile's data, relative to the first tile
+}
+
+// EncodeCOG writes the region r (in world space) to w as a Cloud Optimized
+// GeoTIFF; deflate compressed tiles with overviews (each half the size of
+// the last, until the image fits in a single tile) so GIS clients can read
+// just the tiles they need at the resolution they need with HTTP range
+// requests. All IFDs come first with the tiles of the smallest overview
+// next & those of the full image last, as the COG layout requires. Files
+// over 4GB are written as BigTIFF.
+//
+// Tiles are read & compressed one at a time, overviews being box filtered
+// straight from the image, & held in a temporary file until the header can
+// be written. The georeference, resolution & color profile of the image are
+// written as for other TIFF exports.
+func (m *Mimage) EncodeCOG(w io.Writer, r image.Rectangle, opts COGOptions, exportOpts ...ExportOption) error {
+	tileSize := opts.TileSize
+	if tileSize <= 0 {
+		tileSize = defaultCOGTileSize
+	}
+	if tileSize%16 != 0 {
+		return fmt.Errorf("tile size must be a multiple of 16, given %d", tileSize)
+	}
+	cfg, err := m.configureExport(exportOpts)
+	if err != nil {
+		return err
+	}
+	r = r.Intersect(m.bounds)
+	if r.Empty() {
+		return fmt.Errorf("region %v is outside of the image", r)
+	}
+
+	// full resolution, then overviews until everything fits in one tile
+	levels := []*cogLevel{}
+	for f := 1; ; f *= 2 {
+		lw, lh := (r.Dx()+f-1)/f, (r.Dy()+f-1)/f
+		levels = append(levels, &cogLevel{
+			factor: f, width: lw, height: lh,
+			across: (lw + tileSize - 1) / tileSize, down: (lh + tileSize - 1) / tileSize,
+		})
+		if lw <= tileSize && lh <= tileSize {
+			break
+		}
+	}
+
+	tmp, err := ioutil.TempFile("", "mimage-cog-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// tile data, smallest overview first
+	size := uint64(0)
+	bw := bufio.NewWriter(tmp)
+	for i := len(levels) - 1; i >= 0; i-- {
+		n, err := m.writeCOGTiles(bw, levels[i], r, tileSize, cfg, size)
+		if err != nil {
+			return err
+		}
+		size += n
+	}
+	err = bw.Flush()
+	if err != nil {
+		return err
+	}
+
+	// assume the file isn't a BigTIFF until we know it's too big
+	extra := cfg.info(m, r.Min).tiffFields()
+	header := cogHeader(levels, tileSize, extra, false)
+	if uint64(len(header))+size > tiffMaxClassicBytes {
+		header = cogHeader(levels, tileSize, extra, true)
+	}
+
+	_, err = w.Write(header)
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, tmp)
+	return err
+}
+
+// writeCOGTiles compresses & writes all tiles of a level, recording where each
+// is (from the given offset). Returns the bytes written.
+func (m *Mimage) writeCOGTiles(w io.Writer, level *cogLevel, r image.Rectangle, tileSize int, cfg *exportConfig, at uint64) (uint64, error) {
+	f := level.factor
+	count := level.across * level.down
+	level.offsets, level.counts = make([]uint64, count), make([]uint64, count)
+
+	buf := &bytes.Buffer{}
+	pix := make([]byte, tileSize*tileSize*4)
+	written := uint64(0)
+	for ty := 0; ty < level.down; ty++ {
+		for tx := 0; tx < level.across; tx++ {
+			// the tile in level & world space
+			lr := image.Rect(tx*tileSize, ty*tileSize, (tx+1)*tileSize, (ty+1)*tileSize).Intersect(image.Rect(0, 0, level.width, level.height))
+			wr := image.Rect(lr.Min.X*f, lr.Min.Y*f, lr.Max.X*f, lr.Max.Y*f).Add(r.Min).Intersect(r)
+
+			for i := range pix {
+				pix[i] = 0 // edge tiles are padded
+			}
+			err := m.cogTile(pix, tileSize, wr, f, cfg)
+			if err != nil {
+				return 0, err
+			}
+
+			buf.Reset()
+			zw := zlib.NewWriter(buf)
+			zw.Write(pix)
+			err = zw.Close()
+			if err != nil {
+				return 0, err
+			}
+
+			i := ty*level.across + tx
+			level.offsets[i], level.counts[i] = at+written, uint64(buf.Len())
+			n, err := w.Write(buf.Bytes())
+			written += uint64(n)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+// cogTile fills pix (a tile of straight RGBA samples) with the world space
+// region wr shrunk by factor.
+func (m *Mimage) cogTile(pix []byte, tileSize int, wr image.Rectangle, factor int, cfg *exportConfig) error {
+	var rgba *image.RGBA
+	var straight *image.NRGBA
+	if factor == 1 {
+		img, err := m.Image(wr)
+		if err != nil {
+			return err
+		}
+		rgba = img.(*image.RGBA)
+		if m.alpha == AlphaStraight {
+			straight, err = m.ImageNRGBA(wr)
+			if err != nil {
+				return err
+			}
+		}
+		cfg.apply(rgba, wr.Min)
+	} else {
+		var err error
+		rgba, err = m.downsample(wr, factor)
+		if err != nil {
+			return err
+		}
+		cfg.apply(rgba, image.Pt(wr.Min.X/factor, wr.Min.Y/factor))
+	}
+
+	b := rgba.Bounds()
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			i := rgba.PixOffset(b.Min.X+x, b.Min.Y+y)
+			var c color.NRGBA
+			if straight != nil {
+				c = reconcileAt(straight, rgba, i)
+			} else {
+				p := rgba.Pix[i : i+4 : i+4]
+				c = unpremultiply(color.RGBA{R: p[0], G: p[1], B: p[2], A: p[3]})
+			}
+			o := (y*tileSize + x) * 4
+			pix[o], pix[o+1], pix[o+2], pix[o+3] = c.R, c.G, c.B, c.A
+		}
+	}
+	return nil
+}
+
+// cogHeader returns the TIFF header & the IFDs of every level (full
+// resolution first), with tile offsets moved to follow the header.
+func cogHeader(levels []*cogLevel, tileSize int, extra []tiffField, big bool) []byte {
+	ifds := make([][]tiffField, len(levels))
+	build := func(dataAt uint64) {
+		for i, l := range levels {
+			offsets := make([]uint64, len(l.offsets))
+			for j, o := range l.offsets {
+				offsets[j] = o + dataAt
+			}
+			subfile := uint32(0)
+			if i > 0 {
+				subfile = tiffReducedImage
+			}
+			fields := []tiffField{
+				longField(tagNewSubfileType, subfile),
+				longField(tagImageWidth, uint32(l.width)),
+				longField(tagImageLength, uint32(l.height)),
+				shortField(tagBitsPerSample, 8, 8, 8, 8),
+				shortField(tagCompression, tiffDeflate),
+				shortField(tagPhotometric, 2), // RGB
+				shortField(tagSamplesPerPixel, 4),
+				shortField(tagPlanarConfig, 1),
+				longField(tagTileWidth, uint32(tileSize)),
+				longField(tagTileLength, uint32(tileSize)),
+				offsetsField(tagTileOffsets, offsets, big),
+				offsetsField(tagTileByteCounts, l.counts, big),
+				shortField(tagExtraSamples, tiffUnassocAlpha),
+				shortField(tagSampleFormat, tiffSampleUint, tiffSampleUint, tiffSampleUint, tiffSampleUint),
+			}
+			if i == 0 {
+				fields = append(fields, extra...)
+			}
+			sort.SliceStable(fields, func(a, b int) bool { return fields[a].tag < fields[b].tag })
+			ifds[i] = fields
+		}
+	}
+
+	// sizes don't depend on the offsets, so lay out once to find where the
+	// data starts & again for real
+	build(0)
+	headerSize := 8
+	if big {
+		headerSize = 16
+	}
+	total := uint64(headerSize)
+	for _, fields := range ifds {
+		total += uint64(len(encodeIFD(fields, 0, 0, big)))
+	}
+	build(total)
+
+	out := []byte{'I', 'I', 42, 0, 8, 0, 0, 0}
+	if big {
+		out = []byte{'I', 'I', 43, 0, 8, 0, 0, 0, 16, 0, 0, 0, 0, 0, 0, 0}
+	}
+	for i, fields := range ifds {
+		at := uint64(len(out))
+		next := uint64(0)
+		if i < len(ifds)-1 {
+			next = at + uint64(len(encodeIFD(fields, at, 0, big)))
+		}
+		out = append(out, encodeIFD(fields, at, next, big)...)
+	}
+	return out
+}
+
+// offsetsField returns a field of file offsets or sizes, 64 bit for BigTIFF.
+func offsetsField(tag uint16, values []uint64, big bool) tiffField {
+	if !big {
+		v := make([]uint32, len(values))
+		for i := range values {
+			v[i] = uint32(values[i])
+		}
+		return longField(tag, v...)
+	}
+	data := make([]byte, 8*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint64(data[i*8:], v)
+	}
+	return tiffField{tag: tag, typ: tiffLong8, count: uint32(len(values)), data: data}
+}
+
+// encodeIFD returns an IFD (sorted fields) written at the given file offset
+// followed by its out of line data, pointing to the next IFD.
+func encodeIFD(fields []tiffField, at, next uint64, big bool) []byte {
+	le := binary.LittleEndian
+	countSize, entrySize, valueSize := 2, 12, 4
+	if big {
+		countSize, entrySize, valueSize = 8, 20, 8
+	}
+
+	ifdSize := countSize + entrySize*len(fields) + valueSize
+	out := make([]byte, ifdSize)
+	ext := []byte{}
+	if big {
+		le.PutUint64(out, uint64(len(fields)))
+	} else {
+		le.PutUint16(out, uint16(len(fields)))
+	}
+
+	for i, f := range fields {
+		entry := out[countSize+i*entrySize : countSize+(i+1)*entrySize]
+		le.PutUint16(entry[0:], f.tag)
+		le.PutUint16(entry[2:], f.typ)
+		value := entry[8:]
+		if big {
+			le.PutUint64(entry[4:], uint64(f.count))
+			value = entry[12:]
+		} else {
+			le.PutUint32(entry[4:], f.count)
+		}
+
+		if len(f.data) <= valueSize {
+			copy(value, f.data)
+			continue
+		}
+		offset := at + uint64(ifdSize+len(ext))
+		if big {
+			le.PutUint64(value, offset)
+		} else {
+			le.PutUint32(value, uint32(offset))
+		}
+		ext = append(ext, f.data...)
+		if len(ext)%2 == 1 {
+			ext = append(ext, 0)
+		}
+	}
+
+	if big {
+		le.PutUint64(out[ifdSize-8:], next)
+	} else {
+		le.PutUint32(out[ifdSize-4:], uint32(next))
+	}
+	return append(out, ext...)
+}
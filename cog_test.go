@@ -0,0 +1,74 @@
+package mimage_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+	"golang.org/x/image/tiff"
+)
+
+func TestEncodeCOG(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 96, 96), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(color.RGBA{255, 0, 0, 255})
+	op.Clear()
+	op.SetColor(color.RGBA{0, 0, 255, 255})
+	op.DrawRectangle(0, 0, 48, 96)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.EncodeCOG(&bytes.Buffer{}, m.Bounds(), mimage.COGOptions{TileSize: 20}); err == nil {
+		t.Error("tile size 20 got no error")
+	}
+	if err := m.EncodeCOG(&bytes.Buffer{}, image.Rect(200, 200, 300, 300), mimage.COGOptions{}); err == nil {
+		t.Error("region outside of the image got no error")
+	}
+
+	buf := &bytes.Buffer{}
+	err = m.EncodeCOG(buf, m.Bounds(), mimage.COGOptions{TileSize: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// full resolution, then overviews of 48 & 24 pixels
+	le := binary.LittleEndian
+	widths := []uint32{}
+	for at := le.Uint32(data[4:]); at != 0; {
+		n := uint32(le.Uint16(data[at:]))
+		for i := uint32(0); i < n; i++ {
+			e := data[at+2+i*12:]
+			if le.Uint16(e) == 256 { // ImageWidth
+				widths = append(widths, le.Uint32(e[8:]))
+			}
+		}
+		at = le.Uint32(data[at+2+n*12:])
+	}
+	if len(widths) != 3 || widths[0] != 96 || widths[1] != 48 || widths[2] != 24 {
+		t.Errorf("level widths are %v, want [96 48 24]", widths)
+	}
+
+	img, err := tiff.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 96, 96) {
+		t.Fatalf("decoded bounds %v", img.Bounds())
+	}
+	for _, pt := range []image.Point{{5, 5}, {40, 90}, {50, 5}, {95, 95}} {
+		want := color.RGBA{255, 0, 0, 255}
+		if pt.X < 48 {
+			want = color.RGBA{0, 0, 255, 255}
+		}
+		if got := color.RGBAModel.Convert(img.At(pt.X, pt.Y)); got != want {
+			t.Errorf("pixel %v is %v, want %v", pt, got, want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package mimage
+
+// signatures gives the arguments of each deferred function in order; 'f' is
+// a float64 & 'i' an int, both kept in a command buffer's nums, & 'v' is any
+// other value, kept in its args.
+var signatures = [...]string{
+	setFillStyle:         "v",
+	setStrokeStyle:       "v",
+	setLineWidth:         "f",
+	setColor:             "v",
+	setPixel:             "ii",
+	setMask:              "v",
+	invertMask:           "",
+	moveTo:               "ff",
+	lineTo:               "ff",
+	closePath:            "",
+	drawRectangle:        "ffff",
+	rotateAbout:          "fff",
+	drawEllipse:          "ffff",
+	fill:                 "",
+	stroke:               "",
+	clear:                "",
+	drawImage:            "vii",
+	drawImageOp:          "viivf",
+	drawImageScaled:      "vvvv",
+	drawImageTransformed: "vv",
+	drawStamps:           "vv",
+	scatterStamps:        "v",
+	drawTilemap:          "v",
+	drawGrid:             "v",
+	applyMacro:           "vfff",
+	transform:            "fff",
+	setFillRule:          "v",
+	drawPolygon:          "v",
+	drawPolyline:         "v",
+	setLineDecoration:    "v",
+	drawDashes:           "vv",
+	strokeAlongPath:      "v",
+	setPaintMode:         "v",
+	addNoise:             "v",
+}
+
+// arity is the number of numeric & other arguments of each deferred function,
+// worked out from its signature.
+var arity = func() [len(signatures)][2]int {
+	out := [len(signatures)][2]int{}
+	for fn, sig := range signatures {
+		for _, kind := range sig {
+			if kind == 'v' {
+				out[fn][1]++
+			} else {
+				out[fn][0]++
+			}
+		}
+	}
+	return out
+}()
+
+// commands is a queue of deferred functions to be called on Do(), kept as a
+// typed, append-only buffer; a byte for each call with numeric arguments
+// packed into nums & any others into args (see signatures). Paths of
+// millions of MoveTo / LineTo calls then cost a byte & two floats each,
+// rather than a struct, a slice & a boxed value for every argument.
+//
+// Slicing a buffer (see from) shares the underlying arrays, it's only ever
+// appended to or replaced whole.
+type commands struct {
+	funcs []uint8
+	nums  []float64
+	args  []interface{}
+}
+
+// command is a deferred function read back from a buffer, its arguments
+// point into the buffer.
+type command struct {
+	Func int
+	Nums []float64
+	Args []interface{}
+}
+
+// cursor is the position of a command within a buffer.
+type cursor struct {
+	fn, num, arg int
+}
+
+// len returns the number of commands.
+func (c *commands) len() int { return len(c.funcs) }
+
+// push appends a function with only numeric arguments.
+func (c *commands) push(fn int, nums ...float64) {
+	c.funcs = append(c.funcs, uint8(fn))
+	c.nums = append(c.nums, nums...)
+}
+
+// pushArgs appends a function with other arguments & (after them in the
+// buffer, whatever the signature) its numeric ones.
+func (c *commands) pushArgs(fn int, args []interface{}, nums ...float64) {
+	c.funcs = append(c.funcs, uint8(fn))
+	c.args = append(c.args, args...)
+	c.nums = append(c.nums, nums...)
+}
+
+// pushCommand appends a command read from another buffer.
+func (c *commands) pushCommand(cmd command) {
+	c.pushArgs(cmd.Func, cmd.Args, cmd.Nums...)
+}
+
+// at returns the command at p & the position of the one after it.
+func (c *commands) at(p cursor) (command, cursor) {
+	fn := int(c.funcs[p.fn])
+	n := arity[fn]
+	cmd := command{
+		Func: fn,
+		Nums: c.nums[p.num : p.num+n[0] : p.num+n[0]],
+		Args: c.args[p.arg : p.arg+n[1] : p.arg+n[1]],
+	}
+	return cmd, cursor{p.fn + 1, p.num + n[0], p.arg + n[1]}
+}
+
+// from returns the commands from p onwards.
+func (c *commands) from(p cursor) *commands {
+	return &commands{funcs: c.funcs[p.fn:], nums: c.nums[p.num:], args: c.args[p.arg:]}
+}
+
+// values returns the arguments of the command in the order of its
+// signature, ints as ints.
+func (cmd command) values() []interface{} {
+	out := []interface{}{}
+	n, a := 0, 0
+	for _, kind := range signatures[cmd.Func] {
+		switch kind {
+		case 'f':
+			out = append(out, cmd.Nums[n])
+			n++
+		case 'i':
+			out = append(out, int(cmd.Nums[n]))
+			n++
+		default:
+			out = append(out, cmd.Args[a])
+			a++
+		}
+	}
+	return out
+}
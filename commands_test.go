@@ -0,0 +1,108 @@
+package mimage
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testCommand returns a command for fn with arguments made up from its
+// signature & i.
+func testCommand(fn, i int) (nums []float64, args []interface{}, values []interface{}) {
+	for j, kind := range signatures[fn] {
+		switch kind {
+		case 'f':
+			v := float64(i) + float64(j)/4
+			nums = append(nums, v)
+			values = append(values, v)
+		case 'i':
+			v := i*10 + j
+			nums = append(nums, float64(v))
+			values = append(values, v)
+		default:
+			v := []int{i, j}
+			args = append(args, v)
+			values = append(values, v)
+		}
+	}
+	return nums, args, values
+}
+
+func TestCommandsRoundTrip(t *testing.T) {
+	// every function, a few times over, read back in order
+	c := &commands{}
+	want := [][]interface{}{}
+	for i := 0; i < 3; i++ {
+		for fn := range signatures {
+			nums, args, values := testCommand(fn, i)
+			if len(args) == 0 && i%2 == 0 {
+				c.push(fn, nums...)
+			} else {
+				c.pushArgs(fn, args, nums...)
+			}
+			want = append(want, append([]interface{}{fn}, values...))
+		}
+	}
+
+	if c.len() != len(want) {
+		t.Fatalf("got %d commands, pushed %d", c.len(), len(want))
+	}
+	got := [][]interface{}{}
+	for p := (cursor{}); p.fn < c.len(); {
+		var cmd command
+		cmd, p = c.at(p)
+		got = append(got, append([]interface{}{cmd.Func}, cmd.values()...))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("read back %v, pushed %v", got, want)
+	}
+}
+
+func TestCommandsFrom(t *testing.T) {
+	c := &commands{}
+	c.push(moveTo, 1, 2)
+	c.pushArgs(setColor, []interface{}{"red"})
+	c.push(lineTo, 3, 4)
+	c.pushArgs(drawImage, []interface{}{"img"}, 5, 6)
+
+	_, p := c.at(cursor{})
+	_, p = c.at(p)
+	rest := c.from(p)
+	if rest.len() != 2 {
+		t.Fatalf("got %d commands after the second, want 2", rest.len())
+	}
+
+	// appending to the slice mustn't change the buffer it came from
+	rest.push(fill)
+	copied := &commands{}
+	for p := (cursor{}); p.fn < rest.len(); {
+		var cmd command
+		cmd, p = rest.at(p)
+		copied.pushCommand(cmd)
+	}
+	want := []int{lineTo, drawImage, fill}
+	for i, q := 0, (cursor{}); q.fn < copied.len(); i++ {
+		var cmd command
+		cmd, q = copied.at(q)
+		if cmd.Func != want[i] {
+			t.Errorf("command %d is %d, want %d", i, cmd.Func, want[i])
+		}
+	}
+	if c.len() != 4 {
+		t.Errorf("original buffer has %d commands, want 4", c.len())
+	}
+	last, _ := c.at(cursor{fn: 3, num: 4, arg: 1})
+	if !reflect.DeepEqual(last.values(), []interface{}{"img", 5, 6}) {
+		t.Errorf("last command has values %v", last.values())
+	}
+}
+
+func TestCallNames(t *testing.T) {
+	if len(callNames) != len(signatures) {
+		t.Fatalf("%d functions are named, %d have signatures", len(callNames), len(signatures))
+	}
+	for fn, name := range callNames {
+		if name == "" {
+			t.Errorf("function %d has no name", fn)
+		}
+	}
+}
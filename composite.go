@@ -0,0 +1,171 @@
+package mimage
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+)
+
+// CompositeMode determines how an image drawn with DrawImageOp is combined
+// with what's already there. Modes other than CompositeOver follow the W3C
+// compositing spec (https://www.w3.org/TR/compositing-1/), where they apply
+// only where both images are opaque & fade to plain source over elsewhere.
+type CompositeMode int
+
+const (
+	// CompositeOver draws the image over the top, as DrawImage does.
+	CompositeOver CompositeMode = iota
+
+	// CompositeMultiply darkens, multiplying colors (eg. shadows, shading).
+	CompositeMultiply
+
+	// CompositeScreen lightens, the inverse of multiplying the inverses
+	// (eg. light, glow).
+	CompositeScreen
+
+	// CompositeOverlay multiplies dark areas & screens light ones, boosting
+	// contrast (eg. texture, hillshade over terrain colors).
+	CompositeOverlay
+
+	// CompositeDarken keeps the darker of each channel.
+	CompositeDarken
+
+	// CompositeLighten keeps the lighter of each channel.
+	CompositeLighten
+
+	// CompositeAdd adds colors, clamped to white (aka. linear dodge).
+	CompositeAdd
+
+	// CompositeDifference takes the absolute difference of each channel.
+	CompositeDifference
+)
+
+// blendChannel returns the blended value of backdrop b & source s, both
+// straight (not premultiplied) & 0-1.
+func (c CompositeMode) blendChannel(b, s float64) float64 {
+	switch c {
+	case CompositeMultiply:
+		return b * s
+	case CompositeScreen:
+		return b + s - b*s
+	case CompositeOverlay:
+		if b <= 0.5 {
+			return 2 * b * s
+		}
+		return 1 - 2*(1-b)*(1-s)
+	case CompositeDarken:
+		return math.Min(b, s)
+	case CompositeLighten:
+		return math.Max(b, s)
+	case CompositeAdd:
+		return math.Min(1, b+s)
+	case CompositeDifference:
+		return math.Abs(b - s)
+	}
+	return s
+}
+
+// DrawImageOp draws the image i onto this image with the top left corner at
+// (x,y), combined with what's already there using the given mode & faded by
+// opacity (0-1).
+func (o *operation) DrawImageOp(i image.Image, x, y int, mode CompositeMode, opacity float64) {
+	opacity = math.Max(0, math.Min(1, opacity))
+	bnds := i.Bounds()
+	o.minMax(float64(x+bnds.Min.X), float64(y+bnds.Min.Y))
+	o.minMax(float64(x+bnds.Max.X), float64(y+bnds.Max.Y))
+	o.queue.pushArgs(drawImageOp, []interface{}{i, mode}, float64(x), float64(y), opacity)
+}
+
+// compositeImage draws src onto the chunk with its top left at (x,y) (in
+// chunk space, before the current transform), blending with mode & scaled by
+// opacity & mask (if any). Returns if anything was drawn.
+func compositeImage(ctx *context, src image.Image, x, y int, mode CompositeMode, opacity float64, mask *image.Alpha) bool {
+	dst := ctx.Img.Image().(*image.RGBA)
+	aff := imageTransform(ctx.Img, float64(x), float64(y))
+
+	// the area the image lands on after the transform
+	sb := src.Bounds()
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	x0, y0, x1, y1 := float64(sb.Min.X), float64(sb.Min.Y), float64(sb.Max.X), float64(sb.Max.Y)
+	for _, p := range []Point{{x0, y0}, {x1, y0}, {x0, y1}, {x1, y1}} {
+		px, py := aff[0]*p.X+aff[1]*p.Y+aff[2], aff[3]*p.X+aff[4]*p.Y+aff[5]
+		minX, minY = math.Min(minX, px), math.Min(minY, py)
+		maxX, maxY = math.Max(maxX, px), math.Max(maxY, py)
+	}
+	area := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY))).Intersect(dst.Bounds())
+	if area.Empty() {
+		return false
+	}
+
+	// transform the source onto a layer the size of the area, as gg would,
+	// then blend it down
+	layer := image.NewRGBA(area)
+	xdraw.BiLinear.Transform(layer, aff, src, sb, xdraw.Src, nil)
+
+	for py := area.Min.Y; py < area.Max.Y; py++ {
+		for px := area.Min.X; px < area.Max.X; px++ {
+			i := layer.PixOffset(px, py)
+			s := layer.Pix[i : i+4 : i+4]
+			if s[3] == 0 {
+				continue
+			}
+			sa := float64(s[3]) / 0xff * opacity
+			if mask != nil {
+				sa *= float64(mask.AlphaAt(px, py).A) / 0xff
+			}
+			if sa == 0 {
+				continue
+			}
+
+			j := dst.PixOffset(px, py)
+			var d color.NRGBA
+			if ctx.straight != nil {
+				d = reconcileAt(ctx.straight, dst, j)
+			} else {
+				d = unpremultiply(color.RGBA{R: dst.Pix[j], G: dst.Pix[j+1], B: dst.Pix[j+2], A: dst.Pix[j+3]})
+			}
+			da := float64(d.A) / 0xff
+			oa := sa + da*(1-sa)
+
+			// straight source color, mixed with the blended color where
+			// the backdrop is opaque, then source over
+			back := [3]uint8{d.R, d.G, d.B}
+			mixed := [3]uint8{}
+			for c := range mixed {
+				sc := float64(s[c]) / float64(s[3])
+				bc := float64(back[c]) / 0xff
+				sc = (1-da)*sc + da*mode.blendChannel(bc, sc)
+				mixed[c] = uint8(math.Max(0, math.Min(1, (sa*sc+da*bc*(1-sa))/oa))*0xff + 0.5)
+			}
+			out := color.NRGBA{R: mixed[0], G: mixed[1], B: mixed[2], A: uint8(oa*0xff + 0.5)}
+
+			if ctx.straight != nil {
+				ctx.straight.SetNRGBA(px, py, out)
+			}
+			dst.SetRGBA(px, py, premultiply(out))
+		}
+	}
+	return true
+}
+
+// imageTransform returns the affine transform (from image to chunk space) that
+// gg would use to draw an image at (x,y) given the context's current matrix.
+func imageTransform(dc Canvas, x, y float64) f64.Aff3 {
+	return contextMatrix(dc).Multiply(TranslateMatrix(x, y)).aff3()
+}
+
+// contextMatrix returns the current (affine) transform of the context.
+func contextMatrix(dc Canvas) Matrix3 {
+	ox, oy := dc.TransformPoint(0, 0)
+	ax, ay := dc.TransformPoint(1, 0)
+	bx, by := dc.TransformPoint(0, 1)
+	return Matrix3{ax - ox, bx - ox, ox, ay - oy, by - oy, oy, 0, 0, 1}
+}
+
+// aff3 returns the affine part of the matrix, as used by x/image/draw.
+func (m Matrix3) aff3() f64.Aff3 {
+	return f64.Aff3{m[0], m[1], m[2], m[3], m[4], m[5]}
+}
@@ -0,0 +1,57 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestDrawImageOp(t *testing.T) {
+	red := solid(image.Rect(0, 0, 8, 8), color.RGBA{255, 0, 0, 255})
+	for _, tc := range []struct {
+		mode    mimage.CompositeMode
+		opacity float64
+		want    color.RGBA
+	}{
+		{mimage.CompositeOver, 1, color.RGBA{255, 0, 0, 255}},
+		{mimage.CompositeMultiply, 1, color.RGBA{128, 0, 0, 255}},
+		{mimage.CompositeScreen, 1, color.RGBA{255, 128, 128, 255}},
+		{mimage.CompositeDarken, 1, color.RGBA{128, 0, 0, 255}},
+		{mimage.CompositeLighten, 1, color.RGBA{255, 128, 128, 255}},
+		{mimage.CompositeDifference, 1, color.RGBA{127, 128, 128, 255}},
+		{mimage.CompositeMultiply, 0.5, color.RGBA{128, 64, 64, 255}},
+	} {
+		m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+		op := m.Draw()
+		op.SetColor(color.RGBA{128, 128, 128, 255})
+		op.DrawRectangle(0, 0, 32, 64)
+		op.Fill()
+		// straddling the gray & the empty half, and a chunk boundary
+		op.DrawImageOp(red, 28, 28, tc.mode, tc.opacity)
+		err := op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, pt := range []image.Point{{28, 28}, {31, 35}} {
+			if got := m.At(pt.X, pt.Y).(color.RGBA); !nearRGBA(got, tc.want) {
+				t.Errorf("mode %d opacity %v at %v is %v, want %v", tc.mode, tc.opacity, pt, got, tc.want)
+			}
+		}
+		// on transparent pixels the image is simply drawn over
+		want := color.RGBA{uint8(255*tc.opacity + 0.5), 0, 0, uint8(255*tc.opacity + 0.5)}
+		if got := m.At(33, 33).(color.RGBA); !nearRGBA(got, want) {
+			t.Errorf("mode %d over nothing is %v, want %v", tc.mode, got, want)
+		}
+		if got := m.At(40, 40); got != (color.RGBA{}) {
+			t.Errorf("mode %d drew past the edge of the source, got %v", tc.mode, got)
+		}
+	}
+}
+
+// nearRGBA returns if colors are within 1 per channel.
+func nearRGBA(a, b color.RGBA) bool {
+	return absDiff(a.R, b.R) <= 1 && absDiff(a.G, b.G) <= 1 && absDiff(a.B, b.B) <= 1 && absDiff(a.A, b.A) <= 1
+}
@@ -0,0 +1,132 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"time"
+)
+
+// OptionError is returned by New (& Load) when options can't be used
+// together, or where the image is kept.
+type OptionError struct {
+	// Option is the option that can't be used, eg. "SkipUnchanged".
+	Option string
+
+	// Conflict is what it can't be used with, eg. "Storage".
+	Conflict string
+}
+
+// Error describes the conflict.
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("%s can't be used with %s", e.Option, e.Conflict)
+}
+
+// Config is the configuration an image is using, with defaults filled in
+// (see Options).
+type Config struct {
+	Bounds    image.Rectangle
+	ChunkSize int
+	Routines  int
+	Alpha     AlphaMode
+	Edges     EdgeMode
+	Toroidal  bool
+	Unbounded bool
+	Strict    bool
+
+	// Directory the image is kept in, or if empty, Store.
+	Directory string
+	Store     ChunkStore
+
+	// Codec chunks are written & read with, their format.
+	Codec Codec
+
+	// Renderer chunks are drawn with, nil for gg.
+	Renderer Renderer
+
+	// Remote is the URL chunks not yet saved are fetched from, if any.
+	Remote string
+
+	// Eviction is the policy deciding when chunks are unloaded, after
+	// going unused for EvictAfter with EvictIdle, or when there are more
+	// than MaxChunks with EvictLRU.
+	Eviction    EvictPolicy
+	EvictAfter  time.Duration
+	MaxChunks   int
+	WriteBehind int
+	IOThrottle  int64
+
+	ScheduleConcurrency int
+	SkipUnchanged       bool
+	KeepHistory         bool
+	SnapshotCopies      bool
+}
+
+// Options returns the configuration the image is using, as given when it
+// was made (or loaded) with defaults filled in.
+func (m *Mimage) Options() Config {
+	policy, after := m.eviction()
+	concurrency := m.scheduleConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultScheduleConcurrency
+	}
+
+	m.metaLock.Lock()
+	bounds := m.bounds
+	m.metaLock.Unlock()
+
+	return Config{
+		Bounds:              bounds,
+		ChunkSize:           m.chunkSize,
+		Routines:            m.routines,
+		Alpha:               m.alpha,
+		Edges:               m.edges,
+		Toroidal:            m.toroidal,
+		Unbounded:           m.unbounded,
+		Strict:              m.strictBounds,
+		Directory:           m.root,
+		Store:               m.storage,
+		Codec:               chunkCodec(m.codec),
+		Renderer:            m.renderer,
+		Remote:              m.remote,
+		Eviction:            policy,
+		EvictAfter:          after,
+		MaxChunks:           m.maxChunks,
+		WriteBehind:         m.writeBehind,
+		IOThrottle:          m.ioThrottle,
+		ScheduleConcurrency: concurrency,
+		SkipUnchanged:       m.skipUnchanged,
+		KeepHistory:         m.keepHistory,
+		SnapshotCopies:      m.snapshotCopies,
+	}
+}
+
+// checkOptions returns an OptionError if the options given can't be used
+// together.
+func (m *Mimage) checkOptions() error {
+	if m.unbounded && m.toroidal {
+		return &OptionError{Option: "Unbounded", Conflict: "Toroidal"}
+	}
+	if m.strictBounds && m.toroidal {
+		return &OptionError{Option: "StrictBounds", Conflict: "Toroidal"}
+	}
+	if m.strictBounds && m.unbounded {
+		return &OptionError{Option: "StrictBounds", Conflict: "Unbounded"}
+	}
+	if m.storage != nil && m.root != "" {
+		return &OptionError{Option: "Storage", Conflict: "Directory"}
+	}
+	if m.skipUnchanged && m.storage != nil {
+		return &OptionError{Option: "SkipUnchanged", Conflict: "Storage"}
+	}
+	if m.keepHistory && m.storage != nil {
+		return &OptionError{Option: "KeepHistory", Conflict: "Storage"}
+	}
+	if policy, _ := m.eviction(); m.writeBehind > 0 && policy != EvictLRU {
+		conflict := "Eviction(EvictIdle)"
+		if policy == EvictNever {
+			conflict = "Eviction(EvictNever)"
+		}
+		return &OptionError{Option: "WriteBehind", Conflict: conflict}
+	}
+	return nil
+}
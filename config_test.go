@@ -0,0 +1,51 @@
+package mimage_test
+
+import (
+	"errors"
+	"image"
+	"testing"
+
+	"github.com/voidshard/mimage"
+	"github.com/voidshard/mimage/mimagetest"
+)
+
+func TestOptions(t *testing.T) {
+	dir := t.TempDir()
+	m, err := mimage.New(image.Rect(0, 0, 64, 64), mimage.Directory(dir), mimage.ChunkSize(32), mimage.MaxChunks(8), mimage.WriteBehind(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	c := m.Options()
+	if c.Bounds != image.Rect(0, 0, 64, 64) || c.ChunkSize != 32 || c.Directory != dir || c.Store != nil {
+		t.Errorf("image has bounds %v, chunk size %d in %q (store %v)", c.Bounds, c.ChunkSize, c.Directory, c.Store)
+	}
+	// MaxChunks alone picks EvictLRU
+	if c.Eviction != mimage.EvictLRU || c.MaxChunks != 8 || c.WriteBehind != 2 {
+		t.Errorf("image evicts with %v, max chunks %d & write behind %d", c.Eviction, c.MaxChunks, c.WriteBehind)
+	}
+	// defaults are filled in
+	if c.Routines <= 0 || c.ScheduleConcurrency <= 0 || c.Codec == nil {
+		t.Errorf("image has %d routines, schedules %d at once & codec %v", c.Routines, c.ScheduleConcurrency, c.Codec)
+	}
+
+	store := mimagetest.NewMemStore()
+	for _, test := range []struct {
+		opts             []mimage.Option
+		option, conflict string
+	}{
+		{[]mimage.Option{mimage.Unbounded(), mimage.Toroidal()}, "Unbounded", "Toroidal"},
+		{[]mimage.Option{mimage.Storage(store), mimage.Directory(t.TempDir())}, "Storage", "Directory"},
+		{[]mimage.Option{mimage.Storage(store), mimage.SkipUnchanged()}, "SkipUnchanged", "Storage"},
+		{[]mimage.Option{mimage.Storage(store), mimage.KeepHistory()}, "KeepHistory", "Storage"},
+		{[]mimage.Option{mimage.WriteBehind(2)}, "WriteBehind", "Eviction(EvictIdle)"},
+		{[]mimage.Option{mimage.WriteBehind(2), mimage.Eviction(mimage.EvictNever)}, "WriteBehind", "Eviction(EvictNever)"},
+	} {
+		_, err := mimage.New(image.Rect(0, 0, 10, 10), test.opts...)
+		var conflict *mimage.OptionError
+		if !errors.As(err, &conflict) || conflict.Option != test.option || conflict.Conflict != test.conflict {
+			t.Errorf("got %v, want %s conflicting with %s", err, test.option, test.conflict)
+		}
+	}
+}
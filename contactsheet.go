@@ -0,0 +1,109 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+
+	"github.com/fogleman/gg"
+)
+
+// contactSheetGap is the space (in pixels) around each cell of a contact
+// sheet.
+const contactSheetGap = 4
+
+var (
+	contactSheetBackground = color.RGBA{0x30, 0x30, 0x30, 0xff}
+	contactSheetEmpty      = color.RGBA{0x50, 0x50, 0x50, 0xff}
+	contactSheetChecks     = &checkerboard{
+		size: 8,
+		a:    color.RGBA{0xcc, 0xcc, 0xcc, 0xff},
+		b:    color.RGBA{0x99, 0x99, 0x99, 0xff},
+	}
+)
+
+// ContactSheet returns an image of every chunk shrunk to fit cellSize x
+// cellSize & laid out as they are in the image, each labelled with its chunk
+// (x,y), which is a handy way to check over a huge image (see also
+// ChunkBounds). Chunks that have never been drawn on are shown as gray cells
+// with no picture, without being loaded.
+func (m *Mimage) ContactSheet(cellSize int) (image.Image, error) {
+	if cellSize < 1 {
+		return nil, fmt.Errorf("cell size must be at least 1, given %d", cellSize)
+	}
+	if m.bounds.Empty() {
+		return nil, fmt.Errorf("image is empty")
+	}
+
+	cs := m.chunkSize
+	cx0, cy0 := floorDiv(m.bounds.Min.X, cs), floorDiv(m.bounds.Min.Y, cs)
+	cx1, cy1 := floorDiv(m.bounds.Max.X-1, cs), floorDiv(m.bounds.Max.Y-1, cs)
+	step := cellSize + contactSheetGap
+	sheet := image.NewRGBA(image.Rect(0, 0, (cx1-cx0+1)*step+contactSheetGap, (cy1-cy0+1)*step+contactSheetGap))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(contactSheetBackground), image.Point{}, draw.Src)
+
+	// where the chunk goes on the sheet, with the part outside of the image
+	// (for chunks on the edge) cut off
+	cell := func(cx, cy int) (image.Rectangle, image.Rectangle) {
+		cb := m.chunkBounds(cx, cy)
+		r := cb.Intersect(m.bounds)
+		at := image.Pt(contactSheetGap+(cx-cx0)*step, contactSheetGap+(cy-cy0)*step)
+		min := r.Min.Sub(cb.Min).Mul(cellSize).Div(cs)
+		max := r.Max.Sub(cb.Min).Mul(cellSize).Div(cs)
+		return r, image.Rectangle{Min: min, Max: max}.Add(at)
+	}
+
+	errs := make(chan error)
+	work := m.chunksWithin(m.bounds)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < m.routines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for coords := range work {
+				r, dst := cell(coords[0], coords[1])
+				if dst.Empty() {
+					continue
+				}
+				if !m.cache.exists(coords[0], coords[1]) {
+					draw.Draw(sheet, dst, image.NewUniform(contactSheetEmpty), image.Point{}, draw.Src)
+					continue
+				}
+				img, err := m.scaled(r, dst.Dx(), dst.Dy())
+				if err != nil {
+					errs <- err
+					continue
+				}
+				// cells don't overlap, so routines can draw at once
+				draw.Draw(sheet, dst, contactSheetChecks, dst.Min, draw.Src)
+				draw.Draw(sheet, dst, img, image.Point{}, draw.Over)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(errs)
+	}()
+	err := checkErrors(errs)
+	if err != nil {
+		return nil, err
+	}
+
+	dc := gg.NewContextForRGBA(sheet)
+	for cy := cy0; cy <= cy1; cy++ {
+		for cx := cx0; cx <= cx1; cx++ {
+			_, dst := cell(cx, cy)
+			label := fmt.Sprintf("%d,%d", cx, cy)
+			w, h := dc.MeasureString(label)
+			x, y := float64(dst.Min.X), float64(dst.Min.Y)
+			dc.SetColor(color.RGBA{0, 0, 0, 0xa0})
+			dc.DrawRectangle(x, y, w+4, h+4)
+			dc.Fill()
+			dc.SetColor(color.White)
+			dc.DrawStringAnchored(label, x+2, y+2, 0, 1)
+		}
+	}
+	return sheet, nil
+}
@@ -0,0 +1,43 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestContactSheet(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	if _, err := m.ContactSheet(0); err == nil {
+		t.Error("cell size 0 got no error")
+	}
+
+	op := m.Draw()
+	op.SetColor(color.RGBA{255, 0, 0, 255})
+	op.DrawRectangle(32, 32, 32, 32)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sheet, err := m.ContactSheet(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// two cells of 32 pixels each way, with gaps of 4 around them
+	if sheet.Bounds() != image.Rect(0, 0, 76, 76) {
+		t.Fatalf("sheet bounds are %v, want 76x76", sheet.Bounds())
+	}
+	for pt, want := range map[image.Point]color.RGBA{
+		{1, 1}:   {0x30, 0x30, 0x30, 0xff}, // background
+		{34, 34}: {0x50, 0x50, 0x50, 0xff}, // never drawn on
+		{70, 70}: {255, 0, 0, 255},
+	} {
+		if got := sheet.At(pt.X, pt.Y); got != want {
+			t.Errorf("sheet pixel %v is %v, want %v", pt, got, want)
+		}
+	}
+}
@@ -1,12 +1,13 @@
 package mimage
 
 import (
+	"image"
+	"image/draw"
 	"log"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
-
-	"github.com/fogleman/gg"
 )
 
 // context wraps a individual chunk & implements load / unload
@@ -19,10 +20,48 @@ type context struct {
 	chunkSize int
 	edited    bool
 
-	Img      *gg.Context
+	Img      Canvas
 	loadLock *sync.Mutex
 
+	// straight is a copy of the chunk with straight alpha, only set when
+	// the Mimage is in AlphaStraight mode (see alpha.go)
+	straight  *image.NRGBA
+	keepAlpha bool
+
+	// fetch fills in the chunk the first time it's used, if it's not on
+	// disk (see remote.go)
+	fetch Fetcher
+
+	// renderer makes the canvas the chunk is drawn with, nil for gg
+	renderer Renderer
+
+	// codec writes & reads the chunk (see EncodeWith)
+	codec Codec
+
+	// store is where the chunk is kept (see Storage)
+	store ChunkStore
+
+	// clock tells when the chunk has been idle long enough to unload (see
+	// TimeWith)
+	clock Clock
+
+	// users is how many are using (or about to use) the chunk, used the
+	// tick it was last loaded at (see MaxChunks) & released when it was last
+	// done with (in unix nanoseconds, see EvictAfter)
+	users    int32
+	used     uint64
+	released int64
+
+	// queued is set while the chunk is waiting to be written behind, as of
+	// the tick queuedAt (see WriteBehind), which is guarded by the cache's
+	// chunkLock
+	queued   int32
+	queuedAt uint64
+
 	unloadLock *sync.RWMutex
+
+	// stop is closed when the chunk is no longer to be unloaded (see Close)
+	stop <-chan struct{}
 }
 
 // setEdited means on unload() we have to be written to disk
@@ -39,37 +78,102 @@ func (c *context) maybeLoadImage() error {
 		return nil // it's loaded
 	}
 
-	img, err := gg.LoadPNG(c.key)
-	if os.IsNotExist(err) {
-		c.Img = gg.NewContext(c.chunkSize, c.chunkSize)
+	img, err := c.read()
+	if os.IsNotExist(err) && c.fetch != nil {
+		fetched, err := fetchChunk(c.fetch, c.X, c.Y, c.chunkSize)
+		if err != nil {
+			return err
+		}
+		c.Img = c.newCanvas(fetched)
+		if c.keepAlpha {
+			c.straight = image.NewNRGBA(fetched.Bounds())
+			draw.Draw(c.straight, c.straight.Bounds(), fetched, image.Point{}, draw.Src)
+		}
+		c.setEdited() // so it's saved & not fetched again
+		return nil
+	} else if os.IsNotExist(err) {
+		c.Img = c.newCanvas(image.NewRGBA(image.Rect(0, 0, c.chunkSize, c.chunkSize)))
+		if c.keepAlpha {
+			c.straight = image.NewNRGBA(image.Rect(0, 0, c.chunkSize, c.chunkSize))
+		}
 		return nil
 	} else if err != nil {
 		return err
 	}
 
-	c.Img = gg.NewContextForImage(img)
+	rgba := image.NewRGBA(img.Bounds().Sub(img.Bounds().Min))
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	c.Img = c.newCanvas(rgba)
+	if c.keepAlpha {
+		c.straight = image.NewNRGBA(image.Rect(0, 0, c.chunkSize, c.chunkSize))
+		draw.Draw(c.straight, c.straight.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
 	return nil
 }
 
+// newCanvas returns a canvas drawing onto img with the chunk's renderer.
+func (c *context) newCanvas(img *image.RGBA) Canvas {
+	if c.renderer == nil {
+		return ggRenderer{}.NewCanvas(img)
+	}
+	return c.renderer.NewCanvas(img)
+}
+
+// read decodes the chunk from its store.
+func (c *context) read() (image.Image, error) {
+	f, err := c.store.Open(chunkName(c.X, c.Y))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return c.codec.Decode(f, c.X, c.Y)
+}
+
 // unloadImage writes an image chunk to disk (if needed) and
 // removes the reference to it (switching it to nil).
 // If an error occurs we do not remove the image from memory.
 func (c *context) unloadImage() error {
+	c.loadLock.Lock() // for those checking if we're loaded
+	defer c.loadLock.Unlock()
+
 	if c.Img == nil {
 		return nil // it's not loaded
 	}
 	if c.edited { // no point writing to disk unless edited
-		err := c.Img.SavePNG(c.key)
+		err := c.save()
 		if err != nil {
 			return err
 		}
 	}
 	c.Img = nil
+	c.straight = nil
 	return nil
 }
 
+// save writes the chunk to disk, in straight alpha mode we write the
+// straight copy (updated with any drawing) so no precision is lost.
+func (c *context) save() error {
+	var img image.Image = c.Img.Image()
+	if c.straight != nil {
+		reconcile(c.straight, c.Img.Image().(*image.RGBA))
+		img = c.straight
+	}
+
+	f, err := c.store.Create(chunkName(c.X, c.Y))
+	if err != nil {
+		return err
+	}
+	err = c.codec.Encode(f, img, c.X, c.Y)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 // unload loop that continuously attempts to flush in memory chunks
-// to disk & unload them *if* they're not currently in use.
+// to disk & unload them *if* they're not currently in use, & haven't been
+// for the given time (see EvictAfter).
 // We determine this using a RWLock & the below with() and Done() functions
 // (which represent readers telling us "I'm using this chunk!")
 //
@@ -82,16 +186,31 @@ func (c *context) unloadImage() error {
 // using some kind of LRU cache with overarching locking and a more
 // advanced 'clean' / 'flush' approach. Or not .. I dunno maybe
 // I might try that approach later.
-func (c *context) unload() {
+func (c *context) unload(after time.Duration) {
 	// wake up periodically and flush the image to disk when no one is using it
 	var err error
+	wait := after
 	for {
-		time.Sleep(time.Second * 1)
+		select {
+		case <-c.clock.After(wait):
+		case <-c.stop:
+			return
+		}
+		idle := c.clock.Now().Sub(time.Unix(0, atomic.LoadInt64(&c.released)))
+		if atomic.LoadInt32(&c.users) > 0 {
+			wait = after
+			continue
+		} else if idle < after {
+			wait = after - idle
+			continue
+		}
+		wait = after
+
 		c.unloadLock.Lock()
 		err = c.unloadImage()
 		c.unloadLock.Unlock()
 		if err != nil {
-			log.Println("failed to unload image to disk %s: %v", c.key, err)
+			log.Printf("failed to unload image to disk %s: %v\n", c.key, err)
 		}
 	}
 }
@@ -102,20 +221,42 @@ func (c *context) with() error {
 	return c.maybeLoadImage()
 }
 
+// withImage is like with, but sets the image to img (& straight, if we're
+// keeping straight alpha) rather than loading it.
+func (c *context) withImage(img *image.RGBA, straight *image.NRGBA) {
+	c.unloadLock.RLock()
+	c.loadLock.Lock()
+	defer c.loadLock.Unlock()
+
+	c.Img = c.newCanvas(img)
+	c.straight = nil
+	if c.keepAlpha {
+		c.straight = straight
+		if c.straight == nil {
+			c.straight = image.NewNRGBA(img.Bounds())
+			draw.Draw(c.straight, c.straight.Bounds(), img, img.Bounds().Min, draw.Src)
+		}
+	}
+	c.setEdited()
+}
+
 // Done means a user is done with the image, "it can be unloaded"
 func (c *context) Done() {
+	atomic.StoreInt64(&c.released, c.clock.Now().UnixNano())
 	c.unloadLock.RUnlock()
+	atomic.AddInt32(&c.users, -1)
 }
 
 // newContext creates a new context that can be used to access a chunk,
 // the actual image doesn't need to exist on disk nor is it read when this
 // is called.
-func newContext(key string, x, y, chunkSize int) *context {
+func newContext(key string, x, y, chunkSize int, keepAlpha bool) *context {
 	c := &context{
 		key:        key,
 		X:          x,
 		Y:          y,
 		chunkSize:  chunkSize,
+		keepAlpha:  keepAlpha,
 		loadLock:   &sync.Mutex{},
 		unloadLock: &sync.RWMutex{},
 	}
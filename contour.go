@@ -0,0 +1,243 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Contour is a line of equal height.
+type Contour struct {
+	// Level is the height of the line.
+	Level float64
+
+	// Points along the line in world space, running with higher ground on
+	// the right (with y pointing down).
+	Points Path
+
+	// Closed is true if the line is a loop, the last point isn't repeated.
+	Closed bool
+}
+
+// contourEdge identifies where a contour crosses the line between two
+// neighbouring pixels; from (x,y) to the right or down.
+type contourEdge struct {
+	x, y  int
+	down  bool
+	level int // multiple of the interval
+}
+
+// contourSegment is a piece of contour within one cell (the square between
+// four pixel centers).
+type contourSegment struct {
+	a, b   contourEdge
+	pa, pb Point
+}
+
+// Contours returns lines of equal height every interval, treating this image
+// as a heightmap where the gray level (0-255) of each pixel is its height (see
+// also Hillshade). Heights are taken at pixel centers & lines are found with
+// marching squares.
+//
+// Chunks are processed in parallel, reading a one pixel halo, and the pieces
+// found in each are stitched together into whole lines.
+func (m *Mimage) Contours(interval float64) ([]Contour, error) {
+	if interval <= 0 || math.IsNaN(interval) || math.IsInf(interval, 0) {
+		return nil, fmt.Errorf("interval must be greater than zero, given %v", interval)
+	}
+
+	segments := []contourSegment{}
+	lock := &sync.Mutex{}
+	errs := make(chan error)
+	work := m.chunksWithin(m.bounds)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < m.routines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for coords := range work {
+				found, err := m.contourChunk(coords[0], coords[1], interval)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				lock.Lock()
+				segments = append(segments, found...)
+				lock.Unlock()
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(errs)
+	}()
+	err := checkErrors(errs)
+	if err != nil {
+		return nil, err
+	}
+
+	return stitchContours(segments, interval), nil
+}
+
+// contourChunk returns the contour segments of all cells whose top left pixel
+// is in the given chunk.
+func (m *Mimage) contourChunk(cx, cy int, interval float64) ([]contourSegment, error) {
+	cells := m.chunkBounds(cx, cy).Intersect(image.Rectangle{Min: m.bounds.Min, Max: m.bounds.Max.Sub(image.Pt(1, 1))})
+	if cells.Empty() {
+		return nil, nil
+	}
+	halo := image.Rectangle{Min: cells.Min, Max: cells.Max.Add(image.Pt(1, 1))}
+	heights, err := m.heights(halo)
+	if err != nil {
+		return nil, err
+	}
+	hw := halo.Dx()
+	at := func(x, y int) float64 { return heights[(y-halo.Min.Y)*hw+x-halo.Min.X] }
+
+	found := []contourSegment{}
+	for y := cells.Min.Y; y < cells.Max.Y; y++ {
+		for x := cells.Min.X; x < cells.Max.X; x++ {
+			// corners clockwise from the top left, & the edges between them
+			v := [4]float64{at(x, y), at(x+1, y), at(x+1, y+1), at(x, y+1)}
+			lo := math.Min(math.Min(v[0], v[1]), math.Min(v[2], v[3]))
+			hi := math.Max(math.Max(v[0], v[1]), math.Max(v[2], v[3]))
+			for k := int(math.Floor(lo/interval)) + 1; float64(k)*interval <= hi; k++ {
+				found = append(found, contourCell(x, y, v, k, float64(k)*interval)...)
+			}
+		}
+	}
+	return found, nil
+}
+
+// contourCell returns the segments of the contour at level through the cell
+// with its top left at pixel (x,y) & the given corner heights (clockwise from
+// the top left).
+func contourCell(x, y int, v [4]float64, k int, level float64) []contourSegment {
+	// edge i runs from corner i to corner i+1: top, right, bottom, left
+	edges := [4]contourEdge{{x, y, false, k}, {x + 1, y, true, k}, {x, y + 1, false, k}, {x, y, true, k}}
+	corners := [4]Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	above := [4]bool{}
+	for i := range v {
+		above[i] = v[i] >= level
+	}
+
+	cross := func(i int) Point {
+		j := (i + 1) % 4
+		t := (level - v[i]) / (v[j] - v[i])
+		a, b := corners[i], corners[j]
+		return Point{float64(x) + 0.5 + a.X + (b.X-a.X)*t, float64(y) + 0.5 + a.Y + (b.Y-a.Y)*t}
+	}
+	// segment from edge i to edge j, oriented so higher ground is on the right
+	segment := func(i, j int) contourSegment {
+		if above[(i+1)%4] {
+			i, j = j, i
+		}
+		return contourSegment{a: edges[i], b: edges[j], pa: cross(i), pb: cross(j)}
+	}
+
+	crossing := []int{}
+	for i := range edges {
+		if above[i] != above[(i+1)%4] {
+			crossing = append(crossing, i)
+		}
+	}
+
+	switch len(crossing) {
+	case 2:
+		return []contourSegment{segment(crossing[0], crossing[1])}
+	case 4:
+		// a saddle, use the middle of the cell to decide which corners are
+		// cut off from the others
+		center := (v[0]+v[1]+v[2]+v[3])/4 >= level
+		out := []contourSegment{}
+		for c := range v {
+			if above[c] != center {
+				out = append(out, segment((c+3)%4, c))
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// stitchContours joins segments that share an end into whole lines.
+func stitchContours(segments []contourSegment, interval float64) []Contour {
+	// chunks finish in any order, this keeps the output the same every time
+	sort.Slice(segments, func(i, j int) bool { return edgeLess(segments[i].a, segments[j].a) })
+
+	starts := map[contourEdge]int{}
+	for i, s := range segments {
+		starts[s.a] = i
+	}
+	ends := map[contourEdge]int{}
+	for i, s := range segments {
+		ends[s.b] = i
+	}
+
+	used := make([]bool, len(segments))
+	out := []Contour{}
+	for i := range segments {
+		if used[i] {
+			continue
+		}
+
+		// walk back to the start of the line (or all the way round a loop)
+		first := i
+		for {
+			prev, ok := ends[segments[first].a]
+			if !ok || used[prev] || prev == i {
+				break
+			}
+			first = prev
+		}
+
+		c := Contour{Level: float64(segments[first].a.level) * interval, Points: Path{segments[first].pa}}
+		for j := first; ; {
+			used[j] = true
+			next, ok := starts[segments[j].b]
+			if !ok || used[next] {
+				c.Closed = ok && next == first
+				if !c.Closed {
+					c.Points = append(c.Points, segments[j].pb)
+				}
+				break
+			}
+			c.Points = append(c.Points, segments[next].pa)
+			j = next
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// edgeLess orders contour edges by level then position.
+func edgeLess(a, b contourEdge) bool {
+	if a.level != b.level {
+		return a.level < b.level
+	}
+	if a.y != b.y {
+		return a.y < b.y
+	}
+	if a.x != b.x {
+		return a.x < b.x
+	}
+	return !a.down && b.down
+}
+
+// DrawContours adds the lines to the current path, ready to be stroked.
+func (o *operation) DrawContours(contours []Contour) {
+	for _, c := range contours {
+		if len(c.Points) < 2 {
+			continue
+		}
+		o.MoveTo(c.Points[0].X, c.Points[0].Y)
+		for _, p := range c.Points[1:] {
+			o.LineTo(p.X, p.Y)
+		}
+		if c.Closed {
+			o.ClosePath()
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestContours(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(color.Black)
+	op.Clear()
+	// a plateau across all four chunks
+	op.SetColor(color.White)
+	op.DrawRectangle(20, 20, 24, 24)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bad := range []float64{0, -1} {
+		if _, err := m.Contours(bad); err == nil {
+			t.Errorf("interval %v got no error", bad)
+		}
+	}
+
+	contours, err := m.Contours(128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contours) != 1 {
+		t.Fatalf("found %d contours, want 1 stitched across chunks", len(contours))
+	}
+	c := contours[0]
+	if c.Level != 128 || !c.Closed {
+		t.Errorf("contour level %v closed %v, want 128 & closed", c.Level, c.Closed)
+	}
+	for _, p := range c.Points {
+		if p.X < 19.5 || p.X > 44.5 || p.Y < 19.5 || p.Y > 44.5 {
+			t.Errorf("point %v is off the edge of the plateau", p)
+		}
+		if p.X > 20.5 && p.X < 43.5 && p.Y > 20.5 && p.Y < 43.5 {
+			t.Errorf("point %v is inside the plateau", p)
+		}
+	}
+
+	// draw the lines onto a fresh image
+	out := newImage(t, m.Bounds(), mimage.ChunkSize(32))
+	op = out.Draw()
+	op.SetColor(color.RGBA{255, 0, 0, 255})
+	op.DrawContours(contours)
+	op.Stroke()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out.At(20, 32).(color.RGBA); got.R == 0 {
+		t.Errorf("contour not drawn at (20,32), got %v", got)
+	}
+	if got := out.At(32, 32).(color.RGBA); got.A != 0 {
+		t.Errorf("expected nothing drawn inside the contour, got %v", got)
+	}
+}
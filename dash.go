@@ -0,0 +1,91 @@
+package mimage
+
+import (
+	"math"
+)
+
+// DrawDashedRectangle adds the dashes of the outline of a rectangle beginning
+// at (x,y) with width w and height h to the current path, ready to be
+// stroked (eg. selection boxes). Dashes are alternate lengths (in pixels) on
+// & off, repeated all the way around; an odd number of lengths is repeated
+// twice, as in SVG. Without dashes the whole outline is added.
+func (o *operation) DrawDashedRectangle(x, y, w, h float64, dashes ...float64) {
+	o.drawDashed([]Point{{X: x, Y: y}, {X: x + w, Y: y}, {X: x + w, Y: y + h}, {X: x, Y: y + h}}, dashes)
+}
+
+// DrawDashedEllipse adds the dashes of the outline of an ellipse at (x,y)
+// with axis lengths of rx, ry to the current path, ready to be stroked (eg.
+// construction lines, ranges). Dashes are as for DrawDashedRectangle.
+func (o *operation) DrawDashedEllipse(x, y, rx, ry float64, dashes ...float64) {
+	o.drawDashed(ellipsePoints(x, y, rx, ry), dashes)
+}
+
+// ellipsePoints returns points every couple of pixels around the outline of
+// an ellipse.
+func ellipsePoints(x, y, rx, ry float64) []Point {
+	// Ramanujan's approximation of the perimeter
+	perimeter := math.Pi * (3*(rx+ry) - math.Sqrt((3*rx+ry)*(rx+3*ry)))
+	steps := maxInt(16, int(math.Ceil(perimeter/splineStep)))
+	outline := make([]Point, steps)
+	for i := range outline {
+		a := 2 * math.Pi * float64(i) / float64(steps)
+		outline[i] = Point{X: x + rx*math.Cos(a), Y: y + ry*math.Sin(a)}
+	}
+	return outline
+}
+
+// drawDashed queues the dashes of the closed outline (in world space).
+func (o *operation) drawDashed(outline []Point, dashes []float64) {
+	total := 0.0
+	for _, d := range dashes {
+		total += math.Max(0, d)
+	}
+	if total == 0 {
+		o.drawPoints(drawPolygon, outline)
+		return
+	}
+	if len(dashes)%2 == 1 {
+		dashes = append(dashes[:len(dashes):len(dashes)], dashes...)
+	}
+
+	minX, minY, maxX, maxY := Path(outline).bounds()
+	o.minMax(minX, minY)
+	o.minMax(maxX, maxY)
+	o.queue.pushArgs(drawDashes, []interface{}{outline, append([]float64{}, dashes...)})
+}
+
+// traceDashes adds the dashes of the closed outline to the canvas path, moved
+// by the given offset into chunk space. Every chunk walks the whole outline
+// from the start, so dashes line up across chunks.
+func traceDashes(dc Canvas, outline []Point, dashes []float64, offX, offY float64) {
+	dash := 0                      // the current dash (or gap, if odd)
+	left := math.Max(0, dashes[0]) // of the current dash
+	drawing := false               // if a dash has been started
+	for i := range outline {
+		a, b := outline[i], outline[(i+1)%len(outline)]
+		dir, ok := direction(a, b)
+		if !ok {
+			continue
+		}
+		length := math.Hypot(b.X-a.X, b.Y-a.Y)
+
+		at := 0.0 // along this segment
+		for at < length {
+			step := math.Min(left, length-at)
+			if dash%2 == 0 && step > 0 {
+				if !drawing {
+					dc.MoveTo(a.X+dir.X*at-offX, a.Y+dir.Y*at-offY)
+					drawing = true
+				}
+				dc.LineTo(a.X+dir.X*(at+step)-offX, a.Y+dir.Y*(at+step)-offY)
+			}
+			at += step
+			left -= step
+			if left <= 0 {
+				dash = (dash + 1) % len(dashes)
+				left = math.Max(0, dashes[dash])
+				drawing = false
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestDrawDashedRectangle(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	for _, dashes := range [][]float64{{10, 10}, {10}} {
+		m := newImage(t, image.Rect(0, 0, 96, 96), mimage.ChunkSize(32))
+		op := m.Draw()
+		op.SetColor(red)
+		op.DrawDashedRectangle(10.5, 10.5, 80, 70, dashes...)
+		op.Stroke()
+		err := op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// dashes carry on across chunks & around corners
+		for pt, want := range map[image.Point]color.RGBA{
+			{15, 10}: red,
+			{25, 10}: {},
+			{35, 10}: red,
+			{90, 15}: red, // 80 along, the second side starts with a dash
+			{90, 25}: {},
+			{50, 50}: {},
+		} {
+			if got := m.At(pt.X, pt.Y); got != want {
+				t.Errorf("dashes %v: pixel %v is %v, want %v", dashes, pt, got, want)
+			}
+		}
+	}
+}
+
+func TestDrawDashedEllipse(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	m := newImage(t, image.Rect(0, 0, 96, 96), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(red)
+	op.DrawDashedEllipse(48, 48, 30, 30) // no dashes, the whole outline
+	op.Stroke()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	drawn := 0
+	for _, pt := range []image.Point{{78, 48}, {48, 78}, {18, 48}, {48, 18}} {
+		if c := m.At(pt.X, pt.Y).(color.RGBA); c.A > 0 {
+			drawn++
+		}
+	}
+	if drawn != 4 {
+		t.Errorf("%d of 4 points around the outline drawn, want all", drawn)
+	}
+	if got := m.At(48, 48); got != (color.RGBA{}) {
+		t.Errorf("centre of the ellipse is %v, want it untouched", got)
+	}
+}
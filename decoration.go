@@ -0,0 +1,224 @@
+package mimage
+
+import (
+	"math"
+)
+
+// defaultMarkerSize is the size (in pixels) of line decoration markers if
+// none is given
+const defaultMarkerSize = 8.0
+
+// Marker is a shape drawn on a line by a LineDecoration.
+type Marker int
+
+const (
+	// MarkerNone draws nothing.
+	MarkerNone Marker = iota
+
+	// MarkerArrow is a filled arrowhead pointing along the line.
+	MarkerArrow
+
+	// MarkerOpenArrow is a stroked chevron pointing along the line.
+	MarkerOpenArrow
+
+	// MarkerTick is a stroked line across the line.
+	MarkerTick
+
+	// MarkerDot is a filled circle.
+	MarkerDot
+)
+
+// LineDecoration adds markers to lines as they're stroked, eg. arrowheads
+// to show the direction of a flow or ticks along a boundary.
+type LineDecoration struct {
+	// Start & End are drawn at the first & last points of each open line,
+	// arrows pointing outwards.
+	Start, End Marker
+
+	// Repeat is drawn every Spacing pixels along each line (open or closed),
+	// pointing the way the line goes. Nothing is repeated if Spacing is 0.
+	Repeat  Marker
+	Spacing float64
+
+	// Size is the length (& width) of markers in pixels, 8 if not set.
+	Size float64
+}
+
+// SetLineDecoration sets markers added to lines drawn (MoveTo, LineTo,
+// DrawPolyline ..) from now on as they're stroked. Filled markers use the
+// fill color / style & stroked ones the line width. Set an empty
+// LineDecoration to stop decorating.
+func (o *operation) SetLineDecoration(d LineDecoration) {
+	o.maxlineWidth = math.Max(o.maxlineWidth, d.size())
+	o.queue.pushArgs(setLineDecoration, []interface{}{d})
+}
+
+// size returns the size of markers.
+func (d LineDecoration) size() float64 {
+	if d.Size <= 0 {
+		return defaultMarkerSize
+	}
+	return d.Size
+}
+
+// empty returns if the decoration draws nothing.
+func (d LineDecoration) empty() bool {
+	return d.Start == MarkerNone && d.End == MarkerNone && (d.Repeat == MarkerNone || d.Spacing <= 0)
+}
+
+// tracedPath follows the lines of the current path (in chunk space) while
+// lines are decorated, as the canvas doesn't give them back.
+type tracedPath struct {
+	lines  [][]Point
+	closed []bool
+}
+
+// moveTo starts a new line.
+func (t *tracedPath) moveTo(x, y float64) {
+	t.lines = append(t.lines, []Point{{X: x, Y: y}})
+	t.closed = append(t.closed, false)
+}
+
+// lineTo continues the current line, or starts one.
+func (t *tracedPath) lineTo(x, y float64) {
+	if len(t.lines) == 0 {
+		t.moveTo(x, y)
+		return
+	}
+	t.lines[len(t.lines)-1] = append(t.lines[len(t.lines)-1], Point{X: x, Y: y})
+}
+
+// closePath closes the current line.
+func (t *tracedPath) closePath() {
+	if len(t.closed) > 0 {
+		t.closed[len(t.closed)-1] = true
+	}
+}
+
+// points adds a line through the given points (in world space) moved into
+// chunk space.
+func (t *tracedPath) points(points []Point, offX, offY float64, closed bool) {
+	for i, p := range points {
+		if i == 0 {
+			t.moveTo(p.X-offX, p.Y-offY)
+		} else {
+			t.lineTo(p.X-offX, p.Y-offY)
+		}
+	}
+	if closed {
+		t.closePath()
+	}
+}
+
+// clear forgets all lines, as the canvas does after a Fill or Stroke.
+func (t *tracedPath) clear() {
+	t.lines, t.closed = nil, nil
+}
+
+// render draws the decoration's markers on the traced lines.
+func (d LineDecoration) render(dc Canvas, t *tracedPath) {
+	type placed struct {
+		marker  Marker
+		at, dir Point
+		atTip   bool
+	}
+	markers := []placed{}
+
+	for i, line := range t.lines {
+		if t.closed[i] && len(line) > 1 {
+			line = append(line[:len(line):len(line)], line[0])
+		}
+		if len(line) < 2 {
+			continue
+		}
+		if !t.closed[i] {
+			if dir, ok := direction(line[1], line[0]); ok && d.Start != MarkerNone {
+				markers = append(markers, placed{d.Start, line[0], dir, true})
+			}
+			n := len(line) - 1
+			if dir, ok := direction(line[n-1], line[n]); ok && d.End != MarkerNone {
+				markers = append(markers, placed{d.End, line[n], dir, true})
+			}
+		}
+		if d.Repeat == MarkerNone || d.Spacing <= 0 {
+			continue
+		}
+
+		next := d.Spacing / 2 // distance along the line of the next marker
+		travelled := 0.0
+		for j := 1; j < len(line); j++ {
+			a, b := line[j-1], line[j]
+			length := math.Hypot(b.X-a.X, b.Y-a.Y)
+			dir, ok := direction(a, b)
+			if !ok {
+				continue
+			}
+			for ; next <= travelled+length; next += d.Spacing {
+				s := next - travelled
+				markers = append(markers, placed{d.Repeat, Point{X: a.X + dir.X*s, Y: a.Y + dir.Y*s}, dir, false})
+			}
+			travelled += length
+		}
+	}
+
+	// filled markers then stroked ones, each as one path
+	size := d.size()
+	for _, filled := range []bool{true, false} {
+		drawn := false
+		for _, m := range markers {
+			isFilled := m.marker == MarkerArrow || m.marker == MarkerDot
+			if isFilled != filled {
+				continue
+			}
+			drawMarker(dc, m.marker, m.at, m.dir, size, m.atTip)
+			drawn = true
+		}
+		if !drawn {
+			continue
+		}
+		if filled {
+			dc.Fill()
+		} else {
+			dc.Stroke()
+		}
+	}
+}
+
+// drawMarker adds the marker at the given point, pointing in the (unit)
+// direction, to the path. Arrows have their tip on the point if atTip,
+// otherwise they're centered on it.
+func drawMarker(dc Canvas, m Marker, at, dir Point, size float64, atTip bool) {
+	tip := at
+	if !atTip {
+		tip = Point{X: at.X + dir.X*size/2, Y: at.Y + dir.Y*size/2}
+	}
+	back := Point{X: tip.X - dir.X*size, Y: tip.Y - dir.Y*size}
+	side := Point{X: -dir.Y * size / 2, Y: dir.X * size / 2}
+
+	switch m {
+	case MarkerArrow:
+		dc.MoveTo(tip.X, tip.Y)
+		dc.LineTo(back.X+side.X, back.Y+side.Y)
+		dc.LineTo(back.X-side.X, back.Y-side.Y)
+		dc.ClosePath()
+	case MarkerOpenArrow:
+		dc.MoveTo(back.X+side.X, back.Y+side.Y)
+		dc.LineTo(tip.X, tip.Y)
+		dc.LineTo(back.X-side.X, back.Y-side.Y)
+	case MarkerTick:
+		dc.MoveTo(at.X+side.X, at.Y+side.Y)
+		dc.LineTo(at.X-side.X, at.Y-side.Y)
+	case MarkerDot:
+		dc.DrawEllipse(at.X, at.Y, size/2, size/2)
+	}
+}
+
+// direction returns the unit vector from a to b, & false if they're the
+// same point.
+func direction(a, b Point) (Point, bool) {
+	length := math.Hypot(b.X-a.X, b.Y-a.Y)
+	if length == 0 {
+		return Point{}, false
+	}
+	return Point{X: (b.X - a.X) / length, Y: (b.Y - a.Y) / length}, true
+}
@@ -0,0 +1,53 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestSetLineDecoration(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 96, 96), mimage.ChunkSize(32))
+	red := color.RGBA{255, 0, 0, 255}
+	op := m.Draw()
+	op.SetColor(red)
+
+	// a dot at the start, reaching into the chunk before the line, & an
+	// arrowhead at the end
+	op.SetLineDecoration(mimage.LineDecoration{Start: mimage.MarkerDot, End: mimage.MarkerArrow, Size: 16})
+	op.MoveTo(36.5, 50.5)
+	op.LineTo(80, 50.5)
+	op.Stroke()
+
+	// ticks along a line
+	op.SetLineDecoration(mimage.LineDecoration{Repeat: mimage.MarkerTick, Spacing: 20, Size: 10})
+	op.DrawPolyline([]mimage.Point{{X: 10.5, Y: 20.5}, {X: 90.5, Y: 20.5}})
+	op.Stroke()
+
+	// & no more
+	op.SetLineDecoration(mimage.LineDecoration{})
+	op.MoveTo(10.5, 80.5)
+	op.LineTo(90.5, 80.5)
+	op.Stroke()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for pt, want := range map[image.Point]color.RGBA{
+		{30, 50}: red, // the dot
+		{72, 53}: red, // the arrowhead
+		{50, 53}: {},  // beside the line
+		{40, 17}: red, // a tick, every 20 pixels from 10 along
+		{60, 24}: red,
+		{30, 17}: {},
+		{80, 83}: {}, // undecorated
+		{80, 80}: red,
+	} {
+		if got := m.At(pt.X, pt.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", pt, got, want)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package mimage
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// snapshotDir holds the checksums of chunks at each snapshot
+const snapshotDir = ".mimage_snapshots"
+
+// Snapshot records the state of every chunk under the given name, so that
+// what changes afterwards can be exported with ExportDelta. Only checksums
+// are kept, not copies of the chunks. Taking a snapshot with the name of an
+// existing one replaces it.
+func (m *Mimage) Snapshot(name string) error {
+	path, err := m.snapshotPath(name)
+	if err != nil {
+		return err
+	}
+	sums, err := m.chunkChecksums()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(sums)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0750)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0640)
+}
+
+// ExportDelta writes a tar archive of the chunks that have changed (or been
+// made) since the named snapshot, along with the metadata. Applying it with
+// ApplyDelta to a copy of the image as it was at the snapshot brings the copy
+// up to date.
+func (m *Mimage) ExportDelta(snapshot string, w io.Writer) error {
+	path, err := m.snapshotPath(snapshot)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("snapshot %s not found", snapshot)
+	} else if err != nil {
+		return err
+	}
+	old := map[string]string{}
+	err = json.Unmarshal(data, &old)
+	if err != nil {
+		return err
+	}
+	sums, err := m.chunkChecksums() // flushes everything to disk
+	if err != nil {
+		return err
+	}
+
+	changed := []string{}
+	for name, sum := range sums {
+		if old[name] != sum {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	names := append([]string{metafile}, changed...)
+
+	tw := tar.NewWriter(w)
+	for _, name := range names {
+		data, err := ioutil.ReadFile(filepath.Join(m.root, name))
+		if err != nil {
+			return err
+		}
+		err = tw.WriteHeader(&tar.Header{Name: name, Mode: 0640, Size: int64(len(data))})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// ApplyDelta reads an archive written by ExportDelta, replacing the chunks in
+// it & taking the bounds & annotations from its metadata. The image is
+// expected to be as it was when the snapshot the delta was made from was
+// taken, & to have the same chunk size.
+func (m *Mimage) ApplyDelta(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if hdr.Name == metafile {
+			err = m.applyMetadata(tr)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		x, y, ok := parseChunkName(hdr.Name)
+		if !ok {
+			return fmt.Errorf("unexpected file %s in delta", hdr.Name)
+		}
+		err = m.applyDeltaChunk(x, y, tr)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// applyMetadata takes the bounds & annotations from the given metadata.
+func (m *Mimage) applyMetadata(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	meta, err := decodeJSON(data)
+	if err != nil {
+		return err
+	}
+	if meta.ChunkSize != m.chunkSize {
+		return fmt.Errorf("metadata has chunk size %d, expected %d", meta.ChunkSize, m.chunkSize)
+	}
+
+	m.metaLock.Lock()
+	defer m.metaLock.Unlock()
+	m.bounds = image.Rect(meta.BoundsMinX, meta.BoundsMinY, meta.BoundsMaxX, meta.BoundsMaxY)
+	m.annotations = meta.Annotations
+	return m.writeMetadata()
+}
+
+// applyDeltaChunk replaces the chunk at (x,y) with the chunk file read from
+// r.
+func (m *Mimage) applyDeltaChunk(x, y int, r io.Reader) error {
+	img, err := chunkCodec(m.codec).Decode(r, x, y)
+	if err != nil {
+		return fmt.Errorf("decoding chunk %d,%d: %v", x, y, err)
+	}
+	if img.Bounds().Dx() != m.chunkSize || img.Bounds().Dy() != m.chunkSize {
+		return fmt.Errorf("delta chunk %d,%d is %v, expected %dx%d", x, y, img.Bounds().Size(), m.chunkSize, m.chunkSize)
+	}
+
+	ctx, err := m.cache.Load(x, y)
+	defer ctx.Done()
+	if err != nil {
+		return err
+	}
+	dst := ctx.Img.Image().(*image.RGBA)
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
+	if ctx.straight != nil {
+		draw.Draw(ctx.straight, ctx.straight.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+	ctx.setEdited()
+	return nil
+}
+
+// chunkChecksums flushes the image & returns the checksum of every chunk
+// file by name.
+func (m *Mimage) chunkChecksums() (map[string]string, error) {
+	err := m.Flush()
+	if err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(m.root)
+	if err != nil {
+		return nil, err
+	}
+
+	sums := map[string]string{}
+	for _, info := range infos {
+		if _, _, ok := parseChunkName(info.Name()); !ok || info.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(m.root, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		sums[info.Name()] = checksum(data)
+	}
+	return sums, nil
+}
+
+// snapshotPath returns where the named snapshot is kept.
+func (m *Mimage) snapshotPath(name string) (string, error) {
+	err := m.needsDirectory("a snapshot")
+	if err != nil {
+		return "", err
+	}
+	err = checkName("snapshot", name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(m.root, snapshotDir, name+".json"), nil
+}
+
+// checkName returns an error if name can't be used as the name of a file
+// (for a snapshot, branch & so on).
+func checkName(what, name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return fmt.Errorf("invalid %s name %q", what, name)
+	}
+	return nil
+}
+
+// parseChunkName returns the chunk x,y of the given chunk file name, or false
+// if it isn't one.
+func parseChunkName(name string) (int, int, bool) {
+	var x, y int
+	_, err := fmt.Sscanf(name, "%d.%d.png", &x, &y)
+	return x, y, err == nil && chunkName(x, y) == name
+}
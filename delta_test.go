@@ -0,0 +1,86 @@
+package mimage_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestDelta(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.Clear()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Snapshot("v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a copy of the image as it is at v1
+	dir := t.TempDir()
+	_, err = mimage.Sync(mimage.DirStore(m.Directory()), mimage.DirStore(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	red := color.RGBA{255, 0, 0, 255}
+	op.SetColor(red)
+	op.DrawRectangle(40, 40, 8, 8)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	err = m.ExportDelta("v1", buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if want := []string{".mimage_metadata.json", "1.1.png"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("delta holds %v, want %v", names, want)
+	}
+
+	copied, err := mimage.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copied.Close()
+	err = copied.ApplyDelta(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pt, want := range map[image.Point]color.RGBA{{44, 44}: red, {10, 10}: {255, 255, 255, 255}} {
+		if got := copied.At(pt.X, pt.Y); got != want {
+			t.Errorf("pixel %v after applying the delta is %v, want %v", pt, got, want)
+		}
+	}
+
+	if err := m.ExportDelta("v2", &bytes.Buffer{}); err == nil {
+		t.Error("exporting from a missing snapshot got no error")
+	}
+	if err := m.Snapshot("../v1"); err == nil {
+		t.Error("snapshot with a path as its name got no error")
+	}
+}
@@ -0,0 +1,75 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+// speckled is dark on the left half, light on the right with a few specks
+// of noise, one on the seam between chunks.
+func speckled(t *testing.T, speck color.Color) *mimage.Mimage {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(color.RGBA{50, 50, 50, 255})
+	op.DrawRectangle(0, 0, 32, 64)
+	op.Fill()
+	op.SetColor(color.RGBA{200, 200, 200, 255})
+	op.DrawRectangle(32, 0, 32, 64)
+	op.Fill()
+	op.SetColor(speck)
+	op.DrawRectangle(10, 10, 1, 1)
+	op.Fill()
+	op.DrawRectangle(31, 31, 1, 1)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMedian(t *testing.T) {
+	m := speckled(t, color.RGBA{255, 255, 255, 255})
+	err := m.Median(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pt, want := range map[image.Point]color.RGBA{
+		{10, 10}: {50, 50, 50, 255},
+		{31, 31}: {50, 50, 50, 255},
+		{31, 40}: {50, 50, 50, 255}, // the edge stays put
+		{32, 40}: {200, 200, 200, 255},
+	} {
+		if got := m.At(pt.X, pt.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", pt, got, want)
+		}
+	}
+
+	if err := m.Median(0); err == nil {
+		t.Error("zero radius got no error")
+	}
+}
+
+func TestBilateral(t *testing.T) {
+	m := speckled(t, color.RGBA{70, 70, 70, 255})
+	err := m.Bilateral(2, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the speck is evened out, the edge is kept
+	if got := m.At(10, 10).(color.RGBA); got.R > 55 {
+		t.Errorf("speck is %v after smoothing, want it near the background", got)
+	}
+	for pt, want := range map[image.Point]uint8{{31, 40}: 50, {32, 40}: 200} {
+		if got := m.At(pt.X, pt.Y).(color.RGBA); absDiff(got.R, want) > 2 {
+			t.Errorf("pixel %v on the edge is %v, want about %d", pt, got, want)
+		}
+	}
+
+	if err := m.Bilateral(0, 30); err == nil {
+		t.Error("zero sigma got no error")
+	}
+}
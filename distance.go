@@ -0,0 +1,310 @@
+package mimage
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// maskAlpha is how opaque a mask pixel must be to count as part of a shape,
+// when measuring distances to shapes
+const maskAlpha = 128
+
+// distanceInf stands in for an infinite (squared) distance
+const distanceInf = 1e20
+
+// distanceScale is the steps per pixel distances are stored in, so the 24
+// bits of rgb hold distances up to maxDistance pixels
+const distanceScale = 256
+
+// maxDistance is the furthest distance DistanceTransform records, pixels
+// further from shapes (or with no shapes at all) are given this
+const maxDistance = float64(maxLabels) / distanceScale
+
+// noShape marks a column having no shapes above (or below) a pixel when
+// measuring distances down columns
+const noShape = maxLabels
+
+// DistanceTransform returns a new Mimage the size of this one where each
+// pixel holds the (Euclidean) distance in pixels to the nearest shape in mask
+// (its pixels at least half opaque), readable with DistanceOf, eg. for
+// coastline effects, glows that fade with distance & procedural shading. The
+// mask stays where it is in world space & may be this image.
+//
+// Distances are measured in two passes; down each column & then along each
+// row. Columns are swept over a row of chunks at a time, carrying what's
+// been seen above (or below) between rows, then each chunk is measured along
+// its rows, reading only as far either side as the nearest shape could be.
+// Distances are stored to 1/256 of a pixel & are at most 65535 pixels.
+func (m *Mimage) DistanceTransform(mask *Mimage) (*Mimage, error) {
+	// distances down each column, stored like labels (see labelRGB)
+	columns, found, err := m.columnDistances(mask)
+	if err != nil {
+		return nil, err
+	}
+	defer columns.Close()
+
+	out, err := New(m.bounds, ChunkSize(m.chunkSize), OperationRoutines(m.routines))
+	if err != nil {
+		return nil, err
+	}
+	return out, out.eachChunk(m.bounds, func(ctx *context) error {
+		cb := out.chunkBounds(ctx.X, ctx.Y)
+		area := cb.Intersect(m.bounds)
+		if area.Empty() {
+			return nil
+		}
+		var dist []float64
+		if found {
+			d, err := rowDistances(columns, area)
+			if err != nil {
+				return err
+			}
+			dist = d
+		}
+
+		img := ctx.Img.Image().(*image.RGBA)
+		w := area.Dx()
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			i := img.PixOffset(area.Min.X-cb.Min.X, y-cb.Min.Y)
+			for x := area.Min.X; x < area.Max.X; x++ {
+				p := img.Pix[i : i+4 : i+4]
+				i += 4
+				d := maxDistance
+				if found {
+					d = math.Sqrt(dist[(y-area.Min.Y)*w+x-area.Min.X])
+				}
+				p[0], p[1], p[2] = labelRGB(int32(math.Min(maxLabels, math.Round(d*distanceScale))))
+				p[3] = 0xff
+			}
+		}
+		ctx.setEdited()
+		return nil
+	})
+}
+
+// DistanceOf returns the distance (in pixels) held by a pixel of the image
+// returned by DistanceTransform.
+func DistanceOf(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	return float64(int(r>>8)<<16|int(g>>8)<<8|int(b>>8)) / distanceScale
+}
+
+// columnDistances returns a new Mimage the size of this one holding the
+// distance from each pixel to the nearest shape in mask in the same column,
+// or noShape, & if there were any shapes at all.
+func (m *Mimage) columnDistances(mask *Mimage) (*Mimage, bool, error) {
+	out, err := New(m.bounds, ChunkSize(m.chunkSize), OperationRoutines(m.routines))
+	if err != nil {
+		return nil, false, err
+	}
+	size := m.chunkSize
+	top, bottom := floorDiv(m.bounds.Min.Y, size), floorDiv(m.bounds.Max.Y-1, size)
+
+	// the last shape seen in each column, sweeping down a row of chunks at a
+	// time; chunks of a row are done in parallel as they don't share columns
+	last := make([]int, m.bounds.Dx())
+	for i := range last {
+		last[i] = math.MinInt32
+	}
+	for cy := top; cy <= bottom; cy++ {
+		row := image.Rect(m.bounds.Min.X, cy*size, m.bounds.Max.X, (cy+1)*size).Intersect(m.bounds)
+		err := out.eachChunk(row, func(ctx *context) error {
+			cb := out.chunkBounds(ctx.X, ctx.Y)
+			area := cb.Intersect(row)
+			alpha, err := mask.Mask(area)
+			if err != nil {
+				return err
+			}
+
+			img := ctx.Img.Image().(*image.RGBA)
+			for x := area.Min.X; x < area.Max.X; x++ {
+				seen := last[x-m.bounds.Min.X]
+				for y := area.Min.Y; y < area.Max.Y; y++ {
+					if alpha.Pix[alpha.PixOffset(x-area.Min.X, y-area.Min.Y)] >= maskAlpha {
+						seen = y
+					}
+					d := int32(noShape)
+					if seen != math.MinInt32 {
+						d = int32(minInt(y-seen, noShape-1))
+					}
+					p := img.Pix[img.PixOffset(x-cb.Min.X, y-cb.Min.Y):]
+					p[0], p[1], p[2] = labelRGB(d)
+					p[3] = 0xff
+				}
+				last[x-m.bounds.Min.X] = seen
+			}
+			ctx.setEdited()
+			return nil
+		})
+		if err != nil {
+			return out, false, err
+		}
+	}
+
+	found := false
+	for _, seen := range last {
+		if seen != math.MinInt32 {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return out, false, nil
+	}
+
+	// then sweep back up, shapes being where the distance down is 0
+	next := last
+	for i := range next {
+		next[i] = math.MaxInt32
+	}
+	for cy := bottom; cy >= top; cy-- {
+		row := image.Rect(m.bounds.Min.X, cy*size, m.bounds.Max.X, (cy+1)*size).Intersect(m.bounds)
+		err := out.eachChunk(row, func(ctx *context) error {
+			cb := out.chunkBounds(ctx.X, ctx.Y)
+			area := cb.Intersect(row)
+
+			img := ctx.Img.Image().(*image.RGBA)
+			for x := area.Min.X; x < area.Max.X; x++ {
+				seen := next[x-m.bounds.Min.X]
+				for y := area.Max.Y - 1; y >= area.Min.Y; y-- {
+					p := img.Pix[img.PixOffset(x-cb.Min.X, y-cb.Min.Y):]
+					d := rgbLabel(p)
+					if d == 0 {
+						seen = y
+					}
+					if seen != math.MaxInt32 && int32(seen-y) < d {
+						p[0], p[1], p[2] = labelRGB(int32(seen - y))
+					}
+				}
+				next[x-m.bounds.Min.X] = seen
+			}
+			ctx.setEdited()
+			return nil
+		})
+		if err != nil {
+			return out, false, err
+		}
+	}
+	return out, true, nil
+}
+
+// rowDistances returns the squared distance from each pixel within r to the
+// nearest shape, given the distances down each column (of which there must
+// be some). Rows are read further either side of r until they reach the
+// furthest distance found, beyond that nothing could be nearer.
+func rowDistances(columns *Mimage, r image.Rectangle) ([]float64, error) {
+	bounds := columns.Bounds()
+	span := r
+	for {
+		img, err := columns.Image(span)
+		if err != nil {
+			return nil, err
+		}
+		rgba := img.(*image.RGBA)
+
+		w := span.Dx()
+		f, d := make([]float64, w), make([]float64, w)
+		v, z := make([]int, w), make([]float64, w+1)
+		out := make([]float64, r.Dx()*r.Dy())
+		far := 0.0
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := 0; x < w; x++ {
+				c := rgbLabel(rgba.Pix[rgba.PixOffset(x, y-span.Min.Y):])
+				if c == noShape {
+					f[x] = distanceInf
+				} else {
+					f[x] = float64(c) * float64(c)
+				}
+			}
+			distance1D(f, d, v, z)
+			copy(out[(y-r.Min.Y)*r.Dx():(y-r.Min.Y+1)*r.Dx()], d[r.Min.X-span.Min.X:r.Max.X-span.Min.X])
+			for _, dx := range d[r.Min.X-span.Min.X : r.Max.X-span.Min.X] {
+				far = math.Max(far, dx)
+			}
+		}
+
+		// widen the rows if something nearer could be beyond them
+		reach := int(math.Ceil(math.Sqrt(far)))
+		if far >= distanceInf {
+			// no shape in reach yet, look twice as far
+			reach = 2 * maxInt(r.Min.X-span.Min.X, maxInt(span.Max.X-r.Max.X, r.Dx()))
+		}
+		wider := image.Rect(r.Min.X-reach, r.Min.Y, r.Max.X+reach, r.Max.Y).Intersect(bounds)
+		if wider.In(span) {
+			return out, nil
+		}
+		span = wider
+	}
+}
+
+// squaredDistances returns the squared distance (in pixels) from each pixel
+// of the mask to the nearest pixel at least half opaque, or distanceInf if
+// there are none. Distances are exact (Euclidean), found with the two pass
+// algorithm of Felzenszwalb & Huttenlocher, "Distance Transforms of Sampled
+// Functions".
+func squaredDistances(mask *image.Alpha) []float64 {
+	w, h := mask.Rect.Dx(), mask.Rect.Dy()
+	out := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if mask.Pix[y*mask.Stride+x] >= maskAlpha {
+				out[y*w+x] = 0
+			} else {
+				out[y*w+x] = distanceInf
+			}
+		}
+	}
+
+	n := maxInt(w, h)
+	f, d := make([]float64, n), make([]float64, n)
+	v, z := make([]int, n), make([]float64, n+1)
+
+	// down each column, then along each row
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			f[y] = out[y*w+x]
+		}
+		distance1D(f[:h], d[:h], v, z)
+		for y := 0; y < h; y++ {
+			out[y*w+x] = d[y]
+		}
+	}
+	for y := 0; y < h; y++ {
+		copy(f[:w], out[y*w:(y+1)*w])
+		distance1D(f[:w], d[:w], v, z)
+		copy(out[y*w:(y+1)*w], d[:w])
+	}
+	return out
+}
+
+// distance1D sets d to the lower envelope of the parabolas rooted at f, ie.
+// d[q] = min over p of (q-p)^2 + f[p]. v & z are scratch space of at least
+// len(f) & len(f)+1.
+func distance1D(f, d []float64, v []int, z []float64) {
+	n := len(f)
+	if n == 0 {
+		return
+	}
+	k := 0
+	v[0] = 0
+	z[0], z[1] = math.Inf(-1), math.Inf(1)
+	for q := 1; q < n; q++ {
+		s := ((f[q] + float64(q*q)) - (f[v[k]] + float64(v[k]*v[k]))) / float64(2*q-2*v[k])
+		for s <= z[k] {
+			k--
+			s = ((f[q] + float64(q*q)) - (f[v[k]] + float64(v[k]*v[k]))) / float64(2*q-2*v[k])
+		}
+		k++
+		v[k] = q
+		z[k], z[k+1] = s, math.Inf(1)
+	}
+	k = 0
+	for q := 0; q < n; q++ {
+		for z[k+1] < float64(q) {
+			k++
+		}
+		dq := float64(q - v[k])
+		d[q] = dq*dq + f[v[k]]
+	}
+}
@@ -0,0 +1,70 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestDistanceTransform(t *testing.T) {
+	before := runtime.NumGoroutine()
+	m, err := mimage.New(image.Rect(0, 0, 96, 96), mimage.ChunkSize(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.DrawRectangle(40, 10, 1, 1)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dist, err := m.DistanceTransform(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// measured across chunks, down columns & along rows
+	for pt, want := range map[image.Point]float64{{40, 10}: 0, {40, 50}: 40, {70, 10}: 30, {70, 50}: 50, {0, 10}: 40} {
+		if got := mimage.DistanceOf(dist.At(pt.X, pt.Y)); math.Abs(got-want) > 0.01 {
+			t.Errorf("distance at %v is %v, want %v", pt, got, want)
+		}
+	}
+	err = dist.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// no shapes at all, everything is as far as can be
+	empty, err := mimage.New(image.Rect(0, 0, 96, 96), mimage.ChunkSize(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	far, err := m.DistanceTransform(empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mimage.DistanceOf(far.At(5, 5)); got < 65535 {
+		t.Errorf("distance without shapes is %v, want the furthest recorded", got)
+	}
+	for _, closed := range []*mimage.Mimage{far, empty} {
+		err = closed.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the column distances are closed with the rest
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := settledGoroutines(before); n > before {
+		t.Errorf("%d goroutines running after Close, %d before", n, before)
+	}
+}
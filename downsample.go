@@ -0,0 +1,128 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sync"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// downsample returns the region r (in world space) shrunk by an integer
+// factor, where each pixel is the average of a factor x factor box of the
+// image (starting at r.Min). Boxes partly outside of the image are averaged
+// over the pixels they do cover.
+//
+// Chunks are read in parallel & only the (small) result is held in memory.
+func (m *Mimage) downsample(r image.Rectangle, factor int) (*image.RGBA, error) {
+	if factor < 1 {
+		factor = 1
+	}
+	ow, oh := (r.Dx()+factor-1)/factor, (r.Dy()+factor-1)/factor
+
+	// running totals of premultiplied channels & pixel counts
+	sums := make([]uint64, ow*oh*5)
+	lock := &sync.Mutex{}
+
+	area := r.Intersect(m.bounds)
+	err := m.eachChunk(area, func(ctx *context) error {
+		cb := m.chunkBounds(ctx.X, ctx.Y)
+		img := ctx.Img.Image().(*image.RGBA)
+		in := area.Intersect(cb)
+		if in.Empty() {
+			return nil
+		}
+
+		// output pixels covered by this chunk
+		ox0, oy0 := (in.Min.X-r.Min.X)/factor, (in.Min.Y-r.Min.Y)/factor
+		ox1, oy1 := (in.Max.X-1-r.Min.X)/factor, (in.Max.Y-1-r.Min.Y)/factor
+		lw := ox1 - ox0 + 1
+		local := make([]uint64, lw*(oy1-oy0+1)*5)
+
+		for y := in.Min.Y; y < in.Max.Y; y++ {
+			row := ((y-r.Min.Y)/factor - oy0) * lw
+			i := img.PixOffset(in.Min.X-cb.Min.X, y-cb.Min.Y)
+			for x := in.Min.X; x < in.Max.X; x++ {
+				s := local[(row+(x-r.Min.X)/factor-ox0)*5:]
+				p := img.Pix[i : i+4 : i+4]
+				s[0] += uint64(p[0])
+				s[1] += uint64(p[1])
+				s[2] += uint64(p[2])
+				s[3] += uint64(p[3])
+				s[4]++
+				i += 4
+			}
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		for oy := oy0; oy <= oy1; oy++ {
+			for ox := ox0; ox <= ox1; ox++ {
+				src := local[((oy-oy0)*lw+ox-ox0)*5:]
+				dst := sums[(oy*ow+ox)*5:]
+				for c := 0; c < 5; c++ {
+					dst[c] += src[c]
+				}
+			}
+		}
+
+		return nil
+	})
+
+	out := image.NewRGBA(image.Rect(0, 0, ow, oh))
+	for i := 0; i < ow*oh; i++ {
+		s := sums[i*5 : i*5+5]
+		if s[4] == 0 {
+			continue
+		}
+		for c := 0; c < 4; c++ {
+			out.Pix[i*4+c] = uint8((s[c] + s[4]/2) / s[4])
+		}
+	}
+
+	return out, err
+}
+
+// scaled returns region r (in world space) scaled to ow x oh. Big regions are
+// first box filtered by a whole factor as they're read, so only about the
+// output size is held in memory.
+func (m *Mimage) scaled(r image.Rectangle, ow, oh int) (*image.RGBA, error) {
+	factor := maxInt(1, minInt(r.Dx()/ow, r.Dy()/oh))
+	img, err := m.downsample(r, factor)
+	if err != nil {
+		return nil, err
+	}
+	if img.Bounds().Dx() == ow && img.Bounds().Dy() == oh {
+		return img, nil
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, ow, oh))
+	xdraw.CatmullRom.Scale(out, out.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+	return out, nil
+}
+
+// Thumbnail returns a small preview of the whole image, scaled (keeping its
+// aspect ratio) so that neither side is longer than maxDim. The image is box
+// filtered as chunks are read, so only about the size of the preview is held
+// in memory.
+func (m *Mimage) Thumbnail(maxDim int) (image.Image, error) {
+	if maxDim < 1 {
+		return nil, fmt.Errorf("thumbnail size must be at least 1, given %d", maxDim)
+	}
+	w, h := m.bounds.Dx(), m.bounds.Dy()
+	if w == 0 || h == 0 {
+		return nil, fmt.Errorf("image is empty")
+	}
+
+	tw, th := maxDim, maxDim
+	if w > h {
+		th = maxInt(1, int(math.Round(float64(h)*float64(maxDim)/float64(w))))
+	} else {
+		tw = maxInt(1, int(math.Round(float64(w)*float64(maxDim)/float64(h))))
+	}
+	if tw >= w && th >= h {
+		return m.Image(m.bounds) // it's small already
+	}
+	return m.scaled(m.bounds, tw, th)
+}
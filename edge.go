@@ -0,0 +1,92 @@
+package mimage
+
+import (
+	"image"
+	"image/color"
+)
+
+// EdgeMode determines what is read from outside of the bounds of an image.
+type EdgeMode int
+
+const (
+	// EdgeTransparent reads transparent black, the default.
+	EdgeTransparent EdgeMode = iota
+
+	// EdgeClamp repeats the nearest edge pixel.
+	EdgeClamp
+
+	// EdgeWrap wraps around to the other side, as if the image were tiled,
+	// which is handy for making tileable textures.
+	EdgeWrap
+
+	// EdgeMirror reflects the image back on itself at each edge.
+	EdgeMirror
+)
+
+// Edges sets what is read from outside of the image by At, AtOk, Sample &
+// when resampling (Warp, RotateArbitrary, Undistort). The default is
+// EdgeTransparent.
+func Edges(mode EdgeMode) Option {
+	return func(m *Mimage) error {
+		m.edges = mode
+		return nil
+	}
+}
+
+// EdgeMode returns what is read from outside of this image.
+func (m *Mimage) EdgeMode() EdgeMode { return m.edges }
+
+// edgePoint returns the pixel within bounds that is read for (x,y) given the
+// edge mode, or false if (x,y) is outside & reads as transparent.
+func (m *Mimage) edgePoint(x, y int) (int, int, bool) {
+	b := m.bounds
+	if image.Pt(x, y).In(b) {
+		return x, y, true
+	}
+	if b.Empty() {
+		return 0, 0, false
+	}
+
+	switch m.edges {
+	case EdgeClamp:
+		return clampInt(x, b.Min.X, b.Max.X-1), clampInt(y, b.Min.Y, b.Max.Y-1), true
+	case EdgeWrap:
+		return b.Min.X + positiveMod(x-b.Min.X, b.Dx()), b.Min.Y + positiveMod(y-b.Min.Y, b.Dy()), true
+	case EdgeMirror:
+		return b.Min.X + mirrorMod(x-b.Min.X, b.Dx()), b.Min.Y + mirrorMod(y-b.Min.Y, b.Dy()), true
+	}
+	return 0, 0, false
+}
+
+// positiveMod returns i mod n in [0, n).
+func positiveMod(i, n int) int {
+	i %= n
+	if i < 0 {
+		i += n
+	}
+	return i
+}
+
+// mirrorMod returns i reflected back & forth within [0, n), eg. for n = 3
+// ... 1 0 | 0 1 2 | 2 1 0 | 0 1 ...
+func mirrorMod(i, n int) int {
+	i = positiveMod(i, 2*n)
+	if i >= n {
+		i = 2*n - 1 - i
+	}
+	return i
+}
+
+// Sample returns the color at the (continuous) point (x,y) in world space,
+// where pixel (x,y) covers the area from (x,y) to (x+1,y+1), read with the
+// given filter. Points near or beyond the edge read according to the edge
+// mode (see Edges).
+//
+// This loads chunks as needed each call, so isn't quick for reading many
+// points; see Image for that.
+func (m *Mimage) Sample(x, y float64, filter ResampleFilter) (color.RGBA, error) {
+	s := newSampler(m)
+	defer s.release()
+	c := s.sample(x, y, filter)
+	return c, s.err
+}
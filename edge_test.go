@@ -0,0 +1,94 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestEdges(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	for _, tc := range []struct {
+		mode        mimage.EdgeMode
+		left, right color.RGBA // just beyond each side
+	}{
+		{mimage.EdgeTransparent, color.RGBA{}, color.RGBA{}},
+		{mimage.EdgeClamp, red, blue},
+		{mimage.EdgeWrap, blue, red},
+		{mimage.EdgeMirror, red, blue},
+	} {
+		dir := t.TempDir()
+		m, err := mimage.New(image.Rect(0, 0, 64, 64), mimage.Directory(dir), mimage.ChunkSize(32), mimage.Edges(tc.mode))
+		if err != nil {
+			t.Fatal(err)
+		}
+		op := m.Draw()
+		op.SetColor(red)
+		op.DrawRectangle(0, 0, 1, 64)
+		op.Fill()
+		op.SetColor(blue)
+		op.DrawRectangle(63, 0, 1, 64)
+		op.Fill()
+		err = op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := m.At(-1, 10); got != tc.left {
+			t.Errorf("mode %d left of the image is %v, want %v", tc.mode, got, tc.left)
+		}
+		if got := m.At(64, 10); got != tc.right {
+			t.Errorf("mode %d right of the image is %v, want %v", tc.mode, got, tc.right)
+		}
+		got, err := m.Sample(-0.5, 10.5, mimage.FilterBilinear)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.left {
+			t.Errorf("mode %d sampled left of the image is %v, want %v", tc.mode, got, tc.left)
+		}
+
+		err = m.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := mimage.Load(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loaded.EdgeMode() != tc.mode {
+			t.Errorf("reloaded edge mode is %d, want %d", loaded.EdgeMode(), tc.mode)
+		}
+		loaded.Close()
+	}
+}
+
+func TestSample(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(color.RGBA{200, 0, 0, 255})
+	op.DrawRectangle(0, 0, 32, 64)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// halfway between the last red pixel & the first empty one
+	got, err := m.Sample(32, 10.5, mimage.FilterBilinear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (color.RGBA{100, 0, 0, 128}); !nearRGBA(got, want) {
+		t.Errorf("sampled %v, want %v", got, want)
+	}
+	got, err = m.Sample(31.9, 10.5, mimage.FilterNearest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (color.RGBA{200, 0, 0, 255}); got != want {
+		t.Errorf("sampled %v, want %v", got, want)
+	}
+}
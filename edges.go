@@ -0,0 +1,179 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// cannyReach is how far (in pixels) beyond a chunk Canny follows weak edges
+// looking for a strong one to connect to.
+const cannyReach = 32
+
+// Sobel returns a new Mimage of the gradient magnitude of the gray level of
+// this image using the Sobel operator; flat areas are black & a hard edge
+// from black to white is white.
+//
+// Each chunk is filtered in turn, reading a one pixel halo around it so there
+// are no seams.
+func (m *Mimage) Sobel() (*Mimage, error) {
+	return m.edgeFilter(1, func(pix []float32, w, h int) []float32 {
+		mag, _, _ := sobel(pix, w, h)
+		return mag
+	})
+}
+
+// Canny returns a new Mimage of edges (white on black) found with the Canny
+// edge detector. The gray level of the image is blurred by sigma (in pixels)
+// to reduce noise, edges are thinned to the ridge of the Sobel gradient (see
+// Sobel for the scale) & kept if the gradient is at least high, or at least
+// low & connected to such an edge.
+//
+// Chunks are processed with a halo wide enough for the blur, plus some way
+// further for following weak edges, so a weak edge only connected to a strong
+// one very far outside a chunk may be dropped.
+func (m *Mimage) Canny(sigma, low, high float64) (*Mimage, error) {
+	if low > high {
+		return nil, fmt.Errorf("low threshold %v is greater than high threshold %v", low, high)
+	}
+
+	halo := blurRadius(sigma) + 2 + cannyReach
+	return m.edgeFilter(halo, func(pix []float32, w, h int) []float32 {
+		gaussianBlur(pix, w, h, sigma)
+		mag, gx, gy := sobel(pix, w, h)
+		return hysteresis(thinEdges(mag, gx, gy, w, h), w, h, float32(low), float32(high))
+	})
+}
+
+// edgeFilter returns a new Mimage, filling each chunk with the result of fn
+// run over the gray levels (0-255) of the chunk plus a halo.
+func (m *Mimage) edgeFilter(halo int, fn func(pix []float32, w, h int) []float32) (*Mimage, error) {
+	out, err := New(m.bounds, ChunkSize(m.chunkSize), OperationRoutines(m.routines))
+	if err != nil {
+		return nil, err
+	}
+
+	return out, out.eachChunk(m.bounds, func(ctx *context) error {
+		cb := out.chunkBounds(ctx.X, ctx.Y)
+		r := cb.Intersect(m.bounds)
+		if r.Empty() {
+			return nil
+		}
+
+		work := m.halo(r, halo)
+		heights, err := m.heights(work)
+		if err != nil {
+			return err
+		}
+		pix := make([]float32, len(heights))
+		for i, h := range heights {
+			pix[i] = float32(h)
+		}
+		w := work.Dx()
+		result := fn(pix, w, work.Dy())
+
+		dst := ctx.Img.Image().(*image.RGBA)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				v := uint8(clampFloat(result[(y-work.Min.Y)*w+x-work.Min.X], 0, 255) + 0.5)
+				dst.SetRGBA(x-cb.Min.X, y-cb.Min.Y, color.RGBA{R: v, G: v, B: v, A: 0xff})
+			}
+		}
+		ctx.setEdited()
+		return nil
+	})
+}
+
+// sobel returns the gradient magnitude along with the x & y gradients of the
+// w x h plane, edge values are repeated beyond the plane.
+func sobel(pix []float32, w, h int) ([]float32, []float32, []float32) {
+	at := func(x, y int) float32 { return pix[clampInt(y, 0, h-1)*w+clampInt(x, 0, w-1)] }
+
+	mag, gx, gy := make([]float32, w*h), make([]float32, w*h), make([]float32, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			a, b, c := at(x-1, y-1), at(x, y-1), at(x+1, y-1)
+			d, f := at(x-1, y), at(x+1, y)
+			g, k, l := at(x-1, y+1), at(x, y+1), at(x+1, y+1)
+
+			// scaled so a hard black to white edge has a magnitude of 255
+			dx := ((c + 2*f + l) - (a + 2*d + g)) / 4
+			dy := ((g + 2*k + l) - (a + 2*b + c)) / 4
+			i := y*w + x
+			gx[i], gy[i] = dx, dy
+			mag[i] = float32(math.Hypot(float64(dx), float64(dy)))
+		}
+	}
+	return mag, gx, gy
+}
+
+// thinEdges returns the magnitudes that are the highest of their neighbours
+// across the gradient (non maximum suppression), others are zeroed.
+func thinEdges(mag, gx, gy []float32, w, h int) []float32 {
+	at := func(x, y int) float32 {
+		if x < 0 || y < 0 || x >= w || y >= h {
+			return 0
+		}
+		return mag[y*w+x]
+	}
+
+	out := make([]float32, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			if mag[i] == 0 {
+				continue
+			}
+
+			// the gradient direction rounded to one of four neighbour pairs
+			angle := math.Atan2(float64(gy[i]), float64(gx[i])) * 180 / math.Pi
+			if angle < 0 {
+				angle += 180
+			}
+			dx, dy := 1, 0
+			switch {
+			case angle >= 22.5 && angle < 67.5:
+				dx, dy = 1, 1
+			case angle >= 67.5 && angle < 112.5:
+				dx, dy = 0, 1
+			case angle >= 112.5 && angle < 157.5:
+				dx, dy = -1, 1
+			}
+
+			if mag[i] >= at(x+dx, y+dy) && mag[i] >= at(x-dx, y-dy) {
+				out[i] = mag[i]
+			}
+		}
+	}
+	return out
+}
+
+// hysteresis returns 255 for edges of at least high, or at least low &
+// connected (8 way) to one, & 0 elsewhere.
+func hysteresis(mag []float32, w, h int, low, high float32) []float32 {
+	out := make([]float32, w*h)
+	stack := []int{}
+	for i, v := range mag {
+		if v >= high && v > 0 {
+			out[i] = 255
+			stack = append(stack, i)
+		}
+	}
+
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		x, y := i%w, i/w
+		for ny := maxInt(y-1, 0); ny <= minInt(y+1, h-1); ny++ {
+			for nx := maxInt(x-1, 0); nx <= minInt(x+1, w-1); nx++ {
+				n := ny*w + nx
+				if out[n] == 0 && mag[n] >= low && mag[n] > 0 {
+					out[n] = 255
+					stack = append(stack, n)
+				}
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+// halves returns an image black on the left & white from x=32.
+func halves(t *testing.T) *mimage.Mimage {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(color.Black)
+	op.Clear()
+	op.SetColor(color.White)
+	op.DrawRectangle(32, 0, 32, 64)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestSobel(t *testing.T) {
+	m := halves(t)
+	edges, err := m.Sobel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer edges.Close()
+
+	for x, want := range map[int]uint8{10: 0, 31: 255, 32: 255, 50: 0} {
+		for _, y := range []int{0, 31, 32, 63} {
+			if got := edges.At(x, y).(color.RGBA).R; got != want {
+				t.Errorf("sobel at (%d,%d) is %d, want %d", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestCanny(t *testing.T) {
+	m := halves(t)
+	if _, err := m.Canny(1, 100, 50); err == nil {
+		t.Error("low threshold above high got no error")
+	}
+
+	edges, err := m.Canny(1, 20, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer edges.Close()
+
+	// a thin line all the way down, across the chunk boundary
+	for y := 0; y < 64; y++ {
+		n := 0
+		for x := 0; x < 64; x++ {
+			if edges.At(x, y).(color.RGBA).R == 255 {
+				if x < 30 || x > 33 {
+					t.Errorf("edge at (%d,%d), away from the boundary", x, y)
+				}
+				n++
+			}
+		}
+		if n == 0 || n > 2 {
+			t.Errorf("row %d has %d edge pixels, want 1 or 2", y, n)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package mimage
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+const (
+	// effectsfile logs the last operation applied to each chunk & the result
+	effectsfile = ".mimage_effects.jsonl"
+
+	// maxEffectDepth limits how deeply operation arguments are hashed
+	maxEffectDepth = 32
+
+	// effectsSlack is how many more lines than chunks the effects file may
+	// have before it's compacted
+	effectsSlack = 1024
+)
+
+// SkipUnchanged records (on disk, with the image) a hash of each operation
+// applied to each chunk & of the chunk afterwards. When the same operation
+// is run again, chunks that still hold its result are skipped rather than
+// drawn again, eg. when a pipeline that was interrupted part way through an
+// operation is started again.
+//
+// Operations are compared by their queued calls & arguments, including the
+// pixels of any images drawn. Operations that can't be compared (eg. those
+// using a mask, which may have changed) are always run. Operations must be
+// deterministic for this to be of use.
+//
+// Only the last record of each chunk is kept; the file is compacted by Flush
+// & as it grows.
+func SkipUnchanged() Option {
+	return func(m *Mimage) error {
+		m.skipUnchanged = true
+		return nil
+	}
+}
+
+// effect is the last operation applied to a chunk & the resulting chunk.
+type effect struct {
+	X, Y   int
+	Op     string
+	Result string
+}
+
+// effects is the log of operations applied to chunks.
+type effects struct {
+	path    string
+	lock    *sync.Mutex
+	records map[[2]int]effect
+	lines   int // in the file, more than records if chunks were redrawn
+}
+
+// newEffects returns the effects logged in the given image directory.
+func newEffects(root string) (*effects, error) {
+	e := &effects{
+		path:    filepath.Join(root, effectsfile),
+		lock:    &sync.Mutex{},
+		records: map[[2]int]effect{},
+	}
+
+	f, err := os.Open(e.path)
+	if os.IsNotExist(err) {
+		return e, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		rec := effect{}
+		err = json.Unmarshal(scanner.Bytes(), &rec)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", e.path, err)
+		}
+		e.records[[2]int{rec.X, rec.Y}] = rec // later lines win
+		e.lines++
+	}
+	return e, scanner.Err()
+}
+
+// applied returns if the operation with the given key was the last applied
+// to the chunk at (x,y).
+func (e *effects) applied(x, y int, key string) bool {
+	if e == nil || key == "" {
+		return false
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.records[[2]int{x, y}].Op == key
+}
+
+// done returns if the chunk holds the result of the operation with the given
+// key, so there is no need to apply it again.
+func (e *effects) done(ctx *context, key string) bool {
+	if !e.applied(ctx.X, ctx.Y, key) {
+		return false
+	}
+	result := chunkHash(ctx)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.records[[2]int{ctx.X, ctx.Y}].Result == result
+}
+
+// record logs that the operation with the given key has been applied to the
+// chunk.
+func (e *effects) record(ctx *context, key string) error {
+	if e == nil || key == "" {
+		return nil
+	}
+	rec := effect{X: ctx.X, Y: ctx.Y, Op: key, Result: chunkHash(ctx)}
+	data, err := json.Marshal(&rec)
+	if err != nil {
+		return err
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.records[[2]int{rec.X, rec.Y}] = rec
+	f, err := os.OpenFile(e.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	e.lines++
+	if e.lines > 2*len(e.records)+effectsSlack {
+		return e.rewrite()
+	}
+	return nil
+}
+
+// compact rewrites the effects file with only the last record of each chunk.
+func (e *effects) compact() error {
+	if e == nil {
+		return nil
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.lines == len(e.records) {
+		return nil // nothing to drop
+	}
+	return e.rewrite()
+}
+
+// rewrite writes the records to the effects file, replacing it. The caller
+// is expected to hold the lock.
+func (e *effects) rewrite() error {
+	keys := make([][2]int, 0, len(e.records))
+	for k := range e.records {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][1] != keys[j][1] {
+			return keys[i][1] < keys[j][1]
+		}
+		return keys[i][0] < keys[j][0]
+	})
+
+	tmp := e.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, k := range keys {
+		rec := e.records[k]
+		data, err := json.Marshal(&rec)
+		if err == nil {
+			_, err = w.Write(append(data, '\n'))
+		}
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, e.path)
+	if err != nil {
+		return err
+	}
+	e.lines = len(e.records)
+	return nil
+}
+
+// chunkHash returns a hash of the pixels of a loaded chunk.
+func chunkHash(ctx *context) string {
+	sum := sha256.Sum256(ctx.Img.Image().(*image.RGBA).Pix)
+	return hex.EncodeToString(sum[:])
+}
+
+// effectKey returns a hash of the queued calls & their arguments, or "" if
+// they can't be hashed (see SkipUnchanged).
+func (o *operation) effectKey() string {
+	h := sha256.New()
+	for p := (cursor{}); p.fn < o.queue.len(); {
+		var action command
+		action, p = o.queue.at(p)
+		if action.Func == setMask {
+			return ""
+		}
+		binary.Write(h, binary.LittleEndian, int64(action.Func))
+		for _, arg := range action.values() {
+			if !hashValue(h, reflect.ValueOf(arg), 0) {
+				return ""
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// jobKey returns the key of an operation (see effectKey) as applied to one
+// chunk at the given shifts.
+func jobKey(key string, shifts []image.Point) string {
+	if key == "" {
+		return ""
+	}
+	h := sha256.New()
+	h.Write([]byte(key))
+	for _, s := range shifts {
+		binary.Write(h, binary.LittleEndian, [2]int64{int64(s.X), int64(s.Y)})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashValue writes v (& whatever it points to) to h, returning false if it
+// holds something that can't be compared between runs (eg. a func or map).
+func hashValue(h hash.Hash, v reflect.Value, depth int) bool {
+	if depth > maxEffectDepth {
+		return false
+	}
+	if !v.IsValid() {
+		h.Write([]byte("nil"))
+		return true
+	}
+	h.Write([]byte(v.Type().String()))
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			h.Write([]byte{1})
+		} else {
+			h.Write([]byte{0})
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		binary.Write(h, binary.LittleEndian, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		binary.Write(h, binary.LittleEndian, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		binary.Write(h, binary.LittleEndian, math.Float64bits(v.Float()))
+	case reflect.String:
+		binary.Write(h, binary.LittleEndian, int64(v.Len()))
+		h.Write([]byte(v.String()))
+	case reflect.Slice, reflect.Array:
+		binary.Write(h, binary.LittleEndian, int64(v.Len()))
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			h.Write(v.Bytes()) // eg. image pixels
+			return true
+		}
+		for i := 0; i < v.Len(); i++ {
+			if !hashValue(h, v.Index(i), depth+1) {
+				return false
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !hashValue(h, v.Field(i), depth+1) {
+				return false
+			}
+		}
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			h.Write([]byte("nil"))
+			return true
+		}
+		if v.Type() == reflect.TypeOf(&Mimage{}) {
+			return false // its contents may have changed
+		}
+		return hashValue(h, v.Elem(), depth+1)
+	default: // maps, funcs, channels ..
+		return false
+	}
+	return true
+}
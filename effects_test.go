@@ -0,0 +1,68 @@
+package mimage_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+// effectLines returns how many lines the SkipUnchanged log has.
+func effectLines(t *testing.T, dir string) int {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join(dir, ".mimage_effects.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Count(data, []byte("\n"))
+}
+
+func TestSkipUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	m, err := mimage.New(image.Rect(0, 0, 64, 64), mimage.Directory(dir), mimage.ChunkSize(32), mimage.SkipUnchanged())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	fill := func(c color.Color) {
+		op := m.Draw()
+		op.SetColor(c)
+		op.Clear()
+		err := op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	chunks := 4
+
+	fill(red)
+	if n := effectLines(t, dir); n != chunks {
+		t.Fatalf("logged %d lines for %d chunks", n, chunks)
+	}
+	fill(red)
+	if n := effectLines(t, dir); n != chunks {
+		t.Errorf("logged %d lines after repeating the operation, want the chunks skipped", n)
+	}
+
+	// redrawn chunks are logged again, until compacted
+	fill(blue)
+	fill(red)
+	if got := m.At(10, 10); got != red {
+		t.Errorf("pixel is %v, want %v", got, red)
+	}
+	if n := effectLines(t, dir); n != 3*chunks {
+		t.Errorf("logged %d lines after redrawing, want %d", n, 3*chunks)
+	}
+	err = m.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := effectLines(t, dir); n != chunks {
+		t.Errorf("logged %d lines after flushing, want one per chunk", n)
+	}
+}
@@ -0,0 +1,90 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// Format is an image file format we can write.
+type Format int
+
+const (
+	FormatPNG Format = iota
+	FormatJPEG
+	FormatTIFF
+
+	// FormatTIFFFloat is a TIFF with 32 bit floating point samples, so that
+	// data is written without any loss of precision.
+	FormatTIFFFloat
+)
+
+// Encode writes img to w in this format (with default settings). Format
+// implements Encoder so can be used wherever an Encoder is wanted.
+func (f Format) Encode(w io.Writer, img image.Image) error {
+	switch f {
+	case FormatJPEG:
+		return jpeg.Encode(w, img, nil)
+	case FormatTIFF:
+		return encodeTIFF(w, img)
+	case FormatTIFFFloat:
+		return encodeFloatTIFF(w, img)
+	case FormatPNG:
+		return png.Encode(w, img)
+	}
+	return fmt.Errorf("unknown format %d", f)
+}
+
+// Extension returns the usual file extension for this format.
+func (f Format) Extension() string {
+	switch f {
+	case FormatJPEG:
+		return "jpg"
+	case FormatTIFF, FormatTIFFFloat:
+		return "tif"
+	}
+	return "png"
+}
+
+// Encoder writes images in some file format.
+type Encoder interface {
+	// Encode writes img to w.
+	Encode(w io.Writer, img image.Image) error
+
+	// Extension returns the usual file extension (without a dot) for
+	// files written by this encoder.
+	Extension() string
+}
+
+// encoderFunc adapts a function to the Encoder interface.
+type encoderFunc struct {
+	fn  func(w io.Writer, img image.Image) error
+	ext string
+}
+
+// Encode writes img to w.
+func (e *encoderFunc) Encode(w io.Writer, img image.Image) error { return e.fn(w, img) }
+
+// Extension returns the file extension for written files.
+func (e *encoderFunc) Extension() string { return e.ext }
+
+// EncoderFunc returns an Encoder that calls fn, writing files with the given
+// extension. For example EncoderFunc("png", png.Encode).
+func EncoderFunc(ext string, fn func(w io.Writer, img image.Image) error) Encoder {
+	return &encoderFunc{fn: fn, ext: ext}
+}
+
+// PNGEncoder returns an Encoder that writes PNG files.
+func PNGEncoder() Encoder {
+	return EncoderFunc("png", png.Encode)
+}
+
+// JPEGEncoder returns an Encoder that writes JPEG files of the given
+// quality (1-100).
+func JPEGEncoder(quality int) Encoder {
+	return EncoderFunc("jpg", func(w io.Writer, img image.Image) error {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	})
+}
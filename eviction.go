@@ -0,0 +1,75 @@
+package mimage
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultEvictAfter is how long a chunk is left unused before it's unloaded
+// with EvictIdle
+const defaultEvictAfter = time.Second
+
+// EvictPolicy determines when chunks that aren't in use are written out &
+// dropped from memory.
+type EvictPolicy int
+
+const (
+	// evictAuto picks EvictLRU if MaxChunks is set, otherwise EvictIdle.
+	evictAuto EvictPolicy = iota
+
+	// EvictIdle unloads each chunk once it's gone unused for a while (see
+	// EvictAfter), by a routine per chunk. Memory follows whatever is being
+	// worked on, which suits batch rendering.
+	EvictIdle
+
+	// EvictLRU keeps up to MaxChunks chunks not in use loaded, unloading the
+	// least recently used when more are loaded. No timers are involved & the
+	// chunks around an interactive edit stay put however long the user
+	// pauses.
+	EvictLRU
+
+	// EvictNever keeps every chunk loaded until Flush is called, for images
+	// that fit in memory (or short lived ones) where disk traffic is wasted.
+	EvictNever
+)
+
+// Eviction sets when chunks that aren't in use are unloaded (see
+// EvictPolicy). By default it's EvictLRU if MaxChunks is set & EvictIdle
+// otherwise.
+func Eviction(p EvictPolicy) Option {
+	return func(m *Mimage) error {
+		if p < EvictIdle || p > EvictNever {
+			return fmt.Errorf("unknown eviction policy %d", p)
+		}
+		m.evictPolicy = p
+		return nil
+	}
+}
+
+// EvictAfter sets how long chunks go unused before they're unloaded with
+// EvictIdle, a second by default.
+func EvictAfter(d time.Duration) Option {
+	return func(m *Mimage) error {
+		if d <= 0 {
+			return fmt.Errorf("evict after must be greater than zero, given %v", d)
+		}
+		m.evictAfter = d
+		return nil
+	}
+}
+
+// eviction returns the policy chunks are unloaded with & how long they go
+// unused first (with EvictIdle).
+func (m *Mimage) eviction() (EvictPolicy, time.Duration) {
+	policy, after := m.evictPolicy, m.evictAfter
+	if policy == evictAuto {
+		policy = EvictIdle
+		if m.maxChunks > 0 {
+			policy = EvictLRU
+		}
+	}
+	if after <= 0 {
+		after = defaultEvictAfter
+	}
+	return policy, after
+}
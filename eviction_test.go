@@ -0,0 +1,69 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/voidshard/mimage"
+)
+
+// written returns the number of chunks written out to the image's directory.
+func written(t *testing.T, m *mimage.Mimage) int {
+	files, err := filepath.Glob(filepath.Join(m.Directory(), "*.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(files)
+}
+
+func TestEviction(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []mimage.Option
+		want int // chunks written out before Flush, at least
+	}{
+		{"idle", []mimage.Option{mimage.EvictAfter(20 * time.Millisecond)}, 4},
+		{"lru", []mimage.Option{mimage.Eviction(mimage.EvictLRU), mimage.MaxChunks(1)}, 3},
+		{"never", []mimage.Option{mimage.Eviction(mimage.EvictNever), mimage.EvictAfter(20 * time.Millisecond)}, 0},
+	} {
+		m := newImage(t, image.Rect(0, 0, 64, 64), append(tc.opts, mimage.ChunkSize(32))...)
+		op := m.Draw()
+		op.SetColor(color.White)
+		op.Clear()
+		err := op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a chunk at a time, so all but the last are evicted with EvictLRU
+		for _, pt := range []image.Point{{10, 10}, {40, 10}, {10, 40}, {40, 40}} {
+			m.At(pt.X, pt.Y)
+		}
+
+		got := written(t, m)
+		for i := 0; i < 40 && got < tc.want; i++ {
+			time.Sleep(10 * time.Millisecond)
+			got = written(t, m)
+		}
+		if got < tc.want || (tc.want == 0 && got != 0) {
+			t.Errorf("%s: %d chunks written out, want %d", tc.name, got, tc.want)
+		}
+
+		err = m.Flush()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := written(t, m); got != 4 {
+			t.Errorf("%s: %d chunks written out after Flush, want 4", tc.name, got)
+		}
+	}
+
+	if _, err := mimage.New(image.Rect(0, 0, 10, 10), mimage.Eviction(99)); err == nil {
+		t.Error("unknown eviction policy got no error")
+	}
+	if _, err := mimage.New(image.Rect(0, 0, 10, 10), mimage.EvictAfter(0)); err == nil {
+		t.Error("evicting after no time got no error")
+	}
+}
@@ -0,0 +1,349 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ExportOption configures how (part of) an Mimage is exported.
+type ExportOption func(*exportConfig)
+
+// exportConfig holds the result of all ExportOptions.
+type exportConfig struct {
+	background image.Image // in world space
+	overlap    int
+	profile    []byte        // ICC profile to convert to
+	transform  *iccTransform // set up from profile by configureExport
+}
+
+// newExportConfig applies the given options.
+func newExportConfig(opts []ExportOption) *exportConfig {
+	cfg := &exportConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// configureExport applies the given options, preparing anything that depends
+// on the image.
+func (m *Mimage) configureExport(opts []ExportOption) (*exportConfig, error) {
+	cfg := newExportConfig(opts)
+	if cfg.profile == nil {
+		return cfg, nil
+	}
+
+	src := m.ICCProfile()
+	if src == nil {
+		return nil, fmt.Errorf("cannot convert color profile, image has no ICC profile")
+	}
+	t, err := newICCTransform(src, cfg.profile)
+	cfg.transform = t
+	return cfg, err
+}
+
+// info returns the extra information to write into files exported from m.
+func (c *exportConfig) info(m *Mimage, at image.Point) fileInfo {
+	info := fileInfo{dpi: m.DPI(), icc: m.ICCProfile(), geo: m.geoFor(at)}
+	if c.profile != nil {
+		info.icc = c.profile
+	}
+	return info
+}
+
+// apply alters an exported piece of the image in place, where the origin of img
+// is at the given point in world space.
+func (c *exportConfig) apply(img *image.RGBA, at image.Point) {
+	if c.background != nil {
+		c.composite(img, at)
+	}
+	if c.transform != nil {
+		c.transform.apply(img)
+	}
+}
+
+// composite draws img over the background, where the origin of img is at the
+// given point in world space.
+func (c *exportConfig) composite(img *image.RGBA, at image.Point) {
+	// composite the image over the background, this is the same as drawing
+	// the background "under" the image
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			p := img.Pix[i : i+4 : i+4]
+			if p[3] == 0xff {
+				continue
+			}
+			br, bg, bb, ba := c.background.At(x-b.Min.X+at.X, y-b.Min.Y+at.Y).RGBA()
+			inv := uint32(0xff - p[3])
+			p[0] += uint8((br >> 8) * inv / 0xff)
+			p[1] += uint8((bg >> 8) * inv / 0xff)
+			p[2] += uint8((bb >> 8) * inv / 0xff)
+			p[3] += uint8((ba >> 8) * inv / 0xff)
+		}
+	}
+}
+
+// checkerboard is an endless image of alternating squares.
+type checkerboard struct {
+	size int
+	a, b color.Color
+}
+
+// ColorModel returns the color model of the checkerboard.
+func (c *checkerboard) ColorModel() color.Model { return color.RGBAModel }
+
+// Bounds of the checkerboard are (near enough) infinite.
+func (c *checkerboard) Bounds() image.Rectangle {
+	return image.Rect(-1e9, -1e9, 1e9, 1e9)
+}
+
+// At returns the color of the square containing (x,y).
+func (c *checkerboard) At(x, y int) color.Color {
+	if (floorDiv(x, c.size)+floorDiv(y, c.size))%2 == 0 {
+		return c.a
+	}
+	return c.b
+}
+
+// CheckerboardBackground composites the image over a checkerboard of squares
+// of the given size (in pixels) and colors, the usual way of showing that an
+// image is transparent. The squares are aligned to world space so separately
+// exported regions line up.
+func CheckerboardBackground(size int, a, b color.Color) ExportOption {
+	return func(c *exportConfig) {
+		if size <= 0 {
+			size = 1
+		}
+		c.background = &checkerboard{size: size, a: a, b: b}
+	}
+}
+
+// SolidBackground composites the image over a single color.
+func SolidBackground(col color.Color) ExportOption {
+	return func(c *exportConfig) {
+		c.background = image.NewUniform(col)
+	}
+}
+
+// Overlap sets how far (in pixels) neighbouring pieces of an image overlap
+// when an image is exported in pieces (see SplitGrid).
+func Overlap(pixels int) ExportOption {
+	return func(c *exportConfig) {
+		if pixels < 0 {
+			pixels = 0
+		}
+		c.overlap = pixels
+	}
+}
+
+// regionView is an image over part of an Mimage that reads one band (one chunk
+// high) of the region at a time, so that huge regions can be handed to image
+// encoders without holding the whole region in memory. Since encoders work
+// from top to bottom each band is read only once.
+//
+// Two bands are kept so that encoders working in blocks (eg. jpeg) that
+// straddle two bands don't keep re-reading them.
+//
+// A regionView is not safe for concurrent use.
+type regionView struct {
+	m   *Mimage
+	r   image.Rectangle
+	cfg *exportConfig
+
+	bands  [2]*image.RGBA
+	bandRs [2]image.Rectangle
+	err    error
+
+	// straight copies of the bands, for images in AlphaStraight mode
+	straight [2]*image.NRGBA
+}
+
+// newRegionView returns a view over r (in world space) of m.
+func newRegionView(m *Mimage, r image.Rectangle, cfg *exportConfig) *regionView {
+	return &regionView{m: m, r: r, cfg: cfg}
+}
+
+// ColorModel returns the color model of the view.
+func (v *regionView) ColorModel() color.Model { return color.RGBAModel }
+
+// Bounds returns the bounds of the view, the top left of which is (0,0).
+func (v *regionView) Bounds() image.Rectangle { return v.r.Sub(v.r.Min) }
+
+// At returns the color at (x,y), loading the band containing it if needed.
+func (v *regionView) At(x, y int) color.Color {
+	c := v.RGBAAt(x, y)
+	if v.straight[0] == nil || !image.Pt(x, y).Add(v.r.Min).In(v.r) {
+		return c
+	}
+	return v.straight[0].NRGBAAt(x+v.r.Min.X-v.bandRs[0].Min.X, y+v.r.Min.Y-v.bandRs[0].Min.Y)
+}
+
+// RGBAAt returns the color at (x,y), loading the band containing it if needed.
+func (v *regionView) RGBAAt(x, y int) color.RGBA {
+	pt := image.Pt(x, y).Add(v.r.Min)
+	if !pt.In(v.r) {
+		return color.RGBA{}
+	}
+
+	if !pt.In(v.bandRs[0]) {
+		if pt.In(v.bandRs[1]) {
+			v.bands[0], v.bands[1] = v.bands[1], v.bands[0]
+			v.bandRs[0], v.bandRs[1] = v.bandRs[1], v.bandRs[0]
+			v.straight[0], v.straight[1] = v.straight[1], v.straight[0]
+		} else {
+			v.loadBand(pt.Y)
+		}
+	}
+
+	return v.bands[0].RGBAAt(pt.X-v.bandRs[0].Min.X, pt.Y-v.bandRs[0].Min.Y)
+}
+
+// loadBand reads the band containing the given y (in world space), keeping
+// the last band read.
+func (v *regionView) loadBand(y int) {
+	top := floorDiv(y, v.m.chunkSize) * v.m.chunkSize
+	r := image.Rect(v.r.Min.X, maxInt(top, v.r.Min.Y), v.r.Max.X, minInt(top+v.m.chunkSize, v.r.Max.Y))
+
+	img, err := v.m.Image(r)
+	if err != nil && v.err == nil {
+		v.err = err
+	}
+	band := img.(*image.RGBA)
+
+	var straight *image.NRGBA
+	if v.m.alpha == AlphaStraight {
+		straight, err = v.m.ImageNRGBA(r)
+		if err != nil && v.err == nil {
+			v.err = err
+		}
+	}
+
+	v.cfg.apply(band, r.Min)
+	if straight != nil {
+		reconcile(straight, band) // pick up changes made by the export options
+	}
+
+	v.bands[1], v.bandRs[1] = v.bands[0], v.bandRs[0]
+	v.bands[0], v.bandRs[0] = band, r
+	v.straight[1], v.straight[0] = v.straight[0], straight
+}
+
+// encodeView writes the region r (in world space) to w with the given encoder,
+// reading the region band by band.
+func (m *Mimage) encodeView(w io.Writer, r image.Rectangle, enc Encoder, cfg *exportConfig) error {
+	view := newRegionView(m, r.Intersect(m.bounds), cfg)
+	err := encodeWithInfo(w, view, enc, cfg.info(m, r.Min))
+	if err != nil {
+		return err
+	}
+	return view.err
+}
+
+// EncodeRegion writes the region r (in world space) to w in the given format,
+// which is a Format or any other Encoder (eg. PalettedPNG).
+//
+// Unlike encoding the result of Image() the region is streamed in one band of
+// chunks at a time, so exporting a huge region doesn't require holding it in
+// memory all at once.
+func (m *Mimage) EncodeRegion(r image.Rectangle, w io.Writer, format Encoder, opts ...ExportOption) error {
+	cfg, err := m.configureExport(opts)
+	if err != nil {
+		return err
+	}
+	return m.encodeView(w, r, format, cfg)
+}
+
+// SplitGrid exports the image as cols x rows separate files (eg. pages or panels
+// for printing) in the given directory, named "<col>-<row>.<ext>". Pages can be
+// made to overlap their neighbours with the Overlap option.
+//
+// Each file is written in turn, streaming in the chunks it needs, so only a
+// band of one page is in memory at any one time.
+func (m *Mimage) SplitGrid(cols, rows int, encode Encoder, dir string, opts ...ExportOption) error {
+	if cols <= 0 || rows <= 0 {
+		return fmt.Errorf("cols and rows must be greater than zero, given %d %d", cols, rows)
+	}
+	cfg, err := m.configureExport(opts)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(dir, 0750)
+	if err != nil {
+		return err
+	}
+
+	for _, page := range gridPages(m.bounds, cols, rows, cfg.overlap) {
+		err = m.writePage(filepath.Join(dir, fmt.Sprintf("%d-%d.%s", page.col, page.row, encode.Extension())), page.r, encode, cfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// gridPage is one piece of a region split into a grid.
+type gridPage struct {
+	col, row int
+	r        image.Rectangle
+}
+
+// gridPages splits r into cols x rows pages (left to right, top to bottom),
+// each grown by overlap but kept within r. Empty pages are skipped.
+func gridPages(r image.Rectangle, cols, rows, overlap int) []gridPage {
+	pw := (r.Dx() + cols - 1) / cols
+	ph := (r.Dy() + rows - 1) / rows
+
+	pages := []gridPage{}
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			page := image.Rect(col*pw, row*ph, (col+1)*pw, (row+1)*ph).Add(r.Min)
+			page = page.Inset(-overlap).Intersect(r)
+			if page.Empty() {
+				continue
+			}
+			pages = append(pages, gridPage{col: col, row: row, r: page})
+		}
+	}
+
+	return pages
+}
+
+// writePage encodes the region r (in world space) to a new file at path.
+func (m *Mimage) writePage(path string, r image.Rectangle, encode Encoder, cfg *exportConfig) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = m.encodeView(f, r, encode, cfg)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Export returns a selected piece of the massive image as an image (see Image)
+// with the given export options applied.
+func (m *Mimage) Export(r image.Rectangle, opts ...ExportOption) (image.Image, error) {
+	cfg, err := m.configureExport(opts)
+	if err != nil {
+		return nil, err
+	}
+	img, err := m.Image(r)
+	if err != nil {
+		return img, err
+	}
+
+	rgba := img.(*image.RGBA)
+	cfg.apply(rgba, r.Min)
+	return rgba, nil
+}
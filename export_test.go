@@ -0,0 +1,162 @@
+package mimage_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestExportBackground(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	red := color.RGBA{255, 0, 0, 255}
+	op := m.Draw()
+	op.SetColor(red)
+	op.DrawRectangle(0, 0, 16, 64)
+	op.Fill()
+	op.SetColor(color.RGBA{0, 0, 128, 128})
+	op.DrawRectangle(16, 0, 16, 64)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := m.Export(image.Rect(0, 0, 64, 64), mimage.SolidBackground(color.White))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, want := range map[image.Point]color.RGBA{
+		{5, 5}:  red,                  // opaque, unchanged
+		{20, 5}: {127, 127, 255, 255}, // half blue over white
+		{40, 5}: {255, 255, 255, 255}, // transparent, just the background
+	} {
+		if got := img.At(p.X, p.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestExportCheckerboard(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	a, b := color.RGBA{200, 200, 200, 255}, color.RGBA{100, 100, 100, 255}
+
+	// squares line up with world space, wherever the export starts
+	img, err := m.Export(image.Rect(10, 10, 40, 40), mimage.CheckerboardBackground(8, a, b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, want := range map[image.Point]color.RGBA{
+		{0, 0}:   a, // world (10,10)
+		{6, 6}:   a, // world (16,16)
+		{6, 0}:   b, // world (16,10)
+		{14, 6}:  b, // world (24,16)
+		{14, 14}: a, // world (24,24)
+	} {
+		if got := img.At(img.Bounds().Min.X+p.X, img.Bounds().Min.Y+p.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestSplitGrid(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 100, 100), mimage.ChunkSize(32))
+	red := color.RGBA{255, 0, 0, 255}
+	op := m.Draw()
+	op.SetColor(red)
+	op.DrawRectangle(50, 50, 50, 50)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = m.SplitGrid(2, 2, mimage.PNGEncoder(), dir, mimage.Overlap(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pages are 50x50, plus the overlap where there's image to overlap
+	for _, name := range []string{"0-0.png", "1-0.png", "0-1.png", "1-1.png"} {
+		img := readPNG(t, filepath.Join(dir, name))
+		if b := img.Bounds(); b != image.Rect(0, 0, 54, 54) {
+			t.Errorf("%s has bounds %v", name, b)
+			continue
+		}
+		if got := color.RGBAModel.Convert(img.At(53, 53)); got != red {
+			t.Errorf("%s bottom right pixel is %v, want %v", name, got, red)
+		}
+	}
+	first := readPNG(t, filepath.Join(dir, "0-0.png"))
+	if got := color.RGBAModel.Convert(first.At(49, 49)); got != (color.RGBA{}) {
+		t.Errorf("0-0.png pixel (49,49) is %v, want it empty", got)
+	}
+
+	if err := m.SplitGrid(0, 1, mimage.PNGEncoder(), dir); err == nil {
+		t.Error("splitting into zero columns got no error")
+	}
+}
+
+// readPNG decodes the PNG file at path.
+func readPNG(t *testing.T, path string) image.Image {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestEncodeRegion(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 100, 100), mimage.ChunkSize(16))
+	op := m.Draw()
+	op.SetColor(color.RGBA{255, 0, 0, 255})
+	op.DrawRectangle(0, 0, 100, 50)
+	op.Fill()
+	op.SetColor(color.RGBA{0, 0, 255, 255})
+	op.DrawRectangle(0, 50, 100, 50)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the region spans several bands, & starts part way through one
+	r := image.Rect(10, 20, 90, 85)
+	want, err := m.Export(r, mimage.SolidBackground(color.White))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	err = m.EncodeRegion(r, buf, mimage.FormatPNG, mimage.SolidBackground(color.White))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := png.Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Bounds().Size() != r.Size() {
+		t.Fatalf("encoded region is %v, want size %v", got.Bounds(), r.Size())
+	}
+	wb := want.Bounds()
+	for y := 0; y < r.Dy(); y++ {
+		for x := 0; x < r.Dx(); x++ {
+			if g, w := color.RGBAModel.Convert(got.At(x, y)), want.At(wb.Min.X+x, wb.Min.Y+y); g != w {
+				t.Fatalf("encoded pixel (%d,%d) is %v, want %v", x, y, g, w)
+			}
+		}
+	}
+}
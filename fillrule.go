@@ -0,0 +1,41 @@
+package mimage
+
+import (
+	"github.com/fogleman/gg"
+)
+
+// FillRule determines which parts of a path Fill paints, where the path
+// crosses itself or has shapes within shapes.
+type FillRule int
+
+const (
+	// FillRuleWinding fills anywhere the path winds around, so shapes
+	// within shapes are only holes if drawn in the opposite direction.
+	FillRuleWinding FillRule = iota
+
+	// FillRuleEvenOdd fills where a line out to infinity crosses the path
+	// an odd number of times, so shapes within shapes are holes however
+	// they're drawn (eg. lakes within landmasses, within which are islands).
+	FillRuleEvenOdd
+)
+
+// SetFillRule sets the rule used by following Fill calls (see FillRule), the
+// default being FillRuleWinding. A shape with holes is queued as a single
+// path; MoveTo starts each outline (outer or hole) before the Fill.
+func (o *operation) SetFillRule(r FillRule) {
+	o.queue.pushArgs(setFillRule, []interface{}{r})
+}
+
+// SetFillRule sets the rule used by following Fill calls (see FillRule).
+func (m *Macro) SetFillRule(r FillRule) {
+	m.queue.pushArgs(setFillRule, []interface{}{r})
+}
+
+// SetFillRule sets the rule paths are filled with.
+func (c ggCanvas) SetFillRule(r FillRule) {
+	if r == FillRuleEvenOdd {
+		c.Context.SetFillRule(gg.FillRuleEvenOdd)
+	} else {
+		c.Context.SetFillRule(gg.FillRuleWinding)
+	}
+}
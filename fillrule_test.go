@@ -0,0 +1,45 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestSetFillRule(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	// a square within a square, both drawn in the same direction
+	fill := func(m *mimage.Mimage, rule mimage.FillRule) {
+		op := m.Draw()
+		op.SetColor(red)
+		op.SetFillRule(rule)
+		for _, s := range [][2]float64{{10, 90}, {30, 70}} {
+			op.MoveTo(s[0], s[0])
+			op.LineTo(s[1], s[0])
+			op.LineTo(s[1], s[1])
+			op.LineTo(s[0], s[1])
+			op.ClosePath()
+		}
+		op.Fill()
+		err := op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the accelerated renderer falls back to gg for the even-odd rule
+	for _, renderer := range []mimage.Option{mimage.ChunkSize(32), mimage.RenderWith(mimage.AcceleratedRenderer(nil))} {
+		for rule, hole := range map[mimage.FillRule]color.Color{mimage.FillRuleWinding: red, mimage.FillRuleEvenOdd: color.RGBA{}} {
+			m := newImage(t, image.Rect(0, 0, 100, 100), mimage.ChunkSize(32), renderer)
+			fill(m, rule)
+			if got := m.At(20, 50); got != red {
+				t.Errorf("rule %d: pixel between the squares is %v, want %v", rule, got, red)
+			}
+			if got := m.At(50, 50); got != hole {
+				t.Errorf("rule %d: pixel within the inner square is %v, want %v", rule, got, hole)
+			}
+		}
+	}
+}
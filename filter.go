@@ -0,0 +1,83 @@
+package mimage
+
+import (
+	"image"
+	"image/draw"
+)
+
+// filterRGBA replaces the pixels within r (in world space) with the results
+// of fn, which is run for each chunk with src, the (premultiplied) pixels of
+// the chunk within r plus a halo of n pixels read as per the edge mode, &
+// dst, the same part of the chunk without the halo to fill in.
+//
+// Results are kept in an intermediate image until every chunk is done, so
+// chunks read their neighbours as they were before filtering. They're only
+// copied back where the filter mask (see SetFilterMask) allows.
+func (m *Mimage) filterRGBA(r image.Rectangle, n int, fn func(src, dst *image.RGBA)) error {
+	r = r.Intersect(m.bounds)
+	if r.Empty() {
+		return nil
+	}
+
+	tmp, err := New(m.bounds, ChunkSize(m.chunkSize), OperationRoutines(m.routines))
+	if err != nil {
+		return err
+	}
+	defer tmp.Close()
+
+	err = tmp.eachChunk(r, func(ctx *context) error {
+		cb := tmp.chunkBounds(ctx.X, ctx.Y)
+		area := cb.Intersect(r)
+		if area.Empty() {
+			return nil
+		}
+		work := m.halo(area, n)
+		src, err := m.imageEdged(work)
+		if err != nil {
+			return err
+		}
+		src.Rect = work // in world space
+
+		chunk := ctx.Img.Image().(*image.RGBA)
+		dst := chunk.SubImage(area.Sub(cb.Min)).(*image.RGBA)
+		dst.Rect = area // in world space
+		fn(src, dst)
+		ctx.setEdited()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return m.eachChunk(r, func(ctx *context) error {
+		cb := m.chunkBounds(ctx.X, ctx.Y)
+		area := cb.Intersect(r)
+		if area.Empty() {
+			return nil
+		}
+		src, err := tmp.Image(area)
+		if err != nil {
+			return err
+		}
+		mask, err := m.filterMaskWithin(area)
+		if err != nil {
+			return err
+		}
+		if mask != nil {
+			// only what the filter mask allows is changed
+			filtered := src.(*image.RGBA)
+			dst := ctx.Img.Image().(*image.RGBA)
+			for y := area.Min.Y; y < area.Max.Y; y++ {
+				for x := area.Min.X; x < area.Max.X; x++ {
+					old := dst.RGBAAt(x-cb.Min.X, y-cb.Min.Y)
+					c := blendMasked(old, filtered.RGBAAt(x-area.Min.X, y-area.Min.Y), mask.AlphaAt(x, y).A)
+					filtered.SetRGBA(x-area.Min.X, y-area.Min.Y, c)
+				}
+			}
+		}
+		draw.Draw(ctx.Img.Image().(*image.RGBA), area.Sub(cb.Min), src, image.Point{}, draw.Src)
+		pasteStraight(ctx, area.Sub(cb.Min), src, image.Point{})
+		ctx.setEdited()
+		return nil
+	})
+}
@@ -0,0 +1,56 @@
+package mimage
+
+import (
+	"image"
+	"image/color"
+)
+
+// SetFilterMask limits the filters that change the image in place (Blur,
+// UnsharpMask, Median, Posterize, GradientMap ..) to where the mask is set,
+// as SetMask does for drawing; the filtered result is blended in by the
+// alpha of the mask, which stays where it is in world space. With inverted,
+// filters apply where the mask isn't set, eg. to blur the background around a
+// subject. Pass nil to filter everywhere again.
+//
+// The mask is kept until changed & isn't saved with the image.
+func (m *Mimage) SetFilterMask(mask *Mimage, inverted bool) {
+	m.metaLock.Lock()
+	defer m.metaLock.Unlock()
+	m.filterMask = mask
+	m.filterMaskInverted = inverted
+}
+
+// filterMaskWithin returns the filter mask (see SetFilterMask) over r (in
+// world space), or nil if there isn't one.
+func (m *Mimage) filterMaskWithin(r image.Rectangle) (*image.Alpha, error) {
+	m.metaLock.Lock()
+	mask, inverted := m.filterMask, m.filterMaskInverted
+	m.metaLock.Unlock()
+	if mask == nil {
+		return nil, nil
+	}
+
+	alpha, err := mask.Mask(r)
+	if err != nil {
+		return nil, err
+	}
+	alpha.Rect = r // in world space
+	if inverted {
+		for i, a := range alpha.Pix {
+			alpha.Pix[i] = 0xff - a
+		}
+	}
+	return alpha, nil
+}
+
+// blendMasked returns the color part way from c to filtered by the mask value
+// a, all over 0xff being filtered.
+func blendMasked(c, filtered color.RGBA, a uint8) color.RGBA {
+	if a == 0xff {
+		return filtered
+	}
+	mix := func(from, to uint8) uint8 {
+		return uint8((int(from)*int(0xff-a) + int(to)*int(a) + 0x7f) / 0xff)
+	}
+	return color.RGBA{R: mix(c.R, filtered.R), G: mix(c.G, filtered.G), B: mix(c.B, filtered.B), A: mix(c.A, filtered.A)}
+}
@@ -0,0 +1,85 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestBlur(t *testing.T) {
+	m := speckled(t, color.RGBA{50, 50, 50, 255}) // no specks, just the edge
+	err := m.Blur(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := m.At(31, 20).(color.RGBA), m.At(32, 20).(color.RGBA)
+	if a.R <= 50 || b.R >= 200 || a.R >= b.R {
+		t.Errorf("edge is %v to %v after blurring, want it softened", a, b)
+	}
+	if got := m.At(5, 20); got != (color.RGBA{50, 50, 50, 255}) {
+		t.Errorf("pixel away from the edge is %v, want it unchanged", got)
+	}
+
+	if err := m.Blur(0); err == nil {
+		t.Error("zero sigma got no error")
+	}
+}
+
+func TestSetFilterMask(t *testing.T) {
+	// set over the left half
+	mask := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	op := mask.Draw()
+	op.SetColor(color.White)
+	op.DrawRectangle(0, 0, 32, 64)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dark, light := color.RGBA{50, 50, 50, 255}, color.RGBA{200, 200, 200, 255}
+
+	for _, inverted := range []bool{false, true} {
+		m := speckled(t, dark)
+		m.SetFilterMask(mask, inverted)
+		err := m.Blur(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		left, right := m.At(31, 20) != dark, m.At(32, 20) != light
+		if left == inverted || right != inverted {
+			t.Errorf("inverted %v: blurred left of the edge %v, right %v", inverted, left, right)
+		}
+	}
+
+	// blended in by the alpha of the mask
+	half := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	op = half.Draw()
+	op.SetColor(color.NRGBA{255, 255, 255, 128})
+	op.Clear()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := speckled(t, dark)
+	m.SetFilterMask(half, false)
+	err = m.Threshold(100) // dark to transparent, light to white
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pt, want := range map[image.Point]color.RGBA{{10, 20}: {25, 25, 25, 127}, {40, 20}: {228, 228, 228, 255}} {
+		if got := m.At(pt.X, pt.Y).(color.RGBA); absDiff(got.R, want.R) > 1 || absDiff(got.A, want.A) > 1 {
+			t.Errorf("half masked pixel %v is %v, want about %v", pt, got, want)
+		}
+	}
+
+	m.SetFilterMask(nil, false)
+	err = m.Threshold(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.At(40, 20); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("pixel without a filter mask is %v, want white", got)
+	}
+}
@@ -0,0 +1,167 @@
+package mimage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GeoTIFF tags & keys we use, see the OGC GeoTIFF standard.
+const (
+	tiffASCII  = 2
+	tiffDouble = 12
+
+	tagModelPixelScale     = 33550
+	tagModelTiepoint       = 33922
+	tagModelTransformation = 34264
+	tagGeoKeyDirectory     = 34735
+	tagGeoAsciiParams      = 34737
+
+	geoKeyModelType      = 1024
+	geoKeyRasterType     = 1025
+	geoKeyCitation       = 1026
+	geoKeyGeographicType = 2048
+	geoKeyProjectedType  = 3072
+
+	geoModelProjected    = 1
+	geoModelGeographic   = 2
+	geoUserDefined       = 32767
+	geoRasterPixelIsArea = 1
+)
+
+// GeoReference places an image on the earth, for GIS tools.
+type GeoReference struct {
+	// Transform maps pixel coordinates, where (0,0) is the top left corner of
+	// the image, to coordinates in the CRS. It must be affine (the bottom row
+	// is 0 0 1), see NorthUpTransform for the usual case.
+	Transform Matrix3
+
+	// CRS is the coordinate reference system, as "EPSG:<code>" (eg.
+	// "EPSG:4326" or "EPSG:3857"). Anything else is written as a description
+	// only, which GIS tools may not understand.
+	CRS string
+}
+
+// NorthUpTransform returns the transform of an image whose top left corner
+// is at (x,y) in the CRS and whose pixels are w by h units, with north up.
+func NorthUpTransform(x, y, w, h float64) Matrix3 {
+	return Matrix3{w, 0, x, 0, -h, y, 0, 0, 1}
+}
+
+// SetGeoReference georeferences the image, this is saved with the image and
+// written into exported TIFFs (making them GeoTIFFs). Passing nil removes it.
+func (m *Mimage) SetGeoReference(g *GeoReference) error {
+	if g != nil {
+		t := g.Transform
+		if t[6] != 0 || t[7] != 0 || t[8] != 1 {
+			return fmt.Errorf("geo transform must be affine")
+		}
+		if _, ok := t.Inverse(); !ok {
+			return fmt.Errorf("geo transform must be invertible")
+		}
+		cp := *g
+		g = &cp
+	}
+
+	m.metaLock.Lock()
+	defer m.metaLock.Unlock()
+
+	m.geo = g
+	return m.writeMetadata()
+}
+
+// GeoReference returns where the image is on the earth, or nil if it hasn't
+// been set.
+func (m *Mimage) GeoReference() *GeoReference {
+	m.metaLock.Lock()
+	defer m.metaLock.Unlock()
+
+	if m.geo == nil {
+		return nil
+	}
+	cp := *m.geo
+	return &cp
+}
+
+// geoFor returns the georeference of the part of the image starting at the
+// given point (in world space), or nil if the image isn't georeferenced.
+func (m *Mimage) geoFor(at image.Point) *GeoReference {
+	g := m.GeoReference()
+	if g == nil {
+		return nil
+	}
+	d := at.Sub(m.bounds.Min)
+	g.Transform = g.Transform.Multiply(TranslateMatrix(float64(d.X), float64(d.Y)))
+	return g
+}
+
+// epsgCode returns the code of an "EPSG:<code>" CRS.
+func epsgCode(crs string) (int, bool) {
+	s := strings.TrimSpace(crs)
+	if len(s) < 5 || !strings.EqualFold(s[:5], "EPSG:") {
+		return 0, false
+	}
+	code, err := strconv.Atoi(s[5:])
+	return code, err == nil && code > 0 && code < geoUserDefined
+}
+
+// doubleField returns a field holding float64 values.
+func doubleField(tag uint16, values ...float64) tiffField {
+	data := make([]byte, 8*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint64(data[i*8:], math.Float64bits(v))
+	}
+	return tiffField{tag: tag, typ: tiffDouble, count: uint32(len(values)), data: data}
+}
+
+// geoTIFFFields returns the TIFF fields georeferencing an image.
+func geoTIFFFields(g *GeoReference) []tiffField {
+	t := g.Transform
+	fields := []tiffField{}
+	if t[1] == 0 && t[3] == 0 {
+		// north up, the common case that every reader understands
+		fields = append(fields,
+			doubleField(tagModelPixelScale, t[0], -t[4], 0),
+			doubleField(tagModelTiepoint, 0, 0, 0, t[2], t[5], 0),
+		)
+	} else {
+		fields = append(fields, doubleField(tagModelTransformation,
+			t[0], t[1], 0, t[2],
+			t[3], t[4], 0, t[5],
+			0, 0, 0, 0,
+			0, 0, 0, 1,
+		))
+	}
+
+	// keys are (id, location, count, value) & must be sorted by id
+	keys := [][4]uint16{{geoKeyRasterType, 0, 1, geoRasterPixelIsArea}}
+	ascii := ""
+	code, ok := epsgCode(g.CRS)
+	switch {
+	case ok && code >= 4000 && code < 5000: // the EPSG range of geographic systems
+		keys = append(keys, [4]uint16{geoKeyModelType, 0, 1, geoModelGeographic}, [4]uint16{geoKeyGeographicType, 0, 1, uint16(code)})
+	case ok:
+		keys = append(keys, [4]uint16{geoKeyModelType, 0, 1, geoModelProjected}, [4]uint16{geoKeyProjectedType, 0, 1, uint16(code)})
+	default:
+		keys = append(keys, [4]uint16{geoKeyModelType, 0, 1, geoUserDefined})
+		if g.CRS != "" {
+			ascii = g.CRS + "|"
+			keys = append(keys, [4]uint16{geoKeyCitation, tagGeoAsciiParams, uint16(len(ascii)), 0})
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i][0] < keys[j][0] })
+
+	dir := []uint16{1, 1, 0, uint16(len(keys))} // version 1.1.0
+	for _, k := range keys {
+		dir = append(dir, k[:]...)
+	}
+	fields = append(fields, shortField(tagGeoKeyDirectory, dir...))
+	if ascii != "" {
+		fields = append(fields, tiffField{tag: tagGeoAsciiParams, typ: tiffASCII, count: uint32(len(ascii) + 1), data: append([]byte(ascii), 0)})
+	}
+	return fields
+}
@@ -0,0 +1,110 @@
+package mimage_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"math"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+// tiffDoubles reads n float64s at the given offset of a little endian tiff.
+func tiffDoubles(data []byte, at uint32, n int) []float64 {
+	vals := make([]float64, n)
+	for i := range vals {
+		vals[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[at+uint32(i)*8:]))
+	}
+	return vals
+}
+
+func TestSetGeoReference(t *testing.T) {
+	dir := t.TempDir()
+	m, err := mimage.New(image.Rect(0, 0, 64, 64), mimage.Directory(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := []mimage.Matrix3{
+		{1, 0, 0, 0, 1, 0, 1, 0, 1}, // not affine
+		{0, 0, 0, 0, 0, 0, 0, 0, 1}, // not invertible
+	}
+	for _, tr := range bad {
+		if err := m.SetGeoReference(&mimage.GeoReference{Transform: tr}); err == nil {
+			t.Errorf("transform %v got no error", tr)
+		}
+	}
+	if m.GeoReference() != nil {
+		t.Errorf("expected no georeference, got %v", m.GeoReference())
+	}
+
+	want := mimage.GeoReference{Transform: mimage.NorthUpTransform(500, 1000, 2, 4), CRS: "EPSG:3857"}
+	err = m.SetGeoReference(&want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := mimage.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	got := loaded.GeoReference()
+	if got == nil || *got != want {
+		t.Fatalf("reloaded georeference is %v, want %v", got, want)
+	}
+
+	err = loaded.SetGeoReference(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.GeoReference() != nil {
+		t.Errorf("georeference not removed")
+	}
+}
+
+func TestEncodeGeoTIFF(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64))
+	err := m.SetGeoReference(&mimage.GeoReference{Transform: mimage.NorthUpTransform(500, 1000, 2, 4), CRS: "EPSG:3857"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	err = m.EncodeRegion(image.Rect(10, 20, 42, 52), buf, mimage.FormatTIFF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	tags := tiffTags(t, data)
+
+	scale := tiffDoubles(data, tags[33550], 3)
+	if scale[0] != 2 || scale[1] != 4 {
+		t.Errorf("pixel scale is %v, want [2 4 0]", scale)
+	}
+	// the region starts 10,20 pixels into the image
+	tie := tiffDoubles(data, tags[33922], 6)
+	if tie[3] != 520 || tie[4] != 920 {
+		t.Errorf("tiepoint is %v, want (520, 920)", tie[3:5])
+	}
+
+	keys, ok := tags[34735]
+	if !ok {
+		t.Fatal("no geo key directory")
+	}
+	le := binary.LittleEndian
+	found := false
+	for i := 0; i < int(le.Uint16(data[keys+6:])); i++ {
+		k := data[keys+8+uint32(i)*8:]
+		if le.Uint16(k) == 3072 && le.Uint16(k[6:]) == 3857 { // projected CRS
+			found = true
+		}
+	}
+	if !found {
+		t.Error("projected CRS key not written")
+	}
+}
@@ -0,0 +1,44 @@
+package mimage
+
+import (
+	"math"
+)
+
+// Point is an (x,y) location in world space (that is, the space of the
+// whole massive image rather than any particular chunk).
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Path is a series of points, for some functions this is treated as
+// a closed polygon.
+type Path []Point
+
+// bounds returns the min & max (x,y) of all points in the path.
+func (p Path) bounds() (float64, float64, float64, float64) {
+	if len(p) == 0 {
+		return 0, 0, 0, 0
+	}
+	minX, minY, maxX, maxY := p[0].X, p[0].Y, p[0].X, p[0].Y
+	for _, pt := range p[1:] {
+		minX = math.Min(minX, pt.X)
+		minY = math.Min(minY, pt.Y)
+		maxX = math.Max(maxX, pt.X)
+		maxY = math.Max(maxY, pt.Y)
+	}
+	return minX, minY, maxX, maxY
+}
+
+// contains returns if (x,y) is inside the path, treating it as a closed
+// polygon (using the even-odd rule).
+func (p Path) contains(x, y float64) bool {
+	in := false
+	for i, j := 0, len(p)-1; i < len(p); j, i = i, i+1 {
+		a, b := p[i], p[j]
+		if (a.Y > y) != (b.Y > y) && x < (b.X-a.X)*(y-a.Y)/(b.Y-a.Y)+a.X {
+			in = !in
+		}
+	}
+	return in
+}
@@ -0,0 +1,157 @@
+package mimage
+
+import (
+	"bufio"
+	"compress/lzw"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// gifMaxSize is the largest width / height a GIF can have.
+const gifMaxSize = 0xffff
+
+// gifWriter writes GIF files a frame, and a row, at a time. All frames share
+// one global palette & cover the whole image.
+type gifWriter struct {
+	w           *bufio.Writer
+	width       int
+	height      int
+	transparent int // palette index, or -1
+	litWidth    int
+}
+
+// newGIFWriter writes the GIF header & palette.
+func newGIFWriter(w io.Writer, width, height int, pal color.Palette) (*gifWriter, error) {
+	if width > gifMaxSize || height > gifMaxSize {
+		return nil, fmt.Errorf("image of %dx%d is too large for a GIF", width, height)
+	}
+	if len(pal) == 0 || len(pal) > 256 {
+		return nil, fmt.Errorf("palette must have between 1 and 256 colors, given %d", len(pal))
+	}
+
+	// the color table size is a power of two, at least 2
+	bits := 1
+	for 1<<bits < len(pal) {
+		bits++
+	}
+
+	g := &gifWriter{w: bufio.NewWriter(w), width: width, height: height, transparent: -1, litWidth: maxInt(2, bits)}
+
+	header := make([]byte, 13)
+	copy(header, "GIF89a")
+	binary.LittleEndian.PutUint16(header[6:], uint16(width))
+	binary.LittleEndian.PutUint16(header[8:], uint16(height))
+	header[10] = 0x80 | 0x70 | uint8(bits-1) // global table, 8 bit color resolution, table size
+	g.w.Write(header)
+
+	table := make([]byte, 3<<bits)
+	for i, c := range pal {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		table[i*3], table[i*3+1], table[i*3+2] = n.R, n.G, n.B
+		if n.A == 0 && g.transparent < 0 {
+			g.transparent = i
+		}
+	}
+	_, err := g.w.Write(table)
+	return g, err
+}
+
+// loop writes the NETSCAPE extension so viewers repeat the animation, where
+// 0 is forever. This must be called before any frames.
+func (g *gifWriter) loop(count int) error {
+	ext := []byte{0x21, 0xff, 11, 'N', 'E', 'T', 'S', 'C', 'A', 'P', 'E', '2', '.', '0', 3, 1, 0, 0, 0}
+	binary.LittleEndian.PutUint16(ext[16:], uint16(count))
+	_, err := g.w.Write(ext)
+	return err
+}
+
+// frame writes a frame shown for delay hundredths of a second, calling
+// row(y, buf) for the palette indexes of each row in turn.
+func (g *gifWriter) frame(delay int, row func(y int, buf []byte) error) error {
+	if delay > 0 || g.transparent >= 0 {
+		gce := []byte{0x21, 0xf9, 4, 0, 0, 0, 0, 0}
+		if g.transparent >= 0 {
+			gce[3] = 1
+			gce[6] = uint8(g.transparent)
+		}
+		gce[3] |= 2 << 2 // dispose to background, so transparent areas don't show old frames
+		binary.LittleEndian.PutUint16(gce[4:], uint16(delay))
+		g.w.Write(gce)
+	}
+
+	desc := make([]byte, 10)
+	desc[0] = 0x2c
+	binary.LittleEndian.PutUint16(desc[5:], uint16(g.width))
+	binary.LittleEndian.PutUint16(desc[7:], uint16(g.height))
+	g.w.Write(desc)
+	g.w.WriteByte(uint8(g.litWidth))
+
+	blocks := &gifBlockWriter{w: g.w}
+	lw := lzw.NewWriter(blocks, lzw.LSB, g.litWidth)
+	buf := make([]byte, g.width)
+	for y := 0; y < g.height; y++ {
+		err := row(y, buf)
+		if err != nil {
+			return err
+		}
+		_, err = lw.Write(buf)
+		if err != nil {
+			return err
+		}
+	}
+	err := lw.Close()
+	if err != nil {
+		return err
+	}
+	return blocks.close()
+}
+
+// close writes the GIF trailer.
+func (g *gifWriter) close() error {
+	g.w.WriteByte(0x3b)
+	return g.w.Flush()
+}
+
+// gifBlockWriter splits data into the length prefixed sub-blocks GIF uses.
+type gifBlockWriter struct {
+	w   *bufio.Writer
+	buf [256]byte
+	n   int
+}
+
+// Write buffers p, writing full blocks.
+func (b *gifBlockWriter) Write(p []byte) (int, error) {
+	for i, c := range p {
+		b.buf[1+b.n] = c
+		b.n++
+		if b.n == 255 {
+			err := b.flush()
+			if err != nil {
+				return i, err
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// flush writes the buffered block, if any.
+func (b *gifBlockWriter) flush() error {
+	if b.n == 0 {
+		return nil
+	}
+	b.buf[0] = uint8(b.n)
+	_, err := b.w.Write(b.buf[:1+b.n])
+	b.n = 0
+	return err
+}
+
+// close writes any buffered block & the terminating empty block.
+func (b *gifBlockWriter) close() error {
+	err := b.flush()
+	if err != nil {
+		return err
+	}
+	return b.w.WriteByte(0)
+}
@@ -3,7 +3,8 @@ module github.com/voidshard/mimage
 go 1.17
 
 require (
-	github.com/fogleman/gg v1.3.0 // indirect
-	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
-	golang.org/x/image v0.3.0 // indirect
+	github.com/fogleman/gg v1.3.0
+	golang.org/x/image v0.3.0
 )
+
+require github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
@@ -0,0 +1,36 @@
+package mimage
+
+import (
+	"image/color"
+	"math"
+)
+
+// GradientMap recolors the image by looking up the luminance (0-255) of each
+// pixel in g at (luminance, 0), so g should run from (0,0) to (255,0), eg.
+//
+//	g := gg.NewLinearGradient(0, 0, 255, 0)
+//	g.AddColorStop(0, color.RGBA{0, 0, 128, 255})       // deep water
+//	g.AddColorStop(0.4, color.RGBA{240, 220, 160, 255}) // beach
+//	g.AddColorStop(1, color.White)                      // snow
+//
+// This is the usual way to turn a grayscale heightmap into terrain colors. The
+// alpha of each pixel is kept (multiplied by that of the gradient color).
+func (m *Mimage) GradientMap(g Gradient) error {
+	// gradients are sampled once up front, so g needn't be safe to use from
+	// many routines
+	table := [256]color.NRGBA{}
+	for i := range table {
+		table[i] = color.NRGBAModel.Convert(g.ColorAt(i, 0)).(color.NRGBA)
+	}
+
+	return m.mapPixels(m.bounds, func(x, y int, c color.RGBA) color.RGBA {
+		if c.A == 0 {
+			return c
+		}
+		s := unpremultiply(c)
+		l := 0.299*float64(s.R) + 0.587*float64(s.G) + 0.114*float64(s.B)
+		out := table[clampInt(int(math.Round(l)), 0, 255)]
+		out.A = uint8((int(out.A)*int(c.A) + 127) / 255)
+		return premultiply(out)
+	})
+}
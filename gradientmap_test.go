@@ -0,0 +1,46 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/fogleman/gg"
+)
+
+func TestGradientMap(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64))
+	op := m.Draw()
+	op.SetColor(color.Black)
+	op.DrawRectangle(0, 0, 16, 64)
+	op.Fill()
+	op.SetColor(color.White)
+	op.DrawRectangle(16, 0, 16, 64)
+	op.Fill()
+	op.SetColor(color.RGBA{0, 0, 0, 128})
+	op.DrawRectangle(32, 0, 16, 64)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := gg.NewLinearGradient(0, 0, 255, 0)
+	g.AddColorStop(0, color.RGBA{0, 0, 255, 255})
+	g.AddColorStop(1, color.RGBA{255, 0, 0, 255})
+	err = m.GradientMap(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for pt, want := range map[image.Point]color.RGBA{
+		{5, 5}:  {0, 0, 255, 255},
+		{20, 5}: {255, 0, 0, 255},
+		{40, 5}: {0, 0, 128, 128}, // alpha kept
+		{60, 5}: {},               // transparent left alone
+	} {
+		if got := m.At(pt.X, pt.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", pt, got, want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// GridOptions configures a coordinate grid drawn by DrawGrid.
+type GridOptions struct {
+	// Spacing between grid lines (in pixels) on each axis.
+	SpacingX float64
+	SpacingY float64
+
+	// Origin is where the grid starts, so cell (0,0) has its top left here.
+	Origin Point
+
+	Color     color.Color
+	LineWidth float64
+
+	// Dash, if given, draws dashed lines (see gg's SetDash).
+	Dash []float64
+
+	// Ticks, if given, draws only ticks of this length along the edges of
+	// the image (like a ruler) rather than lines across the whole image.
+	Ticks float64
+
+	// LabelEvery labels every N-th cell with its "column,row", zero means
+	// no labels.
+	LabelEvery int
+	LabelColor color.Color
+}
+
+// grid is a queued DrawGrid call.
+type grid struct {
+	opts   GridOptions
+	bounds image.Rectangle
+}
+
+// lines returns the positions of grid lines between min & max (inclusive)
+// along with the index of the first one.
+func gridLines(origin, spacing, min, max float64) ([]float64, int) {
+	first := int(math.Ceil((min - origin) / spacing))
+	out := []float64{}
+	for i := first; origin+float64(i)*spacing <= max; i++ {
+		out = append(out, origin+float64(i)*spacing)
+	}
+	return out, first
+}
+
+// render draws the part of the grid that overlaps the given image, where the
+// image origin is at (offX, offY) in world space. Returns if anything was drawn.
+//
+// A separate gg context is used so that the grid doesn't interfere with
+// (or get affected by) the state of the operation.
+func (g *grid) render(img *image.RGBA, offX, offY float64) bool {
+	opts := g.opts
+	dc := gg.NewContextForRGBA(img)
+
+	w := math.Max(1, opts.LineWidth)
+	minX, minY := offX-w, offY-w
+	maxX, maxY := offX+float64(dc.Width())+w, offY+float64(dc.Height())+w
+	top, left := float64(g.bounds.Min.Y), float64(g.bounds.Min.X)
+	bottom, right := float64(g.bounds.Max.Y), float64(g.bounds.Max.X)
+
+	xs, col0 := gridLines(opts.Origin.X, opts.SpacingX, minX, maxX)
+	ys, row0 := gridLines(opts.Origin.Y, opts.SpacingY, minY, maxY)
+
+	dc.SetColor(opts.Color)
+	dc.SetLineWidth(w)
+	if len(opts.Dash) > 0 {
+		dc.SetDash(opts.Dash...)
+	}
+
+	// lines are drawn only over this chunk (plus a little), so the dash offset
+	// is set to keep dashes lined up with their neighbours in other chunks
+	vertical := func(x, y0, y1 float64) {
+		dc.SetDashOffset(y0 - top)
+		dc.DrawLine(x-offX, y0-offY, x-offX, y1-offY)
+		dc.Stroke()
+	}
+	horizontal := func(y, x0, x1 float64) {
+		dc.SetDashOffset(x0 - left)
+		dc.DrawLine(x0-offX, y-offY, x1-offX, y-offY)
+		dc.Stroke()
+	}
+
+	drawn := false
+	for _, x := range xs {
+		if opts.Ticks > 0 {
+			vertical(x, top, top+opts.Ticks)
+			vertical(x, bottom-opts.Ticks, bottom)
+		} else {
+			vertical(x, math.Max(top, minY), math.Min(bottom, maxY))
+		}
+		drawn = true
+	}
+	for _, y := range ys {
+		if opts.Ticks > 0 {
+			horizontal(y, left, left+opts.Ticks)
+			horizontal(y, right-opts.Ticks, right)
+		} else {
+			horizontal(y, math.Max(left, minX), math.Min(right, maxX))
+		}
+		drawn = true
+	}
+
+	if opts.LabelEvery <= 0 {
+		return drawn
+	}
+
+	// labels are drawn in the top left of their cell, so cells just above
+	// or to the left of this chunk may have labels reaching into it
+	labelColor := opts.LabelColor
+	if labelColor == nil {
+		labelColor = opts.Color
+	}
+	dc.SetColor(labelColor)
+	pad := w + 2
+	for col := col0 - 1; col <= col0+len(xs); col++ {
+		if col%opts.LabelEvery != 0 {
+			continue
+		}
+		for row := row0 - 1; row <= row0+len(ys); row++ {
+			if row%opts.LabelEvery != 0 {
+				continue
+			}
+			x := opts.Origin.X + float64(col)*opts.SpacingX
+			y := opts.Origin.Y + float64(row)*opts.SpacingY
+			dc.DrawStringAnchored(fmt.Sprintf("%d,%d", col, row), x+pad-offX, y+pad-offY, 0, 1)
+			drawn = true
+		}
+	}
+
+	return drawn
+}
+
+// DrawGrid draws a coordinate grid (or ruler ticks) over the whole image, see
+// GridOptions. The grid is drawn with its own style, the current color, line
+// width etc. of the operation are unaffected.
+func (o *operation) DrawGrid(opts GridOptions) {
+	if opts.SpacingX <= 0 || opts.SpacingY <= 0 {
+		return
+	}
+	if opts.Color == nil {
+		opts.Color = color.Black
+	}
+	opts.Dash = append([]float64{}, opts.Dash...)
+
+	b := o.parent.Bounds()
+	o.minMax(float64(b.Min.X), float64(b.Min.Y))
+	o.minMax(float64(b.Max.X), float64(b.Max.Y))
+	o.queue.pushArgs(drawGrid, []interface{}{&grid{opts: opts, bounds: b}})
+}
@@ -0,0 +1,55 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestDrawGrid(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 128, 128), mimage.ChunkSize(32))
+	black := color.RGBA{0, 0, 0, 255}
+
+	op := m.Draw()
+	op.DrawGrid(mimage.GridOptions{SpacingX: 20, SpacingY: 20, Color: black, LineWidth: 2})
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// lines every 20 pixels, including across chunk edges
+	for _, p := range []image.Point{{20, 5}, {40, 70}, {60, 100}, {5, 20}, {70, 80}, {100, 33}} {
+		if got := m.At(p.X, p.Y); got != black {
+			t.Errorf("pixel %v on a grid line is %v, want %v", p, got, black)
+		}
+	}
+	for _, p := range []image.Point{{10, 10}, {50, 50}, {110, 90}} {
+		if got := m.At(p.X, p.Y); got != (color.RGBA{}) {
+			t.Errorf("pixel %v within a cell is %v, want it empty", p, got)
+		}
+	}
+}
+
+func TestDrawGridTicks(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 128, 128), mimage.ChunkSize(32))
+	black := color.RGBA{0, 0, 0, 255}
+
+	op := m.Draw()
+	op.DrawGrid(mimage.GridOptions{SpacingX: 20, SpacingY: 20, Color: black, LineWidth: 2, Ticks: 6})
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only ticks along the edges
+	for _, p := range []image.Point{{40, 2}, {40, 125}, {2, 60}, {125, 60}} {
+		if got := m.At(p.X, p.Y); got != black {
+			t.Errorf("tick pixel %v is %v, want %v", p, got, black)
+		}
+	}
+	if got := m.At(40, 60); got != (color.RGBA{}) {
+		t.Errorf("pixel (40,60) away from the edges is %v, want it empty", got)
+	}
+}
@@ -0,0 +1,85 @@
+package mimage
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Hillshade returns a new Mimage of shaded relief, treating this image as a
+// heightmap where the gray level (0-255) of each pixel is its height in
+// pixels. The light comes from the given azimuth (degrees clockwise from
+// north / up) & altitude (degrees above the horizon), GIS tools usually
+// default to 315 & 45.
+//
+// Each chunk is shaded in turn, reading a one pixel halo around it so there
+// are no seams.
+func (m *Mimage) Hillshade(azimuth, altitude float64) (*Mimage, error) {
+	out, err := New(m.bounds, ChunkSize(m.chunkSize), OperationRoutines(m.routines))
+	if err != nil {
+		return nil, err
+	}
+
+	zenith := (90 - altitude) * math.Pi / 180
+	az := math.Mod(360-azimuth+90, 360) * math.Pi / 180
+	cosZ, sinZ := math.Cos(zenith), math.Sin(zenith)
+
+	return out, out.eachChunk(m.bounds, func(ctx *context) error {
+		cb := out.chunkBounds(ctx.X, ctx.Y)
+		r := cb.Intersect(m.bounds)
+		if r.Empty() {
+			return nil
+		}
+
+		halo := m.halo(r, 1)
+		heights, err := m.heights(halo)
+		if err != nil {
+			return err
+		}
+		hw := halo.Dx()
+		at := func(x, y int) float64 {
+			// clamp to the edge of what was read
+			x = clampInt(x, halo.Min.X, halo.Max.X-1)
+			y = clampInt(y, halo.Min.Y, halo.Max.Y-1)
+			return heights[(y-halo.Min.Y)*hw+x-halo.Min.X]
+		}
+
+		dst := ctx.Img.Image().(*image.RGBA)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				// Horn's method over the 3x3 neighbourhood
+				a, b, c := at(x-1, y-1), at(x, y-1), at(x+1, y-1)
+				d, f := at(x-1, y), at(x+1, y)
+				g, h, i := at(x-1, y+1), at(x, y+1), at(x+1, y+1)
+				dzdx := ((c + 2*f + i) - (a + 2*d + g)) / 8
+				dzdy := ((g + 2*h + i) - (a + 2*b + c)) / 8
+
+				slope := math.Atan(math.Hypot(dzdx, dzdy))
+				aspect := math.Atan2(dzdy, -dzdx)
+				shade := cosZ*math.Cos(slope) + sinZ*math.Sin(slope)*math.Cos(az-aspect)
+
+				v := uint8(math.Max(0, math.Min(1, shade))*255 + 0.5)
+				dst.SetRGBA(x-cb.Min.X, y-cb.Min.Y, color.RGBA{R: v, G: v, B: v, A: 0xff})
+			}
+		}
+		ctx.setEdited()
+		return nil
+	})
+}
+
+// heights returns the gray level (0-255) of every pixel within r, row by row.
+func (m *Mimage) heights(r image.Rectangle) ([]float64, error) {
+	rgba, err := m.imageEdged(r)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]float64, r.Dx()*r.Dy())
+	for y := 0; y < r.Dy(); y++ {
+		for x := 0; x < r.Dx(); x++ {
+			p := rgba.Pix[rgba.PixOffset(x, y):]
+			out[y*r.Dx()+x] = 0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])
+		}
+	}
+	return out, nil
+}
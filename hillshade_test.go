@@ -0,0 +1,60 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestHillshade(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(color.RGBA{0, 0, 0, 255})
+	op.Clear()
+	// a slope rising to the east, flat past x=32
+	for x := 0; x < 32; x++ {
+		v := uint8(x * 4)
+		op.SetColor(color.RGBA{v, v, v, 255})
+		op.DrawRectangle(float64(x), 0, 1, 64)
+		op.Fill()
+	}
+	op.SetColor(color.RGBA{124, 124, 124, 255})
+	op.DrawRectangle(32, 0, 32, 64)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gray := func(img *mimage.Mimage, x, y int) uint8 {
+		return img.At(x, y).(color.RGBA).R
+	}
+
+	west, err := m.Hillshade(270, 45)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer west.Close()
+	east, err := m.Hillshade(90, 45)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer east.Close()
+
+	// flat ground is lit at cos(45 degrees) from any direction
+	for _, img := range []*mimage.Mimage{west, east} {
+		if v := gray(img, 50, 20); v != 180 {
+			t.Errorf("flat ground shaded %d, want 180", v)
+		}
+	}
+	// the slope faces west
+	if w, e := gray(west, 16, 31), gray(east, 16, 31); w <= 180 || e >= 180 {
+		t.Errorf("west facing slope shaded %d lit from the west & %d from the east", w, e)
+	}
+	// no seam across the chunk boundary at y=32
+	if a, b := gray(west, 16, 31), gray(west, 16, 32); a != b {
+		t.Errorf("shading changes across chunks, %d then %d", a, b)
+	}
+}
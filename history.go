@@ -0,0 +1,255 @@
+package mimage
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+// historyfile logs every operation done to the image, a line each
+const historyfile = ".mimage_history.jsonl"
+
+// callNames names each deferred function in the history
+var callNames = [...]string{
+	setFillStyle:         "SetFillStyle",
+	setStrokeStyle:       "SetStrokeStyle",
+	setLineWidth:         "SetLineWidth",
+	setColor:             "SetColor",
+	setPixel:             "SetPixel",
+	setMask:              "SetMask",
+	invertMask:           "InvertMask",
+	moveTo:               "MoveTo",
+	lineTo:               "LineTo",
+	closePath:            "ClosePath",
+	drawRectangle:        "DrawRectangle",
+	rotateAbout:          "RotateAbout",
+	drawEllipse:          "DrawEllipse",
+	fill:                 "Fill",
+	stroke:               "Stroke",
+	clear:                "Clear",
+	drawImage:            "DrawImage",
+	drawImageOp:          "DrawImageOp",
+	drawImageScaled:      "DrawImageScaled",
+	drawImageTransformed: "DrawImageTransformed",
+	drawStamps:           "StampAlongPath",
+	scatterStamps:        "Scatter",
+	drawTilemap:          "DrawTilemap",
+	drawGrid:             "DrawGrid",
+	applyMacro:           "ApplyMacro",
+	transform:            "Transform", // rotations carried over, folded into one
+	setFillRule:          "SetFillRule",
+	drawPolygon:          "DrawPolygon",
+	drawPolyline:         "DrawPolyline",
+	setLineDecoration:    "SetLineDecoration",
+	drawDashes:           "DrawDashed", // rectangles & ellipses, as their outline
+	strokeAlongPath:      "StrokeAlong",
+	setPaintMode:         "SetPaintMode",
+	addNoise:             "AddNoise",
+}
+
+// KeepHistory logs (on disk, with the image) every operation done to the
+// image, each call queued & its arguments, including any seeds, so how an
+// image came to be can be looked over with History & done again.
+//
+// Images drawn are logged by their size & a checksum of their pixels, rather
+// than whole.
+func KeepHistory() Option {
+	return func(m *Mimage) error {
+		m.keepHistory = true
+		return nil
+	}
+}
+
+// HistoryEntry is an operation done to the image (see KeepHistory).
+type HistoryEntry struct {
+	// Time the operation finished.
+	Time time.Time
+
+	// Bounds of what the operation drew on.
+	Bounds image.Rectangle
+
+	// Calls queued, in order. Those carried over from earlier operations
+	// done with the same Draw() (the color, line width, path not yet
+	// filled & so on) come first, so each entry can be done again alone.
+	Calls []HistoryCall
+}
+
+// HistoryCall is a function queued on an operation.
+type HistoryCall struct {
+	// Func is the name of the function, as on Operation, except that
+	// dashed shapes are all "DrawDashed" with their outline & rotations
+	// carried over from earlier operations are folded into one "Transform"
+	// (an angle, then the x & y to move by).
+	Func string
+
+	// Args are its arguments as they were logged; numbers, strings &
+	// slices as they are, structs (exported fields or not) as maps by
+	// field name & images as their size & checksum.
+	Args []interface{}
+}
+
+// History returns every operation logged (see KeepHistory), oldest first.
+func (m *Mimage) History() ([]*HistoryEntry, error) {
+	if !m.keepHistory {
+		return nil, fmt.Errorf("the image keeps no history, see KeepHistory")
+	}
+	f, err := os.Open(filepath.Join(m.root, historyfile))
+	if os.IsNotExist(err) {
+		return []*HistoryEntry{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []*HistoryEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, math.MaxInt32)
+	for scanner.Scan() {
+		entry := &HistoryEntry{}
+		err = json.Unmarshal(scanner.Bytes(), entry)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", historyfile, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// history returns the entry logging the operation as queued, having drawn on
+// the given area.
+func (o *operation) history(area image.Rectangle) *HistoryEntry {
+	entry := &HistoryEntry{Bounds: area, Calls: []HistoryCall{}}
+	for p := (cursor{}); p.fn < o.queue.len(); {
+		var action command
+		action, p = o.queue.at(p)
+		call := HistoryCall{Func: callNames[action.Func], Args: []interface{}{}}
+		for _, arg := range action.values() {
+			call.Args = append(call.Args, describeValue(reflect.ValueOf(arg), 0))
+		}
+		entry.Calls = append(entry.Calls, call)
+	}
+	return entry
+}
+
+// logHistory appends the entry to the history file.
+func (m *Mimage) logHistory(entry *HistoryEntry) error {
+	entry.Time = time.Now()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	m.metaLock.Lock()
+	defer m.metaLock.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(m.root, historyfile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// describeValue returns v (& whatever it points to) as something that can be
+// written as JSON (see HistoryCall).
+func describeValue(v reflect.Value, depth int) interface{} {
+	if depth > maxEffectDepth {
+		return "..."
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type() == reflect.TypeOf(&Mimage{}) {
+		if v.IsNil() || !v.CanInterface() {
+			return nil
+		}
+		return map[string]interface{}{"Mimage": v.Interface().(*Mimage).Directory()}
+	}
+	if v.Type().Implements(reflect.TypeOf((*image.Image)(nil)).Elem()) && v.CanInterface() {
+		if v.Kind() != reflect.Ptr || !v.IsNil() {
+			return describeImage(v.Interface().(image.Image))
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Sprint(f) // JSON has no such numbers
+		}
+		return f
+	case reflect.String:
+		return v.String()
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, v.Len())
+			for i := range data {
+				data[i] = byte(v.Index(i).Uint())
+			}
+			sum := sha256.Sum256(data) // eg. image pixels
+			return map[string]interface{}{"Len": len(data), "Sha256": hex.EncodeToString(sum[:])}
+		}
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			out[i] = describeValue(v.Index(i), depth+1)
+		}
+		return out
+	case reflect.Struct:
+		out := map[string]interface{}{}
+		for i := 0; i < v.NumField(); i++ {
+			out[v.Type().Field(i).Name] = describeValue(v.Field(i), depth+1)
+		}
+		return out
+	case reflect.Map:
+		out := map[string]interface{}{}
+		iter := v.MapRange()
+		for iter.Next() {
+			out[fmt.Sprint(describeValue(iter.Key(), depth+1))] = describeValue(iter.Value(), depth+1)
+		}
+		return out
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return describeValue(v.Elem(), depth+1)
+	default: // funcs, channels ..
+		return v.Type().String()
+	}
+}
+
+// describeImage returns the bounds & a checksum of the pixels of img.
+func describeImage(img image.Image) interface{} {
+	b := img.Bounds()
+	h := sha256.New()
+	buf := make([]byte, 0, b.Dx()*8)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		buf = buf[:0]
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			buf = append(buf, byte(r>>8), byte(r), byte(g>>8), byte(g), byte(bl>>8), byte(bl), byte(a>>8), byte(a))
+		}
+		h.Write(buf)
+	}
+	return map[string]interface{}{"Image": b, "Sha256": hex.EncodeToString(h.Sum(nil))}
+}
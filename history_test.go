@@ -0,0 +1,80 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/voidshard/mimage"
+	"github.com/voidshard/mimage/mimagetest"
+)
+
+func TestHistory(t *testing.T) {
+	dir := t.TempDir()
+	m, err := mimage.New(image.Rect(0, 0, 64, 64), mimage.Directory(dir), mimage.KeepHistory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := m.Draw()
+	op.SetColor(color.RGBA{255, 0, 0, 255})
+	op.RotateAbout(math.Pi/2, 32, 32)
+	op.DrawRectangle(10, 10, 5, 5)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	op.DrawImage(image.NewRGBA(image.Rect(0, 0, 2, 2)), 40, 40)
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the history is kept with the image
+	loaded, err := mimage.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	history, err := loaded.History()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("history has %d entries, want 2", len(history))
+	}
+	names := func(entry *mimage.HistoryEntry) []string {
+		out := []string{}
+		for _, call := range entry.Calls {
+			out = append(out, call.Func)
+		}
+		return out
+	}
+	if got := names(history[0]); len(got) != 4 || got[1] != "RotateAbout" || got[3] != "Fill" {
+		t.Errorf("first entry has calls %v", got)
+	}
+	// the second carries the color & rotation over, so can be done alone
+	if got := names(history[1]); len(got) != 3 || got[0] != "SetColor" || got[1] != "Transform" || got[2] != "DrawImage" {
+		t.Errorf("second entry has calls %v", got)
+	}
+	img, ok := history[1].Calls[2].Args[0].(map[string]interface{})
+	if !ok || img["Sha256"] == nil {
+		t.Errorf("drawn image is logged as %v, want its checksum", history[1].Calls[2].Args[0])
+	}
+	if history[0].Time.After(history[1].Time) || history[0].Bounds.Empty() {
+		t.Errorf("first entry at %v over %v", history[0].Time, history[0].Bounds)
+	}
+
+	plain := newImage(t, image.Rect(0, 0, 10, 10))
+	if _, err := plain.History(); err == nil {
+		t.Error("reading the history of an image keeping none got no error")
+	}
+	if _, err := mimage.New(image.Rect(0, 0, 10, 10), mimage.Storage(mimagetest.NewMemStore()), mimage.KeepHistory()); err == nil {
+		t.Error("keeping history without a directory got no error")
+	}
+}
@@ -0,0 +1,121 @@
+package mimage
+
+import (
+	"image"
+	"math"
+)
+
+// hitAlpha is how opaque a pixel must be to count as hit, so that the faint
+// anti-aliased edges of shapes don't count
+const hitAlpha = 128
+
+// IsOpaqueAt returns if the pixel at (x,y) is drawn on, being at least half
+// opaque, eg. for picking what's under the mouse. Chunks that have never
+// been drawn on aren't loaded.
+func (m *Mimage) IsOpaqueAt(x, y int) (bool, error) {
+	return m.hit(image.Rect(x, y, x+1, y+1), func(x, y int) bool { return true })
+}
+
+// BoundsOfOpaquePixels returns the smallest rectangle (in world space) within
+// r holding every pixel that's at least half opaque (see IsOpaqueAt), which
+// is empty if there are none, eg. for snapping a selection to what's drawn.
+func (m *Mimage) BoundsOfOpaquePixels(r image.Rectangle) (image.Rectangle, error) {
+	return m.contentWithin(r, hitAlpha)
+}
+
+// HitRect returns if any pixel within r is at least half opaque.
+func (m *Mimage) HitRect(r image.Rectangle) (bool, error) {
+	return m.hit(r, func(x, y int) bool { return true })
+}
+
+// HitEllipse returns if any pixel (by its center) within the ellipse centered
+// on (x,y) with radii rx & ry is at least half opaque.
+func (m *Mimage) HitEllipse(x, y, rx, ry float64) (bool, error) {
+	if rx <= 0 || ry <= 0 {
+		return false, nil
+	}
+	r := pathBounds(Path{{X: x - rx, Y: y - ry}, {X: x + rx, Y: y + ry}})
+	return m.hit(r, func(px, py int) bool {
+		dx, dy := (float64(px)+0.5-x)/rx, (float64(py)+0.5-y)/ry
+		return dx*dx+dy*dy <= 1
+	})
+}
+
+// HitPath returns if any pixel (by its center) within the path, treated as a
+// closed polygon, is at least half opaque, eg. for lasso selection.
+func (m *Mimage) HitPath(p Path) (bool, error) {
+	if len(p) < 3 {
+		return false, nil
+	}
+	return m.hit(pathBounds(p), func(x, y int) bool {
+		return p.contains(float64(x)+0.5, float64(y)+0.5)
+	})
+}
+
+// HitMask returns if any pixel under the mask, placed with its top left at
+// the given point (in world space), is at least half opaque where the mask is
+// at least half set. Masks can be made from other images (see Mask).
+func (m *Mimage) HitMask(mask *image.Alpha, at image.Point) (bool, error) {
+	offset := at.Sub(mask.Rect.Min)
+	return m.hit(mask.Rect.Add(offset), func(x, y int) bool {
+		return mask.AlphaAt(x-offset.X, y-offset.Y).A >= hitAlpha
+	})
+}
+
+// hit returns if any pixel within r (in world space) that inside returns
+// true for is at least half opaque. Chunks are checked one at a time, so that
+// we can stop at the first hit, & those never drawn on are skipped.
+func (m *Mimage) hit(r image.Rectangle, inside func(x, y int) bool) (bool, error) {
+	r = r.Intersect(m.bounds)
+	if r.Empty() {
+		return false, nil
+	}
+	size := m.chunkSize
+	for cy := floorDiv(r.Min.Y, size); cy <= floorDiv(r.Max.Y-1, size); cy++ {
+		for cx := floorDiv(r.Min.X, size); cx <= floorDiv(r.Max.X-1, size); cx++ {
+			if !m.cache.exists(cx, cy) {
+				continue
+			}
+			found, err := m.hitChunk(cx, cy, r, inside)
+			if found || err != nil {
+				return found, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// hitChunk returns if any pixel of the chunk within r that inside returns
+// true for is at least half opaque.
+func (m *Mimage) hitChunk(cx, cy int, r image.Rectangle, inside func(x, y int) bool) (bool, error) {
+	ctx, err := m.cache.Load(cx, cy)
+	defer ctx.Done()
+	if err != nil {
+		return false, err
+	}
+
+	cb := m.chunkBounds(cx, cy)
+	area := cb.Intersect(r)
+	img := ctx.Img.Image().(*image.RGBA)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		i := img.PixOffset(area.Min.X-cb.Min.X, y-cb.Min.Y)
+		for x := area.Min.X; x < area.Max.X; x++ {
+			if img.Pix[i+3] >= hitAlpha && inside(x, y) {
+				return true, nil
+			}
+			i += 4
+		}
+	}
+	return false, nil
+}
+
+// pathBounds returns the pixels (in world space) the given points lie within.
+func pathBounds(p Path) image.Rectangle {
+	minX, minY, maxX, maxY := p.bounds()
+	return image.Rect(
+		int(math.Floor(minX)),
+		int(math.Floor(minY)),
+		int(math.Ceil(maxX)),
+		int(math.Ceil(maxY)),
+	)
+}
@@ -0,0 +1,73 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestHitTest(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 96, 96), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(color.RGBA{255, 0, 0, 255})
+	op.DrawRectangle(40, 40, 10, 10)
+	op.Fill()
+	op.SetColor(color.NRGBA{0, 0, 255, 60}) // too faint to hit
+	op.DrawRectangle(70, 70, 10, 10)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(what string, got bool, err error, want bool) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s hit %v, want %v", what, got, want)
+		}
+	}
+	got, err := m.IsOpaqueAt(45, 45)
+	check("pixel on the square", got, err, true)
+	got, err = m.IsOpaqueAt(75, 75)
+	check("faint pixel", got, err, false)
+	got, err = m.HitRect(image.Rect(0, 0, 41, 41))
+	check("rectangle overlapping the corner", got, err, true)
+	got, err = m.HitRect(image.Rect(0, 0, 40, 40))
+	check("rectangle next to the corner", got, err, false)
+	// the bounding box of the ellipse overlaps the square, the ellipse doesn't
+	got, err = m.HitEllipse(35, 35, 6, 6)
+	check("ellipse short of the corner", got, err, false)
+	got, err = m.HitEllipse(35, 35, 9, 9)
+	check("ellipse over the corner", got, err, true)
+	got, err = m.HitPath(mimage.Path{{X: 20, Y: 20}, {X: 70, Y: 20}, {X: 20, Y: 70}})
+	check("triangle over the corner", got, err, true)
+	got, err = m.HitPath(mimage.Path{{X: 51, Y: 20}, {X: 90, Y: 20}, {X: 90, Y: 60}})
+	check("triangle beside the square", got, err, false)
+
+	mask := image.NewAlpha(image.Rect(0, 0, 4, 4))
+	mask.SetAlpha(3, 3, color.Alpha{255})
+	got, err = m.HitMask(mask, image.Pt(37, 37))
+	check("mask over the corner", got, err, true)
+	got, err = m.HitMask(mask, image.Pt(36, 36))
+	check("mask beside the corner", got, err, false)
+
+	b, err := m.BoundsOfOpaquePixels(image.Rect(0, 0, 96, 96))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := image.Rect(40, 40, 50, 50); b != want {
+		t.Errorf("opaque pixels are within %v, want %v", b, want)
+	}
+	b, err = m.BoundsOfOpaquePixels(image.Rect(45, 0, 96, 48))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := image.Rect(45, 40, 50, 48); b != want {
+		t.Errorf("opaque pixels within part are %v, want %v", b, want)
+	}
+}
@@ -0,0 +1,361 @@
+package mimage
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	// iccfile holds the ICC profile of an Mimage (if any) next to the metafile
+	iccfile = ".mimage_profile.icc"
+
+	// tagICCProfile is the TIFF tag holding an embedded ICC profile
+	tagICCProfile = 34675
+	tiffUndefined = 7
+
+	// jpegICCChunk is the most profile data that fits in one APP2 segment
+	jpegICCChunk = 65519
+
+	// iccLinearSteps is the size of our linear -> encoded lookup table
+	iccLinearSteps = 4096
+)
+
+// SetICCProfile attaches an ICC color profile to the image, describing what the
+// stored colors mean. The profile is saved with the image and embedded in
+// exported PNG, JPEG and TIFF files. Passing nil removes the profile.
+func (m *Mimage) SetICCProfile(profile []byte) error {
+	err := m.needsDirectory("an ICC profile")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(m.root, iccfile)
+
+	m.metaLock.Lock()
+	defer m.metaLock.Unlock()
+
+	if profile == nil {
+		m.icc = nil
+		err := os.Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	err = validICCProfile(profile)
+	if err != nil {
+		return err
+	}
+
+	m.icc = append([]byte{}, profile...)
+	return ioutil.WriteFile(path, m.icc, 0640)
+}
+
+// ICCProfile returns the ICC color profile attached to the image, or nil if
+// there isn't one.
+func (m *Mimage) ICCProfile() []byte {
+	m.metaLock.Lock()
+	defer m.metaLock.Unlock()
+
+	if m.icc == nil {
+		return nil
+	}
+	return append([]byte{}, m.icc...)
+}
+
+// readICCProfile reads the ICC profile saved in the given Mimage directory, if
+// there is one.
+func readICCProfile(root string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filepath.Join(root, iccfile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// ConvertProfile converts colors from the image's ICC profile to the given
+// profile on export, embedding the new profile in place of the image's own.
+//
+// Only RGB matrix / tone curve profiles (eg. sRGB, Display P3, Adobe RGB) are
+// supported; exports fail if either profile is of another kind or the image
+// has no profile attached.
+func ConvertProfile(profile []byte) ExportOption {
+	return func(c *exportConfig) {
+		c.profile = profile
+	}
+}
+
+// validICCProfile checks that data looks like an ICC profile.
+func validICCProfile(data []byte) error {
+	if len(data) < 132 || string(data[36:40]) != "acsp" {
+		return fmt.Errorf("data is not an ICC profile")
+	}
+	if int(binary.BigEndian.Uint32(data)) != len(data) {
+		return fmt.Errorf("ICC profile size %d does not match header %d", len(data), binary.BigEndian.Uint32(data))
+	}
+	return nil
+}
+
+// iccTags returns the tag table of a profile by signature.
+func iccTags(data []byte) (map[string][]byte, error) {
+	err := validICCProfile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	count := int(binary.BigEndian.Uint32(data[128:]))
+	if 132+count*12 > len(data) {
+		return nil, fmt.Errorf("ICC profile tag table is truncated")
+	}
+
+	tags := map[string][]byte{}
+	for i := 0; i < count; i++ {
+		entry := data[132+i*12:]
+		offset := int(binary.BigEndian.Uint32(entry[4:]))
+		size := int(binary.BigEndian.Uint32(entry[8:]))
+		if offset < 0 || size < 0 || offset+size > len(data) {
+			return nil, fmt.Errorf("ICC profile tag %q is out of bounds", entry[:4])
+		}
+		tags[string(entry[:4])] = data[offset : offset+size]
+	}
+
+	return tags, nil
+}
+
+// s15Fixed16 decodes the ICC fixed point number at the start of b.
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+// iccRGB is a matrix / tone curve RGB profile.
+type iccRGB struct {
+	toXYZ Matrix3         // linear RGB -> PCS XYZ
+	curve [3][256]float64 // encoded 8 bit value -> linear
+}
+
+// parseICCRGB reads the parts of an RGB matrix / TRC profile we need.
+func parseICCRGB(data []byte) (*iccRGB, error) {
+	tags, err := iccTags(data)
+	if err != nil {
+		return nil, err
+	}
+	if string(data[16:20]) != "RGB " || string(data[20:24]) != "XYZ " {
+		return nil, fmt.Errorf("only RGB profiles with an XYZ connection space are supported")
+	}
+
+	p := &iccRGB{}
+	for i, name := range []string{"r", "g", "b"} {
+		xyz, ok := tags[name+"XYZ"]
+		if !ok || len(xyz) < 20 || string(xyz[:4]) != "XYZ " {
+			return nil, fmt.Errorf("ICC profile is missing a valid %sXYZ tag", name)
+		}
+		p.toXYZ[i] = s15Fixed16(xyz[8:])
+		p.toXYZ[3+i] = s15Fixed16(xyz[12:])
+		p.toXYZ[6+i] = s15Fixed16(xyz[16:])
+
+		trc, ok := tags[name+"TRC"]
+		if !ok {
+			return nil, fmt.Errorf("ICC profile is missing a %sTRC tag", name)
+		}
+		for v := range p.curve[i] {
+			p.curve[i][v], err = iccCurve(trc, float64(v)/255)
+			if err != nil {
+				return nil, fmt.Errorf("ICC profile %sTRC: %w", name, err)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// iccCurve evaluates a 'curv' or 'para' tone curve at x (0-1).
+func iccCurve(tag []byte, x float64) (float64, error) {
+	if len(tag) < 12 {
+		return 0, fmt.Errorf("curve is truncated")
+	}
+
+	switch string(tag[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(tag[8:]))
+		if len(tag) < 12+n*2 {
+			return 0, fmt.Errorf("curve is truncated")
+		}
+		switch n {
+		case 0:
+			return x, nil
+		case 1:
+			return math.Pow(x, float64(binary.BigEndian.Uint16(tag[12:]))/256), nil
+		}
+		// linear interpolation of the table
+		fi := x * float64(n-1)
+		i := int(fi)
+		if i >= n-1 {
+			return float64(binary.BigEndian.Uint16(tag[12+(n-1)*2:])) / 0xffff, nil
+		}
+		a := float64(binary.BigEndian.Uint16(tag[12+i*2:])) / 0xffff
+		b := float64(binary.BigEndian.Uint16(tag[12+i*2+2:])) / 0xffff
+		return a + (b-a)*(fi-float64(i)), nil
+	case "para":
+		fn := int(binary.BigEndian.Uint16(tag[8:]))
+		params := []int{1, 3, 4, 5, 7}
+		if fn >= len(params) || len(tag) < 12+params[fn]*4 {
+			return 0, fmt.Errorf("unsupported parametric curve")
+		}
+		v := make([]float64, 7)
+		for i := 0; i < params[fn]; i++ {
+			v[i] = s15Fixed16(tag[12+i*4:])
+		}
+		g, a, b, c, d, e, f := v[0], v[1], v[2], v[3], v[4], v[5], v[6]
+		switch fn {
+		case 0:
+			return math.Pow(x, g), nil
+		case 1:
+			if x >= -b/a {
+				return math.Pow(a*x+b, g), nil
+			}
+			return 0, nil
+		case 2:
+			if x >= -b/a {
+				return math.Pow(a*x+b, g) + c, nil
+			}
+			return c, nil
+		case 3:
+			if x >= d {
+				return math.Pow(a*x+b, g), nil
+			}
+			return c * x, nil
+		default:
+			if x >= d {
+				return math.Pow(a*x+b, g) + e, nil
+			}
+			return c*x + f, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unsupported curve type %q", tag[:4])
+}
+
+// iccTransform converts 8 bit colors between two RGB profiles.
+type iccTransform struct {
+	toLinear [3][256]float64
+	matrix   Matrix3
+	toOutput [3][iccLinearSteps + 1]uint8
+}
+
+// newICCTransform returns a transform from the src to the dst profile.
+func newICCTransform(src, dst []byte) (*iccTransform, error) {
+	from, err := parseICCRGB(src)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseICCRGB(dst)
+	if err != nil {
+		return nil, err
+	}
+	fromXYZ, ok := to.toXYZ.Inverse()
+	if !ok {
+		return nil, fmt.Errorf("ICC profile has a singular color matrix")
+	}
+
+	t := &iccTransform{toLinear: from.curve, matrix: fromXYZ.Multiply(from.toXYZ)}
+
+	// invert the output curves by searching the (increasing) 8 bit curve
+	for i := range to.curve {
+		curve := to.curve[i][:]
+		for s := range t.toOutput[i] {
+			v := float64(s) / iccLinearSteps
+			j := sort.SearchFloat64s(curve, v)
+			switch {
+			case j == 0:
+				t.toOutput[i][s] = 0
+			case j >= len(curve):
+				t.toOutput[i][s] = 255
+			default:
+				lo, hi := curve[j-1], curve[j]
+				frac := 0.0
+				if hi > lo {
+					frac = (v - lo) / (hi - lo)
+				}
+				t.toOutput[i][s] = uint8(math.Round(float64(j-1) + frac))
+			}
+		}
+	}
+
+	return t, nil
+}
+
+// apply converts all colors of img (premultiplied) in place.
+func (t *iccTransform) apply(img *image.RGBA) {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			p := img.Pix[i : i+4 : i+4]
+			a := uint32(p[3])
+			if a == 0 {
+				continue
+			}
+
+			var lin [3]float64
+			for c := range lin {
+				lin[c] = t.toLinear[c][minInt(255, int((uint32(p[c])*0xff+a/2)/a))]
+			}
+			for c := range lin {
+				v := t.matrix[c*3]*lin[0] + t.matrix[c*3+1]*lin[1] + t.matrix[c*3+2]*lin[2]
+				out := t.toOutput[c][int(math.Max(0, math.Min(1, v))*iccLinearSteps+0.5)]
+				p[c] = uint8((uint32(out)*a + 0x7f) / 0xff)
+			}
+		}
+	}
+}
+
+// pngICCP returns a PNG iCCP chunk embedding the given profile. Like pHYs it
+// belongs after the IHDR chunk.
+func pngICCP(profile []byte) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("iCCP")
+	buf.WriteString("icc")
+	buf.Write([]byte{0, 0}) // name terminator, deflate compression
+	zw := zlib.NewWriter(buf)
+	zw.Write(profile)
+	zw.Close()
+
+	body := buf.Bytes()
+	chunk := make([]byte, 4, 4+len(body)+4)
+	binary.BigEndian.PutUint32(chunk, uint32(len(body)-4))
+	chunk = append(chunk, body...)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(body))
+	return append(chunk, crc...)
+}
+
+// jpegICC returns the APP2 segments embedding the given profile, split into
+// as many pieces as needed.
+func jpegICC(profile []byte) []byte {
+	count := (len(profile) + jpegICCChunk - 1) / jpegICCChunk
+	out := []byte{}
+	for i := 0; i < count; i++ {
+		piece := profile[i*jpegICCChunk : minInt(len(profile), (i+1)*jpegICCChunk)]
+		seg := []byte{0xff, 0xe2, 0, 0}
+		binary.BigEndian.PutUint16(seg[2:], uint16(2+12+2+len(piece)))
+		seg = append(seg, "ICC_PROFILE\x00"...)
+		seg = append(seg, byte(i+1), byte(count))
+		out = append(append(out, seg...), piece...)
+	}
+	return out
+}
+
+// tiffICC returns a TIFF field embedding the given profile.
+func tiffICC(profile []byte) tiffField {
+	return tiffField{tag: tagICCProfile, typ: tiffUndefined, count: uint32(len(profile)), data: profile}
+}
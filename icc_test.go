@@ -0,0 +1,148 @@
+package mimage_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+// iccProfile returns a minimal RGB matrix / tone curve ICC profile with sRGB
+// primaries and a simple gamma curve.
+func iccProfile(gamma float64) []byte {
+	be := binary.BigEndian
+	fixed := func(v float64) []byte {
+		b := make([]byte, 4)
+		be.PutUint32(b, uint32(int32(v*65536)))
+		return b
+	}
+	primaries := map[string][3]float64{
+		"rXYZ": {0.4361, 0.2225, 0.0139},
+		"gXYZ": {0.3851, 0.7169, 0.0971},
+		"bXYZ": {0.1431, 0.0606, 0.7141},
+	}
+
+	tags := map[string][]byte{}
+	for name, xyz := range primaries {
+		data := append([]byte("XYZ \x00\x00\x00\x00"), fixed(xyz[0])...)
+		data = append(data, fixed(xyz[1])...)
+		tags[name] = append(data, fixed(xyz[2])...)
+	}
+	curve := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00")
+	be.PutUint16(curve[12:], uint16(gamma*256))
+	for _, name := range []string{"rTRC", "gTRC", "bTRC"} {
+		tags[name] = curve
+	}
+
+	names := []string{"rXYZ", "gXYZ", "bXYZ", "rTRC", "gTRC", "bTRC"}
+	header := make([]byte, 128+4+12*len(names))
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB XYZ ")
+	copy(header[36:], "acsp")
+	be.PutUint32(header[128:], uint32(len(names)))
+	body := []byte{}
+	for i, name := range names {
+		entry := header[132+i*12:]
+		copy(entry, name)
+		be.PutUint32(entry[4:], uint32(len(header)+len(body)))
+		be.PutUint32(entry[8:], uint32(len(tags[name])))
+		body = append(body, tags[name]...)
+	}
+	profile := append(header, body...)
+	be.PutUint32(profile, uint32(len(profile)))
+	return profile
+}
+
+func TestSetICCProfile(t *testing.T) {
+	dir := t.TempDir()
+	m, err := mimage.New(image.Rect(0, 0, 64, 64), mimage.Directory(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetICCProfile([]byte("not a profile")); err == nil {
+		t.Error("setting junk as a profile got no error")
+	}
+	profile := iccProfile(2.2)
+	err = m.SetICCProfile(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := mimage.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	if !bytes.Equal(loaded.ICCProfile(), profile) {
+		t.Error("reloaded image doesn't have the profile")
+	}
+	err = loaded.SetICCProfile(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.ICCProfile() != nil {
+		t.Error("profile wasn't removed")
+	}
+}
+
+func TestConvertProfile(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64))
+	op := m.Draw()
+	op.SetColor(color.RGBA{128, 128, 128, 255})
+	op.Clear()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// there's nothing to convert from without a profile
+	if _, err := m.Export(m.Bounds(), mimage.ConvertProfile(iccProfile(2.2))); err == nil {
+		t.Error("converting an image without a profile got no error")
+	}
+
+	err = m.SetICCProfile(iccProfile(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same profile changes nothing, a gamma curve brightens linear grey
+	same, err := m.Export(m.Bounds(), mimage.ConvertProfile(iccProfile(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := same.At(10, 10).(color.RGBA); got.R < 127 || got.R > 129 {
+		t.Errorf("pixel converted to the same profile is %v, want it unchanged", got)
+	}
+	converted, err := m.Export(m.Bounds(), mimage.ConvertProfile(iccProfile(2.2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := converted.At(10, 10).(color.RGBA); got.R < 184 || got.R > 188 || got.R != got.G || got.A != 255 {
+		t.Errorf("pixel converted to gamma 2.2 is %v, want grey of about 186", got)
+	}
+
+	// the new profile is embedded in place of the image's own
+	buf := &bytes.Buffer{}
+	err = m.EncodeRegion(m.Bounds(), buf, mimage.FormatPNG, mimage.ConvertProfile(iccProfile(2.2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("iCCP")) {
+		t.Error("png has no iCCP chunk")
+	}
+	img, err := png.Decode(buf)
+	if err != nil {
+		t.Fatalf("png with a profile doesn't decode: %v", err)
+	}
+	if got := color.RGBAModel.Convert(img.At(10, 10)).(color.RGBA); got.R < 184 || got.R > 188 {
+		t.Errorf("encoded pixel is %v, want grey of about 186", got)
+	}
+}
@@ -0,0 +1,408 @@
+package mimage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// iiifContext is the JSON-LD context of IIIF Image API 3.0 documents
+	iiifContext = "http://iiif.io/api/image/3/context.json"
+
+	// defaultIIIFTileSize is the tile size advertised to viewers if not given
+	defaultIIIFTileSize = 512
+
+	// defaultIIIFMaxSize is the largest width / height we'll render if not given
+	defaultIIIFMaxSize = 4096
+)
+
+// IIIFOptions configures an IIIF image server.
+type IIIFOptions struct {
+	// ID is the base URI of the image (everything before /info.json). If not
+	// given it is worked out from each request.
+	ID string
+
+	// TileSize is the tile size viewers are told to ask for, defaults to 512.
+	TileSize int
+
+	// MaxWidth & MaxHeight limit the size of returned images, both default
+	// to 4096.
+	MaxWidth  int
+	MaxHeight int
+}
+
+// iiifServer serves an Mimage with the IIIF Image API.
+type iiifServer struct {
+	m    *Mimage
+	opts IIIFOptions
+}
+
+// IIIFHandler returns an http.Handler serving m with the IIIF Image API 3.0
+// (level 2), so that standard viewers (eg. Mirador, OpenSeadragon) can show
+// it. Requests are relative to wherever the handler is mounted, eg.
+//
+//	http.Handle("/iiif/map/", http.StripPrefix("/iiif/map", mimage.IIIFHandler(im, mimage.IIIFOptions{})))
+//
+// serves /iiif/map/info.json & /iiif/map/{region}/{size}/{rotation}/{quality}.{format}
+// where
+//   - region is full, square, x,y,w,h or pct:x,y,w,h
+//   - size is max, w,, ,h, pct:n, w,h or !w,h (prefixed with ^ to allow upscaling)
+//   - rotation is a multiple of 90 degrees, prefixed with ! to mirror first
+//   - quality is default, color, gray or bitonal
+//   - format is jpg, png, gif or tif
+//
+// Only the chunks under the requested region are read, and large regions
+// are box filtered down as they're read.
+func IIIFHandler(m *Mimage, opts IIIFOptions) http.Handler {
+	if opts.TileSize <= 0 {
+		opts.TileSize = defaultIIIFTileSize
+	}
+	if opts.MaxWidth <= 0 {
+		opts.MaxWidth = defaultIIIFMaxSize
+	}
+	if opts.MaxHeight <= 0 {
+		opts.MaxHeight = defaultIIIFMaxSize
+	}
+	return &iiifServer{m: m, opts: opts}
+}
+
+// ServeHTTP serves info.json or an image request.
+func (s *iiifServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.Trim(r.URL.Path, "/")
+	switch path {
+	case "":
+		// the base URI redirects to the image information
+		http.Redirect(w, r, strings.TrimSuffix(s.id(r), "/")+"/info.json", http.StatusSeeOther)
+		return
+	case "info.json":
+		s.serveInfo(w, r)
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) != 4 {
+		http.Error(w, "expected {region}/{size}/{rotation}/{quality}.{format}", http.StatusBadRequest)
+		return
+	}
+	status, err := s.serveImage(w, parts[0], parts[1], parts[2], parts[3])
+	if err != nil {
+		http.Error(w, err.Error(), status)
+	}
+}
+
+// id returns the base URI of the image.
+func (s *iiifServer) id(r *http.Request) string {
+	if s.opts.ID != "" {
+		return s.opts.ID
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	// RequestURI is the path before any prefix was stripped
+	path := r.URL.Path
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		path = u.Path
+	}
+	path = strings.TrimSuffix(strings.TrimSuffix(path, "/"), "/info.json")
+	return scheme + "://" + r.Host + path
+}
+
+// serveInfo writes the image information document.
+func (s *iiifServer) serveInfo(w http.ResponseWriter, r *http.Request) {
+	width, height := s.m.Width(), s.m.Height()
+
+	// halve until the whole image fits in a tile
+	factors := []int{1}
+	for f := 1; (maxInt(width, height)+f-1)/f > s.opts.TileSize; {
+		f *= 2
+		factors = append(factors, f)
+	}
+
+	info := map[string]interface{}{
+		"@context":  iiifContext,
+		"id":        s.id(r),
+		"type":      "ImageService3",
+		"protocol":  "http://iiif.io/api/image",
+		"profile":   "level2",
+		"width":     width,
+		"height":    height,
+		"maxWidth":  s.opts.MaxWidth,
+		"maxHeight": s.opts.MaxHeight,
+		"tiles": []map[string]interface{}{
+			{"width": s.opts.TileSize, "scaleFactors": factors},
+		},
+		"extraFormats":   []string{"gif", "tif"},
+		"extraQualities": []string{"color", "gray", "bitonal"},
+		"extraFeatures":  []string{"cors", "mirroring", "sizeUpscaling"},
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", fmt.Sprintf("application/ld+json;profile=%q", iiifContext))
+	w.Write(data)
+}
+
+// serveImage renders & writes an image request, returning a status code with
+// any error.
+func (s *iiifServer) serveImage(w http.ResponseWriter, region, size, rotation, qf string) (int, error) {
+	r, err := parseIIIFRegion(region, s.m.Bounds())
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	ow, oh, err := parseIIIFSize(size, r.Dx(), r.Dy(), s.opts.MaxWidth, s.opts.MaxHeight)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	turns, mirror, err := parseIIIFRotation(rotation)
+	if err != nil {
+		return http.StatusNotImplemented, err
+	}
+
+	dot := strings.LastIndex(qf, ".")
+	if dot < 0 {
+		return http.StatusBadRequest, fmt.Errorf("expected {quality}.{format}, given %q", qf)
+	}
+	quality, format := qf[:dot], qf[dot+1:]
+	var enc Encoder
+	var mime string
+	switch format {
+	case "jpg":
+		enc, mime = FormatJPEG, "image/jpeg"
+	case "png":
+		enc, mime = FormatPNG, "image/png"
+	case "tif":
+		enc, mime = FormatTIFF, "image/tiff"
+	case "gif":
+		enc, mime = PalettedGIF(PaletteOptions{}), "image/gif"
+	default:
+		return http.StatusUnsupportedMediaType, fmt.Errorf("unsupported format %q", format)
+	}
+	switch quality {
+	case "default", "color", "gray", "bitonal":
+	default:
+		return http.StatusBadRequest, fmt.Errorf("unsupported quality %q", quality)
+	}
+
+	img, err := s.render(r, ow, oh)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	img = rotate90s(img, turns, mirror)
+	applyIIIFQuality(img, quality)
+
+	buf := &bytes.Buffer{}
+	err = encodeWithInfo(buf, img, enc, fileInfo{icc: s.m.ICCProfile()})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	w.Header().Set("Content-Type", mime)
+	w.Header().Set("Link", `<http://iiif.io/api/image/3/level2.json>;rel="profile"`)
+	w.Write(buf.Bytes())
+	return http.StatusOK, nil
+}
+
+// render reads region r scaled to ow x oh.
+func (s *iiifServer) render(r image.Rectangle, ow, oh int) (*image.RGBA, error) {
+	return s.m.scaled(r, ow, oh)
+}
+
+// parseIIIFRegion returns the area of the image asked for, cropped to the
+// image bounds.
+func parseIIIFRegion(s string, bounds image.Rectangle) (image.Rectangle, error) {
+	w, h := bounds.Dx(), bounds.Dy()
+
+	var r image.Rectangle
+	switch {
+	case s == "full":
+		return bounds, nil
+	case s == "square":
+		side := minInt(w, h)
+		r = image.Rect((w-side)/2, (h-side)/2, (w-side)/2+side, (h-side)/2+side)
+	case strings.HasPrefix(s, "pct:"):
+		v, err := iiifNumbers(s[4:], 4)
+		if err != nil {
+			return r, fmt.Errorf("region: %w", err)
+		}
+		x0, y0 := v[0]*float64(w)/100, v[1]*float64(h)/100
+		r = image.Rect(int(math.Round(x0)), int(math.Round(y0)), int(math.Round(x0+v[2]*float64(w)/100)), int(math.Round(y0+v[3]*float64(h)/100)))
+	default:
+		v, err := iiifNumbers(s, 4)
+		if err != nil {
+			return r, fmt.Errorf("region: %w", err)
+		}
+		r = image.Rect(int(v[0]), int(v[1]), int(v[0]+v[2]), int(v[1]+v[3]))
+	}
+
+	r = r.Add(bounds.Min).Intersect(bounds)
+	if r.Empty() {
+		return r, fmt.Errorf("region %q is outside of the image", s)
+	}
+	return r, nil
+}
+
+// parseIIIFSize returns the output size for a region of rw x rh.
+func parseIIIFSize(s string, rw, rh, maxW, maxH int) (int, int, error) {
+	upscale := strings.HasPrefix(s, "^")
+	s = strings.TrimPrefix(s, "^")
+	fw, fh := float64(rw), float64(rh)
+
+	var ow, oh float64
+	switch {
+	case s == "max" || s == "full":
+		scale := math.Min(float64(maxW)/fw, float64(maxH)/fh)
+		if !upscale {
+			scale = math.Min(1, scale)
+		}
+		ow, oh = fw*scale, fh*scale
+	case strings.HasPrefix(s, "pct:"):
+		pct, err := strconv.ParseFloat(s[4:], 64)
+		if err != nil || pct <= 0 {
+			return 0, 0, fmt.Errorf("invalid size %q", s)
+		}
+		ow, oh = fw*pct/100, fh*pct/100
+	case strings.HasPrefix(s, "!"):
+		v, err := iiifNumbers(s[1:], 2)
+		if err != nil {
+			return 0, 0, fmt.Errorf("size: %w", err)
+		}
+		scale := math.Min(v[0]/fw, v[1]/fh)
+		ow, oh = fw*scale, fh*scale
+	case strings.HasSuffix(s, ","):
+		v, err := iiifNumbers(strings.TrimSuffix(s, ","), 1)
+		if err != nil {
+			return 0, 0, fmt.Errorf("size: %w", err)
+		}
+		ow, oh = v[0], fh*v[0]/fw
+	case strings.HasPrefix(s, ","):
+		v, err := iiifNumbers(strings.TrimPrefix(s, ","), 1)
+		if err != nil {
+			return 0, 0, fmt.Errorf("size: %w", err)
+		}
+		ow, oh = fw*v[0]/fh, v[0]
+	default:
+		v, err := iiifNumbers(s, 2)
+		if err != nil {
+			return 0, 0, fmt.Errorf("size: %w", err)
+		}
+		ow, oh = v[0], v[1]
+	}
+
+	w, h := maxInt(1, int(math.Round(ow))), maxInt(1, int(math.Round(oh)))
+	if !upscale && (w > rw || h > rh) {
+		return 0, 0, fmt.Errorf("size %q is larger than the region, prefix it with ^ to upscale", s)
+	}
+	if w > maxW || h > maxH {
+		return 0, 0, fmt.Errorf("size %dx%d is larger than the maximum %dx%d", w, h, maxW, maxH)
+	}
+	return w, h, nil
+}
+
+// parseIIIFRotation returns the number of clockwise quarter turns & whether
+// to mirror first.
+func parseIIIFRotation(s string) (int, bool, error) {
+	mirror := strings.HasPrefix(s, "!")
+	deg, err := strconv.ParseFloat(strings.TrimPrefix(s, "!"), 64)
+	if err != nil || deg < 0 || deg > 360 {
+		return 0, false, fmt.Errorf("invalid rotation %q", s)
+	}
+	if math.Mod(deg, 90) != 0 {
+		return 0, false, fmt.Errorf("only rotations by multiples of 90 degrees are supported")
+	}
+	return int(deg/90) % 4, mirror, nil
+}
+
+// iiifNumbers parses exactly n comma separated non negative numbers.
+func iiifNumbers(s string, n int) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d numbers, given %q", n, s)
+	}
+	out := make([]float64, n)
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid number %q", p)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// rotate90s returns img mirrored (left to right) if asked, then rotated
+// clockwise by the given number of quarter turns.
+func rotate90s(img *image.RGBA, turns int, mirror bool) *image.RGBA {
+	if turns == 0 && !mirror {
+		return img
+	}
+
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	ow, oh := w, h
+	if turns%2 == 1 {
+		ow, oh = h, w
+	}
+	out := image.NewRGBA(image.Rect(0, 0, ow, oh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sx := x
+			if mirror {
+				sx = w - 1 - x
+			}
+			dx, dy := x, y
+			switch turns {
+			case 1:
+				dx, dy = h-1-y, x
+			case 2:
+				dx, dy = w-1-x, h-1-y
+			case 3:
+				dx, dy = y, w-1-x
+			}
+			si, di := img.PixOffset(sx, y), out.PixOffset(dx, dy)
+			copy(out.Pix[di:di+4], img.Pix[si:si+4])
+		}
+	}
+	return out
+}
+
+// applyIIIFQuality converts img to gray or black & white in place.
+func applyIIIFQuality(img *image.RGBA, quality string) {
+	if quality != "gray" && quality != "bitonal" {
+		return
+	}
+	for i := 0; i < len(img.Pix); i += 4 {
+		p := img.Pix[i : i+4 : i+4]
+		// luminance of premultiplied colors is itself premultiplied
+		y := color.GrayModel.Convert(color.RGBA{R: p[0], G: p[1], B: p[2], A: p[3]}).(color.Gray).Y
+		if quality == "bitonal" {
+			if uint32(y)*0xff >= 0x80*uint32(p[3]) && p[3] > 0 {
+				y = p[3] // white
+			} else {
+				y = 0
+			}
+		}
+		p[0], p[1], p[2] = y, y, y
+	}
+}
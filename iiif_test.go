@@ -0,0 +1,103 @@
+package mimage_test
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestIIIFHandler(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 256, 256), mimage.ChunkSize(64))
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	op := m.Draw()
+	op.SetColor(red)
+	op.DrawRectangle(0, 0, 128, 256)
+	op.Fill()
+	op.SetColor(blue)
+	op.DrawRectangle(128, 0, 128, 256)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/iiif/map/", http.StripPrefix("/iiif/map", mimage.IIIFHandler(m, mimage.IIIFOptions{TileSize: 64})))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/iiif/map/info.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := struct {
+		ID     string
+		Width  int
+		Height int
+		Tiles  []struct{ ScaleFactors []int }
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != srv.URL+"/iiif/map" || info.Width != 256 || info.Height != 256 {
+		t.Errorf("info is %+v", info)
+	}
+	if len(info.Tiles) != 1 || len(info.Tiles[0].ScaleFactors) != 3 {
+		t.Errorf("tiles are %+v, want scale factors 1, 2 & 4", info.Tiles)
+	}
+
+	get := func(path string) (image.Image, int) {
+		resp, err := http.Get(srv.URL + "/iiif/map/" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, resp.StatusCode
+		}
+		img, err := png.Decode(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img, resp.StatusCode
+	}
+
+	// a region scaled down, then the whole image turned a half turn
+	img, _ := get("120,0,16,16/8,/0/default.png")
+	if b := img.Bounds(); b.Dx() != 8 || b.Dy() != 8 {
+		t.Errorf("scaled region is %v, want 8x8", b)
+	}
+	if got := color.RGBAModel.Convert(img.At(0, 4)); got != red {
+		t.Errorf("left of the region is %v, want %v", got, red)
+	}
+	if got := color.RGBAModel.Convert(img.At(7, 4)); got != blue {
+		t.Errorf("right of the region is %v, want %v", got, blue)
+	}
+	img, _ = get("full/max/180/default.png")
+	if got := color.RGBAModel.Convert(img.At(10, 10)); got != blue {
+		t.Errorf("top left of the turned image is %v, want %v", got, blue)
+	}
+	img, _ = get("full/64,/0/gray.png")
+	if r, g, b, _ := img.At(10, 10).RGBA(); r != g || g != b {
+		t.Errorf("gray pixel is %v", img.At(10, 10))
+	}
+
+	for path, want := range map[string]int{
+		"full/max/45/default.png":         http.StatusNotImplemented,
+		"full/max/0/default.webp":         http.StatusUnsupportedMediaType,
+		"full/max/0/sepia.png":            http.StatusBadRequest,
+		"300,300,10,10/max/0/default.png": http.StatusBadRequest,
+	} {
+		if _, status := get(path); status != want {
+			t.Errorf("%s got status %d, want %d", path, status, want)
+		}
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 )
 
 const (
@@ -28,8 +30,49 @@ type Mimage struct {
 	cache  *cache
 
 	root      string // path to Mimage files on disk
+	temporary bool   // if root was made by New (see Close)
 	chunkSize int
 	routines  int
+
+	metaLock     *sync.Mutex
+	annotations  []*Annotation
+	dpi          float64
+	icc          []byte
+	alpha        AlphaMode
+	timelapse    *timelapse
+	geo          *GeoReference
+	edges        EdgeMode
+	toroidal     bool
+	unbounded    bool
+	strictBounds bool
+	remote       string
+	fetch        Fetcher
+	renderer     Renderer
+	codec        Codec
+	clock        Clock
+
+	skipUnchanged bool
+	effects       *effects
+	keepHistory   bool
+
+	storage   ChunkStore // where the image is kept, if not in root
+	maxChunks int
+
+	evictPolicy EvictPolicy
+	evictAfter  time.Duration
+	writeBehind int
+	ioThrottle  int64
+
+	scheduleConcurrency int
+	sched               *scheduler
+
+	snapshotCopies bool
+	snaps          *snapshots
+
+	filterMask         *Mimage
+	filterMaskInverted bool
+
+	preview *Preview
 }
 
 // Draw performs a set of bounded write operation(s). Various functions are only
@@ -47,7 +90,7 @@ func (m *Mimage) Image(r image.Rectangle) (image.Image, error) {
 	dst := image.NewRGBA(r.Sub(r.Min))
 
 	for coord := range m.chunksWithin(r) {
-		i, err := m.cache.Load(coord[0], coord[1])
+		i, err := m.readChunk(coord[0], coord[1])
 		if err != nil {
 			i.Done()
 			return dst, err
@@ -94,12 +137,17 @@ func (m *Mimage) Mask(r image.Rectangle) (*image.Alpha, error) {
 
 // AtOk returns the color in our massive image at (x,y) along with error information
 func (m *Mimage) AtOk(x, y int) (color.Color, error) {
+	x, y, ok := m.edgePoint(x, y)
+	if !ok {
+		return color.RGBA{}, nil
+	}
+
 	cx, cy, valid := m.toChunk(x, y)
 	if !valid {
 		return color.RGBA{}, nil
 	}
 
-	i, err := m.cache.Load(cx, cy)
+	i, err := m.readChunk(cx, cy)
 	defer i.Done()
 	if err != nil {
 		return color.RGBA{}, err
@@ -115,7 +163,31 @@ func (m *Mimage) At(x, y int) color.Color {
 }
 
 // Flush ensures that each in memory chunk of the image is written to disk.
-func (m *Mimage) Flush() error { return m.cache.Flush() }
+func (m *Mimage) Flush() error {
+	err := m.cache.Flush()
+	if err != nil {
+		return err
+	}
+	return m.effects.compact()
+}
+
+// Close flushes the image & stops the routines it keeps in the background
+// (unloading idle chunks & writing them behind), after which it can't be
+// used. An image New made a temporary directory for (given neither Directory
+// nor Storage) isn't flushed, but removed along with its directory.
+func (m *Mimage) Close() error {
+	m.cache.close()
+	if m.temporary {
+		m.cache.drop()
+		return os.RemoveAll(m.root)
+	}
+	err := m.Flush()
+	if err != nil {
+		return err
+	}
+	m.cache.drop()
+	return nil
+}
 
 // Directory returns the root directory of the current massive image.
 func (m *Mimage) Directory() string { return m.root }
@@ -134,9 +206,9 @@ func (m *Mimage) Height() int { return m.bounds.Max.Y - m.bounds.Min.Y }
 
 // toChunk converts a given (x,y) in the larger image space to a particular image chunk.
 func (m *Mimage) toChunk(x, y int) (int, int, bool) {
-	cx := x / m.chunkSize
-	cy := y / m.chunkSize
-	valid := x >= m.bounds.Min.X && x < m.bounds.Max.Y && y >= m.bounds.Min.Y && y < m.bounds.Max.Y
+	cx := floorDiv(x, m.chunkSize)
+	cy := floorDiv(y, m.chunkSize)
+	valid := x >= m.bounds.Min.X && x < m.bounds.Max.X && y >= m.bounds.Min.Y && y < m.bounds.Max.Y
 	return cx, cy, valid
 }
 
@@ -144,15 +216,15 @@ func (m *Mimage) toChunk(x, y int) (int, int, bool) {
 func (m *Mimage) chunksWithin(r image.Rectangle) <-chan [2]int {
 	out := make(chan [2]int)
 
-	r = r.Intersect(m.bounds.Sub(image.Pt(1, 1))) // clamp r within bounds
+	r = r.Intersect(m.bounds) // clamp r within bounds
 
 	if r.Empty() { // nothing to do here
 		close(out)
 		return out
 	}
 
-	fx, fy, _ := m.toChunk(r.Min.X, r.Min.Y) // first chunk x,y
-	lx, ly, _ := m.toChunk(r.Max.X, r.Max.Y) // last chunk x,y
+	fx, fy, _ := m.toChunk(r.Min.X, r.Min.Y)     // first chunk x,y
+	lx, ly, _ := m.toChunk(r.Max.X-1, r.Max.Y-1) // last chunk x,y
 
 	go func() {
 		for x := fx; x <= lx; x++ {
@@ -166,41 +238,194 @@ func (m *Mimage) chunksWithin(r image.Rectangle) <-chan [2]int {
 	return out
 }
 
+// ChunkBounds returns the area (in world space) covered by the given chunk,
+// which may extend past the bounds of the image for chunks on the edge.
+func (m *Mimage) ChunkBounds(cx, cy int) image.Rectangle { return m.chunkBounds(cx, cy) }
+
+// chunkBounds returns the area (in world space) covered by the given chunk.
+func (m *Mimage) chunkBounds(cx, cy int) image.Rectangle {
+	min := image.Pt(cx*m.chunkSize, cy*m.chunkSize)
+	return image.Rectangle{Min: min, Max: min.Add(image.Pt(m.chunkSize, m.chunkSize))}
+}
+
+// eachChunk calls fn for every chunk within r (in world space) using the
+// configured number of routines. Chunks are loaded before fn is called and
+// released afterwards. All errors are rolled up and returned.
+func (m *Mimage) eachChunk(r image.Rectangle, fn func(ctx *context) error) error {
+	work := m.chunksWithin(r)
+
+	errs := make(chan error)
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < m.routines; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for coords := range work {
+				ctx, err := m.cache.Load(coords[0], coords[1])
+				if err == nil {
+					err = fn(ctx)
+				}
+				ctx.Done()
+				if err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(errs)
+	}()
+
+	return checkErrors(errs)
+}
+
+// mapPixels replaces each pixel within r (in world space) with the result of fn,
+// which is given the world space (x,y) and current (premultiplied) color of the
+// pixel. Chunks are processed in parallel, so fn must be safe to call from
+// multiple routines. Pixels are only changed where the filter mask (see
+// SetFilterMask) allows.
+func (m *Mimage) mapPixels(r image.Rectangle, fn func(x, y int, c color.RGBA) color.RGBA) error {
+	r = r.Intersect(m.bounds)
+
+	return m.eachChunk(r, func(ctx *context) error {
+		cb := m.chunkBounds(ctx.X, ctx.Y)
+		img := ctx.Img.Image().(*image.RGBA)
+		area := r.Intersect(cb)
+		mask, err := m.filterMaskWithin(area)
+		if err != nil {
+			return err
+		}
+
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			i := img.PixOffset(area.Min.X-cb.Min.X, y-cb.Min.Y)
+			for x := area.Min.X; x < area.Max.X; x++ {
+				p := img.Pix[i : i+4 : i+4]
+				i += 4
+				a := uint8(0xff)
+				if mask != nil {
+					a = mask.AlphaAt(x, y).A
+					if a == 0 {
+						continue
+					}
+				}
+				old := color.RGBA{p[0], p[1], p[2], p[3]}
+				c := blendMasked(old, fn(x, y, old), a)
+				p[0], p[1], p[2], p[3] = c.R, c.G, c.B, c.A
+			}
+		}
+		ctx.setEdited()
+
+		return nil
+	})
+}
+
+// paste copies img into the massive image with its top left corner at the
+// given point (in world space), replacing whatever was there.
+func (m *Mimage) paste(img image.Image, at image.Point) error {
+	src := img.Bounds()
+	area := src.Sub(src.Min).Add(at)
+
+	return m.eachChunk(area, func(ctx *context) error {
+		cb := m.chunkBounds(ctx.X, ctx.Y)
+		r := area.Intersect(cb)
+		draw.Draw(ctx.Img.Image().(*image.RGBA), r.Sub(cb.Min), img, src.Min.Add(r.Min.Sub(at)), draw.Src)
+		pasteStraight(ctx, r.Sub(cb.Min), img, src.Min.Add(r.Min.Sub(at)))
+		ctx.setEdited()
+		return nil
+	})
+}
+
 // New creates a new massive image.
 func New(r image.Rectangle, opts ...Option) (*Mimage, error) {
-	me := &Mimage{bounds: r, chunkSize: defaultChunkSize, routines: defaultRoutines}
+	me := &Mimage{
+		bounds:    r,
+		chunkSize: defaultChunkSize,
+		routines:  defaultRoutines,
+		metaLock:  &sync.Mutex{},
+		maxChunks: defaultMaxChunks,
+	}
 	for _, opt := range opts {
 		err := opt(me)
 		if err != nil {
 			return nil, err
 		}
 	}
+	err := me.checkOptions()
+	if err != nil {
+		return nil, err
+	}
 
-	if me.root == "" {
+	if me.root == "" && me.storage == nil {
 		// if we don't have a folder, make one
 		root, err := os.MkdirTemp("", "mimage")
 		if err != nil {
 			return nil, err
 		}
 		me.root = root
+		me.temporary = true
+	}
+	me.cache = newCache(me.root, me.chunkSize, me.alpha)
+	me.cache.setStore(me.storage, me.maxChunks)
+	me.cache.setEviction(me.eviction())
+	me.cache.setWriteBehind(me.writeBehind)
+	me.cache.setThrottle(me.ioThrottle)
+	me.cache.setClock(me.clock)
+	me.cache.setFetcher(me.fetch)
+	me.cache.setRenderer(me.renderer)
+	me.cache.setCodec(me.codec)
+	if me.skipUnchanged {
+		me.effects, err = newEffects(me.root)
+		if err != nil {
+			return nil, err
+		}
 	}
-	me.cache = newCache(me.root, me.chunkSize)
 
-	// save metadata file
+	return me, me.writeMetadata()
+}
+
+// writeMetadata saves the current metadata file to disk, overwriting
+// whatever was there. The caller is expected to hold metaLock if the
+// Mimage is in use elsewhere.
+func (m *Mimage) writeMetadata() error {
 	data, err := encodeJSON(&metadata{
-		BoundsMinX: r.Min.X,
-		BoundsMinY: r.Min.Y,
-		BoundsMaxX: r.Max.X,
-		BoundsMaxY: r.Max.Y,
-		ChunkSize:  me.chunkSize,
-		Routines:   me.routines,
+		BoundsMinX:  m.bounds.Min.X,
+		BoundsMinY:  m.bounds.Min.Y,
+		BoundsMaxX:  m.bounds.Max.X,
+		BoundsMaxY:  m.bounds.Max.Y,
+		ChunkSize:   m.chunkSize,
+		Routines:    m.routines,
+		Alpha:       m.alpha,
+		DPI:         m.dpi,
+		Timelapse:   timelapseSize(m.timelapse),
+		Geo:         m.geo,
+		Edges:       m.edges,
+		Toroidal:    m.toroidal,
+		Unbounded:   m.unbounded,
+		Strict:      m.strictBounds,
+		Remote:      m.remote,
+		Skip:        m.skipUnchanged,
+		History:     m.keepHistory,
+		MaxChunks:   m.maxChunks,
+		Eviction:    m.evictPolicy,
+		EvictAfter:  m.evictAfter,
+		WriteBehind: m.writeBehind,
+		Throttle:    m.ioThrottle,
+		Concurrency: m.scheduleConcurrency,
+		Copies:      m.snapshotCopies,
+		Annotations: m.annotations,
 	})
 	if err != nil {
-		return nil, err
+		return err
 	}
-	err = ioutil.WriteFile(filepath.Join(me.root, metafile), data, 0640)
-
-	return me, err
+	if m.storage != nil {
+		return writeAll(m.storage, metafile, data)
+	}
+	return ioutil.WriteFile(filepath.Join(m.root, metafile), data, 0640)
 }
 
 // Load a mimage by pointing to it's directory.
@@ -223,11 +448,75 @@ func Load(rootdir string) (*Mimage, error) {
 		return nil, err
 	}
 	root := filepath.Dir(metafile)
-	return &Mimage{
-		bounds:    image.Rect(meta.BoundsMinX, meta.BoundsMinY, meta.BoundsMaxX, meta.BoundsMaxY),
-		root:      root,
-		cache:     newCache(root, meta.ChunkSize),
-		chunkSize: meta.ChunkSize,
-		routines:  meta.Routines,
-	}, nil
+	icc, err := readICCProfile(root)
+	if err != nil {
+		return nil, err
+	}
+	return fromMetadata(meta, root, icc, nil)
+}
+
+// fromMetadata returns the Mimage described by the given metadata, kept in
+// root or if given, store.
+func fromMetadata(meta *metadata, root string, icc []byte, store ChunkStore) (*Mimage, error) {
+	bounds := image.Rect(meta.BoundsMinX, meta.BoundsMinY, meta.BoundsMaxX, meta.BoundsMaxY)
+	var tl *timelapse
+	if meta.Timelapse > 0 {
+		tl = newTimelapse(bounds, meta.Timelapse)
+	}
+	maxChunks := meta.MaxChunks
+	if maxChunks == 0 {
+		maxChunks = defaultMaxChunks
+	}
+	me := &Mimage{
+		bounds:       bounds,
+		root:         root,
+		cache:        newCache(root, meta.ChunkSize, meta.Alpha),
+		chunkSize:    meta.ChunkSize,
+		routines:     meta.Routines,
+		metaLock:     &sync.Mutex{},
+		annotations:  meta.Annotations,
+		dpi:          meta.DPI,
+		icc:          icc,
+		alpha:        meta.Alpha,
+		timelapse:    tl,
+		geo:          meta.Geo,
+		edges:        meta.Edges,
+		toroidal:     meta.Toroidal,
+		unbounded:    meta.Unbounded,
+		strictBounds: meta.Strict,
+		remote:       meta.Remote,
+
+		skipUnchanged: meta.Skip,
+		keepHistory:   meta.History,
+
+		storage:   store,
+		maxChunks: maxChunks,
+
+		evictPolicy: meta.Eviction,
+		evictAfter:  meta.EvictAfter,
+		writeBehind: meta.WriteBehind,
+		ioThrottle:  meta.Throttle,
+
+		scheduleConcurrency: meta.Concurrency,
+
+		snapshotCopies: meta.Copies,
+	}
+	me.cache.setStore(store, maxChunks)
+	me.cache.setEviction(me.eviction())
+	me.cache.setWriteBehind(me.writeBehind)
+	me.cache.setThrottle(me.ioThrottle)
+	err := me.checkOptions()
+	if err != nil {
+		return nil, err
+	}
+	if me.remote != "" {
+		me.SetRemote(urlFetcher(me.remote))
+	}
+	if me.skipUnchanged {
+		me.effects, err = newEffects(root)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return me, nil
 }
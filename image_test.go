@@ -0,0 +1,129 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/voidshard/mimage"
+)
+
+// newImage returns an image with the given bounds, kept in a directory that's
+// removed when the test ends.
+func newImage(t *testing.T, r image.Rectangle, opts ...mimage.Option) *mimage.Mimage {
+	t.Helper()
+	m, err := mimage.New(r, append([]mimage.Option{mimage.Directory(t.TempDir())}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+// settledGoroutines returns the number of goroutines, once those that are
+// ending have had a chance to.
+func settledGoroutines(want int) int {
+	n := runtime.NumGoroutine()
+	for i := 0; i < 100 && n > want; i++ {
+		time.Sleep(5 * time.Millisecond)
+		n = runtime.NumGoroutine()
+	}
+	return n
+}
+
+func TestCloseStopsRoutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	m, err := mimage.New(image.Rect(0, 0, 320, 320), mimage.ChunkSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.DrawEllipse(160, 160, 100, 50)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the image's own chunks each have a routine unloading them, filters
+	// mustn't leave those of their intermediate images behind
+	carved, err := m.SeamCarve(200, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = carved.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := 5 * 5
+	if n := settledGoroutines(before + chunks); n > before+chunks {
+		t.Errorf("%d goroutines running for an image of %d chunks, %d before", n, chunks, before)
+	}
+
+	dir := m.Directory()
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := settledGoroutines(before); n > before {
+		t.Errorf("%d goroutines running after Close, %d before", n, before)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("temporary directory %s is still there (%v)", dir, err)
+	}
+}
+
+func TestCloseFlushes(t *testing.T) {
+	dir := t.TempDir()
+	m, err := mimage.New(image.Rect(0, 0, 100, 100), mimage.Directory(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.Clear()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := mimage.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	if got := loaded.At(50, 50); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("reloaded pixel is %v, want white", got)
+	}
+}
+
+func TestImageLastColumn(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.DrawRectangle(63, 0, 1, 64)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := m.Image(image.Rect(63, 10, 64, 40))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 30; y++ {
+		if got := img.At(0, y); got != (color.RGBA{255, 255, 255, 255}) {
+			t.Fatalf("pixel (63,%d) read as %v, want white", y+10, got)
+		}
+	}
+}
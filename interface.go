@@ -12,24 +12,48 @@ type Operation interface {
 	SetStrokeStyle(g Gradient)
 	SetLineWidth(w float64)
 	SetColor(c color.Color)
+	SetFillRule(r FillRule)
+	SetLineDecoration(d LineDecoration)
+	SetPaintMode(mode PaintMode)
 	SetPixel(x, y int)
 
 	MoveTo(x, y float64)
 	LineTo(x, y float64)
 	ClosePath()
+	DrawPolygon(points []Point)
+	DrawPolyline(points []Point)
+	DrawSpline(points []Point, tension float64)
 
 	DrawRectangle(x, y, w, h float64)
 	RotateAbout(angle, x, y float64)
 	DrawEllipse(x, y, rx, ry float64)
+	DrawDashedRectangle(x, y, w, h float64, dashes ...float64)
+	DrawDashedEllipse(x, y, rx, ry float64, dashes ...float64)
+	DrawRegularPolygon(n int, x, y, r, rotation float64)
+	DrawStar(n int, x, y, outer, inner, rotation float64)
+	DrawPie(x, y, r, angle1, angle2 float64)
 
 	Fill()
 	Stroke()
+	StrokeAlong(g Gradient)
 
 	Clear()
 
 	SetMask(mask *Mimage)
 	InvertMask()
 	DrawImage(in image.Image, x, y int)
+	DrawImageOp(in image.Image, x, y int, mode CompositeMode, opacity float64)
+	DrawImageScaled(in image.Image, dst image.Rectangle, filter ResampleFilter)
+	DrawNineSlice(in image.Image, center image.Rectangle, dst image.Rectangle, filter ResampleFilter)
+	DrawImageTransformed(in image.Image, t Matrix3)
+
+	StampAlongPath(img image.Image, points []Point, spacing float64, jitter StampJitter)
+	Scatter(img image.Image, region Path, density float64, seed int64)
+	DrawTilemap(tileset image.Image, tileW, tileH int, indices [][]int, x, y int)
+	DrawGrid(opts GridOptions)
+	ApplyMacro(m *Macro, x, y, scale float64)
+	DrawContours(contours []Contour)
+	AddNoise(r image.Rectangle, amount float64)
 
 	// Do performs the given operation.
 	//
@@ -45,9 +69,33 @@ type Operation interface {
 	// Do() returns when all required chunks have been edited,
 	// they may not necessarily be flushed from memory (for
 	// that see the Flush function). Or when an error is raised.
+	//
+	// An operation can be used again after Do(); anything already
+	// drawn isn't drawn again, but drawing state (color, line width,
+	// mask, transforms and any path not yet filled or stroked) carries
+	// on as if all calls had been queued before a single Do().
 	Do() error
 
+	// Repeat applies all queued functions once at each of the given
+	// offsets, loading each chunk only once.
+	Repeat(offsets []image.Point)
+
+	// OnChunkDone sets a function called as each chunk is finished
+	// during Do(), so results can be used before Do() returns. It's called
+	// from many routines at once, so must be safe for concurrent use.
+	OnChunkDone(fn func(cx, cy int, err error))
+
+	// Plan returns the chunks that Do() would change & estimates of the
+	// work involved, without changing anything.
+	Plan() OperationPlan
+
 	// SetRoutines for this operation (defaults to option value
 	// given to Mimage on creation).
 	SetRoutines(i int)
+
+	// SetSeed sets a seed mixed into every random choice made by
+	// functions called after it (Scatter, StampAlongPath & AddNoise).
+	// Random choices never depend on the order chunks are drawn in, or
+	// how many routines draw them.
+	SetSeed(seed int64)
 }
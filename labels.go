@@ -0,0 +1,269 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+	"sync"
+)
+
+// maxLabels is the most labels that fit in the rgb of a pixel
+const maxLabels = 1<<24 - 1
+
+// labelChunk is what the first pass of Labels learns about a chunk.
+type labelChunk struct {
+	count int   // labels within the chunk, numbered from 1
+	base  int32 // added to chunk labels to make them unique over the image
+
+	// chunk labels along each side, 0 where there's nothing
+	top, bottom, left, right []int32
+}
+
+// Labels finds connected components of a mask; groups of pixels with alpha
+// of at least threshold that touch (above, below, left or right). Returns
+// a new Mimage of the same size where each component is filled with its
+// label number (from 1, see LabelOf) & everything else is transparent, along
+// with the number of labels.
+//
+// Chunks are labelled in parallel, the labels that meet along chunk edges
+// are then merged & finally every chunk is renumbered, so only chunk edges
+// are ever held in memory.
+func (m *Mimage) Labels(threshold uint8) (*Mimage, int, error) {
+	out, err := New(m.bounds, ChunkSize(m.chunkSize), OperationRoutines(m.routines))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// label each chunk on its own
+	chunks := map[[2]int]*labelChunk{}
+	lock := &sync.Mutex{}
+	err = out.eachChunk(m.bounds, func(ctx *context) error {
+		cb := out.chunkBounds(ctx.X, ctx.Y)
+		r := cb.Intersect(m.bounds)
+		if r.Empty() {
+			return nil
+		}
+		mask, err := m.Mask(r)
+		if err != nil {
+			return err
+		}
+
+		found := labelChunkPixels(ctx.Img.Image().(*image.RGBA), r.Sub(cb.Min), mask, threshold)
+		ctx.setEdited()
+
+		lock.Lock()
+		defer lock.Unlock()
+		chunks[[2]int{ctx.X, ctx.Y}] = found
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	final, err := mergeLabels(chunks)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// renumber chunks with the merged labels
+	err = out.eachChunk(m.bounds, func(ctx *context) error {
+		lc, ok := chunks[[2]int{ctx.X, ctx.Y}]
+		if !ok {
+			return nil
+		}
+		img := ctx.Img.Image().(*image.RGBA)
+		for i := 0; i < len(img.Pix); i += 4 {
+			p := img.Pix[i : i+4 : i+4]
+			if p[3] == 0 {
+				continue
+			}
+			p[0], p[1], p[2] = labelRGB(final[lc.base+rgbLabel(p)])
+		}
+		ctx.setEdited()
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count := 0
+	for _, f := range final {
+		if int(f) > count {
+			count = int(f)
+		}
+	}
+	return out, count, nil
+}
+
+// LabelOf returns the label number of a pixel of the image returned by
+// Labels, 0 meaning no label.
+func LabelOf(c color.Color) int {
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		return 0
+	}
+	return int(r>>8)<<16 | int(g>>8)<<8 | int(b>>8)
+}
+
+// labelRGB returns the rgb encoding of a label.
+func labelRGB(l int32) (uint8, uint8, uint8) {
+	return uint8(l >> 16), uint8(l >> 8), uint8(l)
+}
+
+// rgbLabel returns the label encoded in the rgb of a pixel.
+func rgbLabel(p []uint8) int32 {
+	return int32(p[0])<<16 | int32(p[1])<<8 | int32(p[2])
+}
+
+// labelChunkPixels writes the labels of the pixels of mask into dst at r, in
+// the usual two passes; provisional labels with equivalences followed by
+// renumbering in the order labels are first seen.
+func labelChunkPixels(dst *image.RGBA, r image.Rectangle, mask *image.Alpha, threshold uint8) *labelChunk {
+	w, h := r.Dx(), r.Dy()
+	labels := make([]int32, w*h)
+	parent := []int32{0}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if mask.Pix[mask.PixOffset(x, y)] < threshold {
+				continue
+			}
+			up, left := int32(0), int32(0)
+			if y > 0 {
+				up = labels[(y-1)*w+x]
+			}
+			if x > 0 {
+				left = labels[y*w+x-1]
+			}
+
+			switch {
+			case up == 0 && left == 0:
+				parent = append(parent, int32(len(parent)))
+				labels[y*w+x] = int32(len(parent) - 1)
+			case up == 0:
+				labels[y*w+x] = left
+			case left == 0:
+				labels[y*w+x] = up
+			default:
+				labels[y*w+x] = unionLabels(parent, up, left)
+			}
+		}
+	}
+
+	found := &labelChunk{
+		top: make([]int32, w), bottom: make([]int32, w),
+		left: make([]int32, h), right: make([]int32, h),
+	}
+	renumber := make([]int32, len(parent))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			l := labels[y*w+x]
+			if l == 0 {
+				continue
+			}
+			root := findLabel(parent, l)
+			if renumber[root] == 0 {
+				found.count++
+				renumber[root] = int32(found.count)
+			}
+			l = renumber[root]
+
+			i := dst.PixOffset(r.Min.X+x, r.Min.Y+y)
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2] = labelRGB(l)
+			dst.Pix[i+3] = 0xff
+
+			if y == 0 {
+				found.top[x] = l
+			}
+			if y == h-1 {
+				found.bottom[x] = l
+			}
+			if x == 0 {
+				found.left[y] = l
+			}
+			if x == w-1 {
+				found.right[y] = l
+			}
+		}
+	}
+	return found
+}
+
+// mergeLabels gives each chunk a base so that its labels are unique, joins
+// labels that meet along chunk edges & returns the final label of each.
+func mergeLabels(chunks map[[2]int]*labelChunk) ([]int32, error) {
+	// number chunks in a fixed order so labels are the same every time
+	keys := make([][2]int, 0, len(chunks))
+	for k := range chunks {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][1] != keys[j][1] {
+			return keys[i][1] < keys[j][1]
+		}
+		return keys[i][0] < keys[j][0]
+	})
+
+	total := 0
+	for _, k := range keys {
+		chunks[k].base = int32(total)
+		total += chunks[k].count
+		if total > maxLabels {
+			return nil, fmt.Errorf("too many labels, at most %d are supported", maxLabels)
+		}
+	}
+
+	parent := make([]int32, total+1)
+	for i := range parent {
+		parent[i] = int32(i)
+	}
+	join := func(a, b *labelChunk, as, bs []int32) {
+		for i := range as {
+			if as[i] != 0 && bs[i] != 0 {
+				unionLabels(parent, a.base+as[i], b.base+bs[i])
+			}
+		}
+	}
+	for _, k := range keys {
+		lc := chunks[k]
+		if right, ok := chunks[[2]int{k[0] + 1, k[1]}]; ok {
+			join(lc, right, lc.right, right.left)
+		}
+		if below, ok := chunks[[2]int{k[0], k[1] + 1}]; ok {
+			join(lc, below, lc.bottom, below.top)
+		}
+	}
+
+	final := make([]int32, total+1)
+	n := int32(0)
+	for l := 1; l <= total; l++ {
+		root := findLabel(parent, int32(l))
+		if final[root] == 0 {
+			n++
+			final[root] = n
+		}
+		final[l] = final[root]
+	}
+	return final, nil
+}
+
+// findLabel returns the root of label l, flattening the path as it goes.
+func findLabel(parent []int32, l int32) int32 {
+	for parent[l] != l {
+		parent[l] = parent[parent[l]]
+		l = parent[l]
+	}
+	return l
+}
+
+// unionLabels marks labels a & b as the same, returning the root of both.
+func unionLabels(parent []int32, a, b int32) int32 {
+	a, b = findLabel(parent, a), findLabel(parent, b)
+	if a < b {
+		parent[b] = a
+		return a
+	}
+	parent[a] = b
+	return b
+}
@@ -0,0 +1,56 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestLabels(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(color.White)
+	// a U over all four chunks, only joined at the bottom
+	op.DrawRectangle(10, 10, 4, 50)
+	op.DrawRectangle(50, 10, 4, 50)
+	op.DrawRectangle(10, 56, 44, 4)
+	op.Fill()
+	// two squares touching only at a corner
+	op.DrawRectangle(20, 20, 5, 5)
+	op.DrawRectangle(25, 25, 5, 5)
+	op.Fill()
+	// too faint to count
+	op.SetColor(color.RGBA{0, 0, 0, 10})
+	op.DrawRectangle(30, 2, 5, 5)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels, n, err := m.Labels(128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer labels.Close()
+	if n != 3 {
+		t.Fatalf("found %d labels, want 3", n)
+	}
+
+	at := func(x, y int) int { return mimage.LabelOf(labels.At(x, y)) }
+	u := at(11, 11)
+	if u == 0 || at(51, 11) != u || at(30, 57) != u {
+		t.Errorf("U labelled %d, %d & %d, want the same", at(11, 11), at(51, 11), at(30, 57))
+	}
+	a, b := at(21, 21), at(26, 26)
+	if a == 0 || b == 0 || a == b || a == u || b == u {
+		t.Errorf("squares labelled %d & %d (U is %d), want distinct labels", a, b, u)
+	}
+	for _, pt := range []image.Point{{0, 0}, {32, 4}, {40, 40}} {
+		if l := at(pt.X, pt.Y); l != 0 {
+			t.Errorf("pixel %v labelled %d, want 0", pt, l)
+		}
+	}
+}
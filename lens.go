@@ -0,0 +1,49 @@
+package mimage
+
+import (
+	"image"
+	"math"
+)
+
+// LensDistortion describes radial (barrel / pincushion) distortion using the
+// Brown-Conrady model, where a point at distance r from the center is found in
+// the distorted image at distance r * (1 + K1*r^2 + K2*r^4).
+//
+// Distances are normalised so that r=1 is half the image diagonal. Negative
+// values correct barrel distortion (typical of wide angle lenses) and positive
+// values pincushion distortion.
+type LensDistortion struct {
+	K1 float64
+	K2 float64
+
+	// Center of the distortion in world space, if not given the
+	// center of the image is used.
+	Center *Point
+}
+
+// source returns where in the distorted image the point (x,y) of the corrected
+// image is found.
+func (l *LensDistortion) source(x, y float64, center Point, norm float64) (float64, float64) {
+	dx, dy := x-center.X, y-center.Y
+	r2 := (dx*dx + dy*dy) / (norm * norm)
+	f := 1 + l.K1*r2 + l.K2*r2*r2
+	return center.X + dx*f, center.Y + dy*f
+}
+
+// Undistort returns a new Mimage (of the same size) with the given lens
+// distortion removed.
+func (m *Mimage) Undistort(lens LensDistortion, filter ResampleFilter) (*Mimage, error) {
+	center := Point{
+		X: float64(m.bounds.Min.X) + float64(m.Width())/2,
+		Y: float64(m.bounds.Min.Y) + float64(m.Height())/2,
+	}
+	if lens.Center != nil {
+		center = *lens.Center
+	}
+	norm := math.Hypot(float64(m.Width()), float64(m.Height())) / 2
+
+	min := m.bounds.Min
+	return m.warpTo(image.Rect(0, 0, m.Width(), m.Height()), func(x, y float64) (float64, float64) {
+		return lens.source(x+float64(min.X), y+float64(min.Y), center, norm)
+	}, filter)
+}
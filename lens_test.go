@@ -0,0 +1,53 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestUndistort(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 100, 100), mimage.ChunkSize(32))
+	red := color.RGBA{255, 0, 0, 255}
+	op := m.Draw()
+	op.SetColor(red)
+	op.DrawRectangle(48, 48, 4, 4) // at the center
+	op.DrawRectangle(88, 0, 4, 100)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	same, err := m.Undistort(mimage.LensDistortion{}, mimage.FilterNearest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer same.Close()
+	for _, x := range []int{50, 89, 91} {
+		if got := same.At(x, 50); got != red {
+			t.Errorf("without distortion (%d,50) is %v, want %v", x, got, red)
+		}
+	}
+
+	// pincushion correction pulls the edges in, leaving the center alone
+	corrected, err := m.Undistort(mimage.LensDistortion{K1: 0.2}, mimage.FilterNearest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer corrected.Close()
+	if b := corrected.Bounds(); b != m.Bounds() {
+		t.Errorf("corrected bounds are %v, want %v", b, m.Bounds())
+	}
+	if got := corrected.At(50, 50); got != red {
+		t.Errorf("center is %v, want %v", got, red)
+	}
+	if got := corrected.At(87, 50); got != red {
+		t.Errorf("(87,50) is %v, want the stripe pulled in", got)
+	}
+	if got := corrected.At(91, 50); got != (color.RGBA{}) {
+		t.Errorf("(91,50) is %v, want the stripe pulled away", got)
+	}
+}
@@ -0,0 +1,152 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sync"
+)
+
+// levelsClip is the fraction of pixels at each end of a channel ignored by
+// AutoLevels, so a few stray specks don't stop the rest being stretched
+const levelsClip = 0.005
+
+// histogram counts the (straight alpha) values of each color channel.
+type histogram struct {
+	channels [3][256]uint64
+	pixels   uint64
+}
+
+// AutoLevels stretches each color channel of the pixels within r (in world
+// space) to use the full range, eg. to clean up faded scans. The darkest &
+// lightest half a percent of each channel are clipped. Transparent pixels
+// are left alone & aren't counted.
+//
+// The image is read once to work out the levels & again to apply them.
+func (m *Mimage) AutoLevels(r image.Rectangle) error {
+	h, err := m.histogram(r)
+	if err != nil || h.pixels == 0 {
+		return err
+	}
+
+	tables := [3][256]uint8{}
+	for ch := range tables {
+		low, high := h.percentile(ch, levelsClip), h.percentile(ch, 1-levelsClip)
+		for i := range tables[ch] {
+			if high <= low {
+				tables[ch][i] = uint8(i)
+				continue
+			}
+			v := (float64(i) - float64(low)) * 255 / float64(high-low)
+			tables[ch][i] = uint8(math.Max(0, math.Min(255, math.Round(v))))
+		}
+	}
+	return m.mapLevels(r, tables)
+}
+
+// WhiteBalance removes a color cast from the pixels within r (in world
+// space) by scaling each color channel so their averages match (the "gray
+// world" assumption), eg. for scans on yellowed paper or stitched photos
+// taken in different light. Transparent pixels are left alone & aren't
+// counted.
+//
+// The image is read once to work out the balance & again to apply it.
+func (m *Mimage) WhiteBalance(r image.Rectangle) error {
+	h, err := m.histogram(r)
+	if err != nil || h.pixels == 0 {
+		return err
+	}
+
+	means := [3]float64{}
+	for ch := range means {
+		for v, n := range h.channels[ch] {
+			means[ch] += float64(v) * float64(n)
+		}
+		means[ch] /= float64(h.pixels)
+	}
+	gray := (means[0] + means[1] + means[2]) / 3
+
+	tables := [3][256]uint8{}
+	for ch := range tables {
+		gain := 1.0
+		if means[ch] > 0 {
+			gain = gray / means[ch]
+		}
+		for i := range tables[ch] {
+			tables[ch][i] = uint8(math.Min(255, math.Round(float64(i)*gain)))
+		}
+	}
+	return m.mapLevels(r, tables)
+}
+
+// histogram counts the channels of the pixels within r (in world space) that
+// aren't transparent.
+func (m *Mimage) histogram(r image.Rectangle) (*histogram, error) {
+	r = r.Intersect(m.bounds)
+	if r.Empty() {
+		return nil, fmt.Errorf("region %v is outside of the image", r)
+	}
+
+	total := &histogram{}
+	lock := &sync.Mutex{}
+	err := m.eachChunk(r, func(ctx *context) error {
+		cb := m.chunkBounds(ctx.X, ctx.Y)
+		area := r.Intersect(cb)
+		img := ctx.Img.Image().(*image.RGBA)
+
+		h := &histogram{}
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			i := img.PixOffset(area.Min.X-cb.Min.X, y-cb.Min.Y)
+			for x := area.Min.X; x < area.Max.X; x++ {
+				p := img.Pix[i : i+4 : i+4]
+				i += 4
+				if p[3] == 0 {
+					continue
+				}
+				s := unpremultiply(color.RGBA{p[0], p[1], p[2], p[3]})
+				h.channels[0][s.R]++
+				h.channels[1][s.G]++
+				h.channels[2][s.B]++
+				h.pixels++
+			}
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		for ch := range total.channels {
+			for v, n := range h.channels[ch] {
+				total.channels[ch][v] += n
+			}
+		}
+		total.pixels += h.pixels
+		return nil
+	})
+	return total, err
+}
+
+// percentile returns the value of the given channel that the fraction p of
+// pixels are at or below.
+func (h *histogram) percentile(ch int, p float64) int {
+	want := uint64(math.Ceil(p * float64(h.pixels)))
+	seen := uint64(0)
+	for v, n := range h.channels[ch] {
+		seen += n
+		if seen >= want && seen > 0 {
+			return v
+		}
+	}
+	return 255
+}
+
+// mapLevels replaces each (straight alpha) color channel of the pixels
+// within r (in world space) using the given lookup tables.
+func (m *Mimage) mapLevels(r image.Rectangle, tables [3][256]uint8) error {
+	return m.mapPixels(r, func(x, y int, c color.RGBA) color.RGBA {
+		if c.A == 0 {
+			return c
+		}
+		s := unpremultiply(c)
+		return premultiply(color.NRGBA{R: tables[0][s.R], G: tables[1][s.G], B: tables[2][s.B], A: s.A})
+	})
+}
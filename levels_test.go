@@ -0,0 +1,62 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestAutoLevels(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64))
+	op := m.Draw()
+	op.SetColor(color.RGBA{60, 80, 60, 255})
+	op.DrawRectangle(0, 0, 32, 32)
+	op.Fill()
+	op.SetColor(color.RGBA{180, 160, 120, 255})
+	op.DrawRectangle(32, 0, 32, 32)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.AutoLevels(m.Bounds())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pt, want := range map[image.Point]color.RGBA{{10, 10}: {0, 0, 0, 255}, {40, 10}: {255, 255, 255, 255}, {10, 40}: {}} {
+		if got := m.At(pt.X, pt.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", pt, got, want)
+		}
+	}
+
+	if err := m.AutoLevels(image.Rect(100, 100, 110, 110)); err == nil {
+		t.Error("region outside of the image got no error")
+	}
+}
+
+func TestWhiteBalance(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64))
+	op := m.Draw()
+	op.SetColor(color.RGBA{200, 100, 100, 255})
+	op.DrawRectangle(0, 0, 32, 32)
+	op.Fill()
+	op.SetColor(color.RGBA{100, 50, 50, 255})
+	op.DrawRectangle(32, 0, 32, 32)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// channel averages of 150, 75 & 75 are all brought to 100
+	err = m.WhiteBalance(m.Bounds())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pt, want := range map[image.Point]color.RGBA{{10, 10}: {133, 133, 133, 255}, {40, 10}: {67, 67, 67, 255}} {
+		if got := m.At(pt.X, pt.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", pt, got, want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package mimage
+
+import (
+	"image/color"
+	"math"
+)
+
+// Macro is a recorded sequence of drawing calls that can be drawn any number
+// of times, at different positions & scales, with Operation.ApplyMacro. This
+// is handy for symbols that are repeated many times (towns, trees, icons).
+//
+// Coordinates given to a Macro are relative to its own origin, which is
+// placed at the (x,y) given to ApplyMacro. Colors, line widths & rotations set
+// within a Macro don't leak out into the rest of the operation.
+//
+// A Macro shouldn't be changed while operations using it are pending.
+type Macro struct {
+	queue  commands
+	pivots []Point
+
+	minX         float64
+	minY         float64
+	maxX         float64
+	maxY         float64
+	maxlineWidth float64
+	strokeWidth  float64
+}
+
+// NewMacro returns a new, empty, Macro.
+func NewMacro() *Macro {
+	return &Macro{
+		minX: math.Inf(1),
+		minY: math.Inf(1),
+		maxX: math.Inf(-1),
+		maxY: math.Inf(-1),
+	}
+}
+
+// SetLineWidth sets the width of the line, which is scaled along with the
+// rest of the Macro.
+func (m *Macro) SetLineWidth(w float64) {
+	m.maxlineWidth = math.Max(m.maxlineWidth, w)
+	m.queue.push(setLineWidth, w)
+}
+
+// SetColor sets the color of the 'pen'.
+func (m *Macro) SetColor(c color.Color) {
+	m.queue.pushArgs(setColor, []interface{}{c})
+}
+
+// MoveTo moves the pen to (x,y)
+func (m *Macro) MoveTo(x, y float64) {
+	m.minMax(x, y)
+	m.queue.push(moveTo, x, y)
+}
+
+// LineTo draws (or will draw on stroke) from the current location to (x,y)
+func (m *Macro) LineTo(x, y float64) {
+	m.minMax(x, y)
+	m.queue.push(lineTo, x, y)
+}
+
+// ClosePath draws a line back to the start of the current path.
+func (m *Macro) ClosePath() {
+	m.queue.push(closePath)
+}
+
+// DrawRectangle draws a rectangle beginning at (x,y) with width w and height h.
+func (m *Macro) DrawRectangle(x, y, w, h float64) {
+	m.minMax(x, y)
+	m.minMax(x+w, y+h)
+	m.queue.push(drawRectangle, x, y, w, h)
+}
+
+// RotateAbout rotates following drawing calls around (x,y) by the given angle
+// (radians).
+func (m *Macro) RotateAbout(angle, x, y float64) {
+	m.pivots = append(m.pivots, Point{x, y})
+	m.queue.push(rotateAbout, angle, x, y)
+}
+
+// DrawEllipse draws an ellipse at (x,y) with axis lengths of rx, ry
+func (m *Macro) DrawEllipse(x, y, rx, ry float64) {
+	m.minMax(x-rx, y-ry)
+	m.minMax(x+rx, y+ry)
+	m.queue.push(drawEllipse, x, y, rx, ry)
+}
+
+// Fill the queued shape(s) with the currently set color.
+func (m *Macro) Fill() {
+	m.queue.push(fill)
+}
+
+// Stroke applies line strokes with the currently set color.
+func (m *Macro) Stroke() {
+	m.strokeWidth = math.Max(m.strokeWidth, math.Max(1, m.maxlineWidth))
+	m.queue.push(stroke)
+}
+
+// minMax sets internal min & max x & y values
+func (m *Macro) minMax(x, y float64) {
+	m.minX = math.Min(m.minX, x)
+	m.maxX = math.Max(m.maxX, x)
+	m.minY = math.Min(m.minY, y)
+	m.maxY = math.Max(m.maxY, y)
+}
+
+// bounds returns the area (relative to the macro origin) that the macro may
+// draw in. Rotations can swing shapes around their pivots, so for each
+// rotation we allow for anything within reach of the pivot.
+func (m *Macro) bounds() (minX, minY, maxX, maxY float64) {
+	minX, minY, maxX, maxY = m.minX, m.minY, m.maxX, m.maxY
+	for _, p := range m.pivots {
+		reach := 0.0
+		for _, c := range []Point{{m.minX, m.minY}, {m.maxX, m.minY}, {m.minX, m.maxY}, {m.maxX, m.maxY}} {
+			reach = math.Max(reach, math.Hypot(c.X-p.X, c.Y-p.Y))
+		}
+		minX, minY = math.Min(minX, p.X-reach), math.Min(minY, p.Y-reach)
+		maxX, maxY = math.Max(maxX, p.X+reach), math.Max(maxY, p.Y+reach)
+	}
+	return minX, minY, maxX, maxY
+}
+
+// ApplyMacro draws the given macro with its origin at (x,y), scaled by
+// scale.
+func (o *operation) ApplyMacro(m *Macro, x, y, scale float64) {
+	if m.minX > m.maxX {
+		return // nothing is drawn
+	}
+
+	minX, minY, maxX, maxY := m.bounds()
+	o.minMax(x+minX*scale, y+minY*scale)
+	o.minMax(x+maxX*scale, y+maxY*scale)
+	o.maxlineWidth = math.Max(o.maxlineWidth, m.maxlineWidth*scale)
+	o.strokeWidth = math.Max(o.strokeWidth, m.strokeWidth*scale)
+	o.queue.pushArgs(applyMacro, []interface{}{m}, x, y, scale)
+}
+
+// runMacro draws a macro onto a chunk, with the macro origin at (x,y) in world
+// space. Returns if anything was drawn.
+func runMacro(ctx *context, m *Macro, x, y, scale, offX, offY float64) bool {
+	dc := ctx.Img
+	dc.Push()
+	defer dc.Pop()
+
+	// map macro coords into chunk space
+	tx := func(v float64) float64 { return x + v*scale - offX }
+	ty := func(v float64) float64 { return y + v*scale - offY }
+
+	edited := false
+	for p := (cursor{}); p.fn < m.queue.len(); {
+		var action command
+		action, p = m.queue.at(p)
+		switch action.Func {
+		case setLineWidth:
+			dc.SetLineWidth(action.Nums[0] * scale)
+		case setColor:
+			dc.SetColor(action.Args[0].(color.Color))
+		case setFillRule:
+			dc.SetFillRule(action.Args[0].(FillRule))
+		case moveTo:
+			dc.MoveTo(tx(action.Nums[0]), ty(action.Nums[1]))
+		case lineTo:
+			dc.LineTo(tx(action.Nums[0]), ty(action.Nums[1]))
+		case closePath:
+			dc.ClosePath()
+		case drawRectangle:
+			dc.DrawRectangle(tx(action.Nums[0]), ty(action.Nums[1]), action.Nums[2]*scale, action.Nums[3]*scale)
+		case rotateAbout:
+			dc.RotateAbout(action.Nums[0], tx(action.Nums[1]), ty(action.Nums[2]))
+		case drawEllipse:
+			dc.DrawEllipse(tx(action.Nums[0]), ty(action.Nums[1]), action.Nums[2]*scale, action.Nums[3]*scale)
+		case fill:
+			dc.Fill()
+			edited = true
+		case stroke:
+			dc.Stroke()
+			edited = true
+		}
+	}
+
+	return edited
+}
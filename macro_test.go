@@ -0,0 +1,47 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestApplyMacro(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 128, 128), mimage.ChunkSize(32))
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+
+	// a 10x10 red square, turned a quarter about its origin it sits to the left
+	square := mimage.NewMacro()
+	square.SetColor(red)
+	square.RotateAbout(math.Pi/2, 0, 0)
+	square.DrawRectangle(0, 0, 10, 10)
+	square.Fill()
+
+	op := m.Draw()
+	op.SetColor(blue)
+	op.ApplyMacro(square, 30, 30, 1)
+	op.ApplyMacro(square, 90, 60, 2) // across chunks
+	// the macro's color & rotation stay within it
+	op.DrawRectangle(100, 100, 10, 10)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for p, want := range map[image.Point]color.RGBA{
+		{25, 35}:   red,
+		{35, 35}:   {},
+		{72, 78}:   red,
+		{88, 78}:   red,
+		{92, 62}:   {},
+		{105, 105}: blue,
+	} {
+		if got := m.At(p.X, p.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", p, got, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package mimage
+
+import (
+	"fmt"
+	"math"
+)
+
+// Matrix3 is a 3x3 matrix (row major) describing an affine or perspective
+// transform of (x,y) points in homogeneous coordinates, ie.
+//
+//	| x' |   | m[0] m[1] m[2] |   | x |
+//	| y' | = | m[3] m[4] m[5] | * | y |
+//	| w' |   | m[6] m[7] m[8] |   | 1 |
+//
+// with the resulting point being (x'/w', y'/w').
+type Matrix3 [9]float64
+
+// IdentityMatrix returns a matrix that leaves points where they are.
+func IdentityMatrix() Matrix3 {
+	return Matrix3{1, 0, 0, 0, 1, 0, 0, 0, 1}
+}
+
+// TranslateMatrix returns a matrix that moves points by (x,y).
+func TranslateMatrix(x, y float64) Matrix3 {
+	return Matrix3{1, 0, x, 0, 1, y, 0, 0, 1}
+}
+
+// ScaleMatrix returns a matrix that scales points by (x,y) about the origin.
+func ScaleMatrix(x, y float64) Matrix3 {
+	return Matrix3{x, 0, 0, 0, y, 0, 0, 0, 1}
+}
+
+// RotateMatrix returns a matrix that rotates points about the origin by the
+// given angle (radians, clockwise since y points down).
+func RotateMatrix(angle float64) Matrix3 {
+	s, c := math.Sin(angle), math.Cos(angle)
+	return Matrix3{c, -s, 0, s, c, 0, 0, 0, 1}
+}
+
+// PerspectiveMatrix returns the matrix that maps each of the src points to the
+// matching dst point. This is handy for straightening photos of documents etc,
+// by mapping the corners of the page to the corners of a rectangle.
+func PerspectiveMatrix(src, dst [4]Point) (Matrix3, error) {
+	// each pair of points gives us two equations of the eight unknowns
+	// (m[8] is fixed at 1), solved with gaussian elimination
+	var a [8][9]float64
+	for i := 0; i < 4; i++ {
+		x, y, u, v := src[i].X, src[i].Y, dst[i].X, dst[i].Y
+		a[i*2] = [9]float64{x, y, 1, 0, 0, 0, -u * x, -u * y, u}
+		a[i*2+1] = [9]float64{0, 0, 0, x, y, 1, -v * x, -v * y, v}
+	}
+
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return Matrix3{}, fmt.Errorf("points do not describe a valid perspective transform")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+
+		for row := 0; row < 8; row++ {
+			if row == col {
+				continue
+			}
+			f := a[row][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+		}
+	}
+
+	m := Matrix3{}
+	for i := 0; i < 8; i++ {
+		m[i] = a[i][8] / a[i][i]
+	}
+	m[8] = 1
+	return m, nil
+}
+
+// Multiply returns the matrix that applies o and then m.
+func (m Matrix3) Multiply(o Matrix3) Matrix3 {
+	out := Matrix3{}
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			for k := 0; k < 3; k++ {
+				out[r*3+c] += m[r*3+k] * o[k*3+c]
+			}
+		}
+	}
+	return out
+}
+
+// Inverse returns the matrix that undoes m, if there is one.
+func (m Matrix3) Inverse() (Matrix3, bool) {
+	det := m[0]*(m[4]*m[8]-m[5]*m[7]) - m[1]*(m[3]*m[8]-m[5]*m[6]) + m[2]*(m[3]*m[7]-m[4]*m[6])
+	if math.Abs(det) < 1e-12 {
+		return Matrix3{}, false
+	}
+
+	inv := Matrix3{
+		m[4]*m[8] - m[5]*m[7], m[2]*m[7] - m[1]*m[8], m[1]*m[5] - m[2]*m[4],
+		m[5]*m[6] - m[3]*m[8], m[0]*m[8] - m[2]*m[6], m[2]*m[3] - m[0]*m[5],
+		m[3]*m[7] - m[4]*m[6], m[1]*m[6] - m[0]*m[7], m[0]*m[4] - m[1]*m[3],
+	}
+	for i := range inv {
+		inv[i] /= det
+	}
+	return inv, true
+}
+
+// Apply returns the transformed (x,y) point, and the w value from which a
+// negative (or zero) value means the point is "behind" the viewer of a
+// perspective transform.
+func (m Matrix3) Apply(x, y float64) (float64, float64, float64) {
+	w := m[6]*x + m[7]*y + m[8]
+	return (m[0]*x + m[1]*y + m[2]) / w, (m[3]*x + m[4]*y + m[5]) / w, w
+}
@@ -0,0 +1,89 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+
+func TestMatrix3(t *testing.T) {
+	m := mimage.TranslateMatrix(5, -3).Multiply(mimage.RotateMatrix(0.7)).Multiply(mimage.ScaleMatrix(2, 3))
+	inv, ok := m.Inverse()
+	if !ok {
+		t.Fatal("an invertible matrix has no inverse")
+	}
+	x, y, _ := m.Apply(4, 7)
+	x, y, _ = inv.Apply(x, y)
+	if !near(x, 4) || !near(y, 7) {
+		t.Errorf("transformed & back again is (%v,%v), want (4,7)", x, y)
+	}
+
+	// scaled, then moved
+	x, y, _ = mimage.TranslateMatrix(10, 0).Multiply(mimage.ScaleMatrix(2, 2)).Apply(1, 1)
+	if !near(x, 12) || !near(y, 2) {
+		t.Errorf("got (%v,%v), want (12,2)", x, y)
+	}
+
+	if _, ok := mimage.ScaleMatrix(0, 1).Inverse(); ok {
+		t.Error("a singular matrix was inverted")
+	}
+}
+
+func TestPerspectiveMatrix(t *testing.T) {
+	src := [4]mimage.Point{{X: 10, Y: 5}, {X: 90, Y: 20}, {X: 80, Y: 95}, {X: 5, Y: 70}}
+	dst := [4]mimage.Point{{X: 0, Y: 0}, {X: 100, Y: 0}, {X: 100, Y: 100}, {X: 0, Y: 100}}
+	m, err := mimage.PerspectiveMatrix(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range src {
+		x, y, w := m.Apply(src[i].X, src[i].Y)
+		if !near(x, dst[i].X) || !near(y, dst[i].Y) || w <= 0 {
+			t.Errorf("%v maps to (%v,%v), want %v", src[i], x, y, dst[i])
+		}
+	}
+
+	line := [4]mimage.Point{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 2, Y: 2}, {X: 3, Y: 3}}
+	if _, err := mimage.PerspectiveMatrix(line, dst); err == nil {
+		t.Error("points on a line got no error")
+	}
+}
+
+func TestWarp(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 50, 50), mimage.ChunkSize(16))
+	red := color.RGBA{255, 0, 0, 255}
+	op := m.Draw()
+	op.SetColor(red)
+	op.DrawRectangle(10, 20, 5, 5)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	warped, err := m.Warp(mimage.ScaleMatrix(2, 2), mimage.FilterNearest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer warped.Close()
+	if b := warped.Bounds(); b != image.Rect(0, 0, 100, 100) {
+		t.Errorf("warped bounds are %v", b)
+	}
+	for _, p := range []image.Point{{20, 40}, {29, 49}} {
+		if got := warped.At(p.X, p.Y); got != red {
+			t.Errorf("warped pixel %v is %v, want %v", p, got, red)
+		}
+	}
+	if got := warped.At(30, 50); got != (color.RGBA{}) {
+		t.Errorf("pixel beyond the warped square is %v", got)
+	}
+
+	if _, err := m.Warp(mimage.ScaleMatrix(0, 1), mimage.FilterNearest); err == nil {
+		t.Error("a singular transform got no error")
+	}
+}
@@ -0,0 +1,158 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"sync"
+)
+
+// Merge imports the chunks of other that have been drawn on into this image,
+// with the top left of other placed at offset, eg. to put together regions of
+// a world rendered by separate processes. Offsets needn't line up with the
+// chunk grid.
+//
+// With BlendNone the other image replaces what's here wherever it has chunks.
+// BlendLinear & BlendFeather cross fade where the content of the two images
+// overlaps, as Stitch does (working out the extent of the content of both
+// images first). BlendMultiband isn't supported.
+//
+// An unbounded image grows to take in the other image.
+func (m *Mimage) Merge(other *Mimage, offset image.Point, blend BlendMode) error {
+	if other == m {
+		return fmt.Errorf("can't merge an image into itself")
+	}
+	if blend == BlendMultiband {
+		return fmt.Errorf("multiband blending isn't supported when merging")
+	}
+
+	// chunks can be copied as they are if they line up with ours
+	aligned := blend == BlendNone && other.chunkSize == m.chunkSize &&
+		offset.X%m.chunkSize == 0 && offset.Y%m.chunkSize == 0
+
+	// where the other image's chunks land
+	placed := []image.Rectangle{}
+	whole := [][2]int{}
+	area := image.Rectangle{}
+	for coords := range other.chunksWithin(other.bounds) {
+		if !other.cache.exists(coords[0], coords[1]) {
+			continue
+		}
+		cb := other.chunkBounds(coords[0], coords[1])
+		r := cb.Intersect(other.bounds).Add(offset)
+		area = area.Union(r)
+		if aligned && cb.In(other.bounds) && m.drawable(r) == r {
+			whole = append(whole, coords)
+			continue
+		}
+		placed = append(placed, r)
+	}
+	area = m.drawable(area)
+	if area.Empty() {
+		return nil
+	}
+	err := m.grow(area)
+	if err != nil {
+		return err
+	}
+	err = m.copyChunks(other, whole, offset.Div(m.chunkSize))
+	if err != nil || len(placed) == 0 {
+		return err
+	}
+
+	// what is weighed against what, when blending
+	var here, there image.Rectangle
+	if blend != BlendNone {
+		here, err = m.ContentBounds()
+		if err != nil {
+			return err
+		}
+		there, err = other.ContentBounds()
+		if err != nil {
+			return err
+		}
+		there = there.Add(offset)
+	}
+
+	return m.eachChunk(area, func(ctx *context) error {
+		cb := m.chunkBounds(ctx.X, ctx.Y)
+		r := cb.Intersect(area)
+		local := []image.Rectangle{}
+		for _, p := range placed {
+			if p.Overlaps(r) {
+				local = append(local, p.Intersect(r))
+			}
+		}
+		if len(local) == 0 {
+			return nil
+		}
+
+		img, err := other.Image(r.Sub(offset))
+		if err != nil {
+			return err
+		}
+		dst := ctx.Img.Image().(*image.RGBA)
+		tiles := []PlacedImage{{Image: dst, At: cb.Min}, {Image: img, At: r.Min}}
+		bounds := []image.Rectangle{here, there}
+		weights := make([][5]float64, 0, len(tiles))
+
+		for _, p := range local {
+			if blend == BlendNone {
+				bounds = []image.Rectangle{p, p}
+			}
+			for y := p.Min.Y; y < p.Max.Y; y++ {
+				for x := p.Min.X; x < p.Max.X; x++ {
+					c, ok := blendAt(tiles, bounds, blend, x, y, weights)
+					if ok {
+						dst.Set(x-cb.Min.X, y-cb.Min.Y, c)
+					}
+				}
+			}
+		}
+		ctx.setEdited()
+		return nil
+	})
+}
+
+// copyChunks replaces chunks of this image with the given chunks of other,
+// moved by shift (in chunks), both images having the same chunk size.
+func (m *Mimage) copyChunks(other *Mimage, chunks [][2]int, shift image.Point) error {
+	work := make(chan [2]int)
+	errs := make(chan error)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < m.routines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for coords := range work {
+				src, err := other.cache.Load(coords[0], coords[1])
+				if err != nil {
+					src.Done()
+					errs <- err
+					continue
+				}
+				rgba := src.Img.Image().(*image.RGBA)
+				img := image.NewRGBA(rgba.Bounds())
+				copy(img.Pix, rgba.Pix)
+				var straight *image.NRGBA
+				if src.straight != nil {
+					straight = image.NewNRGBA(rgba.Bounds())
+					for i := 0; i < len(rgba.Pix); i += 4 {
+						straight.SetNRGBA(i/4%rgba.Rect.Dx(), i/4/rgba.Rect.Dx(), reconcileAt(src.straight, rgba, i))
+					}
+				}
+				src.Done()
+
+				m.cache.Replace(coords[0]+shift.X, coords[1]+shift.Y, img, straight).Done()
+			}
+		}()
+	}
+	go func() {
+		for _, coords := range chunks {
+			work <- coords
+		}
+		close(work)
+		wg.Wait()
+		close(errs)
+	}()
+	return checkErrors(errs)
+}
@@ -0,0 +1,111 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestMerge(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(red)
+	op.Clear()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	draw := other.Draw()
+	draw.SetColor(blue)
+	draw.DrawRectangle(0, 0, 10, 10)
+	draw.Fill()
+	err = draw.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// reading the other chunks loads them, they still have nothing in them
+	_, err = other.Image(other.Bounds())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Merge(other, image.Pt(20, 20), mimage.BlendNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the drawn chunk of other replaces what's under it, the others were only
+	// read so they leave the image as it was
+	for pt, want := range map[image.Point]color.RGBA{{25, 25}: blue, {35, 35}: {}, {10, 10}: red, {60, 60}: red, {60, 10}: red} {
+		if got := m.At(pt.X, pt.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", pt, got, want)
+		}
+	}
+
+	if err := m.Merge(m, image.Point{}, mimage.BlendNone); err == nil {
+		t.Error("merging an image into itself got no error")
+	}
+	if err := m.Merge(other, image.Point{}, mimage.BlendMultiband); err == nil {
+		t.Error("merging with multiband blending got no error")
+	}
+}
+
+func TestMergeAligned(t *testing.T) {
+	before := runtime.NumGoroutine()
+	blue := color.RGBA{0, 0, 255, 255}
+	half := color.NRGBA{0, 255, 0, 100}
+
+	other, err := mimage.New(image.Rect(0, 0, 64, 64), mimage.ChunkSize(32), mimage.Alpha(mimage.AlphaStraight))
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := other.Draw()
+	op.SetColor(blue)
+	op.DrawRectangle(0, 0, 32, 32)
+	op.Fill()
+	op.SetColor(half)
+	op.DrawRectangle(32, 32, 32, 32)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := mimage.New(image.Rect(0, 0, 96, 96), mimage.ChunkSize(32), mimage.Alpha(mimage.AlphaStraight))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Merge(other, image.Pt(32, 0), mimage.BlendNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.At(40, 10); got != blue {
+		t.Errorf("pixel (40,10) is %v, want %v", got, blue)
+	}
+	straight, err := m.ImageNRGBA(image.Rect(80, 40, 81, 41))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := straight.NRGBAAt(0, 0); got != half {
+		t.Errorf("pixel (80,40) is %v, want %v", got, half)
+	}
+	if got := m.At(10, 10); got != (color.RGBA{}) {
+		t.Errorf("pixel (10,10) is %v, want it untouched", got)
+	}
+
+	for _, img := range []*mimage.Mimage{m, other} {
+		err = img.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := settledGoroutines(before); n > before {
+		t.Errorf("%d goroutines running after Close, %d before", n, before)
+	}
+}
@@ -2,18 +2,39 @@ package mimage
 
 import (
 	"encoding/json"
+	"time"
 )
 
 // metadata stores information on the massive image represented
 // in it's array of smaller chunks so we can "load" an Mimage
 // struct again
 type metadata struct {
-	BoundsMinX int
-	BoundsMinY int
-	BoundsMaxX int
-	BoundsMaxY int
-	ChunkSize  int
-	Routines   int
+	BoundsMinX  int
+	BoundsMinY  int
+	BoundsMaxX  int
+	BoundsMaxY  int
+	ChunkSize   int
+	Routines    int
+	Alpha       AlphaMode
+	DPI         float64
+	Timelapse   int
+	Geo         *GeoReference
+	Edges       EdgeMode
+	Toroidal    bool
+	Unbounded   bool
+	Strict      bool
+	Remote      string
+	Skip        bool
+	History     bool
+	MaxChunks   int
+	Eviction    EvictPolicy
+	EvictAfter  time.Duration
+	WriteBehind int
+	Throttle    int64
+	Concurrency int
+	Copies      bool
+
+	Annotations []*Annotation
 }
 
 // encodeJSON returns the JSON data representation of our metadata
@@ -0,0 +1,75 @@
+package mimagetest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a mimage.Clock that only moves when Advance is called, so chunks
+// of an image using it (see mimage.TimeWith) are never unloaded in the
+// background unless a test says so.
+type Clock struct {
+	lock     *sync.Mutex
+	now      time.Time
+	sleepers []*sleeper
+}
+
+// sleeper is a wait on the clock (see After).
+type sleeper struct {
+	until time.Time
+	wake  chan time.Time
+}
+
+// NewClock returns a clock stopped at the given time.
+func NewClock(start time.Time) *Clock {
+	return &Clock{lock: &sync.Mutex{}, now: start}
+}
+
+// Now returns the time the clock is at.
+func (c *Clock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// After returns a channel the clock's time is sent on once it's been
+// advanced by d.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	wake := make(chan time.Time, 1)
+	if d <= 0 {
+		wake <- c.now
+		return wake
+	}
+	c.sleepers = append(c.sleepers, &sleeper{until: c.now.Add(d), wake: wake})
+	return wake
+}
+
+// Advance moves the clock on by d, waking anything waiting until then.
+// Woken routines carry on in the background, eg. to unload a chunk, so call
+// Flush (or similar) before relying on what they do.
+func (c *Clock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+
+	waiting := c.sleepers[:0]
+	for _, s := range c.sleepers {
+		if s.until.After(c.now) {
+			waiting = append(waiting, s)
+		} else {
+			s.wake <- c.now
+		}
+	}
+	c.sleepers = waiting
+}
+
+// Sleepers returns how many waits on the clock (see After) haven't yet come
+// due.
+func (c *Clock) Sleepers() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.sleepers)
+}
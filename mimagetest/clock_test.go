@@ -0,0 +1,72 @@
+package mimagetest_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/voidshard/mimage"
+	"github.com/voidshard/mimage/mimagetest"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Unix(100, 0)
+	c := mimagetest.NewClock(start)
+	wait := c.After(time.Minute)
+	if c.Sleepers() != 1 {
+		t.Errorf("clock has %d sleepers, want 1", c.Sleepers())
+	}
+
+	c.Advance(30 * time.Second)
+	select {
+	case <-wait:
+		t.Fatal("woke before the time was up")
+	default:
+	}
+	c.Advance(30 * time.Second)
+	select {
+	case now := <-wait:
+		if want := start.Add(time.Minute); !now.Equal(want) {
+			t.Errorf("woke at %v, want %v", now, want)
+		}
+	default:
+		t.Fatal("didn't wake once the time was up")
+	}
+	if c.Sleepers() != 0 || !c.Now().Equal(start.Add(time.Minute)) {
+		t.Errorf("clock at %v with %d sleepers", c.Now(), c.Sleepers())
+	}
+}
+
+func TestClockUnloads(t *testing.T) {
+	clock := mimagetest.NewClock(time.Unix(0, 0))
+	m := mimagetest.NewImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32), mimage.TimeWith(clock))
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.DrawRectangle(0, 0, 10, 10)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loads := func() int {
+		plan := m.Draw()
+		plan.DrawRectangle(0, 0, 10, 10)
+		plan.Fill()
+		return plan.Plan().ChunkLoads
+	}
+
+	// however long the test really takes, the chunk stays until the clock moves
+	time.Sleep(50 * time.Millisecond)
+	if n := loads(); n != 0 {
+		t.Errorf("%d chunks to load before the clock moved, want 0", n)
+	}
+	clock.Advance(time.Hour)
+	deadline := time.Now().Add(5 * time.Second)
+	for loads() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond) // it's unloaded in the background
+	}
+	if n := loads(); n != 1 {
+		t.Errorf("%d chunks to load once idle, want 1", n)
+	}
+}
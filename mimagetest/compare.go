@@ -0,0 +1,129 @@
+package mimagetest
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+// UpdateEnv is the environment variable that, when set, has AssertGolden
+// write golden files rather than compare against them.
+const UpdateEnv = "MIMAGETEST_UPDATE"
+
+// CompareRegion compares the region r of m with want, whose top left corner
+// lines up with r's. Pixels match if no channel (8 bit, premultiplied)
+// differs by more than tolerance, so small differences in anti-aliasing or
+// rounding can be let through. The error describes the first pixel that
+// doesn't match & how many don't.
+func CompareRegion(m *mimage.Mimage, r image.Rectangle, want image.Image, tolerance uint8) error {
+	if r.Size() != want.Bounds().Size() {
+		return fmt.Errorf("region %v is %v, expected image is %v", r, r.Size(), want.Bounds().Size())
+	}
+	got, err := m.Image(r)
+	if err != nil {
+		return err
+	}
+
+	bad := 0
+	var first error
+	for y := 0; y < r.Dy(); y++ {
+		for x := 0; x < r.Dx(); x++ {
+			g := rgba8(got, got.Bounds().Min.X+x, got.Bounds().Min.Y+y)
+			w := rgba8(want, want.Bounds().Min.X+x, want.Bounds().Min.Y+y)
+			if within(g, w, tolerance) {
+				continue
+			}
+			bad++
+			if first == nil {
+				first = fmt.Errorf("pixel %d,%d is %v, expected %v", r.Min.X+x, r.Min.Y+y, g, w)
+			}
+		}
+	}
+	if first != nil {
+		return fmt.Errorf("%d of %d pixels differ by more than %d, %v", bad, r.Dx()*r.Dy(), tolerance, first)
+	}
+	return nil
+}
+
+// AssertRegion fails the test if the region r of m doesn't match want (see
+// CompareRegion).
+func AssertRegion(t testing.TB, m *mimage.Mimage, r image.Rectangle, want image.Image, tolerance uint8) {
+	t.Helper()
+	err := CompareRegion(m, r, want, tolerance)
+	if err != nil {
+		t.Errorf("region %v: %v", r, err)
+	}
+}
+
+// AssertGolden fails the test if the region r of m doesn't match the PNG at
+// path (see CompareRegion). With UpdateEnv set the region is written to path
+// instead, to make or update golden files.
+func AssertGolden(t testing.TB, m *mimage.Mimage, r image.Rectangle, path string, tolerance uint8) {
+	t.Helper()
+	if os.Getenv(UpdateEnv) != "" {
+		err := writeGolden(m, r, path)
+		if err != nil {
+			t.Fatalf("writing golden file %s: %v", path, err)
+		}
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("reading golden file (set %s=1 to write it): %v", UpdateEnv, err)
+	}
+	defer f.Close()
+	want, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("decoding golden file %s: %v", path, err)
+	}
+	err = CompareRegion(m, r, want, tolerance)
+	if err != nil {
+		t.Errorf("region %v against %s: %v", r, path, err)
+	}
+}
+
+// writeGolden writes the region r of m to path as a PNG.
+func writeGolden(m *mimage.Mimage, r image.Rectangle, path string) error {
+	img, err := m.Image(r)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0750)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(f, img)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// rgba8 returns the (premultiplied) color at x,y of img with 8 bits per
+// channel.
+func rgba8(img image.Image, x, y int) [4]uint8 {
+	r, g, b, a := img.At(x, y).RGBA()
+	return [4]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+}
+
+// within returns if no channel of a & b differs by more than tolerance.
+func within(a, b [4]uint8, tolerance uint8) bool {
+	for i := range a {
+		d := int(a[i]) - int(b[i])
+		if d < -int(tolerance) || d > int(tolerance) {
+			return false
+		}
+	}
+	return true
+}
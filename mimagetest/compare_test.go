@@ -0,0 +1,56 @@
+package mimagetest_test
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/voidshard/mimage/mimagetest"
+)
+
+func TestCompareRegion(t *testing.T) {
+	want := image.NewRGBA(image.Rect(10, 10, 20, 20))
+	for y := 10; y < 20; y++ {
+		for x := 10; x < 20; x++ {
+			want.Set(x, y, color.RGBA{uint8(x * 10), uint8(y * 10), 0, 255})
+		}
+	}
+	m := mimagetest.FromImage(t, want)
+	if m.Bounds() != want.Bounds() {
+		t.Errorf("image has bounds %v, want %v", m.Bounds(), want.Bounds())
+	}
+	mimagetest.AssertRegion(t, m, want.Bounds(), want, 0)
+
+	// one pixel a little off passes with a tolerance only
+	off := image.NewRGBA(want.Bounds())
+	copy(off.Pix, want.Pix)
+	off.Set(15, 12, color.RGBA{152, 118, 0, 255})
+	if err := mimagetest.CompareRegion(m, want.Bounds(), off, 2); err != nil {
+		t.Errorf("comparing within tolerance got %v", err)
+	}
+	err := mimagetest.CompareRegion(m, want.Bounds(), off, 1)
+	if err == nil || !strings.Contains(err.Error(), "1 of 100 pixels") || !strings.Contains(err.Error(), "15,12") {
+		t.Errorf("comparing beyond tolerance got %v", err)
+	}
+	if err := mimagetest.CompareRegion(m, image.Rect(10, 10, 15, 15), want, 0); err == nil {
+		t.Error("comparing regions of different sizes got no error")
+	}
+}
+
+func TestAssertGolden(t *testing.T) {
+	want := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	want.Set(3, 4, color.RGBA{0, 0, 255, 255})
+	m := mimagetest.FromImage(t, want)
+	path := filepath.Join(t.TempDir(), "golden", "dot.png")
+
+	os.Setenv(mimagetest.UpdateEnv, "1")
+	mimagetest.AssertGolden(t, m, want.Bounds(), path, 0)
+	os.Unsetenv(mimagetest.UpdateEnv)
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("golden file wasn't written: %v", err)
+	}
+	mimagetest.AssertGolden(t, m, want.Bounds(), path, 0)
+}
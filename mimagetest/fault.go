@@ -0,0 +1,215 @@
+package mimagetest
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/voidshard/mimage"
+)
+
+// Op is a call made on a store.
+type Op int
+
+const (
+	// OpAny is any call
+	OpAny Op = iota
+
+	// OpOpen is a file being opened, eg. a chunk being loaded
+	OpOpen
+
+	// OpCreate is a file being created, eg. a chunk being saved
+	OpCreate
+
+	// OpWrite is a file being written, failing when it's closed with the
+	// file left empty, as a torn write might
+	OpWrite
+
+	// OpList is the files being listed
+	OpList
+)
+
+// Fault describes calls to a FaultStore that fail or are slowed down.
+type Fault struct {
+	// Op is the kind of call, any if OpAny (the zero value).
+	Op Op
+
+	// Name is a pattern (see path.Match) the file's name must match, eg.
+	// "*.png" for chunks, any file (& listing) if empty.
+	Name string
+
+	// Nth has only the nth matching call (from 1, counting from when the
+	// fault is injected) fail. Otherwise with a Probability, matching calls
+	// fail at random, or if neither is set, all of them.
+	Nth int
+
+	// Probability (0-1) of each matching call failing, when Nth isn't set.
+	Probability float64
+
+	// Latency is added to every matching call, failing or not.
+	Latency time.Duration
+
+	// Err is returned by failing calls, with nil they only have Latency.
+	Err error
+}
+
+// fault is an injected Fault & how many calls it has matched.
+type fault struct {
+	Fault
+	calls int
+}
+
+// FaultStore wraps a mimage.ChunkStore, failing or slowing calls on demand so
+// code can be tested against storage errors, eg. that Do() & Flush() report
+// them & what has been saved afterwards.
+type FaultStore struct {
+	store  mimage.ChunkStore
+	lock   *sync.Mutex
+	next   map[Op][]error
+	faults []*fault
+	rng    *rand.Rand
+}
+
+// NewFaultStore returns a store passing calls on to store (if nil, a new
+// MemStore) until told to fail them.
+func NewFaultStore(store mimage.ChunkStore) *FaultStore {
+	if store == nil {
+		store = NewMemStore()
+	}
+	return &FaultStore{
+		store: store,
+		lock:  &sync.Mutex{},
+		next:  map[Op][]error{},
+		rng:   rand.New(rand.NewSource(1)),
+	}
+}
+
+// FailNext has the next call of the given kind fail with err. Called again
+// before then, the calls after fail in turn.
+func (s *FaultStore) FailNext(op Op, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.next[op] = append(s.next[op], err)
+}
+
+// Inject has calls fail or slow down as f describes, until Reset.
+func (s *FaultStore) Inject(f Fault) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.faults = append(s.faults, &fault{Fault: f})
+}
+
+// Seed sets the seed faults with a Probability are decided by, so a run that
+// fails can be repeated (as long as calls are made in the same order).
+func (s *FaultStore) Seed(seed int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.rng = rand.New(rand.NewSource(seed))
+}
+
+// Reset removes all faults, so calls are passed on as they are.
+func (s *FaultStore) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.next = map[Op][]error{}
+	s.faults = nil
+}
+
+// fault waits out any latency, then returns the error the call should fail
+// with, if any. List calls have no name.
+func (s *FaultStore) fault(op Op, name string) error {
+	s.lock.Lock()
+	var err error
+	if len(s.next[op]) > 0 {
+		err = s.next[op][0]
+		s.next[op] = s.next[op][1:]
+	}
+
+	wait := time.Duration(0)
+	for _, f := range s.faults {
+		if f.Op != OpAny && f.Op != op {
+			continue
+		}
+		if f.Name != "" {
+			if ok, _ := path.Match(f.Name, name); !ok || op == OpList {
+				continue
+			}
+		}
+		f.calls++
+		wait += f.Latency
+
+		fails := f.calls == f.Nth
+		if f.Nth == 0 && f.Probability > 0 {
+			fails = s.rng.Float64() < f.Probability
+		} else if f.Nth == 0 {
+			fails = true
+		}
+		if fails && err == nil {
+			err = f.Err
+		}
+	}
+	s.lock.Unlock()
+
+	time.Sleep(wait)
+	return err
+}
+
+// Open the named file for reading.
+func (s *FaultStore) Open(name string) (io.ReadCloser, error) {
+	if err := s.fault(OpOpen, name); err != nil {
+		return nil, err
+	}
+	return s.store.Open(name)
+}
+
+// Create the named file for writing.
+func (s *FaultStore) Create(name string) (io.WriteCloser, error) {
+	if err := s.fault(OpCreate, name); err != nil {
+		return nil, err
+	}
+	w, err := s.store.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &faultWriter{WriteCloser: w, store: s, name: name}, nil
+}
+
+// List the names of all the files.
+func (s *FaultStore) List() ([]string, error) {
+	if err := s.fault(OpList, ""); err != nil {
+		return nil, err
+	}
+	return s.store.List()
+}
+
+// faultWriter holds what's written to a file until it's closed, so a fault
+// can leave the file empty.
+type faultWriter struct {
+	io.WriteCloser
+	buf   bytes.Buffer
+	store *FaultStore
+	name  string
+}
+
+// Write to the file.
+func (w *faultWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+// Close writes the file out, unless it's to fail.
+func (w *faultWriter) Close() error {
+	err := w.store.fault(OpWrite, w.name)
+	if err != nil {
+		w.WriteCloser.Close()
+		return err
+	}
+	_, err = w.WriteCloser.Write(w.buf.Bytes())
+	if err != nil {
+		w.WriteCloser.Close()
+		return err
+	}
+	return w.WriteCloser.Close()
+}
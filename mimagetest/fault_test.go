@@ -0,0 +1,95 @@
+package mimagetest_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/voidshard/mimage/mimagetest"
+)
+
+// write writes data to the named file in s, returning the error from
+// creating or closing it.
+func write(s *mimagetest.FaultStore, name, data string) error {
+	w, err := s.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte(data))
+	if err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func TestFaultStore(t *testing.T) {
+	broken := errors.New("disk on fire")
+	s := mimagetest.NewFaultStore(nil)
+
+	// only the second chunk created fails, other files are left alone
+	s.Inject(mimagetest.Fault{Op: mimagetest.OpCreate, Name: "*.png", Nth: 2, Err: broken})
+	for i, name := range []string{"0.0.png", "meta.json", "0.1.png", "1.1.png"} {
+		err := write(s, name, "data")
+		if failed := errors.Is(err, broken); failed != (i == 2) {
+			t.Errorf("creating %s got %v", name, err)
+		}
+	}
+
+	// a failed write leaves the file empty, as a torn write might
+	s.Reset()
+	s.Inject(mimagetest.Fault{Op: mimagetest.OpWrite, Err: broken})
+	if err := write(s, "0.0.png", "new data"); !errors.Is(err, broken) {
+		t.Errorf("writing got %v, want %v", err, broken)
+	}
+	s.Reset()
+	r, err := s.Open("0.0.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(r)
+	r.Close()
+	if len(data) != 0 {
+		t.Errorf("file after a torn write holds %q, want nothing", data)
+	}
+
+	// latency alone slows calls without failing them
+	s.Inject(mimagetest.Fault{Op: mimagetest.OpList, Latency: 20 * time.Millisecond})
+	start := time.Now()
+	if _, err := s.List(); err != nil {
+		t.Errorf("listing got %v", err)
+	}
+	if took := time.Since(start); took < 20*time.Millisecond {
+		t.Errorf("listing took %v, want at least 20ms", took)
+	}
+
+	// the same seed fails the same calls
+	failures := func(seed int64) []bool {
+		s.Reset()
+		s.Seed(seed)
+		s.Inject(mimagetest.Fault{Op: mimagetest.OpOpen, Probability: 0.5, Err: broken})
+		out := []bool{}
+		for i := 0; i < 32; i++ {
+			r, err := s.Open("0.0.png")
+			if err == nil {
+				r.Close()
+			}
+			out = append(out, err != nil)
+		}
+		return out
+	}
+	a, b := failures(3), failures(3)
+	some := 0
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("call %d failed %v then %v with the same seed", i, a[i], b[i])
+		}
+		if a[i] {
+			some++
+		}
+	}
+	if some == 0 || some == len(a) {
+		t.Errorf("%d of %d calls failed at random, want some", some, len(a))
+	}
+}
@@ -0,0 +1,49 @@
+package mimagetest
+
+import (
+	"image"
+	"image/draw"
+	"testing"
+	"time"
+
+	"github.com/voidshard/mimage"
+)
+
+// NewImage returns an image with the given bounds held in memory (in a
+// MemStore) rather than on disk, using a stopped Clock so chunks aren't
+// unloaded in the background. Options given are applied after these, so can
+// replace them (eg. mimage.Storage(NewFaultStore(nil))). The image is closed
+// when the test ends.
+//
+// Things kept in files next to the chunks (snapshots, branches & so on)
+// need an image in a directory, eg. mimage.New(r, mimage.Directory(t.TempDir())).
+func NewImage(t testing.TB, r image.Rectangle, opts ...mimage.Option) *mimage.Mimage {
+	t.Helper()
+	opts = append([]mimage.Option{
+		mimage.Storage(NewMemStore()),
+		mimage.TimeWith(NewClock(time.Unix(0, 0))),
+	}, opts...)
+	m, err := mimage.New(r, opts...)
+	if err != nil {
+		t.Fatalf("making test image: %v", err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+// FromImage returns an image as NewImage does, with the bounds & pixels of
+// img.
+func FromImage(t testing.TB, img image.Image, opts ...mimage.Option) *mimage.Mimage {
+	t.Helper()
+	m := NewImage(t, img.Bounds(), opts...)
+	rgba := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	op := m.Draw()
+	op.DrawImage(rgba, img.Bounds().Min.X, img.Bounds().Min.Y) // over nothing, so as it is
+	err := op.Do()
+	if err != nil {
+		t.Fatalf("drawing test image: %v", err)
+	}
+	return m
+}
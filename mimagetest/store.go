@@ -0,0 +1,68 @@
+// Package mimagetest provides helpers for testing code built on mimage;
+// images held in memory, comparing regions against expected images, a clock
+// that only moves when told to & a store that fails on demand.
+package mimagetest
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+)
+
+// MemStore is a mimage.ChunkStore held in memory, safe for concurrent use.
+type MemStore struct {
+	lock  *sync.Mutex
+	files map[string][]byte
+}
+
+// NewMemStore returns an empty MemStore.
+func NewMemStore() *MemStore {
+	return &MemStore{lock: &sync.Mutex{}, files: map[string][]byte{}}
+}
+
+// Open the named file for reading.
+func (s *MemStore) Open(name string) (io.ReadCloser, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	data, ok := s.files[name]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Create the named file for writing, it replaces any file of the same name
+// once closed.
+func (s *MemStore) Create(name string) (io.WriteCloser, error) {
+	return &memFile{store: s, name: name}, nil
+}
+
+// List the names of all the files.
+func (s *MemStore) List() ([]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// memFile is a file being written to a MemStore.
+type memFile struct {
+	bytes.Buffer
+	store *MemStore
+	name  string
+}
+
+// Close saves the file to the store.
+func (f *memFile) Close() error {
+	f.store.lock.Lock()
+	defer f.store.lock.Unlock()
+	f.store.files[f.name] = append([]byte{}, f.Bytes()...)
+	return nil
+}
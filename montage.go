@@ -0,0 +1,109 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+)
+
+// NamedImage is an image with a unique name, used to identify where it
+// has been placed (see Montage).
+type NamedImage struct {
+	Name  string
+	Image image.Image
+}
+
+// LayoutOptions configures how images are packed together by Montage.
+type LayoutOptions struct {
+	// Padding is the space (in pixels) left around each image.
+	Padding int
+
+	// MaxWidth is the widest the result is allowed to be. If not given
+	// we aim for something roughly square.
+	MaxWidth int
+
+	// Options are passed to New when creating the resulting Mimage.
+	Options []Option
+}
+
+// packShelves places rectangles of the given sizes into rows ("shelves") no
+// wider than maxWidth, tallest first. Returns the placements in the same order
+// as the sizes given & the overall size required.
+func packShelves(sizes []image.Point, maxWidth, padding int) ([]image.Rectangle, image.Point) {
+	order := make([]int, len(sizes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return sizes[order[a]].Y > sizes[order[b]].Y })
+
+	placed := make([]image.Rectangle, len(sizes))
+	total := image.Point{}
+	x, y, shelfH := 0, 0, 0
+
+	for _, i := range order {
+		w, h := sizes[i].X+padding*2, sizes[i].Y+padding*2
+		if x > 0 && x+w > maxWidth { // start a new shelf
+			x = 0
+			y += shelfH
+			shelfH = 0
+		}
+
+		min := image.Pt(x+padding, y+padding)
+		placed[i] = image.Rectangle{Min: min, Max: min.Add(sizes[i])}
+
+		x += w
+		shelfH = maxInt(shelfH, h)
+		total.X = maxInt(total.X, x)
+		total.Y = maxInt(total.Y, y+shelfH)
+	}
+
+	return placed, total
+}
+
+// Montage packs the given images together into a single new Mimage, returning
+// it along with where each image (by name) was placed.
+func Montage(images []NamedImage, layout LayoutOptions) (*Mimage, map[string]image.Rectangle, error) {
+	if len(images) == 0 {
+		return nil, nil, fmt.Errorf("montage requires at least one image")
+	}
+
+	sizes := make([]image.Point, len(images))
+	seen := map[string]bool{}
+	area, widest := 0.0, 0
+	for i, ni := range images {
+		if seen[ni.Name] {
+			return nil, nil, fmt.Errorf("image name %s is not unique", ni.Name)
+		}
+		seen[ni.Name] = true
+
+		sizes[i] = ni.Image.Bounds().Size()
+		w, h := sizes[i].X+layout.Padding*2, sizes[i].Y+layout.Padding*2
+		area += float64(w * h)
+		widest = maxInt(widest, w)
+	}
+
+	maxWidth := layout.MaxWidth
+	if maxWidth <= 0 {
+		maxWidth = maxInt(widest, int(math.Ceil(math.Sqrt(area))))
+	} else if maxWidth < widest {
+		return nil, nil, fmt.Errorf("max width %d is less than the widest image %d", maxWidth, widest)
+	}
+
+	placed, total := packShelves(sizes, maxWidth, layout.Padding)
+
+	m, err := New(image.Rect(0, 0, total.X, total.Y), layout.Options...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	op := m.Draw()
+	placements := map[string]image.Rectangle{}
+	for i, ni := range images {
+		min := ni.Image.Bounds().Min // gg draws relative to image bounds
+		op.DrawImage(ni.Image, placed[i].Min.X-min.X, placed[i].Min.Y-min.Y)
+		placements[ni.Name] = placed[i]
+	}
+
+	return m, placements, op.Do()
+}
@@ -0,0 +1,67 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestMontage(t *testing.T) {
+	colors := map[string]color.RGBA{
+		"wide":   {255, 0, 0, 255},
+		"tall":   {0, 0, 255, 255},
+		"square": {0, 255, 0, 255},
+	}
+	images := []mimage.NamedImage{
+		{Name: "wide", Image: solid(image.Rect(0, 0, 20, 10), colors["wide"])},
+		{Name: "tall", Image: solid(image.Rect(5, 5, 15, 35), colors["tall"])},
+		{Name: "square", Image: solid(image.Rect(0, 0, 15, 15), colors["square"])},
+	}
+	m, placed, err := mimage.Montage(images, mimage.LayoutOptions{
+		Padding: 2,
+		Options: []mimage.Option{mimage.Directory(t.TempDir()), mimage.ChunkSize(16)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	for _, ni := range images {
+		r, ok := placed[ni.Name]
+		if !ok {
+			t.Fatalf("%s wasn't placed", ni.Name)
+		}
+		if r.Size() != ni.Image.Bounds().Size() || !r.In(m.Bounds()) {
+			t.Errorf("%s placed at %v in %v", ni.Name, r, m.Bounds())
+		}
+		for _, other := range images {
+			if other.Name != ni.Name && r.Inset(-2).Overlaps(placed[other.Name]) {
+				t.Errorf("%s at %v is within the padding of %s at %v", ni.Name, r, other.Name, placed[other.Name])
+			}
+		}
+		for _, p := range []image.Point{r.Min, r.Max.Sub(image.Pt(1, 1))} {
+			if got := m.At(p.X, p.Y); got != colors[ni.Name] {
+				t.Errorf("pixel %v of %s is %v, want %v", p, ni.Name, got, colors[ni.Name])
+			}
+		}
+	}
+}
+
+func TestMontageErrors(t *testing.T) {
+	img := solid(image.Rect(0, 0, 20, 20), color.White)
+	for name, images := range map[string][]mimage.NamedImage{
+		"no images":       nil,
+		"duplicate names": {{Name: "a", Image: img}, {Name: "a", Image: img}},
+	} {
+		_, _, err := mimage.Montage(images, mimage.LayoutOptions{})
+		if err == nil {
+			t.Errorf("%s got no error", name)
+		}
+	}
+	_, _, err := mimage.Montage([]mimage.NamedImage{{Name: "a", Image: img}}, mimage.LayoutOptions{MaxWidth: 10})
+	if err == nil {
+		t.Error("a max width narrower than the image got no error")
+	}
+}
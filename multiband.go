@@ -0,0 +1,178 @@
+package mimage
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// multibandLevels is the number of frequency bands BlendMultiband splits images
+// into. Each band is twice the scale of the last, so the coarsest band blends
+// over roughly 2^multibandLevels pixels.
+const multibandLevels = 6
+
+// multibandSigma returns the blur applied for the k-th band, the first band
+// (k=0) is the original image.
+func multibandSigma(k int) float64 {
+	if k == 0 {
+		return 0
+	}
+	return math.Pow(2, float64(k-1))
+}
+
+// multibandHalo is how far beyond a chunk we need to read so that blending
+// the chunk gives the same result as blending everything at once.
+var multibandHalo = blurRadius(multibandSigma(multibandLevels))
+
+// bandPlanes holds the premultiplied r,g,b,a values of an image in a rectangle.
+type bandPlanes [4][]float32
+
+// newBandPlanes returns zeroed planes of size n.
+func newBandPlanes(n int) bandPlanes {
+	return bandPlanes{make([]float32, n), make([]float32, n), make([]float32, n), make([]float32, n)}
+}
+
+// multibandChunk blends the given images over the chunk using a laplacian stack
+// (the un-decimated form of a laplacian pyramid). Fine detail is blended over a
+// sharp seam while coarser detail (colour, exposure ..) is blended over
+// increasingly wide areas, which hides seams without ghosting.
+//
+// Each image is assigned the pixels where it has the highest edgeWeight, the
+// bands of all images are then combined according to those assignments blurred
+// to the scale of the band.
+func multibandChunk(dst *image.RGBA, cb image.Rectangle, target image.Rectangle, tiles []PlacedImage, bounds []image.Rectangle, area image.Rectangle) {
+	work := target.Inset(-multibandHalo).Intersect(area)
+	w, h := work.Dx(), work.Dy()
+	n := w * h
+	if n == 0 {
+		return
+	}
+
+	// read in all image data & decide which image "owns" each pixel
+	planes := make([]bandPlanes, len(tiles))
+	covers := make([][]float32, len(tiles))
+	owner := make([]int, n)
+	best := make([]float64, n)
+	for p := range owner {
+		owner[p] = -1
+	}
+
+	for i, tile := range tiles {
+		planes[i] = newBandPlanes(n)
+		covers[i] = make([]float32, n)
+
+		r := bounds[i].Intersect(work)
+		offset := tile.Image.Bounds().Min.Sub(tile.At)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				p := (y-work.Min.Y)*w + (x - work.Min.X)
+				cr, cg, cbl, ca := tile.Image.At(x+offset.X, y+offset.Y).RGBA()
+				planes[i][0][p] = float32(cr)
+				planes[i][1][p] = float32(cg)
+				planes[i][2][p] = float32(cbl)
+				planes[i][3][p] = float32(ca)
+				covers[i][p] = 1
+
+				wgt := edgeWeight(bounds[i], x, y) * float64(ca) / 0xffff
+				if wgt > best[p] || owner[p] < 0 {
+					best[p] = wgt
+					owner[p] = i
+				}
+			}
+		}
+	}
+
+	// since exactly one image owns each covered pixel, the sum of all the
+	// blurred ownership masks is just the blurred union of them all
+	union := make([]float32, n)
+	for p, o := range owner {
+		if o >= 0 {
+			union[p] = 1
+		}
+	}
+	norms := make([][]float32, multibandLevels+1)
+	for k := range norms {
+		norms[k] = blurred(union, w, h, multibandSigma(k))
+	}
+
+	out := newBandPlanes(n)
+	for i := range tiles {
+		mask := make([]float32, n)
+		for p, o := range owner {
+			if o == i {
+				mask[p] = 1
+			}
+		}
+
+		// normalised blur; spreads image data out into uncovered areas so
+		// that the edges of an image don't leak darkness into the bands
+		blurCover := func(k int) bandPlanes {
+			if k == 0 {
+				return planes[i]
+			}
+			sigma := multibandSigma(k)
+			cov := blurred(covers[i], w, h, sigma)
+			g := bandPlanes{}
+			for c := 0; c < 4; c++ {
+				g[c] = blurred(planes[i][c], w, h, sigma)
+				for p := range g[c] {
+					if cov[p] > 1e-6 {
+						g[c][p] /= cov[p]
+					}
+				}
+			}
+			return g
+		}
+
+		current := blurCover(0)
+		for k := 0; k <= multibandLevels; k++ {
+			var next bandPlanes
+			if k < multibandLevels {
+				next = blurCover(k + 1)
+			}
+
+			weight := blurred(mask, w, h, multibandSigma(k))
+			for p := 0; p < n; p++ {
+				if weight[p] == 0 || norms[k][p] == 0 {
+					continue
+				}
+				wgt := weight[p] / norms[k][p]
+				for c := 0; c < 4; c++ {
+					band := current[c][p]
+					if k < multibandLevels {
+						band -= next[c][p]
+					}
+					out[c][p] += band * wgt
+				}
+			}
+
+			current = next
+		}
+	}
+
+	for y := target.Min.Y; y < target.Max.Y; y++ {
+		for x := target.Min.X; x < target.Max.X; x++ {
+			p := (y-work.Min.Y)*w + (x - work.Min.X)
+			if owner[p] < 0 {
+				continue
+			}
+			a := clampFloat(out[3][p], 0, 0xffff)
+			dst.Set(x-cb.Min.X, y-cb.Min.Y, color.RGBA64{
+				uint16(clampFloat(out[0][p], 0, a)),
+				uint16(clampFloat(out[1][p], 0, a)),
+				uint16(clampFloat(out[2][p], 0, a)),
+				uint16(a),
+			})
+		}
+	}
+}
+
+// clampFloat returns f clamped to [min, max].
+func clampFloat(f, min, max float32) float32 {
+	if f < min {
+		return min
+	} else if f > max {
+		return max
+	}
+	return f
+}
@@ -0,0 +1,89 @@
+package mimage
+
+import (
+	"image"
+	"math"
+)
+
+// noise is a queued AddNoise call.
+type noise struct {
+	area   image.Rectangle
+	amount float64
+	seed   int64
+}
+
+// SetSeed sets a seed mixed into every random choice the operation makes
+// (Scatter, StampAlongPath jitter & AddNoise) as they're queued, so a whole
+// pipeline can be varied, or reproduced, from one number. Zero (the default)
+// leaves the seeds given to each as they are.
+//
+// Random choices are decided by seeds & world space positions alone, so the
+// same calls with the same seeds always draw the same image, whatever order
+// chunks are drawn in & however many routines draw them.
+func (o *operation) SetSeed(seed int64) {
+	o.seed = seed
+}
+
+// seeded returns the given seed mixed with the operation's (see SetSeed).
+func (o *operation) seeded(seed int64) int64 {
+	if o.seed == 0 {
+		return seed
+	}
+	return seed ^ int64(mix64(uint64(o.seed)))
+}
+
+// AddNoise varies the color of each pixel within r by up to amount (0-1, of
+// the full range) either way, eg. for grain on flat fills or to break up
+// banding in gradients. Transparent pixels are left alone & alpha isn't
+// changed.
+//
+// How each pixel varies is decided by the operation's seed (see SetSeed) &
+// the pixel's position alone, so noise lines up across chunks.
+func (o *operation) AddNoise(r image.Rectangle, amount float64) {
+	r = r.Canon()
+	if r.Empty() || amount <= 0 {
+		return
+	}
+	o.minMax(float64(r.Min.X), float64(r.Min.Y))
+	o.minMax(float64(r.Max.X), float64(r.Max.Y))
+	o.queue.pushArgs(addNoise, []interface{}{&noise{area: r, amount: math.Min(amount, 1), seed: o.seeded(0)}})
+}
+
+// render adds noise to img, where img's origin is at (offX,offY) in world
+// space, reduced by the mask (if any), returning if anything was changed.
+func (n *noise) render(img *image.RGBA, mask *image.Alpha, offX, offY int) bool {
+	area := n.area.Sub(image.Pt(offX, offY)).Intersect(img.Bounds())
+	changed := false
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			a := float64(img.Pix[i+3])
+			if a == 0 {
+				continue
+			}
+			amount := n.amount * 0xff
+			if mask != nil {
+				amount *= float64(mask.AlphaAt(x, y).A) / 0xff
+			}
+
+			h := mix64(uint64(n.seed) ^ uint64(x+offX)*0x9e3779b97f4a7c15 ^ uint64(y+offY)*0xc2b2ae3d27d4eb4f)
+			for c := 0; c < 3; c++ {
+				h = mix64(h)
+				v := float64(img.Pix[i+c]) * 0xff / a // straight
+				v += (float64(h>>11)/(1<<53)*2 - 1) * amount
+				img.Pix[i+c] = uint8(math.Round(math.Max(0, math.Min(0xff, v)) * a / 0xff))
+			}
+			changed = true
+		}
+	}
+	return changed
+}
+
+// mix64 scrambles the bits of x (splitmix64's finalizer), so nearby inputs
+// give unrelated outputs.
+func mix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
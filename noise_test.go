@@ -0,0 +1,63 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestAddNoise(t *testing.T) {
+	gray := color.RGBA{128, 128, 128, 255}
+	noisy := func(seed int64, opts ...mimage.Option) *mimage.Mimage {
+		m := newImage(t, image.Rect(0, 0, 64, 64), opts...)
+		op := m.Draw()
+		op.SetColor(gray)
+		op.DrawRectangle(0, 0, 64, 32) // the bottom half is left transparent
+		op.Fill()
+		op.SetSeed(seed)
+		op.AddNoise(image.Rect(0, 0, 64, 64), 0.1)
+		err := op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	a := noisy(7, mimage.ChunkSize(64))
+	varied := false
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			c := a.At(x, y).(color.RGBA)
+			if y >= 32 {
+				if c != (color.RGBA{}) {
+					t.Fatalf("transparent pixel (%d,%d) is %v", x, y, c)
+				}
+				continue
+			}
+			if c.A != 255 || absDiff(c.R, gray.R) > 26 || absDiff(c.G, gray.G) > 26 || absDiff(c.B, gray.B) > 26 {
+				t.Fatalf("pixel (%d,%d) is %v, want within 10%% of %v", x, y, c, gray)
+			}
+			varied = varied || c != gray
+		}
+	}
+	if !varied {
+		t.Error("no pixel was changed")
+	}
+
+	// the same seed draws the same noise however the image is chunked
+	b, c := noisy(7, mimage.ChunkSize(16), mimage.OperationRoutines(4)), noisy(8, mimage.ChunkSize(64))
+	same, differ := true, false
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 64; x++ {
+			same = same && a.At(x, y) == b.At(x, y)
+			differ = differ || a.At(x, y) != c.At(x, y)
+		}
+	}
+	if !same {
+		t.Error("noise with the same seed differs between chunk sizes")
+	}
+	if !differ {
+		t.Error("noise with different seeds is the same")
+	}
+}
@@ -3,6 +3,7 @@ package mimage
 import (
 	"image"
 	"image/color"
+	"image/draw"
 	"math"
 	"sync"
 )
@@ -28,54 +29,87 @@ const (
 	stroke
 	clear
 	drawImage
+	drawImageOp
+	drawImageScaled
+	drawImageTransformed
+	drawStamps
+	scatterStamps
+	drawTilemap
+	drawGrid
+	applyMacro
+	transform
+	setFillRule
+	drawPolygon
+	drawPolyline
+	setLineDecoration
+	drawDashes
+	strokeAlongPath
+	setPaintMode
+	addNoise
 )
 
-// deferredFunc is a function & arguments to be called on Do()
-type deferredFunc struct {
-	Func int
-	Args []interface{}
-}
-
-// newDefFunc returns a deferredFunc struct
-func newDefFunc(num int, args ...interface{}) *deferredFunc {
-	return &deferredFunc{Func: num, Args: args}
-}
-
 // operation represents a set of actions to perform each affected chunk
 type operation struct {
 	parent *Mimage
-	queue  []*deferredFunc
+	queue  commands
 
 	minX         float64
 	minY         float64
 	maxX         float64
 	maxY         float64
 	maxlineWidth float64
+	strokeWidth  float64 // widest line stroked (see StrictBounds)
 
-	routines int
+	repeats     []image.Point
+	onChunkDone func(cx, cy int, err error)
+	routines    int
+	seed        int64
 }
 
 // newOperation returns a new empty operation
 func newOperation(parent *Mimage) Operation {
 	return &operation{
 		parent:   parent,
-		queue:    []*deferredFunc{},
-		minX:     float64(parent.Width()) + 1,
-		minY:     float64(parent.Height()) + 1,
+		minX:     math.Inf(1),
+		minY:     math.Inf(1),
+		maxX:     math.Inf(-1),
+		maxY:     math.Inf(-1),
 		routines: parent.routines,
 	}
 }
 
 // Do performs all previously called functions across chunks as required.
 func (o *operation) Do() error {
-	// clamp down on the area that contains all operations
-	o.minX = math.Max(0, o.minX-o.maxlineWidth)
-	o.maxX = math.Min(float64(o.parent.Width()), o.maxX+o.maxlineWidth)
-	o.minY = math.Max(0, o.minY-o.maxlineWidth)
-	o.maxY = math.Min(float64(o.parent.Height()), o.maxY+o.maxlineWidth)
+	if o.parent.strictBounds {
+		err := o.checkBounds()
+		if err != nil {
+			return err
+		}
+	}
+	work, dirty := o.plan()
+
+	// reads of snapshots see the image as it was until we're finished
+	area := image.Rectangle{}
+	for _, job := range work {
+		area = area.Union(o.parent.chunkBounds(job.x, job.y))
+	}
+	snap := o.parent.snapshots().begin(area, o.parent.snapshotCopies)
+	defer snap.finish()
+
+	// identifies this operation, if we're skipping chunks it's been applied to
+	key := ""
+	if o.parent.effects != nil {
+		key = o.effectKey()
+	}
 
 	// channel of chunks we need to change
-	work := o.parent.chunksWithin(image.Rect(int(o.minX), int(o.minY), int(o.maxX), int(o.maxY)))
+	jobs := make(chan chunkWork)
+	go func() {
+		for _, job := range work {
+			jobs <- job
+		}
+		close(jobs)
+	}()
 
 	// standard fan out -> fan in to apply changes to all chunks
 	errs := make(chan error)
@@ -87,8 +121,11 @@ func (o *operation) Do() error {
 		go func() {
 			defer wg.Done()
 
-			for coords := range work {
-				err := o.apply(coords[0], coords[1])
+			for job := range jobs {
+				err := o.apply(job, jobKey(key, job.shifts), snap)
+				if o.onChunkDone != nil {
+					o.onChunkDone(job.x, job.y, err)
+				}
 				if err != nil {
 					errs <- err
 					continue
@@ -102,90 +139,521 @@ func (o *operation) Do() error {
 		close(errs)
 	}()
 
-	return checkErrors(errs)
+	err := checkErrors(errs)
+	if err != nil {
+		return err
+	}
+	if o.parent.keepHistory {
+		err = o.parent.logHistory(o.history(dirty))
+		if err != nil {
+			return err
+		}
+	}
+	o.retire()
+	err = o.parent.grow(dirty)
+	if err != nil {
+		return err
+	}
+	return o.parent.captureFrame(dirty)
+}
+
+// retire drops everything that has been drawn from the queue, so that the next
+// Do() only draws what is queued after this one. Only what is needed to carry
+// the drawing state over is kept; the current color, line width, styles, mask
+// & transform and any path that hasn't been filled or stroked yet.
+func (o *operation) retire() {
+	// the current path is everything since it was last filled / stroked
+	pathStart := 0
+	for p := (cursor{}); p.fn < o.queue.len(); {
+		var action command
+		action, p = o.queue.at(p)
+		if action.Func == fill || action.Func == stroke || action.Func == strokeAlongPath {
+			pathStart = p.fn
+		}
+	}
+
+	last := map[int]command{}
+	mask := []command{}
+	kept := []command{}
+	angle, tx, ty, transformed := 0.0, 0.0, 0.0, false
+	for p := (cursor{}); p.fn < o.queue.len(); {
+		i := p.fn
+		var action command
+		action, p = o.queue.at(p)
+		switch action.Func {
+		case setColor, setLineWidth, setFillStyle, setStrokeStyle, setFillRule, setLineDecoration, setPaintMode:
+			last[action.Func] = action
+		case setMask:
+			mask = []command{action}
+		case invertMask:
+			mask = append(mask, action)
+		case rotateAbout, transform:
+			// transforms apply to later path points, so those within the
+			// pending path stay in order & those before it are folded into one
+			if i >= pathStart {
+				kept = append(kept, action)
+				continue
+			}
+			angle, tx, ty = composeTransform(angle, tx, ty, action)
+			transformed = true
+		case moveTo, lineTo, closePath, drawRectangle, drawEllipse, drawPolygon, drawPolyline, drawDashes:
+			if i >= pathStart {
+				kept = append(kept, action)
+			}
+		}
+	}
+
+	queue := commands{}
+	for _, id := range []int{setColor, setLineWidth, setFillStyle, setStrokeStyle, setFillRule, setLineDecoration, setPaintMode} {
+		if action, ok := last[id]; ok {
+			queue.pushCommand(action)
+		}
+	}
+	for _, action := range mask {
+		queue.pushCommand(action)
+	}
+	if transformed {
+		queue.push(transform, angle, tx, ty)
+	}
+	for _, action := range kept {
+		queue.pushCommand(action)
+	}
+	o.queue = queue
+
+	// the area to change is now only that of the pending path, not yet stroked
+	o.minX, o.minY = math.Inf(1), math.Inf(1)
+	o.maxX, o.maxY = math.Inf(-1), math.Inf(-1)
+	o.strokeWidth = 0
+	for _, action := range kept {
+		switch action.Func {
+		case moveTo, lineTo:
+			o.minMax(action.Nums[0], action.Nums[1])
+		case drawRectangle:
+			x, y := action.Nums[0], action.Nums[1]
+			o.minMax(x, y)
+			o.minMax(x+action.Nums[2], y+action.Nums[3])
+		case drawEllipse:
+			x, y := action.Nums[0], action.Nums[1]
+			rx, ry := action.Nums[2], action.Nums[3]
+			o.minMax(x-rx, y-ry)
+			o.minMax(x+rx, y+ry)
+		case drawPolygon, drawPolyline, drawDashes:
+			minX, minY, maxX, maxY := Path(action.Args[0].([]Point)).bounds()
+			o.minMax(minX, minY)
+			o.minMax(maxX, maxY)
+		}
+	}
+}
+
+// composeTransform returns the transform (in world space, a rotation by angle
+// then a move by tx,ty) that is the given transform followed by action, which
+// is a rotateAbout or transform. Like gg, the later transform is applied to
+// points first.
+func composeTransform(angle, tx, ty float64, action command) (float64, float64, float64) {
+	a, dx, dy := action.Nums[0], action.Nums[1], action.Nums[2]
+	if action.Func == rotateAbout {
+		// rotating about (x,y) is rotating about the origin then moving by
+		// the difference between (x,y) and where it was rotated to
+		sin, cos := math.Sincos(a)
+		dx, dy = dx-(cos*dx-sin*dy), dy-(sin*dx+cos*dy)
+	}
+	sin, cos := math.Sincos(angle)
+	return angle + a, tx + cos*dx - sin*dy, ty + sin*dx + cos*dy
+}
+
+// Repeat applies all queued functions once at each of the given offsets
+// (added to all coordinates), rather than once as given. Each chunk is
+// loaded only once with the functions applied at every offset that touches
+// it, which is much faster than queuing the same drawing many times.
+//
+// Colors, line widths, rotations etc. set by the queue are reset before
+// each repetition.
+func (o *operation) Repeat(offsets []image.Point) {
+	o.repeats = append(o.repeats, offsets...)
+}
+
+// OnChunkDone sets a function called as each chunk is finished during Do(),
+// with the error (if any) from that chunk. The chunk is released before fn is
+// called, so fn may read it (eg. with Image and ChunkBounds) while the rest of
+// the operation carries on.
+//
+// fn is called from many routines at once, so must be safe for concurrent use.
+func (o *operation) OnChunkDone(fn func(cx, cy int, err error)) {
+	o.onChunkDone = fn
+}
+
+// chunkWork is a chunk to apply an operation to, and the offsets (see
+// Repeat) to apply it at.
+type chunkWork struct {
+	x, y   int
+	shifts []image.Point
+}
+
+// area returns the area (in world space, before any Repeat offsets) that the
+// queued functions may draw in.
+func (o *operation) area() image.Rectangle {
+	if o.minX > o.maxX || o.minY > o.maxY {
+		return image.Rectangle{} // nothing is drawn
+	}
+	return image.Rect(
+		int(math.Floor(o.minX-o.maxlineWidth)),
+		int(math.Floor(o.minY-o.maxlineWidth)),
+		int(math.Ceil(o.maxX+o.maxlineWidth)),
+		int(math.Ceil(o.maxY+o.maxlineWidth)),
+	)
+}
+
+// plan returns the chunks that need to be changed (in a stable order) and
+// the area (in world space) that may be changed.
+func (o *operation) plan() ([]chunkWork, image.Rectangle) {
+	area := o.area()
+	shifts := o.repeats
+	if shifts == nil {
+		shifts = []image.Point{{}}
+	}
+
+	size := o.parent.chunkSize
+	byChunk := map[[2]int]int{}
+	work := []chunkWork{}
+	dirty := image.Rectangle{}
+
+	for _, repeat := range shifts {
+		// drawing off the edge of a toroidal image wraps around
+		for _, wrap := range o.parent.wraps(area.Add(repeat)) {
+			d := repeat.Add(wrap)
+			r := o.parent.drawable(area.Add(d))
+			if r.Empty() {
+				continue
+			}
+			dirty = dirty.Union(r)
+
+			for cy := floorDiv(r.Min.Y, size); cy <= floorDiv(r.Max.Y-1, size); cy++ {
+				for cx := floorDiv(r.Min.X, size); cx <= floorDiv(r.Max.X-1, size); cx++ {
+					key := [2]int{cx, cy}
+					i, ok := byChunk[key]
+					if !ok {
+						i = len(work)
+						byChunk[key] = i
+						work = append(work, chunkWork{x: cx, y: cy})
+					}
+					work[i].shifts = append(work[i].shifts, d)
+				}
+			}
+		}
+	}
+
+	return work, dirty
 }
 
 // apply operation(s) to the given chunk
-func (o *operation) apply(chunkX, chunkY int) error {
-	ctx, err := o.parent.cache.Load(chunkX, chunkY)
+func (o *operation) apply(job chunkWork, key string, snap *write) error {
+	queue := &o.queue
+	effects := o.parent.effects
+	saved := snap.change(job.x, job.y)
+
+	var ctx *context
+	var err error
+	if rest, img := o.covered(job); img != nil && !snap.keep && !effects.applied(job.x, job.y, key) {
+		// nothing before the covering image shows, so there's no need to
+		// read the chunk
+		ctx = o.parent.cache.Replace(job.x, job.y, img, nil)
+		queue = rest
+	} else {
+		ctx, err = o.parent.cache.Load(job.x, job.y)
+	}
 	defer ctx.Done() // whatever happens, unlock chunk
 	if err != nil {
+		saved(nil)
 		return err
 	}
+	saved(ctx.Img.Image().(*image.RGBA))
+	if effects.done(ctx, key) {
+		return nil // already holds the result of this operation
+	}
+
+	// chunks stay loaded between operations (and Do() calls), so leave each
+	// as we found it; the queue carries all the state we need
+	for _, d := range job.shifts {
+		ctx.Img.Push()
+		err = o.run(ctx, queue, d)
+		ctx.Img.Pop()
+		ctx.Img.ClearPath()
+		ctx.Img.ResetClip()
+		if err != nil {
+			return err
+		}
+	}
+	return effects.record(ctx, key)
+}
+
+// covered returns the queue after the last image that covers the whole
+// chunk with opaque pixels, & a copy of the part of it over the chunk, if the
+// queue is only of simple DrawImage calls. Otherwise the image is nil.
+func (o *operation) covered(job chunkWork) (*commands, *image.RGBA) {
+	if len(job.shifts) != 1 {
+		return nil, nil
+	}
+	cs := o.parent.chunkSize
+	off := image.Pt(job.x*cs, job.y*cs).Sub(job.shifts[0])
+	chunk := image.Rect(0, 0, cs, cs)
+
+	var found *command
+	var after cursor
+	for p := (cursor{}); p.fn < o.queue.len(); {
+		var action command
+		action, p = o.queue.at(p)
+		if action.Func != drawImage {
+			return nil, nil // anything else could move or mask the image
+		}
+		img := action.Args[0].(image.Image)
+		at := image.Pt(int(action.Nums[0]), int(action.Nums[1])).Sub(off)
+		if chunk.Sub(at).In(img.Bounds()) {
+			found, after = &action, p
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+
+	img := found.Args[0].(image.Image)
+	at := image.Pt(int(found.Nums[0]), int(found.Nums[1])).Sub(off)
+	sp := image.Point{}.Sub(at)
+	if !opaqueOver(img, chunk.Add(sp)) {
+		return nil, nil
+	}
+	dst := image.NewRGBA(chunk)
+	draw.Draw(dst, chunk, img, sp, draw.Src)
+	return o.queue.from(after), dst
+}
+
+// opaqueOver returns if img is known to be opaque everywhere in r.
+func opaqueOver(img image.Image, r image.Rectangle) bool {
+	switch i := img.(type) {
+	case *image.RGBA:
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for o := i.PixOffset(r.Min.X, y) + 3; o < i.PixOffset(r.Max.X, y); o += 4 {
+				if i.Pix[o] != 0xff {
+					return false
+				}
+			}
+		}
+		return true
+	case *image.NRGBA:
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for o := i.PixOffset(r.Min.X, y) + 3; o < i.PixOffset(r.Max.X, y); o += 4 {
+				if i.Pix[o] != 0xff {
+					return false
+				}
+			}
+		}
+		return true
+	case interface{ Opaque() bool }:
+		return i.Opaque()
+	}
+	return false
+}
+
+// run applies the queued functions to a loaded chunk, with all coordinates
+// moved by the given shift.
+func (o *operation) run(ctx *context, queue *commands, shift image.Point) error {
+	chunkX, chunkY := ctx.X, ctx.Y
 
 	// offsets for operations, mapping worldspace coords to chunkspace
-	offXI, offYI := chunkX*o.parent.chunkSize, chunkY*o.parent.chunkSize
+	offXI, offYI := chunkX*o.parent.chunkSize-shift.X, chunkY*o.parent.chunkSize-shift.Y
 	offX, offY := float64(offXI), float64(offYI)
 
+	// images can be composited with straight alpha as long as there's no
+	// mask or transform in play
+	masked := false
+
+	// the current mask, which gg doesn't give back
+	var mask *image.Alpha
+
+	// lines to decorate when stroked, which gg doesn't give back either
+	var decoration *LineDecoration
+	traced := &tracedPath{}
+
+	// nor does it give back the line width, for StrokeAlong
+	lineWidth := 1.0
+
+	// painting behind what's there is done by drawing onto a cleared chunk
+	// & putting the chunk back over it
+	paint := PaintOver
+
 	// pretty straight forward, apply all operations in order to the chunk with
 	// offsets factored in. Since we know all the args that refer to some (x,y) in
 	// worldspace we can trivially apply a translation.
-	for _, action := range o.queue {
+	for p := (cursor{}); p.fn < queue.len(); {
+		var action command
+		action, p = queue.at(p)
+		var under *behind
+		if paint == PaintDstOver && paints(action.Func) {
+			under = paintBehind(ctx)
+		}
+
 		switch action.Func {
 		case setFillStyle:
 			ctx.Img.SetFillStyle(action.Args[0].(Gradient))
 		case setStrokeStyle:
 			ctx.Img.SetStrokeStyle(action.Args[0].(Gradient))
 		case setLineWidth:
-			ctx.Img.SetLineWidth(action.Args[0].(float64))
+			ctx.Img.SetLineWidth(action.Nums[0])
+			lineWidth = action.Nums[0]
 		case setColor:
 			ctx.Img.SetColor(action.Args[0].(color.Color))
+		case setFillRule:
+			ctx.Img.SetFillRule(action.Args[0].(FillRule))
+		case setPaintMode:
+			paint = action.Args[0].(PaintMode)
+		case setLineDecoration:
+			decoration = nil
+			if d := action.Args[0].(LineDecoration); !d.empty() {
+				decoration = &d
+			}
 		case setPixel:
-			x := action.Args[0].(int) - offXI
-			y := action.Args[1].(int) - offYI
+			x := int(action.Nums[0]) - offXI
+			y := int(action.Nums[1]) - offYI
 			ctx.Img.SetPixel(x, y)
 			ctx.setEdited()
 		case setMask:
 			other := action.Args[0].(*Mimage)
 			mbounds := ctx.Img.Image().Bounds()
-			mask, err := other.Mask(mbounds.Add(image.Pt(offXI, offYI)))
+			// masks stay where they are in world space, whatever the shift
+			m, err := other.Mask(mbounds.Add(image.Pt(chunkX*o.parent.chunkSize, chunkY*o.parent.chunkSize)))
 			if err != nil {
 				return err
 			}
-			ctx.Img.SetMask(mask)
+			ctx.Img.SetMask(m)
+			mask = m
+			masked = true
 		case invertMask:
-			ctx.Img.InvertMask()
+			ctx.Img.InvertMask() // inverts our mask in place, if there is one
+			if mask == nil {
+				mask = image.NewAlpha(ctx.Img.Image().Bounds())
+			}
 		case moveTo:
-			x := action.Args[0].(float64) - offX
-			y := action.Args[1].(float64) - offY
+			x := action.Nums[0] - offX
+			y := action.Nums[1] - offY
 			ctx.Img.MoveTo(x, y)
+			if decoration != nil {
+				traced.moveTo(x, y)
+			}
 		case lineTo:
-			x := action.Args[0].(float64) - offX
-			y := action.Args[1].(float64) - offY
+			x := action.Nums[0] - offX
+			y := action.Nums[1] - offY
 			ctx.Img.LineTo(x, y)
+			if decoration != nil {
+				traced.lineTo(x, y)
+			}
 		case closePath:
 			ctx.Img.ClosePath()
+			traced.closePath()
+		case drawPolygon, drawPolyline:
+			tracePoints(ctx.Img, action.Args[0].([]Point), offX, offY, action.Func == drawPolygon)
+			if decoration != nil {
+				traced.points(action.Args[0].([]Point), offX, offY, action.Func == drawPolygon)
+			}
+		case drawDashes:
+			traceDashes(ctx.Img, action.Args[0].([]Point), action.Args[1].([]float64), offX, offY)
 		case drawRectangle:
-			x := action.Args[0].(float64) - offX
-			y := action.Args[1].(float64) - offY
-			ctx.Img.DrawRectangle(x, y, action.Args[2].(float64), action.Args[3].(float64))
+			x := action.Nums[0] - offX
+			y := action.Nums[1] - offY
+			ctx.Img.DrawRectangle(x, y, action.Nums[2], action.Nums[3])
 			ctx.setEdited()
 		case rotateAbout:
-			x := action.Args[1].(float64) - offX
-			y := action.Args[2].(float64) - offY
-			ctx.Img.RotateAbout(action.Args[0].(float64), x, y)
+			x := action.Nums[1] - offX
+			y := action.Nums[2] - offY
+			ctx.Img.RotateAbout(action.Nums[0], x, y)
+		case transform:
+			// in chunk space the move also makes up for the offset being rotated
+			a, tx, ty := action.Nums[0], action.Nums[1], action.Nums[2]
+			sin, cos := math.Sincos(a)
+			ctx.Img.Translate(tx+cos*offX-sin*offY-offX, ty+sin*offX+cos*offY-offY)
+			ctx.Img.Rotate(a)
 		case drawEllipse:
-			x := action.Args[0].(float64) - offX
-			y := action.Args[1].(float64) - offY
-			ctx.Img.DrawEllipse(x, y, action.Args[2].(float64), action.Args[3].(float64))
+			x := action.Nums[0] - offX
+			y := action.Nums[1] - offY
+			ctx.Img.DrawEllipse(x, y, action.Nums[2], action.Nums[3])
 			ctx.setEdited()
 		case fill:
 			ctx.Img.Fill()
+			traced.clear()
 			ctx.setEdited()
 		case stroke:
 			ctx.Img.Stroke()
+			if decoration != nil {
+				decoration.render(ctx.Img, traced)
+			}
+			traced.clear()
 			ctx.setEdited()
+		case strokeAlongPath:
+			ctx.Img.ClearPath()
+			traced.clear()
+			if action.Args[0].(*strokeAlong).render(ctx.Img.Image().(*image.RGBA), lineWidth, mask, offX, offY) {
+				ctx.setEdited()
+			}
 		case clear:
 			ctx.Img.Clear()
 			ctx.setEdited()
 		case drawImage:
 			i := action.Args[0].(image.Image)
-			x := action.Args[1].(int) - offXI
-			y := action.Args[2].(int) - offYI
-			ctx.Img.DrawImage(i, x, y)
+			x := int(action.Nums[0]) - offXI
+			y := int(action.Nums[1]) - offYI
+			src, isRGBA := i.(*image.RGBA)
+			if ctx.straight != nil && !masked && isIdentity(ctx.Img) {
+				compositeStraight(ctx, i, x, y)
+			} else if isRGBA && mask == nil && isIdentity(ctx.Img) {
+				overRGBA(ctx.Img.Image().(*image.RGBA), src, x, y)
+			} else {
+				ctx.Img.DrawImage(i, x, y)
+			}
+			ctx.setEdited()
+		case drawImageOp:
+			i := action.Args[0].(image.Image)
+			x := int(action.Nums[0]) - offXI
+			y := int(action.Nums[1]) - offYI
+			if compositeImage(ctx, i, x, y, action.Args[1].(CompositeMode), action.Nums[2], mask) {
+				ctx.setEdited()
+			}
+		case drawImageScaled:
+			dst := action.Args[2].(image.Rectangle).Sub(image.Pt(offXI, offYI))
+			drawScaled(ctx, action.Args[0].(image.Image), action.Args[1].(image.Rectangle), dst, action.Args[3].(ResampleFilter), mask)
 			ctx.setEdited()
+		case drawImageTransformed:
+			t := TranslateMatrix(-offX, -offY).Multiply(action.Args[1].(Matrix3))
+			drawTransformed(ctx, action.Args[0].(image.Image), t, mask)
+			ctx.setEdited()
+		case drawStamps:
+			i := action.Args[0].(image.Image)
+			if renderStamps(ctx.Img, i, action.Args[1].([]stamp), offX, offY) {
+				ctx.setEdited()
+			}
+		case scatterStamps:
+			if action.Args[0].(*scatter).render(ctx.Img, offX, offY) {
+				ctx.setEdited()
+			}
+		case drawTilemap:
+			if action.Args[0].(*tilemap).render(ctx.Img, offXI, offYI) {
+				ctx.setEdited()
+			}
+		case drawGrid:
+			if action.Args[0].(*grid).render(ctx.Img.Image().(*image.RGBA), offX, offY) {
+				ctx.setEdited()
+			}
+		case applyMacro:
+			x, y, scale := action.Nums[0], action.Nums[1], action.Nums[2]
+			if runMacro(ctx, action.Args[0].(*Macro), x, y, scale, offX, offY) {
+				ctx.setEdited()
+			}
+		case addNoise:
+			if action.Args[0].(*noise).render(ctx.Img.Image().(*image.RGBA), mask, offXI, offYI) {
+				ctx.setEdited()
+			}
 		}
 
+		if under != nil {
+			under.finish(ctx)
+		}
 	}
 
 	return nil
@@ -203,14 +671,14 @@ func (o *operation) SetRoutines(i int) {
 func (o *operation) DrawImage(i image.Image, x, y int) {
 	bnds := i.Bounds()
 	o.minMax(float64(x+bnds.Min.X), float64(y+bnds.Min.Y))
-	o.minMax(float64(x+bnds.Max.X-bnds.Min.X), float64(y+bnds.Max.Y-bnds.Min.Y))
-	o.queue = append(o.queue, newDefFunc(drawImage, i, x, y))
+	o.minMax(float64(x+bnds.Max.X), float64(y+bnds.Max.Y))
+	o.queue.pushArgs(drawImage, []interface{}{i}, float64(x), float64(y))
 }
 
 // Clear applies the currently set color across the whole image.
 // Nb. expensive, obviously.
 func (o *operation) Clear() {
-	o.queue = append(o.queue, newDefFunc(clear))
+	o.queue.push(clear)
 	b := o.parent.Bounds()
 	o.minX = float64(b.Min.X)
 	o.minY = float64(b.Min.Y)
@@ -223,89 +691,91 @@ func (o *operation) Clear() {
 // alpha values on the mask at the same location.
 // (Where a value of 255 makes shields the pixel entirely).
 func (o *operation) SetMask(mask *Mimage) {
-	o.queue = append(o.queue, newDefFunc(setMask, mask))
+	o.queue.pushArgs(setMask, []interface{}{mask})
 }
 
 // InvertMask flips the currently set mask's alpha values to be the other
 // way around. Ie. higher alpha values become low and vica versa.
 func (o *operation) InvertMask() {
-	o.queue = append(o.queue, newDefFunc(invertMask))
+	o.queue.push(invertMask)
 }
 
 // SetFillStyle configures some gradient to apply to Fill() operations.
 func (o *operation) SetFillStyle(g Gradient) {
-	o.queue = append(o.queue, newDefFunc(setFillStyle, g))
+	o.queue.pushArgs(setFillStyle, []interface{}{g})
 }
 
 // SetStrokeStyle configures some gradient to apply to Stroke() operations.
 func (o *operation) SetStrokeStyle(g Gradient) {
-	o.queue = append(o.queue, newDefFunc(setStrokeStyle, g))
+	o.queue.pushArgs(setStrokeStyle, []interface{}{g})
 }
 
 // SetLineWidth sets the width of the line (see MoveTo, LineTo, Stroke etc).
 func (o *operation) SetLineWidth(w float64) {
-	w = math.Min(1, w)
+	w = math.Max(1, w)
 	o.maxlineWidth = math.Max(o.maxlineWidth, w)
-	o.queue = append(o.queue, newDefFunc(setLineWidth, w))
+	o.queue.push(setLineWidth, w)
 }
 
 // SetColor sets the color of the 'pen' for lines, SetPixel, Clear etc.
 func (o *operation) SetColor(c color.Color) {
-	o.queue = append(o.queue, newDefFunc(setColor, c))
+	o.queue.pushArgs(setColor, []interface{}{c})
 }
 
 // SetPixel sets the color at (x,y) to the currently set color.
 func (o *operation) SetPixel(x, y int) {
 	o.minMax(float64(x), float64(y))
-	o.queue = append(o.queue, newDefFunc(setPixel, x, y))
+	o.minMax(float64(x+1), float64(y+1)) // the pixel is 1x1
+	o.queue.push(setPixel, float64(x), float64(y))
 }
 
 // MoveTo moves the pen to (x,y)
 func (o *operation) MoveTo(x, y float64) {
 	o.minMax(x, y)
-	o.queue = append(o.queue, newDefFunc(moveTo, x, y))
+	o.queue.push(moveTo, x, y)
 }
 
 // LineTo draws (or will draw on stroke) from the current location (see MoveTo)
 // to the given (x,y)
 func (o *operation) LineTo(x, y float64) {
 	o.minMax(x, y)
-	o.queue = append(o.queue, newDefFunc(lineTo, x, y))
+	o.queue.push(lineTo, x, y)
 }
 
 // ClosePath is effectively a LineTo to whatever the first location of the 'pen'
 // was when a line was started.
 func (o *operation) ClosePath() {
-	o.queue = append(o.queue, newDefFunc(closePath))
+	o.queue.push(closePath)
 }
 
 // DrawRectangle draws a rectangle beginning at (x,y) with width w and height h.
 func (o *operation) DrawRectangle(x, y, w, h float64) {
 	o.minMax(x, y)
 	o.minMax(x+w, y+h)
-	o.queue = append(o.queue, newDefFunc(drawRectangle, x, y, w, h))
+	o.queue.push(drawRectangle, x, y, w, h)
 }
 
 // RotateAbout rotates the image around (x,y) by the given angle (radians).
 func (o *operation) RotateAbout(angle, x, y float64) {
-	o.queue = append(o.queue, newDefFunc(rotateAbout, angle, x, y))
+	o.queue.push(rotateAbout, angle, x, y)
 }
 
 // DrawEllipse draws an ellipse at (x,y) with axis lengths of rx, ry
 func (o *operation) DrawEllipse(x, y, rx, ry float64) {
 	o.minMax(x-rx, y-ry)
 	o.minMax(x+rx, y+ry)
-	o.queue = append(o.queue, newDefFunc(drawEllipse, x, y, rx, ry))
+	o.queue.push(drawEllipse, x, y, rx, ry)
 }
 
 // Fill the queued shape(s) with the currently set color.
 func (o *operation) Fill() {
-	o.queue = append(o.queue, newDefFunc(fill))
+	o.queue.push(fill)
 }
 
 // Stroke applies line strokes with the currently set color.
 func (o *operation) Stroke() {
-	o.queue = append(o.queue, newDefFunc(stroke))
+	o.strokeWidth = math.Max(o.strokeWidth, math.Max(1, o.maxlineWidth))
+	o.queue.push(stroke)
 }
 
 // minMax sets internal min & max x & y values
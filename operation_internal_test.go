@@ -0,0 +1,46 @@
+package mimage
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestRetireFoldsTransforms(t *testing.T) {
+	m, err := New(image.Rect(0, 0, 64, 64), Directory(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	op := m.Draw().(*operation)
+	for i := 0; i < 50; i++ {
+		op.RotateAbout(0.1, float64(i), 10)
+		op.DrawRectangle(10, 10, 5, 5)
+		op.Fill()
+		err = op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if op.queue.len() > 4 {
+		t.Errorf("%d functions queued after 50 Do() calls, want the state folded up", op.queue.len())
+	}
+
+	// the folded transform maps points as the rotations did, applied last first
+	angle, tx, ty := 0.0, 0.0, 0.0
+	for i := 0; i < 50; i++ {
+		angle, tx, ty = composeTransform(angle, tx, ty, command{Func: rotateAbout, Nums: []float64{0.1, float64(i), 10}})
+	}
+	px, py := 3.0, 7.0
+	for i := 49; i >= 0; i-- {
+		sin, cos := math.Sincos(0.1)
+		dx, dy := px-float64(i), py-10
+		px, py = float64(i)+cos*dx-sin*dy, 10+sin*dx+cos*dy
+	}
+	sin, cos := math.Sincos(angle)
+	x, y := cos*3-sin*7+tx, sin*3+cos*7+ty
+	if math.Abs(x-px) > 1e-9 || math.Abs(y-py) > 1e-9 {
+		t.Errorf("folded transform maps (3,7) to (%v,%v), want (%v,%v)", x, y, px, py)
+	}
+}
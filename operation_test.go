@@ -0,0 +1,162 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestDrawImageBounds(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 100, 100), mimage.ChunkSize(32))
+	red := color.RGBA{255, 0, 0, 255}
+
+	// gg draws images relative to their own bounds, so this lands at (20,20)
+	op := m.Draw()
+	op.DrawImage(solid(image.Rect(20, 20, 40, 40), red), 0, 0)
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []image.Point{{20, 20}, {39, 39}} {
+		if got := m.At(p.X, p.Y); got != red {
+			t.Errorf("pixel %v is %v, want %v", p, got, red)
+		}
+	}
+	if got := m.At(40, 40); got != (color.RGBA{}) {
+		t.Errorf("pixel (40,40) is %v, want it untouched", got)
+	}
+}
+
+func TestDoAgain(t *testing.T) {
+	draw := func(op mimage.Operation, i int) {
+		op.RotateAbout(0.3, 64, 64)
+		op.DrawRectangle(80, 60, 20, 8)
+		if i%2 == 0 {
+			op.Fill()
+		}
+	}
+
+	// the same calls with a Do() after each, or all at once
+	again := newImage(t, image.Rect(0, 0, 128, 128), mimage.ChunkSize(32))
+	op := again.Draw()
+	op.SetColor(color.RGBA{255, 0, 0, 255})
+	for i := 0; i < 20; i++ {
+		draw(op, i)
+		err := op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	once := newImage(t, image.Rect(0, 0, 128, 128), mimage.ChunkSize(32))
+	op = once.Draw()
+	op.SetColor(color.RGBA{255, 0, 0, 255})
+	for i := 0; i < 20; i++ {
+		draw(op, i)
+	}
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drawn := 0
+	for y := 0; y < 128; y++ {
+		for x := 0; x < 128; x++ {
+			a, b := again.At(x, y).(color.RGBA), once.At(x, y).(color.RGBA)
+			if absDiff(a.A, b.A) > 2 {
+				t.Fatalf("pixel (%d,%d) is %v drawn again & %v drawn once", x, y, a, b)
+			}
+			if b.A > 0 {
+				drawn++
+			}
+		}
+	}
+	if drawn == 0 {
+		t.Error("nothing was drawn")
+	}
+}
+
+// absDiff returns the difference between a & b.
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func TestDrawImageCovering(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	for _, alpha := range []mimage.AlphaMode{mimage.AlphaPremultiplied, mimage.AlphaStraight} {
+		m := newImage(t, image.Rect(0, 0, 96, 96), mimage.ChunkSize(32), mimage.Alpha(alpha))
+		op := m.Draw()
+		op.SetColor(red)
+		op.Clear()
+		err := op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// covers chunk (1,1) whole & the others in part
+		op.DrawImage(solid(image.Rect(0, 0, 40, 40), blue), 28, 28)
+		err = op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for pt, want := range map[image.Point]color.RGBA{{28, 28}: blue, {48, 48}: blue, {67, 67}: blue, {27, 40}: red, {68, 68}: red} {
+			if got := m.At(pt.X, pt.Y); got != want {
+				t.Errorf("alpha mode %d: pixel %v is %v, want %v", alpha, pt, got, want)
+			}
+		}
+	}
+}
+
+func TestSetPixel(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 100, 100), mimage.ChunkSize(32))
+	red := color.RGBA{255, 0, 0, 255}
+
+	// alone on an operation, including either side of a chunk edge
+	for _, p := range []image.Point{{5, 5}, {31, 40}, {32, 40}, {99, 99}} {
+		op := m.Draw()
+		op.SetColor(red)
+		op.SetPixel(p.X, p.Y)
+		err := op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := m.At(p.X, p.Y); got != red {
+			t.Errorf("pixel %v is %v, want %v", p, got, red)
+		}
+	}
+	if got := m.At(6, 6); got != (color.RGBA{}) {
+		t.Errorf("pixel (6,6) is %v, want it untouched", got)
+	}
+}
+
+func TestSetLineWidth(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 100, 100), mimage.ChunkSize(32))
+	white := color.RGBA{255, 255, 255, 255}
+
+	op := m.Draw()
+	op.SetColor(white)
+	op.SetLineWidth(10)
+	op.MoveTo(10, 50)
+	op.LineTo(90, 50)
+	op.Stroke()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, y := range []int{46, 50, 53} {
+		if got := m.At(50, y); got != white {
+			t.Errorf("(50,%d) within the line is %v, want %v", y, got, white)
+		}
+	}
+	for _, y := range []int{43, 57} {
+		if got := m.At(50, y); got != (color.RGBA{}) {
+			t.Errorf("(50,%d) outside the line is %v, want it untouched", y, got)
+		}
+	}
+}
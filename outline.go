@@ -0,0 +1,87 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Outline draws a border of the given width (in pixels) & color around the
+// shapes in mask (its pixels at least half opaque), outside of them, eg. to
+// outline landmasses or regions on a map. The mask stays where it is in world
+// space & may be this image. Edges are anti-aliased.
+//
+// Chunks are outlined in parallel, each measuring the distance to shapes in
+// the mask within the width of the outline around it, so there are no seams.
+func (m *Mimage) Outline(mask *Mimage, width float64, c color.Color) error {
+	if width <= 0 {
+		return fmt.Errorf("width must be greater than zero, given %v", width)
+	}
+	src := color.RGBAModel.Convert(c).(color.RGBA)
+	halo := int(math.Ceil(width)) + 1
+
+	if mask == m {
+		// outline a copy of us, so chunks see the mask as it was
+		copied, err := m.copy()
+		if err != nil {
+			return err
+		}
+		defer copied.Close()
+		mask = copied
+	}
+
+	return m.eachChunk(m.bounds, func(ctx *context) error {
+		cb := m.chunkBounds(ctx.X, ctx.Y)
+		area := cb.Intersect(m.bounds)
+		if area.Empty() {
+			return nil
+		}
+		window := area.Inset(-halo)
+		alpha, err := mask.Mask(window)
+		if err != nil {
+			return err
+		}
+		dist := squaredDistances(alpha)
+
+		img := ctx.Img.Image().(*image.RGBA)
+		drawn := false
+		ww := window.Dx()
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				d := math.Sqrt(dist[(y-window.Min.Y)*ww+x-window.Min.X])
+				cover := math.Min(1, width+0.5-d)
+				if d == 0 || cover <= 0 {
+					continue
+				}
+				i := img.PixOffset(x-cb.Min.X, y-cb.Min.Y)
+				p := img.Pix[i : i+4 : i+4]
+				sa := float64(src.A) * cover
+				for k, s := range []uint8{src.R, src.G, src.B, src.A} {
+					p[k] = uint8(math.Round(float64(s)*cover + float64(p[k])*(1-sa/0xff)))
+				}
+				drawn = true
+			}
+		}
+		if drawn {
+			ctx.setEdited()
+		}
+		return nil
+	})
+}
+
+// copy returns a copy of the image in a new temporary directory, copying
+// chunks as they are.
+func (m *Mimage) copy() (*Mimage, error) {
+	out, err := New(m.bounds, ChunkSize(m.chunkSize), OperationRoutines(m.routines))
+	if err != nil {
+		return nil, err
+	}
+	chunks := [][2]int{}
+	for coords := range m.chunksWithin(m.bounds) {
+		if m.cache.exists(coords[0], coords[1]) {
+			chunks = append(chunks, coords)
+		}
+	}
+	return out, out.copyChunks(m, chunks, image.Point{})
+}
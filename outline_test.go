@@ -0,0 +1,71 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestOutline(t *testing.T) {
+	// a square across the seams between chunks
+	square := func(m *mimage.Mimage) {
+		op := m.Draw()
+		op.SetColor(color.White)
+		op.DrawRectangle(22, 22, 20, 20)
+		op.Fill()
+		err := op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	mask := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	square(mask)
+
+	red := color.RGBA{255, 0, 0, 255}
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	err := m.Outline(mask, 3, red)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pt, want := range map[image.Point]color.RGBA{
+		{30, 30}: {},  // inside
+		{20, 30}: red, // outside, within the width
+		{30, 43}: red,
+		{16, 30}: {}, // beyond the width
+	} {
+		if got := m.At(pt.X, pt.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", pt, got, want)
+		}
+	}
+
+	// outlining itself, the copy made is closed after
+	before := runtime.NumGoroutine()
+	self, err := mimage.New(image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	square(self)
+	err = self.Outline(self, 3, red)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pt, want := range map[image.Point]color.RGBA{{30, 30}: {255, 255, 255, 255}, {20, 30}: red} {
+		if got := self.At(pt.X, pt.Y); got != want {
+			t.Errorf("self outlined pixel %v is %v, want %v", pt, got, want)
+		}
+	}
+	err = self.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := settledGoroutines(before); n > before {
+		t.Errorf("%d goroutines running after Close, %d before", n, before)
+	}
+
+	if err := m.Outline(mask, 0, red); err == nil {
+		t.Error("zero width got no error")
+	}
+}
@@ -0,0 +1,42 @@
+package mimage
+
+import (
+	"image"
+)
+
+// overRGBA draws src over dst (both premultiplied) with the top left of src
+// at (x,y) in dst, exactly as draw.Draw with draw.Over would but a row at a
+// time with overRow, which is vectorised where we can.
+func overRGBA(dst, src *image.RGBA, x, y int) {
+	sb := src.Bounds()
+	r := sb.Add(image.Pt(x, y)).Intersect(dst.Bounds())
+	if r.Empty() {
+		return
+	}
+	sp := r.Min.Sub(image.Pt(x, y))
+	n := r.Dx() * 4
+	for row := 0; row < r.Dy(); row++ {
+		di := dst.PixOffset(r.Min.X, r.Min.Y+row)
+		si := src.PixOffset(sp.X, sp.Y+row)
+		overRow(dst.Pix[di:di+n:di+n], src.Pix[si:si+n:si+n])
+	}
+}
+
+// overRowGeneric draws the premultiplied RGBA pixels of src over those of dst,
+// the same length. For each channel this is
+//
+//	dst = src + dst * (255 - srcAlpha) / 255
+//
+// rounded as image/draw does, which for 16 bit lanes (so it can be done with
+// SIMD) is src + (t + (t * 514) >> 16 + src) >> 8 where t = dst * (255 -
+// srcAlpha). This is exact for all valid premultiplied colors.
+func overRowGeneric(dst, src []uint8) {
+	for i := 0; i+4 <= len(src) && i+4 <= len(dst); i += 4 {
+		inv := 0xff - uint32(src[i+3])
+		for c := i; c < i+4; c++ {
+			s := uint32(src[c])
+			t := uint32(dst[c]) * inv
+			dst[c] = uint8(s + (t+(t*514)>>16+s)>>8)
+		}
+	}
+}
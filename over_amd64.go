@@ -0,0 +1,22 @@
+//go:build amd64 && !purego
+// +build amd64,!purego
+
+package mimage
+
+// overRowSSE2 is overRowGeneric for whole blocks of 4 pixels, with SSE2
+// (which every amd64 cpu has). Any trailing bytes are left alone.
+//
+//go:noescape
+func overRowSSE2(dst, src []uint8)
+
+// overRow draws the premultiplied RGBA pixels of src over those of dst (see
+// overRowGeneric).
+func overRow(dst, src []uint8) {
+	n := len(src)
+	if len(dst) < n {
+		n = len(dst)
+	}
+	n &^= 15
+	overRowSSE2(dst[:n], src[:n])
+	overRowGeneric(dst[n:], src[n:])
+}
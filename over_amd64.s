@@ -0,0 +1,71 @@
+//go:build amd64 && !purego
+// +build amd64,!purego
+
+#include "textflag.h"
+
+// 0x00ff in each 16 bit lane, for 255 - alpha
+DATA overLow<>+0x00(SB)/8, $0x00ff00ff00ff00ff
+DATA overLow<>+0x08(SB)/8, $0x00ff00ff00ff00ff
+GLOBL overLow<>(SB), RODATA|NOPTR, $16
+
+// 514 in each 16 bit lane, for (t * 514) >> 16
+DATA overMul<>+0x00(SB)/8, $0x0202020202020202
+DATA overMul<>+0x08(SB)/8, $0x0202020202020202
+GLOBL overMul<>(SB), RODATA|NOPTR, $16
+
+// func overRowSSE2(dst, src []uint8)
+TEXT ·overRowSSE2(SB), NOSPLIT, $0-48
+	MOVQ dst_base+0(FP), DI
+	MOVQ src_base+24(FP), SI
+	MOVQ src_len+32(FP), CX
+	SHRQ $4, CX // blocks of 4 pixels
+	JZ   done
+
+	MOVOU overLow<>(SB), X6
+	MOVOU overMul<>(SB), X7
+	PXOR  X5, X5
+
+loop:
+	MOVOU (SI), X0 // src
+	MOVOU (DI), X1 // dst
+
+	// low 2 pixels, widened to 16 bits
+	MOVOA     X0, X2
+	PUNPCKLBW X5, X2 // s
+	MOVOA     X1, X3
+	PUNPCKLBW X5, X3 // d
+	PSHUFLW   $0xff, X2, X4
+	PSHUFHW   $0xff, X4, X4 // alpha in every lane
+	PXOR      X6, X4        // 255 - alpha
+	PMULLW    X4, X3        // t = d * (255 - alpha)
+	MOVOA     X3, X4
+	PMULHUW   X7, X4        // (t * 514) >> 16
+	PADDW     X4, X3
+	PADDW     X2, X3
+	PSRLW     $8, X3
+	PADDW     X2, X3        // low result
+
+	// high 2 pixels
+	PUNPCKHBW X5, X0 // s
+	PUNPCKHBW X5, X1 // d
+	PSHUFLW   $0xff, X0, X4
+	PSHUFHW   $0xff, X4, X4
+	PXOR      X6, X4
+	PMULLW    X4, X1
+	MOVOA     X1, X4
+	PMULHUW   X7, X4
+	PADDW     X4, X1
+	PADDW     X0, X1
+	PSRLW     $8, X1
+	PADDW     X0, X1 // high result
+
+	PACKUSWB X1, X3
+	MOVOU    X3, (DI)
+
+	ADDQ $16, SI
+	ADDQ $16, DI
+	DECQ CX
+	JNZ  loop
+
+done:
+	RET
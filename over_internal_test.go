@@ -0,0 +1,99 @@
+package mimage
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"math/rand"
+	"testing"
+)
+
+// randomPremultiplied fills img with random premultiplied pixels, including
+// fully transparent & opaque ones.
+func randomPremultiplied(rng *rand.Rand, img *image.RGBA) {
+	for i := 0; i < len(img.Pix); i += 4 {
+		a := rng.Intn(256)
+		switch rng.Intn(4) {
+		case 0:
+			a = 0
+		case 1:
+			a = 255
+		}
+		for c := 0; c < 3; c++ {
+			img.Pix[i+c] = uint8(rng.Intn(a + 1))
+		}
+		img.Pix[i+3] = uint8(a)
+	}
+}
+
+func TestOverRowExhaustive(t *testing.T) {
+	// every source value & alpha over every destination value, in each
+	// channel, against image/draw
+	for sa := 0; sa < 256; sa++ {
+		n := (sa + 1) * 256
+		src := image.NewRGBA(image.Rect(0, 0, n, 1))
+		dst := image.NewRGBA(src.Bounds())
+		i := 0
+		for s := 0; s <= sa; s++ {
+			for d := 0; d < 256; d++ {
+				src.Pix[i], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3] = uint8(s), uint8(sa-s), uint8(s/2), uint8(sa)
+				dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(d), uint8(255-d), uint8(d), uint8(d)
+				i += 4
+			}
+		}
+
+		want := image.NewRGBA(dst.Bounds())
+		copy(want.Pix, dst.Pix)
+		draw.Draw(want, want.Bounds(), src, image.Point{}, draw.Over)
+		overRow(dst.Pix, src.Pix)
+
+		if !bytes.Equal(dst.Pix, want.Pix) {
+			for i := range dst.Pix {
+				if dst.Pix[i] != want.Pix[i] {
+					t.Fatalf("source alpha %d, byte %d: got %d, draw.Over gives %d", sa, i, dst.Pix[i], want.Pix[i])
+				}
+			}
+		}
+	}
+}
+
+func TestOverRowLengths(t *testing.T) {
+	// the vectorised part works on blocks of pixels, the rest is left to
+	// the generic loop
+	rng := rand.New(rand.NewSource(1))
+	for n := 0; n < 70; n++ {
+		src := image.NewRGBA(image.Rect(0, 0, n, 1))
+		dst := image.NewRGBA(src.Bounds())
+		randomPremultiplied(rng, src)
+		randomPremultiplied(rng, dst)
+
+		want := image.NewRGBA(dst.Bounds())
+		copy(want.Pix, dst.Pix)
+		draw.Draw(want, want.Bounds(), src, image.Point{}, draw.Over)
+		overRow(dst.Pix, src.Pix)
+
+		if !bytes.Equal(dst.Pix, want.Pix) {
+			t.Errorf("%d pixels: got %v, draw.Over gives %v", n, dst.Pix, want.Pix)
+		}
+	}
+}
+
+func TestOverRGBA(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	src := image.NewRGBA(image.Rect(0, 0, 37, 23))
+	randomPremultiplied(rng, src)
+
+	for _, at := range []image.Point{{0, 0}, {5, 7}, {-10, -3}, {50, 40}, {60, 0}} {
+		dst := image.NewRGBA(image.Rect(0, 0, 64, 48))
+		randomPremultiplied(rng, dst)
+
+		want := image.NewRGBA(dst.Bounds())
+		copy(want.Pix, dst.Pix)
+		draw.Draw(want, src.Bounds().Add(at), src, image.Point{}, draw.Over)
+		overRGBA(dst, src, at.X, at.Y)
+
+		if !bytes.Equal(dst.Pix, want.Pix) {
+			t.Errorf("at %v: differs from draw.Over", at)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !amd64 || purego
+// +build !amd64 purego
+
+package mimage
+
+// overRow draws the premultiplied RGBA pixels of src over those of dst (see
+// overRowGeneric).
+func overRow(dst, src []uint8) {
+	overRowGeneric(dst, src)
+}
@@ -0,0 +1,49 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Pad returns a new Mimage with a solid border of the given color added to each
+// side of this image (sizes in pixels).
+func (m *Mimage) Pad(top, right, bottom, left int, c color.Color) (*Mimage, error) {
+	if top < 0 || right < 0 || bottom < 0 || left < 0 {
+		return nil, fmt.Errorf("padding must not be negative, given %d %d %d %d", top, right, bottom, left)
+	}
+
+	bounds := image.Rect(0, 0, m.Width()+left+right, m.Height()+top+bottom)
+	out, err := New(bounds, ChunkSize(m.chunkSize), OperationRoutines(m.routines))
+	if err != nil {
+		return nil, err
+	}
+
+	// where the original image lands in the new one
+	inner := image.Rect(left, top, left+m.Width(), top+m.Height())
+	offset := m.bounds.Min.Sub(inner.Min)
+	border := image.NewUniform(c)
+
+	return out, out.eachChunk(bounds, func(ctx *context) error {
+		cb := out.chunkBounds(ctx.X, ctx.Y)
+		dst := ctx.Img.Image().(*image.RGBA)
+		ctx.setEdited()
+
+		r := cb.Intersect(inner)
+		if r != cb.Intersect(bounds) {
+			draw.Draw(dst, dst.Bounds(), border, image.Point{}, draw.Src)
+		}
+		if r.Empty() {
+			return nil
+		}
+
+		src, err := m.Image(r.Add(offset))
+		if err != nil {
+			return err
+		}
+		draw.Draw(dst, r.Sub(cb.Min), src, image.Point{}, draw.Src)
+
+		return nil
+	})
+}
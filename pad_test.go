@@ -0,0 +1,52 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestPad(t *testing.T) {
+	m := newImage(t, image.Rect(10, 10, 74, 74), mimage.ChunkSize(32))
+	red := color.RGBA{255, 0, 0, 255}
+	op := m.Draw()
+	op.SetColor(red)
+	op.Clear()
+	op.SetColor(color.White)
+	op.DrawRectangle(10, 10, 1, 1)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blue := color.RGBA{0, 0, 255, 255}
+	padded, err := m.Pad(5, 10, 25, 20, blue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer padded.Close()
+
+	if b := padded.Bounds(); b != image.Rect(0, 0, 94, 94) {
+		t.Errorf("padded bounds are %v", b)
+	}
+	for p, want := range map[image.Point]color.RGBA{
+		{19, 10}: blue,
+		{20, 5}:  {255, 255, 255, 255}, // the old top left
+		{21, 6}:  red,
+		{83, 68}: red,
+		{84, 68}: blue,
+		{83, 69}: blue,
+		{93, 93}: blue,
+	} {
+		if got := padded.At(p.X, p.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", p, got, want)
+		}
+	}
+
+	if _, err := m.Pad(-1, 0, 0, 0, blue); err == nil {
+		t.Error("negative padding got no error")
+	}
+}
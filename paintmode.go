@@ -0,0 +1,96 @@
+package mimage
+
+import (
+	"image"
+	"image/color"
+)
+
+// PaintMode determines how everything drawn (filled, stroked, images ..) is
+// combined with what's already on the image.
+type PaintMode int
+
+const (
+	// PaintOver draws over the top of what's there, as usual.
+	PaintOver PaintMode = iota
+
+	// PaintDstOver draws behind what's there (destination over), so only
+	// shows where the image is transparent or partly so, eg. to add a
+	// background under content that has already been drawn.
+	PaintDstOver
+)
+
+// SetPaintMode sets how following drawing is combined with the image (see
+// PaintMode), the default being PaintOver.
+func (o *operation) SetPaintMode(mode PaintMode) {
+	o.queue.pushArgs(setPaintMode, []interface{}{mode})
+}
+
+// paints returns if the deferred function draws on the chunk (rather than
+// setting state or adding to the path).
+func paints(fn int) bool {
+	switch fn {
+	case setPixel, fill, stroke, strokeAlongPath, clear, drawImage, drawImageOp, drawImageScaled,
+		drawImageTransformed, drawStamps, scatterStamps, drawTilemap, drawGrid, applyMacro:
+		return true
+	}
+	return false
+}
+
+// behind is a chunk as it was before something is painted behind it.
+type behind struct {
+	under    *image.RGBA
+	straight *image.NRGBA
+}
+
+// paintBehind stashes the chunk & clears it, so whatever is drawn next lands
+// on a transparent chunk to be put behind the stashed one by finish.
+func paintBehind(ctx *context) *behind {
+	dst := ctx.Img.Image().(*image.RGBA)
+	b := &behind{
+		under:    &image.RGBA{Pix: append([]uint8{}, dst.Pix...), Stride: dst.Stride, Rect: dst.Rect},
+		straight: ctx.straight,
+	}
+	for i := range dst.Pix {
+		dst.Pix[i] = 0
+	}
+
+	// nothing drawn composites onto the straight copy, which still holds the
+	// stashed chunk
+	ctx.straight = nil
+	return b
+}
+
+// finish puts the stashed chunk back over whatever was drawn.
+func (b *behind) finish(ctx *context) {
+	ctx.straight = b.straight
+	dst := ctx.Img.Image().(*image.RGBA)
+	for i := 0; i < len(dst.Pix); i += 4 {
+		s := dst.Pix[i : i+4 : i+4]
+		d := b.under.Pix[i : i+4 : i+4]
+		if s[3] == 0 || d[3] == 0xff {
+			copy(s, d)
+			continue
+		}
+
+		if b.straight == nil {
+			// premultiplied; the stash plus what shows through it
+			for c := range s {
+				s[c] = d[c] + uint8((uint32(s[c])*uint32(0xff-d[3])+0x7f)/0xff)
+			}
+			continue
+		}
+
+		// straight; mixed in proportion to how much of each shows
+		bc := reconcileAt(b.straight, b.under, i)
+		sc := unpremultiply(color.RGBA{R: s[0], G: s[1], B: s[2], A: s[3]})
+		ba, sa := float64(bc.A)/0xff, float64(sc.A)/0xff*(1-float64(bc.A)/0xff)
+		oa := ba + sa
+		mix := func(b, s uint8) uint8 {
+			return uint8((float64(b)*ba+float64(s)*sa)/oa + 0.5)
+		}
+		out := color.NRGBA{R: mix(bc.R, sc.R), G: mix(bc.G, sc.G), B: mix(bc.B, sc.B), A: uint8(oa*0xff + 0.5)}
+		b.straight.Pix[i], b.straight.Pix[i+1], b.straight.Pix[i+2], b.straight.Pix[i+3] = out.R, out.G, out.B, out.A
+		p := premultiply(out)
+		s[0], s[1], s[2], s[3] = p.R, p.G, p.B, p.A
+	}
+}
@@ -0,0 +1,54 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestSetPaintMode(t *testing.T) {
+	red, green := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255}
+	for _, alpha := range []mimage.AlphaMode{mimage.AlphaPremultiplied, mimage.AlphaStraight} {
+		m := newImage(t, image.Rect(0, 0, 96, 96), mimage.ChunkSize(32), mimage.Alpha(alpha))
+		op := m.Draw()
+		op.SetColor(red)
+		op.DrawRectangle(0, 0, 40, 40)
+		op.Fill()
+		op.SetColor(color.NRGBA{0, 0, 255, 128})
+		op.DrawRectangle(50, 0, 40, 40)
+		op.Fill()
+
+		// a background behind all of it
+		op.SetPaintMode(mimage.PaintDstOver)
+		op.SetColor(green)
+		op.Clear()
+		err := op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for pt, want := range map[image.Point]color.RGBA{{20, 20}: red, {36, 36}: red, {20, 60}: green, {80, 80}: green} {
+			if got := m.At(pt.X, pt.Y); got != want {
+				t.Errorf("alpha mode %d: pixel %v is %v, want %v", alpha, pt, got, want)
+			}
+		}
+		// half blue over the green
+		if got := m.At(70, 20).(color.RGBA); got.A != 255 || absDiff(got.B, 128) > 2 || absDiff(got.G, 127) > 2 {
+			t.Errorf("alpha mode %d: half transparent pixel is %v, want blue over green", alpha, got)
+		}
+
+		// & back over the top
+		op.SetPaintMode(mimage.PaintOver)
+		op.SetColor(color.White)
+		op.DrawRectangle(10, 10, 10, 10)
+		op.Fill()
+		err = op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := m.At(15, 15); got != (color.RGBA{255, 255, 255, 255}) {
+			t.Errorf("alpha mode %d: pixel painted over is %v, want white", alpha, got)
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package mimage
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"sort"
+)
+
+const (
+	// paletteSamples is roughly how many pixels we look at when building an
+	// adaptive palette
+	paletteSamples = 1 << 16
+
+	// pngIDATSize is how much compressed data goes in each PNG IDAT chunk
+	pngIDATSize = 64 * 1024
+)
+
+// PaletteOptions configures exporting to paletted (indexed color) files.
+type PaletteOptions struct {
+	// Palette of at most 256 colors to use. If not given an adaptive palette
+	// of up to Colors colors is built from a sample of the image.
+	Palette color.Palette
+
+	// Colors is the size of the adaptive palette, defaults to 256.
+	Colors int
+
+	// Dither spreads the error of each pixel over its neighbours with
+	// Floyd–Steinberg dithering, rather than just picking the nearest color.
+	Dither bool
+}
+
+// PalettedPNG returns an Encoder that writes paletted PNG files.
+//
+// Rows are quantized & written one at a time as they're read, so used with
+// EncodeRegion or SplitGrid only a band of the image is held in memory.
+func PalettedPNG(opts PaletteOptions) Encoder {
+	return EncoderFunc("png", func(w io.Writer, img image.Image) error {
+		q, err := newQuantizer(img, opts)
+		if err != nil {
+			return err
+		}
+		return writePalettedPNG(w, img.Bounds().Dx(), img.Bounds().Dy(), q.palette, q.row)
+	})
+}
+
+// PalettedGIF returns an Encoder that writes GIF files. Like PalettedPNG
+// rows are quantized & written one at a time.
+func PalettedGIF(opts PaletteOptions) Encoder {
+	return EncoderFunc("gif", func(w io.Writer, img image.Image) error {
+		q, err := newQuantizer(img, opts)
+		if err != nil {
+			return err
+		}
+		b := img.Bounds()
+		gw, err := newGIFWriter(w, b.Dx(), b.Dy(), q.palette)
+		if err != nil {
+			return err
+		}
+		err = gw.frame(0, q.row)
+		if err != nil {
+			return err
+		}
+		return gw.close()
+	})
+}
+
+// quantizer maps rows of an image to palette indexes, optionally dithering.
+type quantizer struct {
+	img         image.Image
+	palette     color.Palette
+	transparent int // index of a fully transparent color, or -1
+	dither      bool
+
+	// error carried to this and the next row, 3 channels per pixel
+	errCur, errNext []float32
+
+	// palette indexes of exact colors, and the nearest palette index by 5
+	// bit per channel color (or -1 if not yet known)
+	exact  map[[3]uint8]int
+	lookup []int16
+}
+
+// newQuantizer prepares to quantize img, building a palette if one isn't given.
+func newQuantizer(img image.Image, opts PaletteOptions) (*quantizer, error) {
+	pal := opts.Palette
+	if pal == nil {
+		n := opts.Colors
+		if n <= 0 || n > 256 {
+			n = 256
+		}
+		pal = adaptivePalette(img, n)
+	}
+	if len(pal) == 0 || len(pal) > 256 {
+		return nil, fmt.Errorf("palette must have between 1 and 256 colors, given %d", len(pal))
+	}
+
+	w := img.Bounds().Dx()
+	q := &quantizer{
+		img:         img,
+		palette:     pal,
+		transparent: -1,
+		dither:      opts.Dither,
+		errCur:      make([]float32, (w+2)*3),
+		errNext:     make([]float32, (w+2)*3),
+		exact:       map[[3]uint8]int{},
+		lookup:      make([]int16, 1<<15),
+	}
+	for i := range q.lookup {
+		q.lookup[i] = -1
+	}
+	for i := len(pal) - 1; i >= 0; i-- { // so the first of any duplicates wins
+		c := color.NRGBAModel.Convert(pal[i]).(color.NRGBA)
+		if c.A == 0 {
+			q.transparent = i
+			continue
+		}
+		q.exact[[3]uint8{c.R, c.G, c.B}] = i
+	}
+
+	return q, nil
+}
+
+// nearest returns the index of the palette color closest to (r,g,b). Colors
+// not exactly in the palette are looked up at 5 bits per channel.
+func (q *quantizer) nearest(r, g, b float32) int {
+	rounded := [3]uint8{uint8(clampFloat(r+0.5, 0, 255)), uint8(clampFloat(g+0.5, 0, 255)), uint8(clampFloat(b+0.5, 0, 255))}
+	if idx, ok := q.exact[rounded]; ok {
+		return idx
+	}
+
+	key := int(clampFloat(r, 0, 255))>>3<<10 | int(clampFloat(g, 0, 255))>>3<<5 | int(clampFloat(b, 0, 255))>>3
+	if idx := q.lookup[key]; idx >= 0 {
+		return int(idx)
+	}
+
+	// the center of the bin
+	cr, cg, cb := float64(key>>10<<3+4), float64(key>>5&31<<3+4), float64(key&31<<3+4)
+	best, bestDist := 0, math.MaxFloat64
+	for i, c := range q.palette {
+		pr, pg, pb, pa := c.RGBA()
+		if pa == 0 {
+			continue
+		}
+		dr, dg, db := float64(pr>>8)-cr, float64(pg>>8)-cg, float64(pb>>8)-cb
+		dist := dr*dr + dg*dg + db*db
+		if dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+
+	q.lookup[key] = int16(best)
+	return best
+}
+
+// row writes the palette indexes for row y of the image into buf. Rows must
+// be requested in order when dithering.
+func (q *quantizer) row(y int, buf []byte) error {
+	b := q.img.Bounds()
+	q.errCur, q.errNext = q.errNext, q.errCur
+	for i := range q.errNext {
+		q.errNext[i] = 0
+	}
+
+	for x := 0; x < b.Dx(); x++ {
+		c := color.NRGBAModel.Convert(q.img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+		if c.A < 0x80 && q.transparent >= 0 {
+			buf[x] = uint8(q.transparent)
+			continue
+		}
+
+		e := q.errCur[(x+1)*3 : (x+1)*3+3]
+		r, g, bl := float32(c.R)+e[0], float32(c.G)+e[1], float32(c.B)+e[2]
+		idx := q.nearest(r, g, bl)
+		buf[x] = uint8(idx)
+		if !q.dither {
+			continue
+		}
+
+		pr, pg, pb, _ := q.palette[idx].RGBA()
+		diff := [3]float32{r - float32(pr>>8), g - float32(pg>>8), bl - float32(pb>>8)}
+		for ch, d := range diff {
+			q.errCur[(x+2)*3+ch] += d * 7 / 16
+			q.errNext[x*3+ch] += d * 3 / 16
+			q.errNext[(x+1)*3+ch] += d * 5 / 16
+			q.errNext[(x+2)*3+ch] += d * 1 / 16
+		}
+	}
+
+	return nil
+}
+
+// colorBox is a set of sample colors for median cut.
+type colorBox []color.NRGBA
+
+// channel returns the given channel (0-2) of a color.
+func channel(c color.NRGBA, ch int) uint8 {
+	switch ch {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	}
+	return c.B
+}
+
+// widest returns the channel with the largest range in the box & that range.
+func (b colorBox) widest() (int, int) {
+	best, bestRange := 0, -1
+	for ch := 0; ch < 3; ch++ {
+		lo, hi := 255, 0
+		for _, c := range b {
+			v := int(channel(c, ch))
+			lo, hi = minInt(lo, v), maxInt(hi, v)
+		}
+		if hi-lo > bestRange {
+			best, bestRange = ch, hi-lo
+		}
+	}
+	return best, bestRange
+}
+
+// average returns the mean color of the box.
+func (b colorBox) average() color.NRGBA {
+	var r, g, bl int
+	for _, c := range b {
+		r, g, bl = r+int(c.R), g+int(c.G), bl+int(c.B)
+	}
+	n := len(b)
+	return color.NRGBA{R: uint8((r + n/2) / n), G: uint8((g + n/2) / n), B: uint8((bl + n/2) / n), A: 0xff}
+}
+
+// adaptivePalette builds a palette of up to n colors for img using median cut
+// over a sample of its pixels. Sampled rows are read top to bottom, so images
+// that stream bands (see regionView) are read only once.
+func adaptivePalette(img image.Image, n int) color.Palette {
+	b := img.Bounds()
+	step := maxInt(1, int(math.Sqrt(float64(b.Dx())*float64(b.Dy())/paletteSamples)))
+
+	samples := colorBox{}
+	transparent := false
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A < 0x80 {
+				transparent = true
+				continue
+			}
+			samples = append(samples, c)
+		}
+	}
+
+	pal := color.Palette{}
+	if transparent {
+		pal = append(pal, color.NRGBA{})
+		n--
+	}
+	if len(samples) == 0 {
+		if len(pal) == 0 {
+			pal = append(pal, color.NRGBA{A: 0xff})
+		}
+		return pal
+	}
+
+	// repeatedly split the box with the widest channel range at its median
+	boxes := []colorBox{samples}
+	for len(boxes) < n {
+		split, splitRange, ch := -1, 0, 0
+		for i, box := range boxes {
+			c, r := box.widest()
+			if len(box) > 1 && r > splitRange {
+				split, splitRange, ch = i, r, c
+			}
+		}
+		if split < 0 {
+			break // every box is a single color
+		}
+
+		box := boxes[split]
+		sort.Slice(box, func(i, j int) bool { return channel(box[i], ch) < channel(box[j], ch) })
+		mid := len(box) / 2
+		boxes[split] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+
+	for _, box := range boxes {
+		pal = append(pal, box.average())
+	}
+	return pal
+}
+
+// pngChunkWriter collects written data into PNG chunks of the given type.
+type pngChunkWriter struct {
+	w    io.Writer
+	typ  string
+	buf  []byte
+	err  error
+	size int
+}
+
+// Write buffers p, writing out full chunks.
+func (p *pngChunkWriter) Write(data []byte) (int, error) {
+	p.buf = append(p.buf, data...)
+	for len(p.buf) >= p.size && p.err == nil {
+		p.err = writePNGChunk(p.w, p.typ, p.buf[:p.size])
+		p.buf = append(p.buf[:0], p.buf[p.size:]...)
+	}
+	return len(data), p.err
+}
+
+// flush writes any remaining buffered data as a chunk.
+func (p *pngChunkWriter) flush() error {
+	if p.err != nil || len(p.buf) == 0 {
+		return p.err
+	}
+	p.err = writePNGChunk(p.w, p.typ, p.buf)
+	p.buf = nil
+	return p.err
+}
+
+// writePNGChunk writes a single chunk (length, type, data, crc).
+func writePNGChunk(w io.Writer, typ string, data []byte) error {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], typ)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	footer := make([]byte, 4)
+	binary.BigEndian.PutUint32(footer, crc.Sum32())
+
+	for _, b := range [][]byte{header, data, footer} {
+		_, err := w.Write(b)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePalettedPNG writes an 8 bit paletted PNG, calling row(y, buf) for the
+// palette indexes of each row in turn.
+func writePalettedPNG(w io.Writer, width, height int, pal color.Palette, row func(y int, buf []byte) error) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("\x89PNG\r\n\x1a\n")
+
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr, uint32(width))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(height))
+	ihdr[8], ihdr[9] = 8, 3 // bit depth, paletted
+	err := writePNGChunk(bw, "IHDR", ihdr)
+	if err != nil {
+		return err
+	}
+
+	plte := make([]byte, 0, len(pal)*3)
+	trns := make([]byte, 0, len(pal))
+	opaque := true
+	for _, c := range pal {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		plte = append(plte, n.R, n.G, n.B)
+		trns = append(trns, n.A)
+		opaque = opaque && n.A == 0xff
+	}
+	err = writePNGChunk(bw, "PLTE", plte)
+	if err != nil {
+		return err
+	}
+	if !opaque {
+		err = writePNGChunk(bw, "tRNS", trns)
+		if err != nil {
+			return err
+		}
+	}
+
+	idat := &pngChunkWriter{w: bw, typ: "IDAT", size: pngIDATSize}
+	zw := zlib.NewWriter(idat)
+	buf := make([]byte, width+1) // leading filter byte, always 0 (none)
+	for y := 0; y < height; y++ {
+		err = row(y, buf[1:])
+		if err != nil {
+			return err
+		}
+		_, err = zw.Write(buf)
+		if err != nil {
+			return err
+		}
+	}
+	err = zw.Close()
+	if err != nil {
+		return err
+	}
+	err = idat.flush()
+	if err != nil {
+		return err
+	}
+
+	err = writePNGChunk(bw, "IEND", nil)
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,95 @@
+package mimage_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+// quadrants returns an image with each quarter a different solid color.
+func quadrants(t *testing.T) (*mimage.Mimage, []color.RGBA) {
+	t.Helper()
+	colors := []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {255, 255, 0, 255}}
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(16))
+	op := m.Draw()
+	for i, c := range colors {
+		op.SetColor(c)
+		op.DrawRectangle(float64(i%2*32), float64(i/2*32), 32, 32)
+		op.Fill()
+	}
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, colors
+}
+
+func TestPalettedPNG(t *testing.T) {
+	m, colors := quadrants(t)
+
+	buf := &bytes.Buffer{}
+	err := m.EncodeRegion(m.Bounds(), buf, mimage.PalettedPNG(mimage.PaletteOptions{Colors: 4}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paletted, ok := img.(*image.Paletted)
+	if !ok {
+		t.Fatalf("decoded a %T, want a paletted image", img)
+	}
+	if len(paletted.Palette) > 4 {
+		t.Errorf("palette has %d colors, want at most 4", len(paletted.Palette))
+	}
+	for i, c := range colors {
+		x, y := i%2*32+16, i/2*32+16
+		if got := color.RGBAModel.Convert(img.At(x, y)); got != c {
+			t.Errorf("pixel (%d,%d) is %v, want %v", x, y, got, c)
+		}
+	}
+}
+
+func TestPalettedGIF(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(16))
+	op := m.Draw()
+	op.SetColor(color.RGBA{128, 128, 128, 255})
+	op.Clear()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// mid grey from only black & white dithers to about half of each
+	bw := color.Palette{color.Black, color.White}
+	buf := &bytes.Buffer{}
+	err = m.EncodeRegion(m.Bounds(), buf, mimage.PalettedGIF(mimage.PaletteOptions{Palette: bw, Dither: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := gif.Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b != image.Rect(0, 0, 64, 64) {
+		t.Fatalf("decoded bounds are %v", b)
+	}
+	white := 0
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			if r, _, _, _ := img.At(x, y).RGBA(); r > 0 {
+				white++
+			}
+		}
+	}
+	if white < 64*64*4/10 || white > 64*64*6/10 {
+		t.Errorf("%d of %d pixels dithered to white, want about half", white, 64*64)
+	}
+}
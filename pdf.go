@@ -0,0 +1,213 @@
+package mimage
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"io"
+)
+
+// defaultPDFDPI is the resolution images are placed at when not given.
+const defaultPDFDPI = 300
+
+// PDFOptions configures a PDF export (see EncodePDF).
+type PDFOptions struct {
+	// Region to export (in world space), if not given the whole image is used.
+	Region image.Rectangle
+
+	// DPI sets how many pixels make up an inch on the page, and so the
+	// physical size of each page. Defaults to the DPI of the image (see
+	// SetDPI) or 300 if that isn't set.
+	DPI float64
+
+	// Cols & Rows split the region into a grid of pages (left to right, top
+	// to bottom), each page sized to fit its piece of the image. Defaults
+	// to a single page.
+	Cols int
+	Rows int
+}
+
+// countingWriter tracks how many bytes have been written.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write passes data through to the underlying writer.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// pdfWriter writes the objects of a PDF file, tracking where each begins.
+type pdfWriter struct {
+	out     *countingWriter
+	offsets map[int]int64
+	err     error
+}
+
+// printf writes formatted text to the file, remembering the first error.
+func (p *pdfWriter) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.out, format, args...)
+}
+
+// object begins the numbered object.
+func (p *pdfWriter) object(num int) {
+	p.offsets[num] = p.out.n
+	p.printf("%d 0 obj\n", num)
+}
+
+// EncodePDF writes the image (or a region, see PDFOptions) to w as a PDF with
+// one or more pages. Export options such as Overlap (between pages) and
+// backgrounds are supported.
+//
+// Image data is streamed into the file one band of chunks at a time; only the
+// (compressed) alpha channel of a page is buffered.
+func (m *Mimage) EncodePDF(w io.Writer, opts PDFOptions, exportOpts ...ExportOption) error {
+	cfg, err := m.configureExport(exportOpts)
+	if err != nil {
+		return err
+	}
+	if opts.Region.Empty() {
+		opts.Region = m.bounds
+	}
+	if opts.DPI <= 0 {
+		opts.DPI = m.DPI()
+	}
+	if opts.DPI <= 0 {
+		opts.DPI = defaultPDFDPI
+	}
+	if opts.Cols <= 0 {
+		opts.Cols = 1
+	}
+	if opts.Rows <= 0 {
+		opts.Rows = 1
+	}
+
+	pages := gridPages(opts.Region.Intersect(m.bounds), opts.Cols, opts.Rows, cfg.overlap)
+	if len(pages) == 0 {
+		return fmt.Errorf("nothing to export in region %v", opts.Region)
+	}
+
+	pw := &pdfWriter{out: &countingWriter{w: w}, offsets: map[int]int64{}}
+	pw.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+
+	// objects 1 & 2 are the catalog & page tree, each page then uses six
+	// objects: page, contents, image, image length, alpha mask, mask length
+	pageObj := func(i int) int { return 3 + i*6 }
+
+	pw.object(1)
+	pw.printf("<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+
+	pw.object(2)
+	pw.printf("<< /Type /Pages /Count %d /Kids [", len(pages))
+	for i := range pages {
+		pw.printf(" %d 0 R", pageObj(i))
+	}
+	pw.printf(" ] >>\nendobj\n")
+
+	for i, page := range pages {
+		err := m.writePDFPage(pw, pageObj(i), page.r, opts.DPI, cfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	// cross reference table & trailer
+	last := pageObj(len(pages)) - 1
+	xref := pw.out.n
+	pw.printf("xref\n0 %d\n0000000000 65535 f \n", last+1)
+	for i := 1; i <= last; i++ {
+		pw.printf("%010d 00000 n \n", pw.offsets[i])
+	}
+	pw.printf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", last+1, xref)
+
+	return pw.err
+}
+
+// writePDFPage writes a single page showing the region r (in world space),
+// starting at the given object number.
+func (m *Mimage) writePDFPage(pw *pdfWriter, obj int, r image.Rectangle, dpi float64, cfg *exportConfig) error {
+	width := float64(r.Dx()) * 72 / dpi
+	height := float64(r.Dy()) * 72 / dpi
+	contents := fmt.Sprintf("q %.4f 0 0 %.4f 0 0 cm /Im0 Do Q\n", width, height)
+
+	pw.object(obj)
+	pw.printf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.4f %.4f] ", width, height)
+	pw.printf("/Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>\nendobj\n", obj+2, obj+1)
+
+	pw.object(obj + 1)
+	pw.printf("<< /Length %d >>\nstream\n%sendstream\nendobj\n", len(contents), contents)
+
+	// the color data is streamed straight into the file, while the alpha
+	// is compressed into memory & written after
+	pw.object(obj + 2)
+	pw.printf("<< /Type /XObject /Subtype /Image /Width %d /Height %d ", r.Dx(), r.Dy())
+	pw.printf("/ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode ")
+	pw.printf("/SMask %d 0 R /Length %d 0 R >>\nstream\n", obj+4, obj+3)
+	if pw.err != nil {
+		return pw.err
+	}
+
+	start := pw.out.n
+	rgb := zlib.NewWriter(pw.out)
+	alphaBuf := &bytes.Buffer{}
+	alpha := zlib.NewWriter(alphaBuf)
+
+	view := newRegionView(m, r, cfg)
+	row := make([]byte, r.Dx()*3)
+	arow := make([]byte, r.Dx())
+	for y := 0; y < r.Dy(); y++ {
+		for x := 0; x < r.Dx(); x++ {
+			c := view.RGBAAt(x, y)
+			if c.A != 0 && c.A != 0xff { // pdf wants straight (not premultiplied) color
+				c.R = uint8(uint32(c.R) * 0xff / uint32(c.A))
+				c.G = uint8(uint32(c.G) * 0xff / uint32(c.A))
+				c.B = uint8(uint32(c.B) * 0xff / uint32(c.A))
+			}
+			row[x*3], row[x*3+1], row[x*3+2] = c.R, c.G, c.B
+			arow[x] = c.A
+		}
+		if _, err := rgb.Write(row); err != nil {
+			return err
+		}
+		if _, err := alpha.Write(arow); err != nil {
+			return err
+		}
+	}
+	if view.err != nil {
+		return view.err
+	}
+	if err := rgb.Close(); err != nil {
+		return err
+	}
+	if err := alpha.Close(); err != nil {
+		return err
+	}
+	length := pw.out.n - start
+
+	pw.printf("\nendstream\nendobj\n")
+	pw.object(obj + 3)
+	pw.printf("%d\nendobj\n", length)
+
+	pw.object(obj + 4)
+	pw.printf("<< /Type /XObject /Subtype /Image /Width %d /Height %d ", r.Dx(), r.Dy())
+	pw.printf("/ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode /Length %d 0 R >>\nstream\n", obj+5)
+	if pw.err != nil {
+		return pw.err
+	}
+	if _, err := pw.out.Write(alphaBuf.Bytes()); err != nil {
+		return err
+	}
+	pw.printf("\nendstream\nendobj\n")
+
+	pw.object(obj + 5)
+	pw.printf("%d\nendobj\n", alphaBuf.Len())
+
+	return pw.err
+}
@@ -0,0 +1,95 @@
+package mimage_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestEncodePDF(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 96, 96), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(color.RGBA{255, 0, 0, 255})
+	op.DrawRectangle(0, 0, 48, 96)
+	op.Fill()
+	op.SetColor(color.RGBA{0, 0, 128, 128})
+	op.DrawRectangle(48, 0, 48, 96)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	err = m.EncodePDF(buf, mimage.PDFOptions{DPI: 72, Cols: 2, Rows: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf := buf.String()
+
+	if !strings.HasPrefix(pdf, "%PDF-1.4") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatal("file doesn't look like a pdf")
+	}
+	if !strings.Contains(pdf, "/Count 2") {
+		t.Error("want two pages")
+	}
+	if n := strings.Count(pdf, "/MediaBox [0 0 48.0000 96.0000]"); n != 2 {
+		t.Errorf("%d pages are 48x96 points at 72 dpi, want 2", n)
+	}
+
+	// each entry in the cross reference table points at its object
+	xref := pdf[strings.LastIndex(pdf, "\nxref\n"):]
+	offsets := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllStringSubmatch(xref, -1)
+	if len(offsets) != 2+2*6 {
+		t.Fatalf("%d objects in the cross reference table, want %d", len(offsets), 2+2*6)
+	}
+	for i, o := range offsets {
+		at, _ := strconv.Atoi(o[1])
+		if want := fmt.Sprintf("%d 0 obj", i+1); !strings.HasPrefix(pdf[at:], want) {
+			t.Errorf("object %d is listed at %d, which starts %q", i+1, at, pdf[at:at+10])
+		}
+	}
+
+	// the second page's image is the half transparent blue, with straight color
+	// & alpha in its mask
+	rgb := pdfStream(t, pdf, 3+6+2)
+	alpha := pdfStream(t, pdf, 3+6+4)
+	if len(rgb) != 48*96*3 || len(alpha) != 48*96 {
+		t.Fatalf("page images are %d rgb & %d alpha bytes", len(rgb), len(alpha))
+	}
+	if got := rgb[:3]; got[0] != 0 || got[1] != 0 || got[2] < 254 {
+		t.Errorf("first pixel of the second page is %v, want blue", got)
+	}
+	if alpha[0] != 128 {
+		t.Errorf("first alpha of the second page is %d, want 128", alpha[0])
+	}
+}
+
+// pdfStream returns the decompressed stream of the numbered object.
+func pdfStream(t *testing.T, pdf string, obj int) []byte {
+	t.Helper()
+	start := strings.Index(pdf, fmt.Sprintf("\n%d 0 obj\n", obj))
+	if start < 0 {
+		t.Fatalf("no object %d", obj)
+	}
+	data := pdf[start:]
+	data = data[strings.Index(data, "stream\n")+len("stream\n"):]
+	r, err := zlib.NewReader(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
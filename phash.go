@@ -0,0 +1,99 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	// phashSize is the width & height regions are shrunk to before hashing
+	phashSize = 32
+
+	// phashBits is the width & height of the block of low frequencies kept
+	phashBits = 8
+)
+
+// PHash returns a perceptual hash of the region r (in world space); regions
+// that look alike have hashes that differ in few bits (see PHashDistance),
+// even after scaling, slight blurring or recompression. Handy for finding
+// duplicated areas across huge mosaics.
+//
+// The region is shrunk to 32x32 as it's read, so hashing a huge region needs
+// little memory. The low frequencies of the discrete cosine transform of its
+// gray level are each compared to their median to give the 64 bits.
+func (m *Mimage) PHash(r image.Rectangle) (uint64, error) {
+	r = r.Intersect(m.bounds)
+	if r.Empty() {
+		return 0, fmt.Errorf("region %v is outside of the image", r)
+	}
+
+	img, err := m.scaled(r, phashSize, phashSize)
+	if err != nil {
+		return 0, err
+	}
+
+	gray := make([]float64, phashSize*phashSize)
+	for i := range gray {
+		p := img.Pix[i*4 : i*4+4]
+		gray[i] = 0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])
+	}
+	freq := dctLow(gray, phashSize, phashBits)
+
+	// the first (DC) term is the average brightness, which would swamp the
+	// median, so is left out of it
+	sorted := append([]float64{}, freq[1:]...)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	hash := uint64(0)
+	for i, f := range freq {
+		if f > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash, nil
+}
+
+// PHashDistance returns how many bits differ between two perceptual hashes, 0
+// for (almost certainly) the same image & roughly 10 or less for a near
+// duplicate.
+func PHashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// dctLow returns the k x k lowest frequency terms of the 2D discrete cosine
+// transform (DCT-II) of the n x n plane, row by row.
+func dctLow(pix []float64, n, k int) []float64 {
+	cos := make([]float64, k*n)
+	for u := 0; u < k; u++ {
+		for x := 0; x < n; x++ {
+			cos[u*n+x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / float64(2*n))
+		}
+	}
+
+	// rows then columns
+	rows := make([]float64, n*k)
+	for y := 0; y < n; y++ {
+		for u := 0; u < k; u++ {
+			sum := 0.0
+			for x := 0; x < n; x++ {
+				sum += pix[y*n+x] * cos[u*n+x]
+			}
+			rows[y*k+u] = sum
+		}
+	}
+	out := make([]float64, k*k)
+	for v := 0; v < k; v++ {
+		for u := 0; u < k; u++ {
+			sum := 0.0
+			for y := 0; y < n; y++ {
+				sum += rows[y*k+u] * cos[v*n+y]
+			}
+			out[v*k+u] = sum
+		}
+	}
+	return out
+}
@@ -0,0 +1,60 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestPHash(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 192, 192))
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.Clear()
+	// the same picture at 64 & 128 pixels, and a different one
+	for _, at := range []struct{ x, y, s float64 }{{0, 0, 1}, {64, 0, 2}} {
+		op.SetColor(color.Black)
+		op.DrawEllipse(at.x+20*at.s, at.y+20*at.s, 12*at.s, 12*at.s)
+		op.Fill()
+		op.DrawRectangle(at.x+36*at.s, at.y+30*at.s, 20*at.s, 30*at.s)
+		op.Fill()
+	}
+	op.SetColor(color.Black)
+	op.DrawRectangle(0, 128, 64, 20)
+	op.Fill()
+	op.DrawEllipse(48, 176, 10, 10)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.PHash(image.Rect(200, 200, 300, 300)); err == nil {
+		t.Error("region outside of the image got no error")
+	}
+
+	small, err := m.PHash(image.Rect(0, 0, 64, 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	big, err := m.PHash(image.Rect(64, 0, 192, 128))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := m.PHash(image.Rect(0, 128, 64, 192))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := mimage.PHashDistance(small, small); d != 0 {
+		t.Errorf("distance to itself is %d", d)
+	}
+	if d := mimage.PHashDistance(small, big); d > 10 {
+		t.Errorf("scaled copy is %d bits away, want 10 or less", d)
+	}
+	if d := mimage.PHashDistance(small, other); d <= 10 {
+		t.Errorf("different picture is only %d bits away", d)
+	}
+}
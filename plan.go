@@ -0,0 +1,84 @@
+package mimage
+
+import (
+	"image"
+)
+
+// OperationPlan describes the work an operation would do, without doing it.
+// Byte counts are estimates; chunks are compressed as they're written so the
+// real sizes depend on what is drawn.
+type OperationPlan struct {
+	// Area (in world space) that may be changed.
+	Area image.Rectangle
+
+	// Chunks (by chunk x,y) that would be changed, in the order they're
+	// queued for processing.
+	Chunks []image.Point
+
+	// ChunkLoads is how many chunks would be read from storage (or created),
+	// including chunks of any mask images. Chunks already in memory are not
+	// counted, though they may be unloaded before the operation is done.
+	ChunkLoads int
+
+	// BytesRead is the size (as stored) of the chunks that would be read.
+	BytesRead int64
+
+	// BytesWritten is the expected size of the chunks written. Chunks that
+	// exist are assumed to stay about the same size, new chunks are counted
+	// at their uncompressed size, so this is an upper bound for them.
+	BytesWritten int64
+
+	// Repeats is how many times the queued functions would be applied to
+	// chunks in total (see Repeat).
+	Repeats int
+}
+
+// Plan returns what the operation would do if Do() were called now.
+func (o *operation) Plan() OperationPlan {
+	work, dirty := o.plan()
+	plan := OperationPlan{Area: dirty, Chunks: make([]image.Point, len(work))}
+	raw := int64(o.parent.chunkSize) * int64(o.parent.chunkSize) * 4
+
+	for i, job := range work {
+		plan.Chunks[i] = image.Pt(job.x, job.y)
+		plan.Repeats += len(job.shifts)
+
+		size := o.parent.cache.size(job.x, job.y)
+		if !o.parent.cache.loaded(job.x, job.y) {
+			plan.ChunkLoads++
+			plan.BytesRead += size
+		}
+		if size > 0 {
+			plan.BytesWritten += size
+		} else {
+			plan.BytesWritten += raw
+		}
+	}
+
+	// masks are read for every chunk they cover
+	for p := (cursor{}); p.fn < o.queue.len(); {
+		var action command
+		action, p = o.queue.at(p)
+		if action.Func != setMask {
+			continue
+		}
+		mask := action.Args[0].(*Mimage)
+		size := mask.chunkSize
+		for _, c := range plan.Chunks {
+			r := o.parent.chunkBounds(c.X, c.Y).Intersect(mask.bounds)
+			if r.Empty() {
+				continue
+			}
+			for my := floorDiv(r.Min.Y, size); my <= floorDiv(r.Max.Y-1, size); my++ {
+				for mx := floorDiv(r.Min.X, size); mx <= floorDiv(r.Max.X-1, size); mx++ {
+					if !mask.cache.loaded(mx, my) {
+						plan.ChunkLoads++
+						plan.BytesRead += mask.cache.size(mx, my)
+					}
+				}
+			}
+		}
+	}
+
+	return plan
+}
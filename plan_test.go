@@ -0,0 +1,64 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestPlan(t *testing.T) {
+	dir := t.TempDir()
+	m, err := mimage.New(image.Rect(0, 0, 128, 128), mimage.Directory(dir), mimage.ChunkSize(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	square := func(m *mimage.Mimage) mimage.Operation {
+		op := m.Draw()
+		op.SetColor(color.White)
+		op.DrawRectangle(10, 10, 40, 40)
+		op.Fill()
+		return op
+	}
+
+	// nothing exists yet, so chunks are counted at their uncompressed size
+	plan := square(m).Plan()
+	if len(plan.Chunks) != 4 || plan.ChunkLoads != 4 || plan.Repeats != 4 {
+		t.Errorf("plan of %d chunks, %d loads & %d repeats, want 4 of each", len(plan.Chunks), plan.ChunkLoads, plan.Repeats)
+	}
+	if !image.Rect(10, 10, 50, 50).In(plan.Area) || !plan.Area.In(image.Rect(9, 9, 51, 51)) {
+		t.Errorf("planned area is %v", plan.Area)
+	}
+	if plan.BytesRead != 0 || plan.BytesWritten != 4*32*32*4 {
+		t.Errorf("plan reads %d & writes %d bytes of new chunks", plan.BytesRead, plan.BytesWritten)
+	}
+	if got := m.At(20, 20); got != (color.RGBA{}) {
+		t.Errorf("planning drew pixel (20,20) %v", got)
+	}
+
+	err = square(m).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// once written, chunks are read from disk at their file sizes
+	m, err = mimage.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	op := square(m)
+	op.Repeat([]image.Point{{0, 0}, {64, 64}})
+	plan = op.Plan()
+	if len(plan.Chunks) != 8 || plan.ChunkLoads != 8 || plan.Repeats != 8 {
+		t.Errorf("repeated plan of %d chunks, %d loads & %d repeats, want 8 of each", len(plan.Chunks), plan.ChunkLoads, plan.Repeats)
+	}
+	if plan.BytesRead <= 0 || plan.BytesRead >= 4*32*32*4 {
+		t.Errorf("plan reads %d bytes, want the size of the 4 existing chunks", plan.BytesRead)
+	}
+}
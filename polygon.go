@@ -0,0 +1,38 @@
+package mimage
+
+// DrawPolygon adds a closed outline through the given points (in world
+// space) to the current path, ready to be filled or stroked. Long outlines
+// (eg. coastlines) are queued as one call, with their bounds worked out once.
+func (o *operation) DrawPolygon(points []Point) {
+	o.drawPoints(drawPolygon, points)
+}
+
+// DrawPolyline adds an open line through the given points (in world space)
+// to the current path, ready to be stroked (eg. rivers, roads).
+func (o *operation) DrawPolyline(points []Point) {
+	o.drawPoints(drawPolyline, points)
+}
+
+// drawPoints queues the points (copied, so the caller can reuse them) as the
+// given function.
+func (o *operation) drawPoints(fn int, points []Point) {
+	if len(points) == 0 {
+		return
+	}
+	minX, minY, maxX, maxY := Path(points).bounds()
+	o.minMax(minX, minY)
+	o.minMax(maxX, maxY)
+	o.queue.pushArgs(fn, []interface{}{append([]Point{}, points...)})
+}
+
+// tracePoints adds the points to the canvas path, moved by the given offset
+// into chunk space, closing the path if asked.
+func tracePoints(dc Canvas, points []Point, offX, offY float64, closed bool) {
+	dc.MoveTo(points[0].X-offX, points[0].Y-offY)
+	for _, p := range points[1:] {
+		dc.LineTo(p.X-offX, p.Y-offY)
+	}
+	if closed {
+		dc.ClosePath()
+	}
+}
@@ -0,0 +1,41 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestDrawPolygon(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 96, 96), mimage.ChunkSize(32))
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+
+	op := m.Draw()
+	op.SetColor(red)
+	points := []mimage.Point{{X: 10, Y: 10}, {X: 80, Y: 10}, {X: 10, Y: 80}}
+	op.DrawPolygon(points)
+	points[1] = mimage.Point{X: 11, Y: 10} // the polygon was copied when queued
+	op.Fill()
+	op.DrawPolygon(nil)
+	op.SetColor(blue)
+	op.DrawPolyline([]mimage.Point{{X: 50, Y: 60.5}, {X: 90.5, Y: 60.5}, {X: 90.5, Y: 90}})
+	op.Stroke()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for pt, want := range map[image.Point]color.RGBA{
+		{20, 20}: red,
+		{60, 15}: red, // in another chunk
+		{60, 60}: blue,
+		{90, 75}: blue,
+		{70, 70}: {}, // the polyline isn't closed
+	} {
+		if got := m.At(pt.X, pt.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", pt, got, want)
+		}
+	}
+}
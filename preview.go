@@ -0,0 +1,146 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+)
+
+// Preview is an operation drawn onto copies of the chunks it changes, rather
+// than the image, so the result can be looked at (eg. served as tiles) before
+// it's committed to or thrown away.
+type Preview struct {
+	m      *Mimage
+	shadow *Mimage // holding the copies
+	chunks map[[2]int]bool
+	shown  bool // once drawn, guarded by the image's metaLock
+
+	// entry logs the operation when it's committed (see KeepHistory)
+	entry *HistoryEntry
+}
+
+// Preview does the operation (as Do would) onto copies of the chunks it
+// changes, kept apart from the image until the preview is committed or
+// discarded. Until then Image, At & ImageSnapshot read the copies where there
+// are any, so show the image as it would be.
+//
+// There can only be one preview of an image at a time. Drawing on the image
+// itself while previewing isn't shown in the preview, & is lost under the
+// copies when it's committed.
+func (m *Mimage) Preview(op Operation) (*Preview, error) {
+	o, ok := op.(*operation)
+	if !ok || o.parent != m {
+		return nil, fmt.Errorf("only operations on this image can be previewed")
+	}
+
+	// the copies are drawn & kept as the image's chunks are
+	opts := []Option{
+		ChunkSize(m.chunkSize), OperationRoutines(m.routines), Alpha(m.alpha), Edges(m.edges),
+		RenderWith(m.renderer), EncodeWith(m.codec),
+	}
+	if m.toroidal {
+		opts = append(opts, Toroidal())
+	}
+	if m.unbounded {
+		opts = append(opts, Unbounded())
+	}
+	if m.strictBounds {
+		opts = append(opts, StrictBounds())
+	}
+	shadow, err := New(m.bounds, opts...)
+	if err != nil {
+		return nil, err
+	}
+	p := &Preview{m: m, shadow: shadow, chunks: map[[2]int]bool{}}
+	work, dirty := o.plan()
+	if m.keepHistory {
+		p.entry = o.history(dirty) // before Do retires what's queued
+	}
+	existing := [][2]int{}
+	for _, job := range work {
+		p.chunks[[2]int{job.x, job.y}] = true
+		if m.cache.exists(job.x, job.y) {
+			existing = append(existing, [2]int{job.x, job.y})
+		}
+	}
+
+	m.metaLock.Lock()
+	busy := m.preview != nil
+	if !busy {
+		m.preview = p
+	}
+	m.metaLock.Unlock()
+	if busy {
+		shadow.Close()
+		return nil, fmt.Errorf("the image is already being previewed")
+	}
+
+	// copy the chunks the operation changes, then draw on the copies
+	err = shadow.copyChunks(m, existing, image.Point{})
+	if err == nil {
+		o.parent = shadow
+		err = o.Do()
+		o.parent = m
+	}
+	if err != nil {
+		p.Discard()
+		return nil, err
+	}
+
+	m.metaLock.Lock()
+	p.shown = true
+	m.metaLock.Unlock()
+	return p, nil
+}
+
+// Chunks returns the chunks the preview changes, eg. to refresh tiles over
+// them.
+func (p *Preview) Chunks() []image.Point {
+	out := make([]image.Point, 0, len(p.chunks))
+	for c := range p.chunks {
+		out = append(out, image.Pt(c[0], c[1]))
+	}
+	return out
+}
+
+// Commit replaces the chunks of the image with the preview's copies, ending
+// the preview. The operation is logged then, if the image keeps history.
+func (p *Preview) Commit() error {
+	chunks := make([][2]int, 0, len(p.chunks))
+	for c := range p.chunks {
+		chunks = append(chunks, c)
+	}
+	err := p.m.copyChunks(p.shadow, chunks, image.Point{})
+	if err == nil {
+		err = p.m.grow(p.shadow.bounds)
+	}
+	if err == nil && p.entry != nil {
+		err = p.m.logHistory(p.entry)
+	}
+	if err != nil {
+		return err
+	}
+	return p.Discard()
+}
+
+// Discard throws the preview's copies away, leaving the image as it was.
+func (p *Preview) Discard() error {
+	p.m.metaLock.Lock()
+	if p.m.preview == p {
+		p.m.preview = nil
+	}
+	p.m.metaLock.Unlock()
+	return p.shadow.Close()
+}
+
+// readChunk loads the chunk at (x,y) to be read, from the preview if there's
+// one changing it. As with cache.Load, Done() should be called on it.
+func (m *Mimage) readChunk(x, y int) (*context, error) {
+	m.metaLock.Lock()
+	p := m.preview
+	shown := p != nil && p.shown
+	m.metaLock.Unlock()
+	if shown && p.chunks[[2]int{x, y}] {
+		return p.shadow.cache.Load(x, y)
+	}
+	return m.cache.Load(x, y)
+}
@@ -0,0 +1,137 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestPreview(t *testing.T) {
+	before := runtime.NumGoroutine()
+	renderer := &countingRenderer{Renderer: mimage.AcceleratedRenderer(nil)}
+	m, err := mimage.New(image.Rect(0, 0, 200, 200), mimage.Directory(t.TempDir()), mimage.ChunkSize(64), mimage.RenderWith(renderer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	white := color.RGBA{255, 255, 255, 255}
+	square := func() mimage.Operation {
+		op := m.Draw()
+		op.SetColor(white)
+		op.DrawRectangle(10, 10, 100, 100)
+		op.Fill()
+		return op
+	}
+
+	p, err := m.Preview(square())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.At(50, 50); got != white {
+		t.Errorf("previewed pixel is %v, want %v", got, white)
+	}
+	if n := len(p.Chunks()); n != 4 {
+		t.Errorf("preview changes %d chunks, want 4", n)
+	}
+	// the copies are drawn as the image would be
+	if n := atomic.LoadInt32(&renderer.made); n < 4 {
+		t.Errorf("preview made %d canvases with the image's renderer, want at least 4", n)
+	}
+	if _, err := m.Preview(square()); err == nil {
+		t.Error("previewing twice at once got no error")
+	}
+	err = p.Discard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.At(50, 50); got != (color.RGBA{}) {
+		t.Errorf("pixel after discarding is %v, want it untouched", got)
+	}
+
+	p, err = m.Preview(square())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.At(50, 50); got != white {
+		t.Errorf("pixel after committing is %v, want %v", got, white)
+	}
+
+	other := newImage(t, image.Rect(0, 0, 10, 10))
+	if _, err := m.Preview(other.Draw()); err == nil {
+		t.Error("previewing another image's operation got no error")
+	}
+
+	// the copies are closed with the previews
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := settledGoroutines(before); n > before {
+		t.Errorf("%d goroutines running after Close, %d before", n, before)
+	}
+}
+
+func TestPreviewHistory(t *testing.T) {
+	m, err := mimage.New(image.Rect(0, 0, 200, 200), mimage.Directory(t.TempDir()), mimage.KeepHistory(), mimage.ChunkSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	square := func() mimage.Operation {
+		op := m.Draw()
+		op.SetColor(color.White)
+		op.DrawRectangle(10, 10, 100, 100)
+		op.Fill()
+		return op
+	}
+	logged := func() []*mimage.HistoryEntry {
+		t.Helper()
+		history, err := m.History()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return history
+	}
+
+	p, err := m.Preview(square())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Discard()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(logged()); n != 0 {
+		t.Errorf("a discarded preview logged %d entries", n)
+	}
+
+	p, err = m.Preview(square())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(logged()); n != 0 {
+		t.Errorf("a preview logged %d entries before being committed", n)
+	}
+	err = p.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := logged()
+	if len(history) != 1 {
+		t.Fatalf("committing logged %d entries, want 1", len(history))
+	}
+	if want := image.Rect(10, 10, 110, 110); history[0].Bounds != want {
+		t.Errorf("committed entry is over %v, want %v", history[0].Bounds, want)
+	}
+	calls := history[0].Calls
+	if calls[len(calls)-1].Func != "Fill" {
+		t.Errorf("last call logged is %s, want Fill", calls[len(calls)-1].Func)
+	}
+}
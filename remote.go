@@ -0,0 +1,124 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// remoteClient is used to fetch chunks from a RemoteURL
+var remoteClient = &http.Client{Timeout: time.Minute}
+
+// Fetcher returns the imagery for the region r (in world space, always one
+// whole chunk) from somewhere else. A nil image with no error means there is
+// nothing there & the chunk starts out transparent.
+type Fetcher func(r image.Rectangle) (image.Image, error)
+
+// Remote fills in chunks from f the first time they're used, the result is
+// then saved with the rest of the image so it's only fetched once. This lets
+// an image be layered on top of imagery too big to download all at once, only
+// the parts that are read or drawn on are ever fetched.
+//
+// Fetched images not the size of a chunk are scaled to fit.
+//
+// Functions can't be saved with the image, so after Load call SetRemote
+// again before using it (see RemoteURL for an option that is saved).
+func Remote(f Fetcher) Option {
+	return func(m *Mimage) error {
+		m.fetch = f
+		return nil
+	}
+}
+
+// RemoteURL is like Remote, fetching each chunk over HTTP(S) from a URL made
+// from the given template where
+//   - {x} & {y} are the chunk x,y
+//   - {left}, {top}, {right} & {bottom} are the chunk bounds in pixels
+//   - {size} is the chunk size in pixels
+//
+// eg. "https://tiles.example.com/{x}/{y}.png" with a chunk size matching the
+// tile size. A 404 is taken as an empty chunk, any other non 200 response is
+// an error. Responses can be any format registered with the image package
+// (png & jpeg are always available).
+//
+// Unlike Remote, the template is saved with the image.
+func RemoteURL(template string) Option {
+	return func(m *Mimage) error {
+		if template == "" {
+			return fmt.Errorf("remote url template is empty")
+		}
+		m.remote = template
+		m.fetch = urlFetcher(template)
+		return nil
+	}
+}
+
+// SetRemote sets (or with nil, stops) where chunks that haven't been used yet
+// are fetched from (see Remote). It's intended to be called right after Load,
+// before anything is read or drawn.
+func (m *Mimage) SetRemote(f Fetcher) {
+	m.fetch = f
+	m.cache.setFetcher(f)
+}
+
+// urlFetcher returns a Fetcher that GETs images from URLs made from template.
+func urlFetcher(template string) Fetcher {
+	return func(r image.Rectangle) (image.Image, error) {
+		size := r.Dx()
+		url := strings.NewReplacer(
+			"{x}", strconv.Itoa(floorDiv(r.Min.X, size)),
+			"{y}", strconv.Itoa(floorDiv(r.Min.Y, size)),
+			"{left}", strconv.Itoa(r.Min.X),
+			"{top}", strconv.Itoa(r.Min.Y),
+			"{right}", strconv.Itoa(r.Max.X),
+			"{bottom}", strconv.Itoa(r.Max.Y),
+			"{size}", strconv.Itoa(size),
+		).Replace(template)
+
+		resp, err := remoteClient.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		} else if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+		}
+
+		img, _, err := image.Decode(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s: %v", url, err)
+		}
+		return img, nil
+	}
+}
+
+// fetchChunk returns a chunk sized RGBA image of what f returns for the
+// chunk at (x,y).
+func fetchChunk(f Fetcher, x, y, chunkSize int) (*image.RGBA, error) {
+	min := image.Pt(x*chunkSize, y*chunkSize)
+	img, err := f(image.Rectangle{Min: min, Max: min.Add(image.Pt(chunkSize, chunkSize))})
+	if err != nil {
+		return nil, fmt.Errorf("fetching chunk %d,%d: %v", x, y, err)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, chunkSize, chunkSize))
+	if img == nil {
+		return dst, nil
+	}
+	b := img.Bounds()
+	if b.Dx() == chunkSize && b.Dy() == chunkSize {
+		draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	} else {
+		xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	}
+	return dst, nil
+}
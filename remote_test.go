@@ -0,0 +1,95 @@
+package mimage_test
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestRemoteURL(t *testing.T) {
+	fetches := int32(0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		switch r.URL.Path {
+		case "/1/0/32.png":
+			png.Encode(w, solid(image.Rect(0, 0, 32, 32), color.RGBA{0, 0, 255, 255}))
+		case "/0/1/32.png":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	if _, err := mimage.New(image.Rect(0, 0, 64, 64), mimage.Directory(t.TempDir()), mimage.RemoteURL("")); err == nil {
+		t.Error("empty template got no error")
+	}
+
+	dir := t.TempDir()
+	m, err := mimage.New(image.Rect(0, 0, 64, 64), mimage.Directory(dir), mimage.ChunkSize(32), mimage.RemoteURL(srv.URL+"/{x}/{y}/{size}.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blue := color.RGBA{0, 0, 255, 255}
+	for i := 0; i < 2; i++ {
+		if got := m.At(40, 10); got != blue {
+			t.Errorf("fetched pixel is %v, want %v", got, blue)
+		}
+	}
+	if got := m.At(10, 10); got != (color.RGBA{}) {
+		t.Errorf("missing chunk pixel is %v, want transparent", got)
+	}
+	if _, err := m.AtOk(10, 40); err == nil {
+		t.Error("server error got no error")
+	}
+	if n := atomic.LoadInt32(&fetches); n != 3 {
+		t.Errorf("fetched %d times, want 3", n)
+	}
+
+	// fetched chunks are kept with the image, the template too
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := mimage.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	if got := loaded.At(40, 10); got != blue {
+		t.Errorf("reloaded pixel is %v, want %v", got, blue)
+	}
+	loaded.At(40, 40)
+	if n := atomic.LoadInt32(&fetches); n != 4 {
+		t.Errorf("fetched %d times after reloading, want 4", n)
+	}
+}
+
+func TestRemote(t *testing.T) {
+	asked := make(chan image.Rectangle, 4)
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32), mimage.Remote(func(r image.Rectangle) (image.Image, error) {
+		asked <- r
+		if r.Min.X != 0 {
+			return nil, fmt.Errorf("nothing here")
+		}
+		return solid(image.Rect(0, 0, 8, 8), color.RGBA{255, 0, 0, 255}), nil // scaled to fit
+	}))
+
+	if got := m.At(31, 31); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("fetched pixel is %v, want red", got)
+	}
+	if r := <-asked; r != image.Rect(0, 0, 32, 32) {
+		t.Errorf("fetched %v, want the chunk bounds", r)
+	}
+	if _, err := m.AtOk(40, 0); err == nil {
+		t.Error("fetch error got no error")
+	}
+}
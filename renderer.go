@@ -0,0 +1,104 @@
+package mimage
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/fogleman/gg"
+)
+
+// Canvas is what chunks are drawn on with, rasterizing paths & images onto a
+// premultiplied RGBA chunk. Coordinates are in pixels of the chunk, with the
+// usual 2D drawing state (color, line width, transform, mask & the current
+// path) that Push & Pop save & restore.
+//
+// The methods are those of fogleman/gg's Context, which the default renderer
+// uses, see there for details of each.
+type Canvas interface {
+	// Image returns the chunk being drawn on, the *image.RGBA the canvas
+	// was made for; other parts of mimage read & write it directly.
+	Image() image.Image
+	Width() int
+	Height() int
+
+	Push()
+	Pop()
+	ClearPath()
+	ResetClip()
+
+	SetColor(c color.Color)
+	SetFillStyle(p Pattern)
+	SetStrokeStyle(p Pattern)
+	SetLineWidth(w float64)
+	SetFillRule(r FillRule)
+	SetPixel(x, y int)
+
+	SetMask(mask *image.Alpha) error
+	InvertMask()
+	AsMask() *image.Alpha
+
+	MoveTo(x, y float64)
+	LineTo(x, y float64)
+	ClosePath()
+	DrawRectangle(x, y, w, h float64)
+	DrawEllipse(x, y, rx, ry float64)
+
+	Translate(x, y float64)
+	Rotate(angle float64)
+	RotateAbout(angle, x, y float64)
+	ScaleAbout(sx, sy, x, y float64)
+	TransformPoint(x, y float64) (float64, float64)
+
+	Fill()
+	Stroke()
+	Clear()
+	DrawImage(im image.Image, x, y int)
+	DrawImageAnchored(im image.Image, x, y int, ax, ay float64)
+}
+
+// Renderer makes the Canvas each chunk is drawn on, so that the rasterizer
+// can be swapped out (eg. for one built on golang.org/x/image/vector, or
+// bindings to another library).
+type Renderer interface {
+	// NewCanvas returns a Canvas drawing onto img, which is one chunk.
+	NewCanvas(img *image.RGBA) Canvas
+}
+
+// RenderWith sets the Renderer chunks are drawn with, the default draws with
+// fogleman/gg.
+//
+// Renderers can't be saved with the image, so after Load call SetRenderer
+// again if it isn't the default.
+func RenderWith(r Renderer) Option {
+	return func(m *Mimage) error {
+		m.renderer = r
+		return nil
+	}
+}
+
+// SetRenderer sets the Renderer chunks are drawn with (see RenderWith), or
+// with nil, the default. It's intended to be called right after Load, before
+// anything is read or drawn.
+func (m *Mimage) SetRenderer(r Renderer) {
+	m.renderer = r
+	m.cache.setRenderer(r)
+}
+
+// ggRenderer draws with fogleman/gg
+type ggRenderer struct{}
+
+// NewCanvas returns a gg Context drawing onto img.
+func (ggRenderer) NewCanvas(img *image.RGBA) Canvas {
+	return ggCanvas{gg.NewContextForRGBA(img)}
+}
+
+// ggCanvas adapts a gg Context to a Canvas, gg has its own Pattern type.
+type ggCanvas struct {
+	*gg.Context
+}
+
+// SetFillStyle sets the pattern paths are filled with.
+func (c ggCanvas) SetFillStyle(p Pattern) { c.Context.SetFillStyle(p) }
+
+// SetStrokeStyle sets the pattern paths are stroked with.
+func (c ggCanvas) SetStrokeStyle(p Pattern) { c.Context.SetStrokeStyle(p) }
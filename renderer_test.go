@@ -0,0 +1,64 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"sync/atomic"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+// countingRenderer counts the canvases made by another renderer.
+type countingRenderer struct {
+	mimage.Renderer
+	made int32
+}
+
+func (c *countingRenderer) NewCanvas(img *image.RGBA) mimage.Canvas {
+	atomic.AddInt32(&c.made, 1)
+	return c.Renderer.NewCanvas(img)
+}
+
+func TestRenderWith(t *testing.T) {
+	dir := t.TempDir()
+	renderer := &countingRenderer{Renderer: mimage.AcceleratedRenderer(nil)}
+	m, err := mimage.New(image.Rect(0, 0, 64, 64), mimage.Directory(dir), mimage.ChunkSize(32), mimage.RenderWith(renderer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := color.RGBA{255, 0, 0, 255}
+	op := m.Draw()
+	op.SetColor(red)
+	op.DrawRectangle(0, 0, 64, 20)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.At(40, 10); got != red {
+		t.Errorf("pixel is %v, want %v", got, red)
+	}
+	if n := atomic.LoadInt32(&renderer.made); n != 2 {
+		t.Errorf("renderer made %d canvases, want one for each of 2 chunks", n)
+	}
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// renderers aren't saved, so are set again after loading
+	loaded, err := mimage.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	again := &countingRenderer{Renderer: mimage.AcceleratedRenderer(nil)}
+	loaded.SetRenderer(again)
+	if got := loaded.At(10, 10); got != red {
+		t.Errorf("reloaded pixel is %v, want %v", got, red)
+	}
+	if n := atomic.LoadInt32(&again.made); n != 1 {
+		t.Errorf("renderer set after loading made %d canvases, want 1", n)
+	}
+}
@@ -0,0 +1,39 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestRepeat(t *testing.T) {
+	// away from the origin, as the planned area must be too
+	m := newImage(t, image.Rect(100, 100, 228, 228), mimage.ChunkSize(32))
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+
+	op := m.Draw()
+	op.SetColor(red)
+	op.DrawRectangle(104, 104, 8, 8)
+	op.Fill()
+	op.SetColor(blue) // colors are reset before each repetition, so every square is red
+	op.Repeat([]image.Point{{0, 0}, {30, 30}, {90, 10}, {116, 116}})
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for p, want := range map[image.Point]color.RGBA{
+		{108, 108}: red,
+		{138, 138}: red,
+		{198, 118}: red,
+		{224, 224}: red,
+		{124, 124}: {},
+		{164, 164}: {},
+	} {
+		if got := m.At(p.X, p.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", p, got, want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package mimage
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// ResampleFilter decides how colors are sampled from between pixels when an
+// image is transformed.
+type ResampleFilter int
+
+const (
+	// FilterNearest takes the nearest pixel, fast but blocky.
+	FilterNearest ResampleFilter = iota
+
+	// FilterBilinear interpolates between the nearest 2x2 pixels.
+	FilterBilinear
+
+	// FilterBicubic interpolates (Catmull-Rom) between the nearest 4x4 pixels,
+	// giving the sharpest results.
+	FilterBicubic
+)
+
+// samplerMaxHeld is the most chunks a sampler keeps loaded at a time.
+const samplerMaxHeld = 16
+
+// sampler reads pixels from anywhere in an Mimage, loading chunks as they're
+// needed. Chunks stay loaded (to save re-loading them for nearby samples)
+// until release() is called, or too many are held.
+type sampler struct {
+	m    *Mimage
+	held map[[2]int]*context
+	err  error
+}
+
+// newSampler returns a sampler over the given Mimage.
+func newSampler(m *Mimage) *sampler {
+	return &sampler{m: m, held: map[[2]int]*context{}}
+}
+
+// release all held chunks.
+func (s *sampler) release() {
+	for k, ctx := range s.held {
+		ctx.Done()
+		delete(s.held, k)
+	}
+}
+
+// pixel returns the premultiplied r,g,b,a values (0-255) at (x,y) in world space.
+// Pixels outside of the image are read according to the edge mode.
+func (s *sampler) pixel(x, y int) [4]float64 {
+	x, y, ok := s.m.edgePoint(x, y)
+	if !ok {
+		return [4]float64{}
+	}
+
+	cx, cy := floorDiv(x, s.m.chunkSize), floorDiv(y, s.m.chunkSize)
+	ctx, ok := s.held[[2]int{cx, cy}]
+	if !ok {
+		if len(s.held) >= samplerMaxHeld {
+			s.release()
+		}
+		var err error
+		ctx, err = s.m.cache.Load(cx, cy)
+		if err != nil {
+			ctx.Done()
+			s.err = err
+			return [4]float64{}
+		}
+		s.held[[2]int{cx, cy}] = ctx
+	}
+
+	img := ctx.Img.Image().(*image.RGBA)
+	i := img.PixOffset(x-cx*s.m.chunkSize, y-cy*s.m.chunkSize)
+	return [4]float64{float64(img.Pix[i]), float64(img.Pix[i+1]), float64(img.Pix[i+2]), float64(img.Pix[i+3])}
+}
+
+// catmullRom returns the Catmull-Rom cubic weight for distance t.
+func catmullRom(t float64) float64 {
+	t = math.Abs(t)
+	if t < 1 {
+		return 1.5*t*t*t - 2.5*t*t + 1
+	} else if t < 2 {
+		return -0.5*t*t*t + 2.5*t*t - 4*t + 2
+	}
+	return 0
+}
+
+// sample returns the color at the (continuous) point (fx,fy) in world space,
+// where pixel (x,y) covers the area from (x,y) to (x+1,y+1).
+func (s *sampler) sample(fx, fy float64, filter ResampleFilter) color.RGBA {
+	var c [4]float64
+
+	switch filter {
+	case FilterBilinear:
+		fx, fy = fx-0.5, fy-0.5
+		x0, y0 := math.Floor(fx), math.Floor(fy)
+		tx, ty := fx-x0, fy-y0
+		for j := 0; j < 2; j++ {
+			for i := 0; i < 2; i++ {
+				w := math.Abs(1-float64(i)-tx) * math.Abs(1-float64(j)-ty)
+				if w == 0 {
+					continue
+				}
+				p := s.pixel(int(x0)+i, int(y0)+j)
+				for k := range c {
+					c[k] += p[k] * w
+				}
+			}
+		}
+	case FilterBicubic:
+		fx, fy = fx-0.5, fy-0.5
+		x0, y0 := math.Floor(fx), math.Floor(fy)
+		for j := -1; j < 3; j++ {
+			wy := catmullRom(fy - (y0 + float64(j)))
+			for i := -1; i < 3; i++ {
+				w := wy * catmullRom(fx-(x0+float64(i)))
+				if w == 0 {
+					continue
+				}
+				p := s.pixel(int(x0)+i, int(y0)+j)
+				for k := range c {
+					c[k] += p[k] * w
+				}
+			}
+		}
+	default:
+		c = s.pixel(int(math.Floor(fx)), int(math.Floor(fy)))
+	}
+
+	a := math.Max(0, math.Min(255, c[3]))
+	clamp := func(v float64) uint8 { return uint8(math.Round(math.Max(0, math.Min(a, v)))) }
+	return color.RGBA{clamp(c[0]), clamp(c[1]), clamp(c[2]), uint8(math.Round(a))}
+}
@@ -0,0 +1,74 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestRotateArbitrary(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 100, 100), mimage.ChunkSize(32))
+	red := color.RGBA{255, 0, 0, 255}
+	op := m.Draw()
+	op.SetColor(red)
+	op.DrawRectangle(10, 10, 20, 10)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a quarter turn clockwise moves the top left to the top right
+	for _, filter := range []mimage.ResampleFilter{mimage.FilterNearest, mimage.FilterBilinear, mimage.FilterBicubic} {
+		rotated, err := m.RotateArbitrary(math.Pi/2, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rotated.Close()
+		if b := rotated.Bounds(); b != image.Rect(0, 0, 100, 100) {
+			t.Errorf("filter %d: rotated bounds are %v", filter, b)
+		}
+		if got := rotated.At(85, 20); got != red {
+			t.Errorf("filter %d: rotated pixel is %v, want %v", filter, got, red)
+		}
+		if got := rotated.At(15, 20); got != (color.RGBA{}) {
+			t.Errorf("filter %d: pixel rotated away is %v, want it empty", filter, got)
+		}
+	}
+}
+
+func TestRotateArbitraryNegativeBounds(t *testing.T) {
+	m := newImage(t, image.Rect(-100, -100, 100, 100), mimage.ChunkSize(32))
+	red := color.RGBA{255, 0, 0, 255}
+	op := m.Draw()
+	op.SetColor(red)
+	op.DrawRectangle(10, 10, 20, 20)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sampling either side of the origin
+	rotated, err := m.RotateArbitrary(0.3, mimage.FilterBilinear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rotated.Close()
+	if b := rotated.Bounds(); b.Dx() < 200 || b.Dy() < 200 {
+		t.Errorf("rotated bounds are %v", b)
+	}
+
+	// the new image starts at the origin
+	rotated, err = m.RotateArbitrary(0, mimage.FilterBilinear)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rotated.Close()
+	if got := rotated.At(120, 120); got != red {
+		t.Errorf("pixel moved to (120,120) is %v, want %v", got, red)
+	}
+}
@@ -0,0 +1,173 @@
+package mimage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"io"
+	"math"
+	"strings"
+)
+
+// metresPerInch is used to convert to the pixels-per-metre PNG wants.
+const metresPerInch = 0.0254
+
+// SetDPI sets the physical resolution of the image in dots (pixels) per
+// inch. This is saved with the image and written into exported PNG, JPEG,
+// TIFF and PDF files so they print at the right size. A value of 0 unsets
+// it.
+func (m *Mimage) SetDPI(dpi float64) error {
+	if dpi < 0 || math.IsNaN(dpi) || math.IsInf(dpi, 0) {
+		return fmt.Errorf("invalid dpi %v", dpi)
+	}
+
+	m.metaLock.Lock()
+	defer m.metaLock.Unlock()
+
+	m.dpi = dpi
+	return m.writeMetadata()
+}
+
+// DPI returns the physical resolution of the image in dots (pixels) per
+// inch, or 0 if it hasn't been set.
+func (m *Mimage) DPI() float64 {
+	m.metaLock.Lock()
+	defer m.metaLock.Unlock()
+	return m.dpi
+}
+
+// insertWriter passes writes through to w, inserting some extra bytes once
+// the first 'after' bytes have been written.
+type insertWriter struct {
+	w      io.Writer
+	after  int
+	insert []byte
+	n      int
+}
+
+// Write writes p, inserting our bytes part way through if needed.
+func (i *insertWriter) Write(p []byte) (int, error) {
+	if i.insert == nil || i.n+len(p) < i.after {
+		i.n += len(p)
+		return i.w.Write(p)
+	}
+
+	head := i.after - i.n
+	n, err := i.w.Write(p[:head])
+	if err != nil {
+		return n, err
+	}
+	_, err = i.w.Write(i.insert)
+	if err != nil {
+		return n, err
+	}
+	i.insert = nil
+
+	m, err := i.w.Write(p[head:])
+	i.n += len(p)
+	return n + m, err
+}
+
+// pngPhys returns a PNG pHYs chunk for the given dpi. It belongs right after
+// the IHDR chunk, which is always the first 33 bytes of the file (including
+// the signature).
+func pngPhys(dpi float64) []byte {
+	ppm := uint32(math.Round(dpi / metresPerInch))
+
+	chunk := make([]byte, 4+4+9+4)
+	binary.BigEndian.PutUint32(chunk, 9)
+	copy(chunk[4:], "pHYs")
+	binary.BigEndian.PutUint32(chunk[8:], ppm)
+	binary.BigEndian.PutUint32(chunk[12:], ppm)
+	chunk[16] = 1 // unit: metre
+	binary.BigEndian.PutUint32(chunk[17:], crc32.ChecksumIEEE(chunk[4:17]))
+
+	return chunk
+}
+
+// jpegJFIF returns a JFIF APP0 segment for the given dpi. Go's encoder doesn't
+// write one, so it goes straight after the SOI marker (the first 2 bytes).
+func jpegJFIF(dpi float64) []byte {
+	density := uint16(math.Min(math.Round(dpi), math.MaxUint16))
+
+	seg := []byte{0xff, 0xe0, 0, 16, 'J', 'F', 'I', 'F', 0, 1, 1, 1, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(seg[12:], density)
+	binary.BigEndian.PutUint16(seg[14:], density)
+
+	return seg
+}
+
+// tiffResolution returns TIFF resolution fields for the given dpi.
+func tiffResolution(dpi float64) []tiffField {
+	// store as a rational with a few decimal places
+	num := uint32(math.Min(math.Round(dpi*1000), math.MaxUint32))
+	return []tiffField{
+		rationalField(tagXResolution, num, 1000),
+		rationalField(tagYResolution, num, 1000),
+		shortField(tagResolutionUnit, 2), // inch
+	}
+}
+
+// fileInfo is extra information we write into exported files.
+type fileInfo struct {
+	dpi float64
+	icc []byte
+	geo *GeoReference // of the top left of the file
+}
+
+// tiffFields returns the TIFF fields holding the information.
+func (info fileInfo) tiffFields() []tiffField {
+	fields := []tiffField{}
+	if info.dpi > 0 {
+		fields = append(fields, tiffResolution(info.dpi)...)
+	}
+	if info.icc != nil {
+		fields = append(fields, tiffICC(info.icc))
+	}
+	if info.geo != nil {
+		fields = append(fields, geoTIFFFields(info.geo)...)
+	}
+	return fields
+}
+
+// encodeWithInfo encodes img with enc, writing the given resolution, color
+// profile & georeference into the file where we know how to. Our TIFF formats are written
+// with the relevant tags, otherwise PNG and JPEG files (judged by the
+// encoder's extension) have chunks / segments spliced in.
+func encodeWithInfo(w io.Writer, img image.Image, enc Encoder, info fileInfo) error {
+	if info.dpi <= 0 && info.icc == nil && info.geo == nil {
+		return enc.Encode(w, img)
+	}
+
+	switch enc {
+	case FormatTIFF, FormatTIFFFloat:
+		fields := info.tiffFields()
+		if enc == FormatTIFF {
+			return encodeTIFF(w, img, fields...)
+		}
+		return encodeFloatTIFF(w, img, fields...)
+	}
+
+	insert := []byte{}
+	switch strings.ToLower(enc.Extension()) {
+	case "png":
+		if info.dpi > 0 {
+			insert = append(insert, pngPhys(info.dpi)...)
+		}
+		if info.icc != nil {
+			insert = append(insert, pngICCP(info.icc)...)
+		}
+		return enc.Encode(&insertWriter{w: w, after: 33, insert: insert}, img)
+	case "jpg", "jpeg":
+		if info.dpi > 0 {
+			insert = append(insert, jpegJFIF(info.dpi)...)
+		}
+		if info.icc != nil {
+			insert = append(insert, jpegICC(info.icc)...)
+		}
+		return enc.Encode(&insertWriter{w: w, after: 2, insert: insert}, img)
+	}
+
+	return enc.Encode(w, img)
+}
@@ -0,0 +1,102 @@
+package mimage_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/voidshard/mimage"
+	"golang.org/x/image/tiff"
+)
+
+func TestSetDPI(t *testing.T) {
+	dir := t.TempDir()
+	m, err := mimage.New(image.Rect(0, 0, 64, 64), mimage.Directory(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dpi := range []float64{-1, math.Inf(1), math.NaN()} {
+		if err := m.SetDPI(dpi); err == nil {
+			t.Errorf("setting dpi %v got no error", dpi)
+		}
+	}
+	err = m.SetDPI(254)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := mimage.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	if loaded.DPI() != 254 {
+		t.Errorf("reloaded dpi is %v, want 254", loaded.DPI())
+	}
+}
+
+func TestEncodeDPI(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64))
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.Clear()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.SetDPI(254)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encode := func(f mimage.Format) []byte {
+		buf := &bytes.Buffer{}
+		err := m.EncodeRegion(m.Bounds(), buf, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	// png: a pHYs chunk of 10000 pixels per metre, files still decode
+	data := encode(mimage.FormatPNG)
+	at := bytes.Index(data, []byte("pHYs"))
+	if at < 0 || binary.BigEndian.Uint32(data[at+4:]) != 10000 || data[at+12] != 1 {
+		t.Error("png has no pHYs chunk of 10000 pixels per metre")
+	}
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Errorf("png with dpi doesn't decode: %v", err)
+	}
+
+	// jpeg: a JFIF segment in dots per inch
+	data = encode(mimage.FormatJPEG)
+	if !bytes.HasPrefix(data[2:], []byte{0xff, 0xe0, 0, 16, 'J', 'F', 'I', 'F', 0}) || data[13] != 1 || binary.BigEndian.Uint16(data[14:]) != 254 {
+		t.Error("jpeg doesn't start with a JFIF segment of 254 dpi")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+		t.Errorf("jpeg with dpi doesn't decode: %v", err)
+	}
+
+	// tiff: resolution tags
+	data = encode(mimage.FormatTIFF)
+	tags := tiffTags(t, data)
+	if at := tags[282]; at == 0 || binary.LittleEndian.Uint32(data[at:])/binary.LittleEndian.Uint32(data[at+4:]) != 254 || tags[296] != 2 {
+		t.Error("tiff has no x resolution of 254 dpi")
+	}
+	img, err := tiff.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("tiff with dpi doesn't decode: %v", err)
+	}
+	if got := color.RGBAModel.Convert(img.At(10, 10)); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("decoded tiff pixel is %v, want white", got)
+	}
+}
@@ -0,0 +1,123 @@
+package mimage
+
+import (
+	"image"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// interpolator returns the x/image interpolator matching the filter.
+func (f ResampleFilter) interpolator() xdraw.Interpolator {
+	switch f {
+	case FilterBilinear:
+		return xdraw.BiLinear
+	case FilterBicubic:
+		return xdraw.CatmullRom
+	}
+	return xdraw.NearestNeighbor
+}
+
+// DrawImageScaled draws the image i stretched to fill dst (in world space),
+// sampled with the given filter.
+func (o *operation) DrawImageScaled(i image.Image, dst image.Rectangle, filter ResampleFilter) {
+	o.drawImageScaled(i, i.Bounds(), dst, filter)
+}
+
+// DrawNineSlice draws the image i stretched to fill dst (in world space)
+// without distorting its edges, eg. for frames, panels & map legends. The
+// center rectangle (in the image's own space) is the part of i that is
+// stretched both ways, the corners outside of it are drawn at their own
+// size & the edges between them are stretched along their length only.
+//
+// Corners are shrunk to fit if dst is too small for them.
+func (o *operation) DrawNineSlice(i image.Image, center image.Rectangle, dst image.Rectangle, filter ResampleFilter) {
+	src := i.Bounds()
+	center = center.Intersect(src)
+
+	// the lines between slices across & down, in source & destination
+	sx := [4]int{src.Min.X, center.Min.X, center.Max.X, src.Max.X}
+	sy := [4]int{src.Min.Y, center.Min.Y, center.Max.Y, src.Max.Y}
+	dx := nineSliceLines(sx, dst.Min.X, dst.Max.X)
+	dy := nineSliceLines(sy, dst.Min.Y, dst.Max.Y)
+
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			sr := image.Rect(sx[col], sy[row], sx[col+1], sy[row+1])
+			dr := image.Rect(dx[col], dy[row], dx[col+1], dy[row+1])
+			if sr.Empty() || dr.Empty() {
+				continue
+			}
+			o.drawImageScaled(i, sr, dr, filter)
+		}
+	}
+}
+
+// nineSliceLines returns where the source lines between slices s land
+// between min & max, keeping the outer slices at their own size if they fit
+// or shrinking them evenly if not.
+func nineSliceLines(s [4]int, min, max int) [4]int {
+	a, b := s[1]-s[0], s[3]-s[2]
+	if size := max - min; a+b > size {
+		a = a * size / (a + b)
+		b = size - a
+	}
+	return [4]int{min, min + a, max - b, max}
+}
+
+// drawImageScaled queues drawing the area sr of i stretched over dst.
+func (o *operation) drawImageScaled(i image.Image, sr, dst image.Rectangle, filter ResampleFilter) {
+	if sr.Empty() || dst.Empty() {
+		return
+	}
+	o.minMax(float64(dst.Min.X), float64(dst.Min.Y))
+	o.minMax(float64(dst.Max.X), float64(dst.Max.Y))
+	o.queue.pushArgs(drawImageScaled, []interface{}{i, sr, dst, filter})
+}
+
+// drawScaled draws the area sr of src stretched over dst (in chunk space,
+// before the current transform) onto the chunk.
+func drawScaled(ctx *context, src image.Image, sr, dst image.Rectangle, filter ResampleFilter, mask *image.Alpha) {
+	// source -> destination, then the context's own transform
+	t := ScaleMatrix(float64(dst.Dx())/float64(sr.Dx()), float64(dst.Dy())/float64(sr.Dy()))
+	t = TranslateMatrix(float64(dst.Min.X), float64(dst.Min.Y)).Multiply(t).Multiply(TranslateMatrix(-float64(sr.Min.X), -float64(sr.Min.Y)))
+	aff := contextMatrix(ctx.Img).Multiply(t).aff3()
+	filter.interpolator().Transform(ctx.Img.Image().(*image.RGBA), aff, src, sr, xdraw.Over, maskOptions(mask))
+}
+
+// maskOptions returns the options to draw through the mask (if any).
+func maskOptions(mask *image.Alpha) *xdraw.Options {
+	if mask == nil {
+		return nil
+	}
+	return &xdraw.Options{DstMask: mask}
+}
+
+// DrawImageTransformed draws the image i placed by the affine transform t,
+// which maps points of i (in its own space) to world space, eg. to draw a
+// symbol rotated by a about its center at (x,y)
+//
+//	size := i.Bounds().Size()
+//	t := TranslateMatrix(x, y).Multiply(RotateMatrix(a)).Multiply(TranslateMatrix(-float64(size.X)/2, -float64(size.Y)/2))
+//
+// Sampled bilinearly as DrawImage is. Only the affine part of t is used, the
+// bottom row is taken to be 0, 0, 1.
+func (o *operation) DrawImageTransformed(i image.Image, t Matrix3) {
+	t[6], t[7], t[8] = 0, 0, 1
+
+	// every chunk the transformed image lands on, with a pixel to spare for
+	// the filter
+	b := i.Bounds()
+	for _, p := range []image.Point{b.Min, {b.Max.X, b.Min.Y}, {b.Min.X, b.Max.Y}, b.Max} {
+		x, y, _ := t.Apply(float64(p.X), float64(p.Y))
+		o.minMax(x-1, y-1)
+		o.minMax(x+1, y+1)
+	}
+	o.queue.pushArgs(drawImageTransformed, []interface{}{i, t})
+}
+
+// drawTransformed draws src placed by t (from image to chunk space, before
+// the current transform) onto the chunk.
+func drawTransformed(ctx *context, src image.Image, t Matrix3, mask *image.Alpha) {
+	aff := contextMatrix(ctx.Img).Multiply(t).aff3()
+	xdraw.BiLinear.Transform(ctx.Img.Image().(*image.RGBA), aff, src, src.Bounds(), xdraw.Over, maskOptions(mask))
+}
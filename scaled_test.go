@@ -0,0 +1,88 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestDrawImageScaled(t *testing.T) {
+	// a 2x2 checker, stretched over the chunk boundary
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	src.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	src.Set(1, 1, color.RGBA{255, 0, 0, 255})
+
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.DrawImageScaled(src, image.Rect(16, 16, 48, 48), mimage.FilterNearest)
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	red := color.RGBA{255, 0, 0, 255}
+	for pt, want := range map[image.Point]color.RGBA{
+		{16, 16}: red, {31, 31}: red, {32, 32}: red, {47, 47}: red,
+		{32, 16}: {}, {16, 47}: {}, {15, 15}: {}, {48, 48}: {},
+	} {
+		if got := m.At(pt.X, pt.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", pt, got, want)
+		}
+	}
+}
+
+func TestDrawNineSlice(t *testing.T) {
+	blue, green := color.RGBA{0, 0, 255, 255}, color.RGBA{0, 255, 0, 255}
+	// a 12x12 frame with a 4 pixel border
+	src := image.NewRGBA(image.Rect(0, 0, 12, 12))
+	draw.Draw(src, src.Bounds(), image.NewUniform(blue), image.Point{}, draw.Src)
+	draw.Draw(src, image.Rect(4, 4, 8, 8), image.NewUniform(green), image.Point{}, draw.Src)
+
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.DrawNineSlice(src, image.Rect(4, 4, 8, 8), image.Rect(2, 2, 62, 62), mimage.FilterNearest)
+	// corners shrink to fit a small destination
+	op.DrawNineSlice(src, image.Rect(4, 4, 8, 8), image.Rect(0, 0, 4, 4), mimage.FilterNearest)
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for pt, want := range map[image.Point]color.RGBA{
+		{5, 5}: blue, {6, 6}: green, {30, 5}: blue, {5, 40}: blue,
+		{32, 32}: green, {55, 55}: green, {58, 58}: blue, {61, 33}: blue,
+		{62, 62}: {}, {1, 1}: blue, {3, 3}: blue,
+	} {
+		if got := m.At(pt.X, pt.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", pt, got, want)
+		}
+	}
+}
+
+func TestDrawImageTransformed(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	src := solid(image.Rect(0, 0, 16, 8), red)
+
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	op := m.Draw()
+	// turned on end about its center, landing on all four chunks
+	tr := mimage.TranslateMatrix(32, 32).Multiply(mimage.RotateMatrix(math.Pi / 2)).Multiply(mimage.TranslateMatrix(-8, -4))
+	op.DrawImageTransformed(src, tr)
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for pt, want := range map[image.Point]color.RGBA{
+		{29, 25}: red, {34, 38}: red, {32, 32}: red, {31, 31}: red,
+		{26, 32}: {}, {38, 32}: {}, {32, 22}: {}, {32, 42}: {},
+	} {
+		if got := m.At(pt.X, pt.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", pt, got, want)
+		}
+	}
+}
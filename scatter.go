@@ -0,0 +1,92 @@
+package mimage
+
+import (
+	"image"
+	"math"
+	"math/rand"
+)
+
+// scatterCell is the size (in pixels) of the cells a scatter region is broken
+// into. Each cell decides its own stamps from a seed derived from the cell
+// (x,y) so that any chunk can work out which stamps land on it without
+// needing to know about any other chunk.
+const scatterCell = 256
+
+// scatter is a queued Scatter call.
+type scatter struct {
+	img     image.Image
+	region  Path
+	density float64
+	seed    int64
+}
+
+// cellStamps returns the stamps that land in the given scatter cell.
+func (s *scatter) cellStamps(cx, cy int) []stamp {
+	rng := rand.New(rand.NewSource(s.seed ^ int64(cx)*73856093 ^ int64(cy)*19349663))
+
+	expected := s.density * scatterCell * scatterCell
+	count := int(expected)
+	if rng.Float64() < expected-float64(count) {
+		count++
+	}
+
+	stamps := []stamp{}
+	for i := 0; i < count; i++ {
+		x := float64(cx*scatterCell) + rng.Float64()*scatterCell
+		y := float64(cy*scatterCell) + rng.Float64()*scatterCell
+		if !s.region.contains(x, y) {
+			continue
+		}
+		stamps = append(stamps, stamp{X: x, Y: y, Scale: 1, Alpha: 1})
+	}
+
+	return stamps
+}
+
+// render draws all scattered stamps that intersect the context, where the
+// context origin is at (offX, offY) in world space. Returns if anything was drawn.
+func (s *scatter) render(dc Canvas, offX, offY float64) bool {
+	size := s.img.Bounds().Size()
+	radius := math.Hypot(float64(size.X), float64(size.Y)) / 2
+
+	minX, minY, maxX, maxY := s.region.bounds()
+	minX = math.Max(minX, offX-radius)
+	minY = math.Max(minY, offY-radius)
+	maxX = math.Min(maxX, offX+float64(dc.Width())+radius)
+	maxY = math.Min(maxY, offY+float64(dc.Height())+radius)
+	if minX > maxX || minY > maxY {
+		return false
+	}
+
+	drawn := false
+	for cx := int(math.Floor(minX / scatterCell)); cx <= int(math.Floor(maxX/scatterCell)); cx++ {
+		for cy := int(math.Floor(minY / scatterCell)); cy <= int(math.Floor(maxY/scatterCell)); cy++ {
+			if renderStamps(dc, s.img, s.cellStamps(cx, cy), offX, offY) {
+				drawn = true
+			}
+		}
+	}
+
+	return drawn
+}
+
+// Scatter places copies of img (centered) at random points within the polygon
+// region. Density is the average number of stamps per pixel of area, so 0.001
+// would be about one stamp per 1000 pixels.
+//
+// Placement is random but decided only by the seed, so the same call always
+// produces the same result.
+func (o *operation) Scatter(img image.Image, region Path, density float64, seed int64) {
+	if len(region) < 3 || density <= 0 {
+		return
+	}
+
+	size := img.Bounds().Size()
+	radius := math.Hypot(float64(size.X), float64(size.Y)) / 2
+	minX, minY, maxX, maxY := region.bounds()
+	o.minMax(minX-radius, minY-radius)
+	o.minMax(maxX+radius, maxY+radius)
+
+	s := &scatter{img: img, region: append(Path{}, region...), density: density, seed: o.seeded(seed)}
+	o.queue.pushArgs(scatterStamps, []interface{}{s})
+}
@@ -0,0 +1,56 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+// scattered returns the pixels drawn by scattering a 1px stamp within region.
+func scattered(t *testing.T, chunkSize int, region mimage.Path, seed int64) map[image.Point]bool {
+	m := newImage(t, image.Rect(0, 0, 100, 100), mimage.ChunkSize(chunkSize))
+	op := m.Draw()
+	op.Scatter(solid(image.Rect(0, 0, 1, 1), color.RGBA{255, 0, 0, 255}), region, 0.01, seed)
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drawn := map[image.Point]bool{}
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			if m.At(x, y) != (color.RGBA{}) {
+				drawn[image.Pt(x, y)] = true
+			}
+		}
+	}
+	return drawn
+}
+
+func TestScatter(t *testing.T) {
+	region := mimage.Path{{X: 20, Y: 20}, {X: 80, Y: 20}, {X: 80, Y: 80}, {X: 20, Y: 80}}
+	drawn := scattered(t, 32, region, 1)
+
+	// about one stamp per 100 pixels of the region
+	if len(drawn) < 15 || len(drawn) > 60 {
+		t.Errorf("scattered %d stamps over 3600 pixels at a density of 0.01", len(drawn))
+	}
+	for p := range drawn {
+		if p.X < 19 || p.X > 80 || p.Y < 19 || p.Y > 80 {
+			t.Errorf("stamp at %v is outside the region", p)
+		}
+	}
+
+	// decided by the seed alone, not by how the image is chunked
+	if again := scattered(t, 100, region, 1); len(again) != len(drawn) {
+		t.Errorf("scattered %d stamps in one chunk, %d in many", len(again), len(drawn))
+	} else {
+		for p := range drawn {
+			if !again[p] {
+				t.Errorf("stamp at %v wasn't scattered in one chunk", p)
+			}
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package mimage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const defaultScheduleConcurrency = 1
+
+// Priority orders operations waiting to be run by Schedule, higher first.
+type Priority int
+
+const (
+	// PriorityBatch is for long running work (eg. fills) that can wait
+	PriorityBatch Priority = iota
+	PriorityNormal
+	// PriorityInteractive is for edits someone is waiting to see
+	PriorityInteractive
+)
+
+// JobState is where an operation given to Schedule is up to.
+type JobState int
+
+const (
+	JobQueued JobState = iota
+	JobRunning
+	JobDone
+	JobFailed
+	JobCancelled
+)
+
+// ScheduleConcurrency sets how many operations given to Schedule may run at
+// once (by default one). Operations only ever run at the same time if they
+// change different chunks.
+func ScheduleConcurrency(i int) Option {
+	return func(m *Mimage) error {
+		if i <= 0 {
+			i = 1
+		}
+		m.scheduleConcurrency = i
+		return nil
+	}
+}
+
+// Job is an operation given to Schedule.
+type Job struct {
+	op       *operation
+	priority Priority
+	seq      uint64
+	chunks   map[[2]int]bool
+
+	lock     *sync.Mutex
+	state    JobState
+	err      error
+	done     chan struct{}
+	finished int // chunks finished by Do so far
+}
+
+// Schedule queues the operation to be done (as by Do) in the background,
+// returning the job tracking it. Queued operations run highest priority first
+// & in the order they were given otherwise, except that an operation that
+// changes none of the chunks of those ahead of it may run alongside them (see
+// ScheduleConcurrency). Operations that change the same chunks always run
+// one after the other, so interactive edits are never mixed up with a batch
+// fill of the same area.
+//
+// The operation shouldn't be added to until the job is finished. Operations
+// that are never given to Schedule (ie. Do is called directly) aren't
+// coordinated with those that are.
+func (m *Mimage) Schedule(op Operation, p Priority) *Job {
+	o, ok := op.(*operation)
+	if !ok || o.parent != m {
+		j := &Job{lock: &sync.Mutex{}, state: JobFailed, done: make(chan struct{})}
+		j.err = fmt.Errorf("only operations on this image can be scheduled")
+		close(j.done)
+		return j
+	}
+
+	work, _ := o.plan()
+	j := &Job{
+		op:       o,
+		priority: p,
+		chunks:   make(map[[2]int]bool, len(work)),
+		lock:     &sync.Mutex{},
+		state:    JobQueued,
+		done:     make(chan struct{}),
+	}
+	for _, w := range work {
+		j.chunks[[2]int{w.x, w.y}] = true
+	}
+	m.scheduler().submit(j)
+	return j
+}
+
+// Jobs returns the scheduled operations that are running or queued, in the
+// order they'd be started.
+func (m *Mimage) Jobs() []*Job {
+	s := m.scheduler()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	jobs := make([]*Job, 0, len(s.running)+len(s.queued))
+	for j := range s.running {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].seq < jobs[b].seq })
+	return append(jobs, s.queued...)
+}
+
+// Priority returns the priority the job was scheduled with.
+func (j *Job) Priority() Priority { return j.priority }
+
+// State returns where the job is up to.
+func (j *Job) State() JobState {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.state
+}
+
+// Progress returns how many of the chunks the operation changes are finished
+// & how many there are in all.
+func (j *Job) Progress() (int, int) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.finished, len(j.chunks)
+}
+
+// Done returns a channel that's closed when the job has finished, failed or
+// been cancelled.
+func (j *Job) Done() <-chan struct{} { return j.done }
+
+// Wait waits for the job to finish, returning the error from Do (if any).
+func (j *Job) Wait() error {
+	<-j.done
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.err
+}
+
+// Cancel removes the job from the queue if it hasn't started, returning if it
+// was cancelled. Running jobs can't be cancelled.
+func (j *Job) Cancel() bool {
+	if j.op == nil {
+		return false
+	}
+	s := j.op.parent.scheduler()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i, q := range s.queued {
+		if q != j {
+			continue
+		}
+		s.queued = append(s.queued[:i], s.queued[i+1:]...)
+		j.finish(JobCancelled, fmt.Errorf("operation cancelled"))
+		s.dispatch() // jobs waiting on this one may now run
+		return true
+	}
+	return false
+}
+
+// finish sets the final state of the job.
+func (j *Job) finish(state JobState, err error) {
+	j.lock.Lock()
+	j.state = state
+	j.err = err
+	j.lock.Unlock()
+	close(j.done)
+}
+
+// run does the operation, counting chunks as they're finished.
+func (j *Job) run() error {
+	user := j.op.onChunkDone
+	defer func() { j.op.onChunkDone = user }()
+
+	j.op.onChunkDone = func(cx, cy int, err error) {
+		j.lock.Lock()
+		j.finished++
+		j.lock.Unlock()
+		if user != nil {
+			user(cx, cy, err)
+		}
+	}
+	return j.op.Do()
+}
+
+// scheduler runs jobs given to Schedule.
+type scheduler struct {
+	lock        *sync.Mutex
+	concurrency int
+	seq         uint64
+	queued      []*Job
+	running     map[*Job]bool
+}
+
+// scheduler returns the image's scheduler, making it the first time.
+func (m *Mimage) scheduler() *scheduler {
+	m.metaLock.Lock()
+	defer m.metaLock.Unlock()
+
+	if m.sched == nil {
+		concurrency := m.scheduleConcurrency
+		if concurrency <= 0 {
+			concurrency = defaultScheduleConcurrency
+		}
+		m.sched = &scheduler{
+			lock:        &sync.Mutex{},
+			concurrency: concurrency,
+			running:     map[*Job]bool{},
+		}
+	}
+	return m.sched
+}
+
+// submit queues a job & starts whatever can be started.
+func (s *scheduler) submit(j *Job) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.seq++
+	j.seq = s.seq
+	s.queued = append(s.queued, j)
+	s.dispatch()
+}
+
+// dispatch starts queued jobs, by priority, while there's room for them &
+// they don't change the chunks of jobs running or ahead of them in the queue.
+// The caller is expected to hold the lock.
+func (s *scheduler) dispatch() {
+	sort.SliceStable(s.queued, func(a, b int) bool {
+		if s.queued[a].priority != s.queued[b].priority {
+			return s.queued[a].priority > s.queued[b].priority
+		}
+		return s.queued[a].seq < s.queued[b].seq
+	})
+
+	claimed := map[[2]int]bool{}
+	for j := range s.running {
+		for c := range j.chunks {
+			claimed[c] = true
+		}
+	}
+
+	waiting := []*Job{}
+	for _, j := range s.queued {
+		free := len(s.running) < s.concurrency
+		for c := range j.chunks {
+			if claimed[c] {
+				free = false
+			}
+			claimed[c] = true
+		}
+		if !free {
+			waiting = append(waiting, j)
+			continue
+		}
+		s.start(j)
+	}
+	s.queued = waiting
+}
+
+// start runs the job in the background. The caller is expected to hold the
+// lock.
+func (s *scheduler) start(j *Job) {
+	s.running[j] = true
+	j.lock.Lock()
+	j.state = JobRunning
+	j.lock.Unlock()
+
+	go func() {
+		err := j.run()
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		delete(s.running, j)
+		if err != nil {
+			j.finish(JobFailed, err)
+		} else {
+			j.finish(JobDone, nil)
+		}
+		s.dispatch()
+	}()
+}
@@ -0,0 +1,97 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestSchedule(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	fill := func(c color.Color) mimage.Operation {
+		op := m.Draw()
+		op.SetColor(c)
+		op.DrawRectangle(0, 0, 32, 32)
+		op.Fill()
+		return op
+	}
+
+	// the first job holds up the rest until released
+	release := make(chan struct{})
+	once := &sync.Once{}
+	first := fill(color.RGBA{255, 0, 0, 255})
+	first.OnChunkDone(func(cx, cy int, err error) { once.Do(func() { <-release }) })
+	running := m.Schedule(first, mimage.PriorityNormal)
+
+	blue, green := color.RGBA{0, 0, 255, 255}, color.RGBA{0, 255, 0, 255}
+	batch := m.Schedule(fill(blue), mimage.PriorityBatch)
+	interactive := m.Schedule(fill(green), mimage.PriorityInteractive)
+	cancelled := m.Schedule(fill(blue), mimage.PriorityBatch)
+
+	jobs := m.Jobs()
+	if len(jobs) != 4 || jobs[0] != running || jobs[1] != interactive || jobs[2] != batch || jobs[3] != cancelled {
+		t.Fatalf("jobs are %v, want running, interactive then batch jobs", jobs)
+	}
+	if running.State() != mimage.JobRunning || batch.State() != mimage.JobQueued {
+		t.Errorf("states are %d & %d, want running & queued", running.State(), batch.State())
+	}
+	if !cancelled.Cancel() || cancelled.State() != mimage.JobCancelled || cancelled.Wait() == nil {
+		t.Errorf("cancelling a queued job left it %d", cancelled.State())
+	}
+	if running.Cancel() {
+		t.Error("cancelled a running job")
+	}
+
+	close(release)
+	for _, j := range []*mimage.Job{running, interactive, batch} {
+		err := j.Wait()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if done, all := batch.Progress(); done != all || all != 1 {
+		t.Errorf("batch job finished %d of %d chunks, want 1 of 1", done, all)
+	}
+	// the batch fill waited on the interactive one, so is drawn last
+	if got := m.At(10, 10); got != blue {
+		t.Errorf("pixel is %v, want %v", got, blue)
+	}
+	if len(m.Jobs()) != 0 {
+		t.Errorf("%d jobs left once all are done", len(m.Jobs()))
+	}
+
+	other := newImage(t, image.Rect(0, 0, 64, 64))
+	if err := m.Schedule(other.Draw(), mimage.PriorityNormal).Wait(); err == nil {
+		t.Error("scheduling another image's operation got no error")
+	}
+}
+
+func TestScheduleConcurrency(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32), mimage.ScheduleConcurrency(2))
+
+	// jobs on different chunks run together, the same chunk waits
+	release := make(chan struct{})
+	held := func(x int) mimage.Operation {
+		op := m.Draw()
+		op.DrawRectangle(float64(x), 0, 10, 10)
+		op.Fill()
+		op.OnChunkDone(func(cx, cy int, err error) { <-release })
+		return op
+	}
+	a := m.Schedule(held(0), mimage.PriorityNormal)
+	b := m.Schedule(held(40), mimage.PriorityNormal)
+	c := m.Schedule(held(0), mimage.PriorityInteractive)
+	if a.State() != mimage.JobRunning || b.State() != mimage.JobRunning || c.State() != mimage.JobQueued {
+		t.Errorf("states are %d, %d & %d, want the job on the same chunk as another queued", a.State(), b.State(), c.State())
+	}
+	close(release)
+	for _, j := range []*mimage.Job{a, b, c} {
+		err := j.Wait()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package mimage
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// scriptArgCounts is how many numbers each command takes, for those that take
+// only numbers
+var scriptArgCounts = map[string]int{
+	"setlinewidth": 1, "setpixel": 2, "moveto": 2, "lineto": 2, "closepath": 0,
+	"rectangle": 4, "ellipse": 4, "rotateabout": 3, "fill": 0, "stroke": 0,
+	"clear": 0, "invertmask": 0, "do": 0,
+}
+
+// ScriptOption configures how a script is applied.
+type ScriptOption func(*scriptConfig)
+
+// scriptConfig holds things scripts may refer to
+type scriptConfig struct {
+	images map[string]image.Image
+}
+
+// ScriptImage makes img available to scripts as ref, for use with the
+// drawimage command.
+func ScriptImage(ref string, img image.Image) ScriptOption {
+	return func(c *scriptConfig) {
+		c.images[ref] = img
+	}
+}
+
+// ApplyScript reads drawing commands from r and draws them onto m, so that
+// tools not written in Go can drive rendering. Commands are queued onto a
+// single operation which is run with each 'do' command & at the end of the
+// script, so long scripts needn't be held in memory.
+//
+// Each line is one command, either as words separated by spaces or as a JSON
+// object (NDJSON), so these are the same
+//
+//	lineto 10 20.5
+//	{"cmd": "lineto", "args": [10, 20.5]}
+//
+// Blank lines & lines starting with '#' are ignored. Coordinates are in pixels
+// and angles in radians. The commands are
+//
+//	setcolor #rrggbb[aa] | setcolor r g b [a]  (0-255)
+//	setlinewidth w
+//	setpixel x y
+//	moveto x y
+//	lineto x y
+//	closepath
+//	rectangle x y w h
+//	ellipse x y rx ry
+//	rotateabout angle x y
+//	fill
+//	stroke
+//	clear
+//	invertmask
+//	drawimage ref x y  (ref is given with ScriptImage)
+//	do
+//
+// Drawing state (color, path, transforms) carries on across 'do' commands.
+func ApplyScript(m *Mimage, r io.Reader, opts ...ScriptOption) error {
+	cfg := &scriptConfig{images: map[string]image.Image{}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	op := m.Draw()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		cmd, args, err := parseScriptLine(text)
+		if err == nil {
+			err = cfg.exec(op, cmd, args)
+		}
+		if err != nil {
+			return fmt.Errorf("script line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return op.Do()
+}
+
+// parseScriptLine splits a line into a command & its arguments.
+func parseScriptLine(text string) (string, []string, error) {
+	if !strings.HasPrefix(text, "{") {
+		words := strings.Fields(text)
+		return strings.ToLower(words[0]), words[1:], nil
+	}
+
+	var obj struct {
+		Cmd  string        `json:"cmd"`
+		Args []interface{} `json:"args"`
+	}
+	err := json.Unmarshal([]byte(text), &obj)
+	if err != nil {
+		return "", nil, err
+	}
+	if obj.Cmd == "" {
+		return "", nil, fmt.Errorf("command missing 'cmd'")
+	}
+
+	args := make([]string, len(obj.Args))
+	for i, a := range obj.Args {
+		switch v := a.(type) {
+		case string:
+			args[i] = v
+		case float64:
+			args[i] = strconv.FormatFloat(v, 'g', -1, 64)
+		default:
+			return "", nil, fmt.Errorf("argument %d must be a number or string", i)
+		}
+	}
+	return strings.ToLower(obj.Cmd), args, nil
+}
+
+// scriptFloats parses args as exactly n numbers.
+func scriptFloats(cmd string, args []string, n int) ([]float64, error) {
+	if len(args) != n {
+		return nil, fmt.Errorf("%s expects %d arguments, given %d", cmd, n, len(args))
+	}
+	out := make([]float64, n)
+	for i, a := range args {
+		v, err := strconv.ParseFloat(a, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s argument %d: %w", cmd, i, err)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// parseScriptColor reads a color as #rrggbb[aa] or r g b [a].
+func parseScriptColor(args []string) (color.Color, error) {
+	if len(args) == 1 {
+		s := strings.TrimPrefix(args[0], "#")
+		b, err := hex.DecodeString(s)
+		if err != nil || (len(b) != 3 && len(b) != 4) {
+			return nil, fmt.Errorf("invalid color %q", args[0])
+		}
+		c := color.NRGBA{R: b[0], G: b[1], B: b[2], A: 0xff}
+		if len(b) == 4 {
+			c.A = b[3]
+		}
+		return c, nil
+	}
+
+	if len(args) != 3 && len(args) != 4 {
+		return nil, fmt.Errorf("setcolor expects a hex color or 3-4 channels, given %d arguments", len(args))
+	}
+	v, err := scriptFloats("setcolor", args, len(args))
+	if err != nil {
+		return nil, err
+	}
+	c := color.NRGBA{A: 0xff}
+	for i, p := range []*uint8{&c.R, &c.G, &c.B, &c.A}[:len(v)] {
+		if v[i] < 0 || v[i] > 255 {
+			return nil, fmt.Errorf("color channel %v out of range 0-255", v[i])
+		}
+		*p = uint8(v[i])
+	}
+	return c, nil
+}
+
+// exec queues (or, for 'do', runs) a single command.
+func (c *scriptConfig) exec(op Operation, cmd string, args []string) error {
+	var v []float64
+	if n, ok := scriptArgCounts[cmd]; ok {
+		var err error
+		v, err = scriptFloats(cmd, args, n)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch cmd {
+	case "setcolor":
+		col, err := parseScriptColor(args)
+		if err != nil {
+			return err
+		}
+		op.SetColor(col)
+	case "setlinewidth":
+		op.SetLineWidth(v[0])
+	case "setpixel":
+		op.SetPixel(int(v[0]), int(v[1]))
+	case "moveto":
+		op.MoveTo(v[0], v[1])
+	case "lineto":
+		op.LineTo(v[0], v[1])
+	case "closepath":
+		op.ClosePath()
+	case "rectangle":
+		op.DrawRectangle(v[0], v[1], v[2], v[3])
+	case "ellipse":
+		op.DrawEllipse(v[0], v[1], v[2], v[3])
+	case "rotateabout":
+		op.RotateAbout(v[0], v[1], v[2])
+	case "fill":
+		op.Fill()
+	case "stroke":
+		op.Stroke()
+	case "clear":
+		op.Clear()
+	case "invertmask":
+		op.InvertMask()
+	case "drawimage":
+		if len(args) != 3 {
+			return fmt.Errorf("drawimage expects 3 arguments, given %d", len(args))
+		}
+		img, ok := c.images[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown image %q", args[0])
+		}
+		pt, err := scriptFloats(cmd, args[1:], 2)
+		if err != nil {
+			return err
+		}
+		op.DrawImage(img, int(pt[0]), int(pt[1]))
+	case "do":
+		return op.Do()
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestApplyScript(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 128, 128), mimage.ChunkSize(32))
+	blue := color.RGBA{0, 0, 255, 255}
+
+	script := `
+# words & json may be mixed
+setcolor #ff0000
+rectangle 10 10 20 20
+fill
+do
+{"cmd": "setcolor", "args": [0, 255, 0]}
+{"cmd": "rectangle", "args": [60, 10, 20, 20]}
+{"cmd": "fill"}
+
+drawimage logo 60 60
+`
+	err := mimage.ApplyScript(m, strings.NewReader(script), mimage.ScriptImage("logo", solid(image.Rect(0, 0, 10, 10), blue)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for p, want := range map[image.Point]color.RGBA{
+		{20, 20}: {255, 0, 0, 255},
+		{70, 20}: {0, 255, 0, 255},
+		{65, 65}: blue,
+		{40, 40}: {},
+	} {
+		if got := m.At(p.X, p.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestApplyScriptErrors(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64))
+	for script, want := range map[string]string{
+		"fill\nlineto 10":                      "line 2",
+		"setcolor #12":                         "invalid color",
+		"setcolor 300 0 0":                     "out of range",
+		"explode":                              "unknown command",
+		"drawimage missing 0 0":                "unknown image",
+		`{"args": [1]}`:                        "missing 'cmd'",
+		`{"cmd": "moveto", "args": [1, true]}`: "must be a number",
+	} {
+		err := mimage.ApplyScript(m, strings.NewReader(script))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("script %q got error %v, want one containing %q", script, err, want)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// carver removes seams of pixels from an RGBA image with seam carving.
+// Seams run top to bottom, so this only narrows images; to shorten an image
+// it is transposed first.
+type carver struct {
+	pix    []uint8   // RGBA data, the stride stays the original width
+	energy []float32 // energy of each pixel, same layout as pix
+	dirs   []int8    // seam direction to the pixel above
+	stride int       // original width
+	w, h   int       // current size
+}
+
+// newCarver prepares a carver for the given image.
+func newCarver(img *image.RGBA) *carver {
+	b := img.Bounds()
+	c := &carver{
+		pix:    make([]uint8, b.Dx()*b.Dy()*4),
+		energy: make([]float32, b.Dx()*b.Dy()),
+		dirs:   make([]int8, b.Dx()*b.Dy()),
+		stride: b.Dx(),
+		w:      b.Dx(),
+		h:      b.Dy(),
+	}
+	for y := 0; y < c.h; y++ {
+		i := img.PixOffset(b.Min.X, b.Min.Y+y)
+		copy(c.pix[y*c.stride*4:(y+1)*c.stride*4], img.Pix[i:i+c.w*4])
+	}
+	for y := 0; y < c.h; y++ {
+		for x := 0; x < c.w; x++ {
+			c.updateEnergy(x, y)
+		}
+	}
+	return c
+}
+
+// lum returns the luminance of the pixel at (x,y), clamping to the edges.
+func (c *carver) lum(x, y int) float32 {
+	i := (clampInt(y, 0, c.h-1)*c.stride + clampInt(x, 0, c.w-1)) * 4
+	return 0.299*float32(c.pix[i]) + 0.587*float32(c.pix[i+1]) + 0.114*float32(c.pix[i+2])
+}
+
+// updateEnergy recalculates the energy (gradient magnitude) at (x,y).
+func (c *carver) updateEnergy(x, y int) {
+	if x < 0 || x >= c.w || y < 0 || y >= c.h {
+		return
+	}
+	dx := c.lum(x+1, y) - c.lum(x-1, y)
+	dy := c.lum(x, y+1) - c.lum(x, y-1)
+	c.energy[y*c.stride+x] = float32(math.Abs(float64(dx)) + math.Abs(float64(dy)))
+}
+
+// meanEnergy returns the average energy of the image.
+func (c *carver) meanEnergy() float64 {
+	total := 0.0
+	for y := 0; y < c.h; y++ {
+		for x := 0; x < c.w; x++ {
+			total += float64(c.energy[y*c.stride+x])
+		}
+	}
+	return total / float64(c.w*c.h)
+}
+
+// seam returns the x position (per row) of the lowest energy seam.
+func (c *carver) seam() []int {
+	prev, cur := make([]float32, c.w), make([]float32, c.w)
+	copy(prev, c.energy[:c.w])
+
+	for y := 1; y < c.h; y++ {
+		row := y * c.stride
+		for x := 0; x < c.w; x++ {
+			best, d := prev[x], int8(0)
+			if x > 0 && prev[x-1] < best {
+				best, d = prev[x-1], -1
+			}
+			if x < c.w-1 && prev[x+1] < best {
+				best, d = prev[x+1], 1
+			}
+			cur[x] = c.energy[row+x] + best
+			c.dirs[row+x] = d
+		}
+		prev, cur = cur, prev
+	}
+
+	seam := make([]int, c.h)
+	for x := 1; x < c.w; x++ {
+		if prev[x] < prev[seam[c.h-1]] {
+			seam[c.h-1] = x
+		}
+	}
+	for y := c.h - 1; y > 0; y-- {
+		seam[y-1] = seam[y] + int(c.dirs[y*c.stride+seam[y]])
+	}
+	return seam
+}
+
+// remove cuts the given seam out, shifting everything right of it left.
+func (c *carver) remove(seam []int) {
+	for y, sx := range seam {
+		row := y * c.stride
+		copy(c.pix[(row+sx)*4:(row+c.w-1)*4], c.pix[(row+sx+1)*4:(row+c.w)*4])
+		copy(c.energy[row+sx:row+c.w-1], c.energy[row+sx+1:row+c.w])
+	}
+	c.w--
+
+	// energy only changes near the seam; neighbours either side of it & the
+	// rows above & below (whose seam positions are at most one pixel away)
+	for y, sx := range seam {
+		for x := sx - 2; x <= sx+1; x++ {
+			c.updateEnergy(x, y)
+		}
+	}
+}
+
+// image returns the (now narrower) image.
+func (c *carver) image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, c.w, c.h))
+	for y := 0; y < c.h; y++ {
+		copy(img.Pix[y*img.Stride:y*img.Stride+c.w*4], c.pix[y*c.stride*4:])
+	}
+	return img
+}
+
+// transpose returns img with x & y swapped.
+func transpose(img *image.RGBA) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			copy(out.Pix[out.PixOffset(y, x):out.PixOffset(y, x)+4], img.Pix[img.PixOffset(b.Min.X+x, b.Min.Y+y):])
+		}
+	}
+	return out
+}
+
+// allocateSeams splits the number of seams to remove between strips, taking
+// more from low energy strips. No strip is reduced below one pixel.
+func allocateSeams(remove int, widths []int, energies []float64) []int {
+	weights := make([]float64, len(widths))
+	total := 0.0
+	for i := range widths {
+		weights[i] = float64(widths[i]) / (energies[i] + 1)
+		total += weights[i]
+	}
+
+	seams := make([]int, len(widths))
+	left := remove
+	for i := range widths {
+		seams[i] = minInt(widths[i]-1, int(float64(remove)*weights[i]/total))
+		left -= seams[i]
+	}
+
+	// hand out what's left (from rounding & capping) to the lowest energy
+	// strips that still have room
+	for left > 0 {
+		best := -1
+		for i := range widths {
+			if seams[i] < widths[i]-1 && (best < 0 || energies[i] < energies[best]) {
+				best = i
+			}
+		}
+		seams[best]++
+		left--
+	}
+
+	return seams
+}
+
+// carveWidth returns a new Mimage narrowed to width w with seam carving.
+//
+// Each chunk column is a strip that we carve independently, with seams shared
+// out between strips according to their energy. Only one strip is held in
+// memory at a time.
+func (m *Mimage) carveWidth(w int, horizontal bool) (*Mimage, error) {
+	// a "strip" is a chunk column, or for horizontal (transposed) carving
+	// a chunk row
+	length, size := m.Width(), m.Height()
+	if horizontal {
+		length, size = size, length
+	}
+	strip := func(i int) image.Rectangle {
+		if horizontal {
+			return image.Rect(0, i*m.chunkSize, size, minInt((i+1)*m.chunkSize, length)).Add(m.bounds.Min)
+		}
+		return image.Rect(i*m.chunkSize, 0, minInt((i+1)*m.chunkSize, length), size).Add(m.bounds.Min)
+	}
+	load := func(i int) (*carver, error) {
+		img, err := m.Image(strip(i))
+		if err != nil {
+			return nil, err
+		}
+		if horizontal {
+			return newCarver(transpose(img.(*image.RGBA))), nil
+		}
+		return newCarver(img.(*image.RGBA)), nil
+	}
+
+	count := (length + m.chunkSize - 1) / m.chunkSize
+	widths, energies := make([]int, count), make([]float64, count)
+	for i := range widths {
+		c, err := load(i)
+		if err != nil {
+			return nil, err
+		}
+		widths[i], energies[i] = c.w, c.meanEnergy()
+	}
+	seams := allocateSeams(length-w, widths, energies)
+
+	bounds := image.Rect(0, 0, w, size)
+	if horizontal {
+		bounds = image.Rect(0, 0, size, w)
+	}
+	out, err := New(bounds, ChunkSize(m.chunkSize), OperationRoutines(m.routines))
+	if err != nil {
+		return nil, err
+	}
+
+	at := 0
+	for i := range widths {
+		c, err := load(i)
+		if err != nil {
+			return nil, err
+		}
+		for s := 0; s < seams[i]; s++ {
+			c.remove(c.seam())
+		}
+
+		img, pt := c.image(), image.Pt(at, 0)
+		if horizontal {
+			img, pt = transpose(img), image.Pt(0, at)
+		}
+		err = out.paste(img, pt)
+		if err != nil {
+			return nil, err
+		}
+		at += c.w
+	}
+
+	return out, nil
+}
+
+// SeamCarve returns a new Mimage resized to (width, height) with content aware
+// resizing (seam carving), which removes the least interesting paths of pixels
+// rather than scaling everything evenly. Only shrinking is supported.
+//
+// To keep memory bounded, seams are found within strips one chunk wide (or high)
+// rather than across the whole image, so memory use is proportional to the
+// size of one row / column of chunks.
+func (m *Mimage) SeamCarve(width, height int) (*Mimage, error) {
+	if width <= 0 || height <= 0 || width > m.Width() || height > m.Height() {
+		return nil, fmt.Errorf("target size %dx%d must be within the current size %dx%d", width, height, m.Width(), m.Height())
+	}
+
+	narrow, err := m.carveWidth(width, false)
+	if err != nil {
+		return nil, err
+	}
+	defer narrow.Close()
+
+	return narrow.carveWidth(height, true)
+}
@@ -0,0 +1,56 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestSeamCarve(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 128, 128), mimage.ChunkSize(64))
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.Clear()
+	// a striped band has all the energy, so seams go around it
+	op.SetColor(color.Black)
+	for x := 48; x < 80; x += 8 {
+		op.DrawRectangle(float64(x), 0, 4, 128)
+	}
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	carved, err := m.SeamCarve(96, 112)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer carved.Close()
+	if b := carved.Bounds(); b != image.Rect(0, 0, 96, 112) {
+		t.Errorf("carved bounds are %v", b)
+	}
+
+	stripes, black := 0, false
+	for x := 0; x < 96; x++ {
+		now := carved.At(x, 50) == (color.RGBA{0, 0, 0, 255})
+		if now && !black {
+			stripes++
+		}
+		black = now
+	}
+	if stripes != 4 {
+		t.Errorf("%d stripes left after carving, want 4", stripes)
+	}
+}
+
+func TestSeamCarveErrors(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64))
+	for _, size := range []image.Point{{65, 64}, {64, 65}, {0, 10}, {10, -1}} {
+		if _, err := m.SeamCarve(size.X, size.Y); err == nil {
+			t.Errorf("carving to %v got no error", size)
+		}
+	}
+}
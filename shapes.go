@@ -0,0 +1,69 @@
+package mimage
+
+import (
+	"math"
+)
+
+// DrawRegularPolygon adds a regular polygon with n sides centered at (x,y)
+// with its corners r from the center to the current path, rotated by
+// rotation (radians). As with gg, a point is at the top (or with an even
+// number of sides, a flat side is at the bottom) before rotating.
+func (o *operation) DrawRegularPolygon(n int, x, y, r, rotation float64) {
+	if n < 3 {
+		return
+	}
+	step := 2 * math.Pi / float64(n)
+	rotation -= math.Pi / 2
+	if n%2 == 0 {
+		rotation += step / 2
+	}
+	points := make([]Point, n)
+	for i := range points {
+		a := rotation + step*float64(i)
+		points[i] = Point{X: x + r*math.Cos(a), Y: y + r*math.Sin(a)}
+	}
+	o.drawPoints(drawPolygon, points)
+}
+
+// DrawStar adds a star with n points centered at (x,y) to the current path,
+// its points outer from the center & the corners between them inner from
+// the center, rotated by rotation (radians) from having a point at the top.
+func (o *operation) DrawStar(n int, x, y, outer, inner, rotation float64) {
+	if n < 2 {
+		return
+	}
+	step := math.Pi / float64(n)
+	points := make([]Point, 2*n)
+	for i := range points {
+		r := outer
+		if i%2 == 1 {
+			r = inner
+		}
+		a := rotation - math.Pi/2 + step*float64(i)
+		points[i] = Point{X: x + r*math.Cos(a), Y: y + r*math.Sin(a)}
+	}
+	o.drawPoints(drawPolygon, points)
+}
+
+// DrawPie adds a wedge of the circle centered at (x,y) with radius r to the
+// current path, from angle1 to angle2 (radians, clockwise from the positive
+// x axis as y is down), eg. for pie charts.
+func (o *operation) DrawPie(x, y, r, angle1, angle2 float64) {
+	sweep := angle2 - angle1
+	if sweep == 0 || r <= 0 {
+		return
+	}
+	if math.Abs(sweep) > 2*math.Pi {
+		sweep = math.Copysign(2*math.Pi, sweep)
+	}
+
+	// a point on the arc every couple of pixels
+	steps := maxInt(4, int(math.Ceil(math.Abs(sweep)*r/splineStep)))
+	points := make([]Point, 0, steps+2)
+	points = append(points, Point{X: x, Y: y})
+	for i := 0; i <= steps; i++ {
+		a := angle1 + sweep*float64(i)/float64(steps)
+		points = append(points, Point{X: x + r*math.Cos(a), Y: y + r*math.Sin(a)})
+	}
+	o.drawPoints(drawPolygon, points)
+}
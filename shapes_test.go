@@ -0,0 +1,68 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestShapes(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	for _, tc := range []struct {
+		name    string
+		draw    func(op mimage.Operation)
+		in, out []image.Point
+	}{
+		{
+			"square", // a flat side at the bottom, so square to the axes
+			func(op mimage.Operation) { op.DrawRegularPolygon(4, 48, 48, 30, 0) },
+			[]image.Point{{66, 66}, {30, 30}},
+			[]image.Point{{72, 48}, {48, 72}},
+		},
+		{
+			"star", // a point at the top
+			func(op mimage.Operation) { op.DrawStar(5, 48, 48, 30, 10, 0) },
+			[]image.Point{{48, 48}, {48, 23}},
+			[]image.Point{{60, 32}, {48, 70}},
+		},
+		{
+			"pie", // clockwise, from the right to the bottom
+			func(op mimage.Operation) { op.DrawPie(48, 48, 40, 0, math.Pi/2) },
+			[]image.Point{{60, 60}, {80, 50}},
+			[]image.Point{{36, 60}, {60, 36}},
+		},
+		{
+			"nothing",
+			func(op mimage.Operation) {
+				op.DrawRegularPolygon(2, 48, 48, 30, 0)
+				op.DrawStar(1, 48, 48, 30, 10, 0)
+				op.DrawPie(48, 48, 40, 1, 1)
+			},
+			nil,
+			[]image.Point{{48, 48}},
+		},
+	} {
+		m := newImage(t, image.Rect(0, 0, 96, 96), mimage.ChunkSize(32))
+		op := m.Draw()
+		op.SetColor(red)
+		tc.draw(op)
+		op.Fill()
+		err := op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pt := range tc.in {
+			if got := m.At(pt.X, pt.Y); got != red {
+				t.Errorf("%s: pixel %v is %v, want it filled", tc.name, pt, got)
+			}
+		}
+		for _, pt := range tc.out {
+			if got := m.At(pt.X, pt.Y); got != (color.RGBA{}) {
+				t.Errorf("%s: pixel %v is %v, want it untouched", tc.name, pt, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestUnsharpMask(t *testing.T) {
+	// a step from dark to light on the seam between chunks
+	dark, light := color.RGBA{100, 100, 100, 255}, color.RGBA{200, 200, 200, 255}
+	step := func() *mimage.Mimage {
+		m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+		op := m.Draw()
+		op.SetColor(dark)
+		op.DrawRectangle(0, 0, 32, 64)
+		op.Fill()
+		op.SetColor(light)
+		op.DrawRectangle(32, 0, 32, 64)
+		op.Fill()
+		err := op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	m := step()
+	err := m.UnsharpMask(1.5, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := m.At(31, 20).(color.RGBA), m.At(32, 20).(color.RGBA)
+	if a.R >= dark.R || b.R <= light.R {
+		t.Errorf("edge is %v to %v, want it darker & lighter than %v to %v", a, b, dark, light)
+	}
+	if got := m.At(5, 20); got != dark {
+		t.Errorf("pixel away from the edge is %v, want %v", got, dark)
+	}
+
+	// the difference across the edge is under the threshold
+	m = step()
+	err = m.UnsharpMask(1.5, 1, 255)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.At(31, 20); got != dark {
+		t.Errorf("pixel under the threshold is %v, want %v", got, dark)
+	}
+
+	if err := m.UnsharpMask(0, 1, 0); err == nil {
+		t.Error("zero radius got no error")
+	}
+}
@@ -0,0 +1,163 @@
+package mimage
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+)
+
+// SnapshotCopies has operations keep a copy of each chunk they change, as it
+// was, until they're finished so ImageSnapshot never has to wait for them.
+// This costs a copy of every chunk an operation changes while it's running,
+// so suits images drawn in small operations while being read (eg. served as
+// tiles).
+func SnapshotCopies() Option {
+	return func(m *Mimage) error {
+		m.snapshotCopies = true
+		return nil
+	}
+}
+
+// snapshots tracks the operations running on an image, so reads can see the
+// image without any of them part way through.
+type snapshots struct {
+	// readers hold the gate for reading while they read, operations take it
+	// to start & (if keeping copies) to start a chunk or finish, so they
+	// don't change what's being read
+	gate *sync.RWMutex
+
+	lock *sync.Mutex // guards ops
+	ops  []*write
+}
+
+// write is an operation that's running.
+type write struct {
+	s    *snapshots
+	area image.Rectangle // in world space, that may change
+	keep bool
+	done chan struct{}
+
+	// chunks as they were before the operation changed them, if keeping them
+	before map[[2]int]*savedChunk
+}
+
+// savedChunk is a copy of a chunk, ready to read once ready is closed.
+type savedChunk struct {
+	ready chan struct{}
+	img   *image.RGBA
+}
+
+// snapshots returns the image's snapshots, made the first time it's needed.
+func (m *Mimage) snapshots() *snapshots {
+	m.metaLock.Lock()
+	defer m.metaLock.Unlock()
+
+	if m.snaps == nil {
+		m.snaps = &snapshots{gate: &sync.RWMutex{}, lock: &sync.Mutex{}}
+	}
+	return m.snaps
+}
+
+// begin records that an operation that may change area is starting.
+func (s *snapshots) begin(area image.Rectangle, keep bool) *write {
+	w := &write{s: s, area: area, keep: keep, done: make(chan struct{}), before: map[[2]int]*savedChunk{}}
+	s.gate.Lock()
+	defer s.gate.Unlock()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.ops = append(s.ops, w)
+	return w
+}
+
+// change is called before the operation loads the chunk to change it,
+// returning a function to call with the loaded chunk before changing it, or
+// nil if it couldn't be loaded.
+func (w *write) change(x, y int) func(img *image.RGBA) {
+	if !w.keep {
+		return func(*image.RGBA) {}
+	}
+	saved := &savedChunk{ready: make(chan struct{})}
+	w.s.gate.Lock()
+	w.before[[2]int{x, y}] = saved
+	w.s.gate.Unlock()
+
+	return func(img *image.RGBA) {
+		if img != nil {
+			saved.img = &image.RGBA{Pix: append([]uint8{}, img.Pix...), Stride: img.Stride, Rect: img.Rect}
+		}
+		close(saved.ready)
+	}
+}
+
+// finish records that the operation is over, dropping the chunks it kept.
+// Readers wait for operations that don't keep copies, so only those that do
+// need to wait for readers.
+func (w *write) finish() {
+	if w.keep {
+		w.s.gate.Lock()
+		defer w.s.gate.Unlock()
+	}
+	w.s.lock.Lock()
+	defer w.s.lock.Unlock()
+	for i, op := range w.s.ops {
+		if op == w {
+			w.s.ops = append(w.s.ops[:i], w.s.ops[i+1:]...)
+			break
+		}
+	}
+	close(w.done)
+}
+
+// ImageSnapshot is like Image, but never returns r with an operation (see
+// Draw) part way through changing it. With SnapshotCopies r is read as it
+// was before operations still running over it, from copies of the chunks
+// they've changed, otherwise once they've finished. Operations don't start
+// while a snapshot is being read.
+//
+// Only operations are covered; other changes (filters etc.) are read as they
+// are.
+func (m *Mimage) ImageSnapshot(r image.Rectangle) (image.Image, error) {
+	s := m.snapshots()
+	s.gate.RLock()
+	defer s.gate.RUnlock()
+
+	// no more operations can start, so wait out those over r that are
+	// running (without copies)
+	s.lock.Lock()
+	ops := append([]*write{}, s.ops...)
+	s.lock.Unlock()
+	for _, w := range ops {
+		if !w.keep && w.area.Overlaps(r) {
+			<-w.done
+		}
+	}
+
+	dst := image.NewRGBA(r.Sub(r.Min))
+	for coord := range m.chunksWithin(r) {
+		at := image.Pt(coord[0]*m.chunkSize, coord[1]*m.chunkSize).Sub(r.Min)
+
+		var saved *savedChunk
+		for _, w := range ops {
+			if saved = w.before[coord]; saved != nil {
+				break // the earliest operation has the oldest copy
+			}
+		}
+		if saved != nil {
+			<-saved.ready
+		}
+		if saved != nil && saved.img != nil {
+			draw.Draw(dst, saved.img.Bounds().Add(at), saved.img, image.Point{}, draw.Src)
+			continue
+		}
+
+		i, err := m.readChunk(coord[0], coord[1])
+		if err != nil {
+			i.Done()
+			return dst, err
+		}
+		img := i.Img.Image()
+		draw.Draw(dst, img.Bounds().Add(at), img, image.Point{}, draw.Src)
+		i.Done()
+	}
+	return dst, nil
+}
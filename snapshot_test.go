@@ -0,0 +1,87 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/voidshard/mimage"
+)
+
+// heldFill starts filling m with c, returning once the first chunk is done &
+// a function to let the rest go ahead, which waits for the fill to finish.
+func heldFill(t *testing.T, m *mimage.Mimage, c color.Color) func() {
+	started, release := make(chan struct{}), make(chan struct{})
+	once := &sync.Once{}
+	op := m.Draw()
+	op.SetColor(c)
+	op.Clear()
+	op.OnChunkDone(func(cx, cy int, err error) {
+		once.Do(func() {
+			close(started)
+			<-release
+		})
+	})
+	done := make(chan error)
+	go func() { done <- op.Do() }()
+	<-started
+
+	return func() {
+		close(release)
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestImageSnapshot(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	for _, copies := range []bool{true, false} {
+		opts := []mimage.Option{mimage.ChunkSize(32)}
+		if copies {
+			opts = append(opts, mimage.SnapshotCopies())
+		}
+		m := newImage(t, image.Rect(0, 0, 64, 64), opts...)
+		op := m.Draw()
+		op.SetColor(red)
+		op.Clear()
+		err := op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		finish := heldFill(t, m, blue)
+		read := make(chan image.Image)
+		go func() {
+			img, err := m.ImageSnapshot(m.Bounds())
+			if err != nil {
+				t.Error(err)
+			}
+			read <- img
+		}()
+
+		// with copies the snapshot is of the image before the fill, without
+		// it waits for the fill to finish
+		want := red
+		if !copies {
+			want = blue
+			select {
+			case <-read:
+				t.Fatal("snapshot read while an operation was part way through")
+			case <-time.After(50 * time.Millisecond):
+			}
+			finish()
+		}
+		img := <-read
+		if copies {
+			finish()
+		}
+		for _, pt := range []image.Point{{10, 10}, {40, 10}, {10, 40}, {40, 40}} {
+			if got := img.At(pt.X, pt.Y); got != want {
+				t.Errorf("copies %v: snapshot pixel %v is %v, want %v", copies, pt, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package mimage
+
+import (
+	"math"
+)
+
+// splineStep is roughly how far apart (in pixels) points are placed along a
+// spline when it's turned into lines
+const splineStep = 2.0
+
+// DrawSpline adds a smooth curve passing through each of the given points
+// (in world space) to the current path, ready to be stroked (eg. rivers,
+// roads or smoothed contours). It's a cardinal spline, tension 0 being a
+// Catmull-Rom spline & 1 straight lines between the points.
+//
+// The curve is turned into lines a couple of pixels long when queued, so it
+// costs the same as DrawPolyline & its bounds (the curve can swing a little
+// outside of the points) are worked out once.
+func (o *operation) DrawSpline(points []Point, tension float64) {
+	o.drawPoints(drawPolyline, splinePoints(points, tension))
+}
+
+// splinePoints returns points along the cardinal spline through the given
+// points, each segment being a cubic hermite curve with tangents from the
+// points either side.
+func splinePoints(points []Point, tension float64) []Point {
+	if len(points) < 3 {
+		return points
+	}
+	scale := (1 - tension) / 2
+
+	out := []Point{points[0]}
+	for i := 0; i < len(points)-1; i++ {
+		p0, p1, p2, p3 := points[maxInt(i-1, 0)], points[i], points[i+1], points[minInt(i+2, len(points)-1)]
+		m1 := Point{X: (p2.X - p0.X) * scale, Y: (p2.Y - p0.Y) * scale}
+		m2 := Point{X: (p3.X - p1.X) * scale, Y: (p3.Y - p1.Y) * scale}
+
+		// the control polygon is at least as long as the curve
+		length := math.Hypot(m1.X, m1.Y)/3 + math.Hypot(p2.X-p1.X, p2.Y-p1.Y) + math.Hypot(m2.X, m2.Y)/3
+		steps := maxInt(1, int(math.Ceil(length/splineStep)))
+		for s := 1; s <= steps; s++ {
+			t := float64(s) / float64(steps)
+			t2, t3 := t*t, t*t*t
+			h00, h10 := 2*t3-3*t2+1, t3-2*t2+t
+			h01, h11 := -2*t3+3*t2, t3-t2
+			out = append(out, Point{
+				X: h00*p1.X + h10*m1.X + h01*p2.X + h11*m2.X,
+				Y: h00*p1.Y + h10*m1.Y + h01*p2.Y + h11*m2.Y,
+			})
+		}
+	}
+	return out
+}
@@ -0,0 +1,43 @@
+package mimage
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSplinePoints(t *testing.T) {
+	through := []Point{{X: 10, Y: 50}, {X: 50, Y: 20}, {X: 90, Y: 50}, {X: 90, Y: 90}}
+
+	for _, tension := range []float64{0, 0.5, 1} {
+		out := splinePoints(through, tension)
+		// passes through every point, in steps of about splineStep
+		next := 0
+		for i, p := range out {
+			if next < len(through) && math.Hypot(p.X-through[next].X, p.Y-through[next].Y) < 1e-9 {
+				next++
+			}
+			if i > 0 {
+				if d := math.Hypot(p.X-out[i-1].X, p.Y-out[i-1].Y); d > 2*splineStep { // steps are even in t, not in length
+					t.Errorf("tension %v: points %d & %d are %v apart", tension, i-1, i, d)
+				}
+			}
+		}
+		if next != len(through) {
+			t.Errorf("tension %v: curve passes through %d of %d points", tension, next, len(through))
+		}
+
+		// the curve swings out past the turn at (90,50) unless it's taut
+		right := math.Inf(-1)
+		for _, p := range out {
+			right = math.Max(right, p.X)
+		}
+		if swings := right > 90+1e-9; swings != (tension < 1) {
+			t.Errorf("tension %v: curve reaches right to %v", tension, right)
+		}
+	}
+
+	short := []Point{{X: 1, Y: 2}, {X: 3, Y: 4}}
+	if out := splinePoints(short, 0); len(out) != 2 || out[0] != short[0] || out[1] != short[1] {
+		t.Errorf("two points made %v, want a straight line", out)
+	}
+}
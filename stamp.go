@@ -0,0 +1,136 @@
+package mimage
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+)
+
+// StampJitter configures random variation applied to each stamp placed
+// by StampAlongPath. All values are the maximum deviation either way; zero
+// means no variation.
+//
+// Variation is decided when the stamps are queued (using Seed) so the same
+// stamp is placed in the same way regardless of which chunk draws it.
+type StampJitter struct {
+	Rotation float64 // radians
+	Scale    float64 // fraction of the image size, eg. 0.2 is 80%-120%
+	Alpha    float64 // fraction of the alpha removed, eg. 0.5 is 50%-100% opaque
+	Offset   float64 // pixels moved away from the path
+	Seed     int64
+}
+
+// stamp is a single placement of a stamp image in world space.
+type stamp struct {
+	X, Y  float64 // center of the stamp
+	Angle float64
+	Scale float64
+	Alpha float64
+}
+
+// bounds returns the world space area a stamp of the given size may draw over.
+func (s *stamp) bounds(size image.Point) (float64, float64, float64, float64) {
+	r := math.Hypot(float64(size.X), float64(size.Y)) * s.Scale / 2
+	return s.X - r, s.Y - r, s.X + r, s.Y + r
+}
+
+// jittered returns a stamp at (x,y) with random variation applied.
+func (j *StampJitter) jittered(rng *rand.Rand, x, y float64) stamp {
+	spread := func(max float64) float64 { return (rng.Float64()*2 - 1) * max }
+
+	return stamp{
+		X:     x + spread(j.Offset),
+		Y:     y + spread(j.Offset),
+		Angle: spread(j.Rotation),
+		Scale: math.Max(0, 1+spread(j.Scale)),
+		Alpha: 1 - rng.Float64()*math.Min(1, math.Max(0, j.Alpha)),
+	}
+}
+
+// fadedImage wraps an image, scaling down the alpha of all colors.
+type fadedImage struct {
+	image.Image
+	alpha float64
+}
+
+// At returns the color at (x,y) with reduced alpha.
+func (f *fadedImage) At(x, y int) color.Color {
+	r, g, b, a := f.Image.At(x, y).RGBA()
+	return color.RGBA64{
+		uint16(float64(r) * f.alpha),
+		uint16(float64(g) * f.alpha),
+		uint16(float64(b) * f.alpha),
+		uint16(float64(a) * f.alpha),
+	}
+}
+
+// renderStamps draws all the given stamps that intersect the context, where the
+// context origin is at (offX, offY) in world space. Returns if anything was drawn.
+func renderStamps(dc Canvas, img image.Image, stamps []stamp, offX, offY float64) bool {
+	size := img.Bounds().Size()
+	min := img.Bounds().Min // gg draws images relative to their own bounds
+	w, h := float64(dc.Width()), float64(dc.Height())
+
+	drawn := false
+	for _, s := range stamps {
+		minX, minY, maxX, maxY := s.bounds(size)
+		if maxX < offX || maxY < offY || minX > offX+w || minY > offY+h {
+			continue
+		}
+
+		var src image.Image = img
+		if s.Alpha < 1 {
+			src = &fadedImage{Image: img, alpha: s.Alpha}
+		}
+
+		x, y := s.X-offX, s.Y-offY
+		dc.Push()
+		dc.RotateAbout(s.Angle, x, y)
+		dc.ScaleAbout(s.Scale, s.Scale, x, y)
+		dc.DrawImageAnchored(src, int(math.Round(x))-min.X, int(math.Round(y))-min.Y, 0.5, 0.5)
+		dc.Pop()
+		drawn = true
+	}
+
+	return drawn
+}
+
+// StampAlongPath repeats the image img every spacing pixels along the path
+// given by points, centering each copy on the path.
+func (o *operation) StampAlongPath(img image.Image, points []Point, spacing float64, jitter StampJitter) {
+	if len(points) == 0 || spacing <= 0 {
+		return
+	}
+
+	rng := rand.New(rand.NewSource(o.seeded(jitter.Seed)))
+	size := img.Bounds().Size()
+	stamps := []stamp{}
+
+	place := func(x, y float64) {
+		s := jitter.jittered(rng, x, y)
+		minX, minY, maxX, maxY := s.bounds(size)
+		o.minMax(minX, minY)
+		o.minMax(maxX, maxY)
+		stamps = append(stamps, s)
+	}
+
+	place(points[0].X, points[0].Y)
+	travelled := 0.0 // distance since the last stamp
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		dist := math.Hypot(b.X-a.X, b.Y-a.Y)
+		if dist == 0 {
+			continue
+		}
+
+		at := spacing - travelled // distance along this segment of the next stamp
+		for ; at <= dist; at += spacing {
+			t := at / dist
+			place(a.X+(b.X-a.X)*t, a.Y+(b.Y-a.Y)*t)
+		}
+		travelled = dist - (at - spacing)
+	}
+
+	o.queue.pushArgs(drawStamps, []interface{}{img, stamps})
+}
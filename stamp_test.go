@@ -0,0 +1,77 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+// solid returns an image of the given bounds filled with c.
+func solid(r image.Rectangle, c color.Color) *image.RGBA {
+	img := image.NewRGBA(r)
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func TestStampAlongPath(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 100, 100), mimage.ChunkSize(32))
+	red := color.RGBA{255, 0, 0, 255}
+
+	// a stamp whose bounds don't start at the origin, drawn across chunks
+	brush := solid(image.Rect(4, 4, 8, 8), red)
+	op := m.Draw()
+	op.StampAlongPath(brush, []mimage.Point{{X: 10, Y: 50}, {X: 50, Y: 50}, {X: 50, Y: 90}}, 20, mimage.StampJitter{})
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []image.Point{{10, 50}, {30, 50}, {31, 51}, {50, 50}, {50, 70}, {50, 90}} {
+		if got := m.At(p.X, p.Y); got != red {
+			t.Errorf("stamped pixel %v is %v, want %v", p, got, red)
+		}
+	}
+	for _, p := range []image.Point{{20, 50}, {40, 50}, {50, 60}, {5, 5}} {
+		if got := m.At(p.X, p.Y); got != (color.RGBA{}) {
+			t.Errorf("pixel %v between stamps is %v, want it untouched", p, got)
+		}
+	}
+}
+
+func TestStampJitterRepeatable(t *testing.T) {
+	path := []mimage.Point{{X: 5, Y: 20}, {X: 95, Y: 80}}
+	jitter := mimage.StampJitter{Rotation: 1, Scale: 0.5, Alpha: 0.5, Offset: 6, Seed: 7}
+	brush := solid(image.Rect(0, 0, 6, 6), color.RGBA{0, 0, 255, 255})
+
+	// chunks draw each stamp the same way, whatever their size
+	images := []*mimage.Mimage{}
+	for _, size := range []int{16, 100} {
+		m := newImage(t, image.Rect(0, 0, 100, 100), mimage.ChunkSize(size))
+		op := m.Draw()
+		op.StampAlongPath(brush, path, 10, jitter)
+		err := op.Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		images = append(images, m)
+	}
+
+	drawn := 0
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			a, b := images[0].At(x, y), images[1].At(x, y)
+			if a != b {
+				t.Fatalf("pixel (%d,%d) is %v in small chunks, %v in one", x, y, a, b)
+			}
+			if a != (color.RGBA{}) {
+				drawn++
+			}
+		}
+	}
+	if drawn == 0 {
+		t.Error("nothing was stamped")
+	}
+}
@@ -0,0 +1,163 @@
+package mimage
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// BlendMode determines how overlapping images are combined when stitching.
+type BlendMode int
+
+const (
+	// BlendNone means later images simply overwrite earlier ones.
+	BlendNone BlendMode = iota
+
+	// BlendLinear weights each image by the distance to its edges, giving a
+	// linear cross fade across overlapping areas.
+	BlendLinear
+
+	// BlendFeather is like BlendLinear but with a smooth (s-curve) cross fade,
+	// which keeps more of each image before quickly fading to the other.
+	BlendFeather
+
+	// BlendMultiband splits images into frequency bands, blending fine detail
+	// over a sharp seam and coarse detail over a wide area. This is slower but
+	// removes visible seams (eg. from exposure changes) without ghosting.
+	BlendMultiband
+)
+
+// PlacedImage is an image along with where it should be placed (in world
+// space). The top left of the image is placed at At.
+type PlacedImage struct {
+	Image image.Image
+	At    image.Point
+}
+
+// bounds returns the world space area the image covers.
+func (p *PlacedImage) bounds() image.Rectangle {
+	b := p.Image.Bounds()
+	return b.Sub(b.Min).Add(p.At)
+}
+
+// edgeWeight returns a weight for (x,y) within r that falls off towards the
+// edges. The distance to the nearest vertical & horizontal edges are treated
+// separately (and multiplied) so that images overlapping only on one axis
+// blend evenly along the other.
+func edgeWeight(r image.Rectangle, x, y int) float64 {
+	dx := minInt(x-r.Min.X, r.Max.X-1-x) + 1
+	dy := minInt(y-r.Min.Y, r.Max.Y-1-y) + 1
+	return float64(dx) * float64(dy)
+}
+
+// smoothstep returns an s-curve from 0-1 for t in 0-1.
+func smoothstep(t float64) float64 {
+	t = math.Max(0, math.Min(1, t))
+	return t * t * (3 - 2*t)
+}
+
+// blendAt returns the blended color at (x,y) in world space from all of the
+// given images that cover that point. Returns false if no image covers it.
+// The weights slice is scratch space, reused between calls.
+func blendAt(tiles []PlacedImage, bounds []image.Rectangle, blend BlendMode, x, y int, weights [][5]float64) (color.RGBA64, bool) {
+	pt := image.Pt(x, y)
+
+	weights = weights[:0] // weight, r, g, b, a
+	total := 0.0
+	for i, tile := range tiles {
+		if !pt.In(bounds[i]) {
+			continue
+		}
+
+		src := tile.Image.Bounds().Min.Add(pt.Sub(tile.At))
+		r, g, b, a := tile.Image.At(src.X, src.Y).RGBA()
+		if blend == BlendNone {
+			weights = append(weights[:0], [5]float64{1, float64(r), float64(g), float64(b), float64(a)})
+			total = 1
+			continue
+		}
+
+		w := edgeWeight(bounds[i], x, y) * float64(a) / 0xffff
+		weights = append(weights, [5]float64{w, float64(r), float64(g), float64(b), float64(a)})
+		total += w
+	}
+
+	if len(weights) == 0 {
+		return color.RGBA64{}, false
+	} else if total == 0 { // all transparent
+		return color.RGBA64{}, true
+	}
+
+	if blend == BlendFeather && len(weights) > 1 {
+		sum := total
+		total = 0
+		for i := range weights {
+			weights[i][0] = smoothstep(weights[i][0] / sum)
+			total += weights[i][0]
+		}
+	}
+
+	var out [4]float64
+	for _, w := range weights {
+		for c := 0; c < 4; c++ {
+			out[c] += w[c+1] * w[0] / total
+		}
+	}
+
+	return color.RGBA64{uint16(out[0]), uint16(out[1]), uint16(out[2]), uint16(out[3])}, true
+}
+
+// Stitch imports many (possibly overlapping) images into the massive image at
+// the given offsets. Where images overlap they're combined according to the
+// blend mode; outside of the images the massive image is left alone.
+//
+// Chunks are processed in parallel and each is read & written once.
+func (m *Mimage) Stitch(tiles []PlacedImage, blend BlendMode) error {
+	bounds := make([]image.Rectangle, len(tiles))
+	area := image.Rectangle{}
+	for i := range tiles {
+		bounds[i] = tiles[i].bounds()
+		area = area.Union(bounds[i])
+	}
+
+	return m.eachChunk(area, func(ctx *context) error {
+		cb := m.chunkBounds(ctx.X, ctx.Y)
+
+		// only the images that touch this chunk are of interest
+		reach := cb
+		if blend == BlendMultiband {
+			reach = cb.Inset(-multibandHalo)
+		}
+		local, localBounds := []PlacedImage{}, []image.Rectangle{}
+		for i := range tiles {
+			if bounds[i].Overlaps(reach) {
+				local = append(local, tiles[i])
+				localBounds = append(localBounds, bounds[i])
+			}
+		}
+		if len(local) == 0 {
+			return nil
+		}
+
+		dst := ctx.Img.Image().(*image.RGBA)
+		r := cb.Intersect(area).Intersect(m.bounds)
+		ctx.setEdited()
+
+		if blend == BlendMultiband {
+			multibandChunk(dst, cb, r, local, localBounds, area)
+			return nil
+		}
+
+		weights := make([][5]float64, 0, len(local))
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				c, ok := blendAt(local, localBounds, blend, x, y, weights)
+				if ok {
+					dst.Set(x-cb.Min.X, y-cb.Min.Y, c)
+				}
+			}
+		}
+
+		return nil
+	})
+}
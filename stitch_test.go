@@ -0,0 +1,83 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestStitch(t *testing.T) {
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	tiles := []mimage.PlacedImage{
+		{Image: solid(image.Rect(0, 0, 40, 20), red), At: image.Pt(0, 10)},
+		{Image: solid(image.Rect(5, 5, 45, 25), blue), At: image.Pt(30, 10)},
+	}
+
+	for _, blend := range []mimage.BlendMode{mimage.BlendNone, mimage.BlendLinear, mimage.BlendFeather} {
+		m := newImage(t, image.Rect(0, 0, 100, 100), mimage.ChunkSize(32))
+		err := m.Stitch(tiles, blend)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := m.At(10, 20); got != red {
+			t.Errorf("blend %d: pixel only in the first image is %v, want %v", blend, got, red)
+		}
+		if got := m.At(60, 20); got != blue {
+			t.Errorf("blend %d: pixel only in the second image is %v, want %v", blend, got, blue)
+		}
+		if got := m.At(10, 5); got != (color.RGBA{}) {
+			t.Errorf("blend %d: pixel outside both is %v, want it untouched", blend, got)
+		}
+
+		got := m.At(35, 20).(color.RGBA)
+		if blend == mimage.BlendNone {
+			if got != blue {
+				t.Errorf("overwritten pixel is %v, want the later image's %v", got, blue)
+			}
+			continue
+		}
+		if got.R < 80 || got.B < 80 || got.A != 255 {
+			t.Errorf("blend %d: pixel in the middle of the overlap is %v, want both mixed", blend, got)
+		}
+	}
+}
+
+func TestStitchMultiband(t *testing.T) {
+	// the same scene exposed differently either side of the overlap
+	tiles := []mimage.PlacedImage{
+		{Image: solid(image.Rect(0, 0, 60, 40), color.RGBA{200, 100, 0, 255}), At: image.Pt(0, 0)},
+		{Image: solid(image.Rect(0, 0, 60, 40), color.RGBA{100, 50, 0, 255}), At: image.Pt(40, 0)},
+	}
+
+	// chunks blend as if stitched all at once
+	stitched := []*mimage.Mimage{}
+	for _, size := range []int{16, 128} {
+		m := newImage(t, image.Rect(0, 0, 100, 100), mimage.ChunkSize(size))
+		err := m.Stitch(tiles, mimage.BlendMultiband)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stitched = append(stitched, m)
+	}
+	for x := 0; x < 100; x++ {
+		a, b := stitched[0].At(x, 20).(color.RGBA), stitched[1].At(x, 20).(color.RGBA)
+		if diff := int(a.R) - int(b.R); diff < -2 || diff > 2 || a.A != 255 {
+			t.Errorf("pixel (%d,20) is %v in small chunks, %v in one", x, a, b)
+		}
+	}
+
+	// the exposure changes gradually rather than at a seam
+	m := stitched[1]
+	for x := 1; x < 100; x++ {
+		prev, cur := m.At(x-1, 20).(color.RGBA), m.At(x, 20).(color.RGBA)
+		if step := int(prev.R) - int(cur.R); step > 20 || step < -2 {
+			t.Errorf("red steps from %d to %d at x=%d", prev.R, cur.R, x)
+		}
+	}
+	if first, last := m.At(0, 20).(color.RGBA), m.At(99, 20).(color.RGBA); first.R < 180 || last.R > 120 {
+		t.Errorf("far edges are %v & %v, want them to keep their own exposure", first, last)
+	}
+}
@@ -0,0 +1,67 @@
+package mimage
+
+import (
+	"fmt"
+)
+
+// Storage keeps the image (its metadata & chunks) in the given ChunkStore
+// rather than in a directory, eg. a shim over IndexedDB or the origin private
+// file system when running as WebAssembly in a browser, where there's no
+// file system to speak of. The store is read & written as the image would be.
+//
+// An image kept in a store has no Directory, so things kept in files next to
+// the chunks (ICC profiles, timelapses, snapshots & SkipUnchanged) aren't
+// available.
+//
+// Load an image kept in a store again with LoadStorage.
+func Storage(store ChunkStore) Option {
+	return func(m *Mimage) error {
+		if store == nil {
+			return fmt.Errorf("storage must not be nil")
+		}
+		m.storage = store
+		return nil
+	}
+}
+
+// MaxChunks bounds how many chunks not in use are held in memory. When more
+// are loaded the least recently used are written out & dropped there & then,
+// rather than by the routine each chunk otherwise has that wakes up every
+// second or so to unload it. So no timers are involved, which suits
+// WebAssembly, where this is on (at 64 chunks) by default.
+//
+// This is the EvictLRU policy, which MaxChunks picks unless Eviction says
+// otherwise. Zero (the default elsewhere) unloads chunks on a timer as usual,
+// or with EvictLRU, as soon as they're not in use.
+func MaxChunks(n int) Option {
+	return func(m *Mimage) error {
+		if n < 0 {
+			return fmt.Errorf("max chunks must not be negative, given %d", n)
+		}
+		m.maxChunks = n
+		return nil
+	}
+}
+
+// LoadStorage loads an image kept in a ChunkStore (see Storage), reading &
+// writing it there from now on.
+func LoadStorage(store ChunkStore) (*Mimage, error) {
+	data, err := readAll(store, metafile)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return fromMetadata(meta, "", nil, store)
+}
+
+// needsDirectory returns an error if the image is kept in a store rather than
+// a directory, for things that keep files next to the chunks.
+func (m *Mimage) needsDirectory(what string) error {
+	if m.storage != nil {
+		return fmt.Errorf("%s needs the image to be kept in a directory, not a store", what)
+	}
+	return nil
+}
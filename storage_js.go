@@ -0,0 +1,9 @@
+//go:build js && wasm
+// +build js,wasm
+
+package mimage
+
+// defaultMaxChunks is how many idle chunks are kept in memory by default (see
+// MaxChunks). Browsers don't take kindly to timers ticking away for every
+// chunk, nor to pages using unbounded memory.
+const defaultMaxChunks = 64
@@ -0,0 +1,8 @@
+//go:build !(js && wasm)
+// +build !js !wasm
+
+package mimage
+
+// defaultMaxChunks is how many idle chunks are kept in memory by default (see
+// MaxChunks), none meaning chunks are unloaded on a timer instead.
+const defaultMaxChunks = 0
@@ -0,0 +1,88 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+
+	"github.com/voidshard/mimage"
+	"github.com/voidshard/mimage/mimagetest"
+)
+
+func TestStorage(t *testing.T) {
+	store := mimagetest.NewMemStore()
+	m, err := mimage.New(image.Rect(0, 0, 100, 100), mimage.Storage(store), mimage.ChunkSize(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Directory() != "" {
+		t.Errorf("image kept in a store has directory %q", m.Directory())
+	}
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.DrawRectangle(40, 40, 20, 20)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := mimage.LoadStorage(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+
+	// sizes come from the store, not the disk
+	plan := loaded.Draw()
+	plan.DrawRectangle(40, 40, 5, 5)
+	plan.Fill()
+	if p := plan.Plan(); p.ChunkLoads != 1 || p.BytesRead == 0 {
+		t.Errorf("plan reads %d chunks of %d bytes, want 1 chunk from the store", p.ChunkLoads, p.BytesRead)
+	}
+
+	if got := loaded.At(50, 50); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("reloaded pixel is %v, want white", got)
+	}
+	if got := loaded.At(10, 10); got != (color.RGBA{}) {
+		t.Errorf("reloaded pixel is %v, want it untouched", got)
+	}
+
+	if _, err := mimage.New(image.Rect(0, 0, 10, 10), mimage.Storage(nil)); err == nil {
+		t.Error("nil storage got no error")
+	}
+}
+
+func TestMaxChunks(t *testing.T) {
+	before := runtime.NumGoroutine()
+	m := newImage(t, image.Rect(0, 0, 128, 128), mimage.ChunkSize(32), mimage.MaxChunks(2))
+
+	red := color.RGBA{255, 0, 0, 255}
+	op := m.Draw()
+	op.SetColor(red)
+	op.Clear()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 128; y += 32 {
+		for x := 0; x < 128; x += 32 {
+			if got := m.At(x+5, y+5); got != red {
+				t.Fatalf("pixel (%d,%d) is %v, want %v", x+5, y+5, got, red)
+			}
+		}
+	}
+	// chunks are unloaded as others are loaded, not by routines
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines running for 16 chunks, %d before", n, before)
+	}
+
+	if _, err := mimage.New(image.Rect(0, 0, 10, 10), mimage.MaxChunks(-1)); err == nil {
+		t.Error("negative max chunks got no error")
+	}
+}
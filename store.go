@@ -0,0 +1,170 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChunkStore is somewhere the files making up an Mimage (the metadata, chunks
+// & so on) are kept. Names are relative to the root of the image & slash
+// separated, as in the image's directory.
+type ChunkStore interface {
+	// Open returns the named file for reading, if it doesn't exist the
+	// error satisfies os.IsNotExist.
+	Open(name string) (io.ReadCloser, error)
+
+	// Create returns the named file for writing, replacing it if it exists.
+	Create(name string) (io.WriteCloser, error)
+
+	// List returns the names of all the files.
+	List() ([]string, error)
+}
+
+// dirStore is a ChunkStore of files in a local directory.
+type dirStore struct {
+	root string
+}
+
+// DirStore returns a ChunkStore of files in the given directory, eg. where an
+// Mimage lives.
+func DirStore(dir string) ChunkStore {
+	return &dirStore{root: dir}
+}
+
+// Open the named file within the directory.
+func (s *dirStore) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.root, filepath.FromSlash(name)))
+}
+
+// Create the named file within the directory, making subdirectories as needed.
+func (s *dirStore) Create(name string) (io.WriteCloser, error) {
+	path := filepath.Join(s.root, filepath.FromSlash(name))
+	err := os.MkdirAll(filepath.Dir(path), 0750)
+	if err != nil {
+		return nil, err
+	}
+	return os.Create(path)
+}
+
+// List every file within the directory.
+func (s *dirStore) List() ([]string, error) {
+	names := []string{}
+	err := filepath.Walk(s.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	return names, err
+}
+
+// httpStore is a read only ChunkStore of files served over HTTP.
+type httpStore struct {
+	base   string
+	client *http.Client
+}
+
+// HTTPStore returns a read only ChunkStore of files under the given URL, eg.
+// an Mimage directory published as is on a static web host, so that
+// "{base}/.mimage_metadata.json" & "{base}/0.0.png" can be fetched.
+//
+// Static hosts don't give out directory listings, so List isn't supported.
+func HTTPStore(base string) ChunkStore {
+	return &httpStore{base: strings.TrimSuffix(base, "/"), client: remoteClient}
+}
+
+// Open GETs the named file, a 404 means it doesn't exist.
+func (s *httpStore) Open(name string) (io.ReadCloser, error) {
+	url := s.base + "/" + name
+	resp, err := s.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, &os.PathError{Op: "open", Path: url, Err: os.ErrNotExist}
+	} else if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// Create isn't supported, the store is read only.
+func (s *httpStore) Create(name string) (io.WriteCloser, error) {
+	return nil, fmt.Errorf("http store %s is read only", s.base)
+}
+
+// List isn't supported, see HTTPStore.
+func (s *httpStore) List() ([]string, error) {
+	return nil, fmt.Errorf("http store %s can't be listed", s.base)
+}
+
+// LoadStore loads an Mimage kept in a ChunkStore (eg. HTTPStore), using the
+// given local directory as a cache. The metadata is copied into the directory
+// & chunks are fetched from the store the first time they're used (see
+// Remote), after which they're read from the directory.
+//
+// Anything drawn is only saved to the directory, the store isn't written to.
+// Loading the same store with the same directory again keeps the chunks
+// already fetched.
+func LoadStore(store ChunkStore, dir string) (*Mimage, error) {
+	err := os.MkdirAll(dir, 0750)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{metafile, iccfile} {
+		err = copyFromStore(store, name, filepath.Join(dir, name))
+		if os.IsNotExist(err) && name != metafile {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	m, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	m.SetRemote(m.storeFetcher(store))
+	return m, nil
+}
+
+// copyFromStore copies the named file from the store to the given path.
+func copyFromStore(store ChunkStore, name, path string) error {
+	data, err := readAll(store, name)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0640)
+}
+
+// storeFetcher returns a Fetcher that reads chunks from the store, decoding
+// them with the image's codec (see SetCodec).
+func (m *Mimage) storeFetcher(store ChunkStore) Fetcher {
+	return func(r image.Rectangle) (image.Image, error) {
+		x, y := floorDiv(r.Min.X, r.Dx()), floorDiv(r.Min.Y, r.Dy())
+		f, err := store.Open(chunkName(x, y))
+		if os.IsNotExist(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return chunkCodec(m.codec).Decode(f, x, y)
+	}
+}
@@ -0,0 +1,236 @@
+package mimage_test
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/voidshard/mimage"
+	"github.com/voidshard/mimage/mimagetest"
+)
+
+// roundTrip writes files to the store, reads them back & lists them.
+func roundTrip(t *testing.T, store mimage.ChunkStore) {
+	t.Helper()
+	files := map[string]string{"a.png": "chunk a", "b/c.png": "chunk c"}
+	for name, data := range files {
+		w, err := store.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = w.Write([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = w.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, want := range files {
+		r, err := store.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("read %q from %s, wrote %q", got, name, want)
+		}
+	}
+	names, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.png", "b/c.png"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("listed %v, want %v", names, want)
+	}
+
+	_, err = store.Open("missing.png")
+	if !os.IsNotExist(err) {
+		t.Errorf("opening a missing file got %v, want it not to exist", err)
+	}
+}
+
+func TestDirStore(t *testing.T) {
+	roundTrip(t, mimage.DirStore(t.TempDir()))
+}
+
+func TestMemStore(t *testing.T) {
+	roundTrip(t, mimagetest.NewMemStore())
+}
+
+func TestLoadStore(t *testing.T) {
+	// an image published as is on a web server
+	published := t.TempDir()
+	m, err := mimage.New(image.Rect(0, 0, 64, 64), mimage.Directory(published), mimage.ChunkSize(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.DrawRectangle(40, 40, 10, 10)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := int32(0)
+	files := http.FileServer(http.Dir(published))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		files.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	store := mimage.HTTPStore(srv.URL + "/")
+	if _, err := store.Create("0.0.png"); err == nil {
+		t.Error("writing to an http store got no error")
+	}
+	if _, err := store.Open("9.9.png"); !os.IsNotExist(err) {
+		t.Errorf("opening a missing file got %v, want it not to exist", err)
+	}
+
+	local := t.TempDir()
+	loaded, err := mimage.LoadStore(store, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Bounds() != image.Rect(0, 0, 64, 64) {
+		t.Errorf("loaded bounds %v", loaded.Bounds())
+	}
+	if got := loaded.At(45, 45); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("fetched pixel is %v, want white", got)
+	}
+	if got := loaded.At(10, 10); got != (color.RGBA{}) {
+		t.Errorf("fetched pixel is %v, want transparent", got)
+	}
+	err = loaded.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// fetched chunks are kept locally
+	before := atomic.LoadInt32(&requests)
+	again, err := mimage.LoadStore(store, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer again.Close()
+	again.At(45, 45)
+	if n := atomic.LoadInt32(&requests) - before; n > 2 {
+		t.Errorf("reloading made %d requests, want only the metadata", n)
+	}
+}
+
+// fakeGCS serves the parts of the GCS XML & JSON APIs GCSStore uses.
+type fakeGCS struct {
+	lock    sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if strings.HasPrefix(r.URL.Path, "/storage/v1/b/bucket/o") {
+		names := []string{}
+		for name := range f.objects {
+			if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names) // as GCS lists them
+		items := []map[string]string{}
+		for _, name := range names {
+			items = append(items, map[string]string{"name": name})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := ioutil.ReadAll(r.Body)
+		f.objects[name] = data
+	case http.MethodGet:
+		data, ok := f.objects[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}
+}
+
+func TestGCSStore(t *testing.T) {
+	srv := httptest.NewServer(&fakeGCS{objects: map[string][]byte{}})
+	defer srv.Close()
+
+	store, err := mimage.GCSStore(srv.Client(), "bucket", mimage.StoreEndpoint(srv.URL), mimage.StorePrefix("renders/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, store)
+}
+
+func TestCloudStoreErrors(t *testing.T) {
+	_, err := mimage.GCSStore(http.DefaultClient, "")
+	if err == nil {
+		t.Error("GCSStore without a bucket got no error")
+	}
+	_, err = mimage.GCSStore(http.DefaultClient, "bucket", mimage.StoreEndpoint("localhost:4443"))
+	if err == nil {
+		t.Error("GCSStore with an endpoint without a scheme got no error")
+	}
+	_, err = mimage.AzureBlobStore(http.DefaultClient, "renders?sv=1")
+	if err == nil {
+		t.Error("AzureBlobStore with a relative URL got no error")
+	}
+}
+
+func TestStorageFaults(t *testing.T) {
+	store := mimagetest.NewFaultStore(nil)
+	m := mimagetest.NewImage(t, image.Rect(0, 0, 64, 64), mimage.Storage(store), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.Clear()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broken := errors.New("disk on fire")
+	store.FailNext(mimagetest.OpCreate, broken)
+	err = m.Flush()
+	if !errors.Is(err, broken) {
+		t.Errorf("flushing got %v, want %v", err, broken)
+	}
+	err = m.Flush()
+	if err != nil {
+		t.Errorf("flushing once the store is fixed got %v", err)
+	}
+	if got := m.At(10, 10); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("pixel after a failed flush is %v, want white", got)
+	}
+}
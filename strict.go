@@ -0,0 +1,68 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// StrictBounds has Do() refuse operations reaching outside the image's
+// bounds with a *BoundsError, rather than drawing what falls within them,
+// to catch mistakes in coordinate math early. What an operation reaches is
+// the extent of everything queued on it, including the size of pixels,
+// images & stamps drawn & half the width of lines stroked, at each offset
+// it's repeated at.
+//
+// Toroidal & unbounded images have no edges to reach past, so can't be
+// strict.
+func StrictBounds() Option {
+	return func(m *Mimage) error {
+		m.strictBounds = true
+		return nil
+	}
+}
+
+// BoundsError is returned by Do() for operations reaching outside the bounds
+// of a strict image (see StrictBounds).
+type BoundsError struct {
+	// Area the operation reaches, at the offset (see Repeat) that reaches
+	// furthest out.
+	Area image.Rectangle
+
+	// Bounds of the image.
+	Bounds image.Rectangle
+}
+
+// Error describes how far out of bounds the operation reaches.
+func (e *BoundsError) Error() string {
+	return fmt.Sprintf("operation reaches %v, outside the image bounds %v", e.Area, e.Bounds)
+}
+
+// checkBounds returns a *BoundsError if the operation reaches outside the
+// image's bounds.
+func (o *operation) checkBounds() error {
+	if o.minX > o.maxX || o.minY > o.maxY {
+		return nil // nothing is drawn
+	}
+	pad := o.strokeWidth / 2 // lines are stroked either side of the path
+	area := image.Rect(
+		int(math.Floor(o.minX-pad)),
+		int(math.Floor(o.minY-pad)),
+		int(math.Ceil(o.maxX+pad)),
+		int(math.Ceil(o.maxY+pad)),
+	)
+	shifts := o.repeats
+	if shifts == nil {
+		shifts = []image.Point{{}}
+	}
+
+	bounds := o.parent.Bounds()
+	for _, shift := range shifts {
+		r := area.Add(shift)
+		// r may be empty (eg. a path only filled), so compare each edge
+		if r.Min.X < bounds.Min.X || r.Min.Y < bounds.Min.Y || r.Max.X > bounds.Max.X || r.Max.Y > bounds.Max.Y {
+			return &BoundsError{Area: r, Bounds: bounds}
+		}
+	}
+	return nil
+}
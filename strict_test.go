@@ -0,0 +1,110 @@
+package mimage_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+	"github.com/voidshard/mimage/mimagetest"
+)
+
+func TestStrictBounds(t *testing.T) {
+	cases := []struct {
+		name    string
+		draw    func(op mimage.Operation)
+		outside bool
+	}{
+		{"last pixel", func(op mimage.Operation) { op.SetPixel(99, 49) }, false},
+		{"pixel right of the edge", func(op mimage.Operation) { op.SetPixel(100, 5) }, true},
+		{"pixel below the edge", func(op mimage.Operation) { op.SetPixel(5, 50) }, true},
+		{"pixel left of the edge", func(op mimage.Operation) { op.SetPixel(-1, 5) }, true},
+		{"whole image filled", func(op mimage.Operation) {
+			op.SetLineWidth(10) // only strokes reach further
+			op.DrawRectangle(0, 0, 100, 50)
+			op.Fill()
+		}, false},
+		{"rectangle past the edge", func(op mimage.Operation) {
+			op.DrawRectangle(60, 10, 50, 10)
+			op.Fill()
+		}, true},
+		{"stroke within", func(op mimage.Operation) {
+			op.SetLineWidth(4)
+			op.MoveTo(2, 25)
+			op.LineTo(98, 25)
+			op.Stroke()
+		}, false},
+		{"stroke wider than its margin", func(op mimage.Operation) {
+			op.SetLineWidth(6)
+			op.MoveTo(2, 25)
+			op.LineTo(98, 25)
+			op.Stroke()
+		}, true},
+		{"repeated off the image", func(op mimage.Operation) {
+			op.SetPixel(10, 10)
+			op.Repeat([]image.Point{{X: 95}})
+		}, true},
+		{"cleared", func(op mimage.Operation) { op.Clear() }, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := mimagetest.NewImage(t, image.Rect(0, 0, 100, 50), mimage.StrictBounds(), mimage.ChunkSize(32))
+			op := m.Draw()
+			op.SetColor(color.White)
+			c.draw(op)
+			err := op.Do()
+
+			bounds := &mimage.BoundsError{}
+			if c.outside && !errors.As(err, &bounds) {
+				t.Fatalf("got %v, want a *BoundsError", err)
+			}
+			if !c.outside && err != nil {
+				t.Fatalf("got %v, want no error", err)
+			}
+			if c.outside && m.At(10, 10) != (color.RGBA{}) {
+				t.Errorf("drew %v, want nothing drawn", m.At(10, 10))
+			}
+		})
+	}
+}
+
+func TestStrictBoundsAgain(t *testing.T) {
+	m := mimagetest.NewImage(t, image.Rect(0, 0, 100, 50), mimage.StrictBounds(), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.SetLineWidth(6)
+	op.MoveTo(10, 25)
+	op.LineTo(90, 25)
+	op.Stroke()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the stroke was drawn, so doesn't widen what's drawn next
+	op.DrawRectangle(0, 0, 100, 50)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Errorf("filling the image after a stroke got %v", err)
+	}
+
+	// previews are as strict as the image
+	op = m.Draw()
+	op.SetPixel(100, 5)
+	if _, err := m.Preview(op); !errors.As(err, new(*mimage.BoundsError)) {
+		t.Errorf("previewing off the image got %v, want a *BoundsError", err)
+	}
+}
+
+func TestStrictBoundsConflicts(t *testing.T) {
+	for _, opt := range []mimage.Option{mimage.Toroidal(), mimage.Unbounded()} {
+		_, err := mimage.New(image.Rect(0, 0, 10, 10), mimage.Directory(t.TempDir()), mimage.StrictBounds(), opt)
+		conflict := &mimage.OptionError{}
+		if !errors.As(err, &conflict) || conflict.Option != "StrictBounds" {
+			t.Errorf("got %v, want an *OptionError for StrictBounds", err)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sync"
+)
+
+// StrokeSession draws a brush stroke as it's made, eg. following the mouse in
+// an editor. Each point added is drawn straight away, only the chunks around
+// the newest part of the stroke being redrawn, & chunks are left in memory
+// to be written out as usual (see Flush) rather than saved every point.
+//
+// The chunks the stroke touches are kept as they were before it, so the
+// whole stroke can be undone in one go, even after it's committed. That
+// means a session holds a copy of every chunk it has touched until it's
+// undone or dropped.
+type StrokeSession struct {
+	m     *Mimage
+	color color.Color
+	width float64
+
+	lock      *sync.Mutex
+	points    []Point
+	changed   image.Rectangle
+	committed bool
+
+	beforeLock *sync.Mutex
+	before     map[[2]int]*chunkCopy
+}
+
+// chunkCopy is a chunk as it was, with its straight alpha copy if it has one.
+type chunkCopy struct {
+	img      *image.RGBA
+	straight *image.NRGBA
+}
+
+// BeginStroke starts a brush stroke of the given color & width (see
+// StrokeSession), with round ends & joins.
+func (m *Mimage) BeginStroke(c color.Color, width float64) *StrokeSession {
+	return &StrokeSession{
+		m:          m,
+		color:      c,
+		width:      width,
+		lock:       &sync.Mutex{},
+		beforeLock: &sync.Mutex{},
+		before:     map[[2]int]*chunkCopy{},
+	}
+}
+
+// Add extends the stroke to (x,y) in world space, drawing it.
+func (s *StrokeSession) Add(x, y float64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.committed {
+		return fmt.Errorf("stroke has already been committed")
+	}
+
+	p := Point{X: x, Y: y}
+	from := p
+	if len(s.points) > 0 {
+		from = s.points[len(s.points)-1]
+	}
+	s.points = append(s.points, p)
+	return s.redraw(strokeArea([]Point{from, p}, s.width))
+}
+
+// Points returns the points of the stroke so far.
+func (s *StrokeSession) Points() []Point {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Point{}, s.points...)
+}
+
+// Bounds returns the area (in world space) the stroke has changed, eg. for
+// working out which tiles to refresh.
+func (s *StrokeSession) Bounds() image.Rectangle {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.changed
+}
+
+// Commit finishes the stroke, after which no more points can be added. It
+// can still be undone.
+func (s *StrokeSession) Commit() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.committed = true
+	return nil
+}
+
+// Undo puts every chunk the stroke touched back as it was before the stroke,
+// & finishes it. Anything drawn on those chunks since the stroke was started
+// is undone too. Unbounded images don't shrink back.
+func (s *StrokeSession) Undo() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.committed = true
+	s.beforeLock.Lock()
+	defer s.beforeLock.Unlock()
+
+	for key, b := range s.before {
+		s.m.cache.Replace(key[0], key[1], b.img, b.straight).Done()
+		delete(s.before, key)
+	}
+	return nil
+}
+
+// redraw draws the stroke again on the chunks within r (in world space),
+// starting from the chunks as they were before the stroke, so that where it
+// crosses itself isn't drawn twice.
+func (s *StrokeSession) redraw(r image.Rectangle) error {
+	shifts := s.m.wraps(strokeArea(s.points, s.width))
+
+	for _, d := range s.m.wraps(r) {
+		area := s.m.drawable(r.Add(d))
+		if area.Empty() {
+			continue
+		}
+		err := s.m.grow(area)
+		if err != nil {
+			return err
+		}
+		s.changed = s.changed.Union(area)
+
+		err = s.m.eachChunk(area, func(ctx *context) error {
+			s.restore(ctx)
+			min := s.m.chunkBounds(ctx.X, ctx.Y).Min
+			for _, shift := range shifts {
+				s.render(ctx.Img, min.Sub(shift))
+			}
+			ctx.setEdited()
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restore puts the chunk back as it was before the stroke, keeping a copy of
+// it the first time it's touched.
+func (s *StrokeSession) restore(ctx *context) {
+	img := ctx.Img.Image().(*image.RGBA)
+	key := [2]int{ctx.X, ctx.Y}
+
+	s.beforeLock.Lock()
+	b, ok := s.before[key]
+	if !ok {
+		b = &chunkCopy{img: image.NewRGBA(img.Rect)}
+		copy(b.img.Pix, img.Pix)
+		if ctx.straight != nil {
+			b.straight = image.NewNRGBA(ctx.straight.Rect)
+			copy(b.straight.Pix, ctx.straight.Pix)
+		}
+		s.before[key] = b
+	}
+	s.beforeLock.Unlock()
+
+	copy(img.Pix, b.img.Pix)
+	if ctx.straight != nil && b.straight != nil {
+		copy(ctx.straight.Pix, b.straight.Pix)
+	}
+}
+
+// render draws the whole stroke onto a chunk, whose top left is at (in the
+// stroke's world space) the given point.
+func (s *StrokeSession) render(c Canvas, at image.Point) {
+	ox, oy := float64(at.X), float64(at.Y)
+
+	c.Push()
+	defer c.Pop()
+	c.ResetClip()
+	c.ClearPath()
+	c.SetColor(s.color)
+	c.SetLineWidth(s.width)
+
+	if len(s.points) == 1 { // a dab
+		p := s.points[0]
+		c.DrawEllipse(p.X-ox, p.Y-oy, s.width/2, s.width/2)
+		c.Fill()
+		return
+	}
+	c.MoveTo(s.points[0].X-ox, s.points[0].Y-oy)
+	for _, p := range s.points[1:] {
+		c.LineTo(p.X-ox, p.Y-oy)
+	}
+	c.Stroke()
+}
+
+// strokeArea returns the area (in world space) a stroke through the given
+// points of the given width may draw on.
+func strokeArea(points []Point, width float64) image.Rectangle {
+	if len(points) == 0 {
+		return image.Rectangle{}
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range points {
+		minX, minY = math.Min(minX, p.X), math.Min(minY, p.Y)
+		maxX, maxY = math.Max(maxX, p.X), math.Max(maxY, p.Y)
+	}
+	pad := width/2 + 1
+	return image.Rect(
+		int(math.Floor(minX-pad)),
+		int(math.Floor(minY-pad)),
+		int(math.Ceil(maxX+pad)),
+		int(math.Ceil(maxY+pad)),
+	)
+}
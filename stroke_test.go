@@ -0,0 +1,63 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestStrokeSession(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 96, 96), mimage.ChunkSize(32))
+	red := color.RGBA{255, 0, 0, 255}
+	op := m.Draw()
+	op.SetColor(red)
+	op.Clear()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	half := color.NRGBA{0, 0, 255, 128}
+	s := m.BeginStroke(half, 6)
+	// across chunks & back over itself
+	for _, p := range []mimage.Point{{X: 10, Y: 50}, {X: 80, Y: 50}, {X: 80, Y: 20}, {X: 40, Y: 20}, {X: 40, Y: 80}} {
+		err := s.Add(p.X, p.Y)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(s.Points()); n != 5 {
+		t.Errorf("stroke has %d points, want 5", n)
+	}
+	if b := s.Bounds(); !image.Rect(10, 20, 80, 80).In(b) {
+		t.Errorf("stroke changed %v, want at least (10,20)-(80,80)", b)
+	}
+
+	// where the stroke crosses itself it's drawn once, not twice
+	once, crossed := m.At(60, 50).(color.RGBA), m.At(40, 50).(color.RGBA)
+	if once == red || once != crossed {
+		t.Errorf("stroke is %v, %v where it crosses itself", once, crossed)
+	}
+	if got := m.At(20, 80); got != red {
+		t.Errorf("pixel off the stroke is %v, want %v", got, red)
+	}
+
+	err = s.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(0, 0); err == nil {
+		t.Error("adding to a committed stroke got no error")
+	}
+	err = s.Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pt := range []image.Point{{60, 50}, {40, 50}, {80, 30}} {
+		if got := m.At(pt.X, pt.Y); got != red {
+			t.Errorf("pixel %v after undoing is %v, want %v", pt, got, red)
+		}
+	}
+}
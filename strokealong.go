@@ -0,0 +1,173 @@
+package mimage
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// strokeLine is a line of the path given to StrokeAlong, with the distance
+// along it to each point.
+type strokeLine struct {
+	points []Point
+	along  []float64
+}
+
+// strokeAlong is a queued StrokeAlong call.
+type strokeAlong struct {
+	lines []strokeLine
+	table [256]color.RGBA // premultiplied colors from start to end of a line
+}
+
+// StrokeAlong strokes the current path like Stroke, but colored by how far
+// along each line (MoveTo, LineTo, DrawPolyline, DrawRectangle ..) it is
+// rather than where it is, eg. for flow maps or trails fading out over many
+// chunks. Like GradientMap, g is looked up from (0,0) at the start of each
+// line to (255,0) at its end, so should run from (0,0) to (255,0), eg.
+//
+//	g := gg.NewLinearGradient(0, 0, 255, 0)
+//	g.AddColorStop(0, color.RGBA{255, 255, 0, 0}) // faded out
+//	g.AddColorStop(1, color.RGBA{255, 255, 0, 255})
+//
+// Lines are drawn with round ends & joins at the current line width, within
+// the current mask, but rotations (see RotateAbout) don't apply.
+func (o *operation) StrokeAlong(g Gradient) {
+	s := &strokeAlong{lines: o.pendingLines()}
+
+	// gradients are sampled once up front, so g needn't be safe to use from
+	// many routines
+	for i := range s.table {
+		c := color.NRGBAModel.Convert(g.ColorAt(i, 0)).(color.NRGBA)
+		s.table[i] = premultiply(c)
+	}
+	o.strokeWidth = math.Max(o.strokeWidth, math.Max(1, o.maxlineWidth))
+	o.queue.pushArgs(strokeAlongPath, []interface{}{s})
+}
+
+// pendingLines returns the lines (in world space) of the current path, that
+// is everything queued since it was last filled or stroked.
+func (o *operation) pendingLines() []strokeLine {
+	lines := [][]Point{}
+	extend := func(p Point) {
+		if len(lines) == 0 {
+			lines = append(lines, []Point{})
+		}
+		lines[len(lines)-1] = append(lines[len(lines)-1], p)
+	}
+
+	for p := (cursor{}); p.fn < o.queue.len(); {
+		var action command
+		action, p = o.queue.at(p)
+		switch action.Func {
+		case fill, stroke, strokeAlongPath:
+			lines = lines[:0]
+		case moveTo:
+			lines = append(lines, []Point{{X: action.Nums[0], Y: action.Nums[1]}})
+		case lineTo:
+			extend(Point{X: action.Nums[0], Y: action.Nums[1]})
+		case closePath:
+			if len(lines) > 0 && len(lines[len(lines)-1]) > 0 {
+				extend(lines[len(lines)-1][0])
+			}
+		case drawPolygon, drawPolyline:
+			points := action.Args[0].([]Point)
+			lines = append(lines, append([]Point{}, points...))
+			if action.Func == drawPolygon {
+				extend(points[0])
+			}
+		case drawRectangle:
+			x, y, w, h := action.Nums[0], action.Nums[1], action.Nums[2], action.Nums[3]
+			lines = append(lines, []Point{{X: x, Y: y}, {X: x + w, Y: y}, {X: x + w, Y: y + h}, {X: x, Y: y + h}, {X: x, Y: y}})
+		case drawEllipse:
+			points := ellipsePoints(action.Nums[0], action.Nums[1], action.Nums[2], action.Nums[3])
+			lines = append(lines, append(points, points[0]))
+		}
+	}
+
+	out := make([]strokeLine, 0, len(lines))
+	for _, points := range lines {
+		along := make([]float64, len(points))
+		for i := 1; i < len(points); i++ {
+			along[i] = along[i-1] + math.Hypot(points[i].X-points[i-1].X, points[i].Y-points[i-1].Y)
+		}
+		out = append(out, strokeLine{points: points, along: along})
+	}
+	return out
+}
+
+// render draws the lines onto a chunk with the given line width, offset
+// (world space to chunk space) & mask (if any), returning if anything was
+// drawn. Each pixel takes its color from the nearest point of any line.
+func (s *strokeAlong) render(img *image.RGBA, width float64, mask *image.Alpha, offX, offY float64) bool {
+	half := width / 2
+	b := img.Bounds()
+	w := b.Dx()
+	var nearest, at []float64 // distance to the nearest line & how far along it (0-1)
+
+	for _, line := range s.lines {
+		total := line.along[len(line.along)-1]
+		for i := 1; i < len(line.points); i++ {
+			a, c := line.points[i-1], line.points[i]
+			ax, ay, cx, cy := a.X-offX, a.Y-offY, c.X-offX, c.Y-offY
+			r := image.Rect(
+				int(math.Floor(math.Min(ax, cx)-half-1)),
+				int(math.Floor(math.Min(ay, cy)-half-1)),
+				int(math.Ceil(math.Max(ax, cx)+half+1)),
+				int(math.Ceil(math.Max(ay, cy)+half+1)),
+			).Intersect(b)
+			if r.Empty() {
+				continue
+			}
+			if nearest == nil {
+				nearest, at = make([]float64, w*b.Dy()), make([]float64, w*b.Dy())
+				for j := range nearest {
+					nearest[j] = math.Inf(1)
+				}
+			}
+
+			dx, dy := cx-ax, cy-ay
+			length2 := dx*dx + dy*dy
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					px, py := float64(x)+0.5-ax, float64(y)+0.5-ay
+					u := 0.0
+					if length2 > 0 {
+						u = math.Max(0, math.Min(1, (px*dx+py*dy)/length2))
+					}
+					d := math.Hypot(px-u*dx, py-u*dy)
+					j := (y-b.Min.Y)*w + x - b.Min.X
+					if d < nearest[j] {
+						nearest[j] = d
+						at[j] = 0
+						if total > 0 {
+							at[j] = (line.along[i-1] + u*(line.along[i]-line.along[i-1])) / total
+						}
+					}
+				}
+			}
+		}
+	}
+	if nearest == nil {
+		return false
+	}
+
+	drawn := false
+	for j, d := range nearest {
+		cover := math.Min(1, half+0.5-d)
+		if cover <= 0 {
+			continue
+		}
+		x, y := b.Min.X+j%w, b.Min.Y+j/w
+		if mask != nil {
+			cover *= float64(mask.AlphaAt(x, y).A) / 0xff
+		}
+		src := s.table[clampInt(int(math.Round(at[j]*255)), 0, 255)]
+		sa := float64(src.A) * cover
+		p := img.Pix[img.PixOffset(x, y):]
+		for k, v := range []uint8{src.R, src.G, src.B, src.A} {
+			p[k] = uint8(math.Round(float64(v)*cover + float64(p[k])*(1-sa/0xff)))
+		}
+		drawn = true
+	}
+	return drawn
+}
@@ -0,0 +1,52 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/fogleman/gg"
+	"github.com/voidshard/mimage"
+)
+
+func TestStrokeAlong(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 96, 96), mimage.ChunkSize(32))
+	g := gg.NewLinearGradient(0, 0, 255, 0)
+	g.AddColorStop(0, color.RGBA{255, 0, 0, 255})
+	g.AddColorStop(1, color.RGBA{0, 0, 255, 255})
+
+	// an L over three chunks, colored by how far along it is
+	op := m.Draw()
+	op.MoveTo(8, 48.5)
+	op.LineTo(88.5, 48.5)
+	op.LineTo(88.5, 88)
+	op.StrokeAlong(g)
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start, corner, end := m.At(8, 48).(color.RGBA), m.At(88, 48).(color.RGBA), m.At(88, 87).(color.RGBA)
+	if start.R < 250 || end.B < 250 || corner.R < 50 || corner.B < 50 {
+		t.Errorf("line is %v, %v & %v at the start, corner & end, want red to blue", start, corner, end)
+	}
+	// the gradient carries on across chunks
+	a, b := m.At(31, 48).(color.RGBA), m.At(32, 48).(color.RGBA)
+	if absDiff(a.R, b.R) > 4 || absDiff(a.B, b.B) > 4 {
+		t.Errorf("line is %v & %v either side of the seam between chunks", a, b)
+	}
+	if got := m.At(48, 60); got != (color.RGBA{}) {
+		t.Errorf("pixel off the line is %v, want it untouched", got)
+	}
+
+	// the path is used up, so stroking again draws nothing more
+	op.SetColor(color.White)
+	op.Stroke()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.At(48, 48); got == (color.RGBA{255, 255, 255, 255}) {
+		t.Error("path stroked along was stroked again")
+	}
+}
@@ -0,0 +1,168 @@
+package mimage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+)
+
+// checksumfile is kept in the destination of Sync, recording what was copied
+const checksumfile = ".mimage_checksums.json"
+
+// Sync copies the files of the Mimage in src to dst, skipping those that are
+// unchanged since the last Sync to dst, so an image can be pushed somewhere
+// (eg. a GCSStore) again & again cheaply. The metadata is always copied, last,
+// after the chunks.
+//
+// Checksums of what was copied are kept in dst, so only src is read to find
+// what has changed. Files removed from src aren't removed from dst.
+//
+// If src is the directory of an Mimage in use, call Flush first so all chunks
+// are on disk. Sync returns how many files were copied.
+func Sync(src, dst ChunkStore) (int, error) {
+	names, err := src.List()
+	if err != nil {
+		return 0, err
+	}
+	sort.Strings(names)
+	found := false
+	for _, name := range names {
+		if name == metafile {
+			found = true
+		}
+	}
+	if !found {
+		return 0, fmt.Errorf("source doesn't contain an mimage, %s not found", metafile)
+	}
+
+	old, err := readChecksums(dst)
+	if err != nil {
+		return 0, err
+	}
+
+	// hash & copy everything but the metadata in parallel
+	type result struct {
+		name, sum string
+		copied    bool
+		err       error
+	}
+	work := make(chan string)
+	results := make(chan result)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < defaultStoreConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range work {
+				sum, copied, err := syncFile(src, dst, name, old[name])
+				results <- result{name: name, sum: sum, copied: copied, err: err}
+			}
+		}()
+	}
+	go func() {
+		for _, name := range names {
+			if name != metafile && name != checksumfile {
+				work <- name
+			}
+		}
+		close(work)
+		wg.Wait()
+		close(results)
+	}()
+
+	sums := map[string]string{}
+	copied := 0
+	for r := range results {
+		if r.err != nil {
+			if err == nil {
+				err = r.err
+			}
+			continue
+		}
+		sums[r.name] = r.sum
+		if r.copied {
+			copied++
+		}
+	}
+	if err != nil {
+		return copied, err
+	}
+
+	_, _, err = syncFile(src, dst, metafile, "")
+	if err != nil {
+		return copied, err
+	}
+	copied++
+	return copied, writeChecksums(dst, sums)
+}
+
+// syncFile copies the named file from src to dst if its checksum isn't the
+// given one, returning the checksum & if it was copied.
+func syncFile(src, dst ChunkStore, name, oldSum string) (string, bool, error) {
+	data, err := readAll(src, name)
+	if err != nil {
+		return "", false, err
+	}
+	sum := checksum(data)
+	if sum == oldSum {
+		return sum, false, nil
+	}
+	return sum, true, writeAll(dst, name, data)
+}
+
+// checksum returns the hex SHA-256 of data.
+func checksum(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// readChecksums returns the checksums kept in the store, or none if there
+// aren't any.
+func readChecksums(store ChunkStore) (map[string]string, error) {
+	data, err := readAll(store, checksumfile)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	sums := map[string]string{}
+	return sums, json.Unmarshal(data, &sums)
+}
+
+// writeChecksums saves the checksums to the store.
+func writeChecksums(store ChunkStore, sums map[string]string) error {
+	data, err := json.Marshal(sums)
+	if err != nil {
+		return err
+	}
+	return writeAll(store, checksumfile, data)
+}
+
+// readAll returns the contents of the named file in the store.
+func readAll(store ChunkStore, name string) ([]byte, error) {
+	r, err := store.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// writeAll replaces the named file in the store with data.
+func writeAll(store ChunkStore, name string, data []byte) error {
+	w, err := store.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	if err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,77 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestSync(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.DrawRectangle(0, 0, 64, 20)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := mimage.DirStore(m.Directory())
+	dst := t.TempDir()
+	n, err := mimage.Sync(src, mimage.DirStore(dst))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 { // two chunks & the metadata
+		t.Errorf("first sync copied %d files, want 3", n)
+	}
+
+	n, err = mimage.Sync(src, mimage.DirStore(dst))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("unchanged sync copied %d files, want only the metadata", n)
+	}
+
+	red := color.RGBA{255, 0, 0, 255}
+	op.SetColor(red)
+	op.DrawRectangle(40, 4, 4, 4)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err = mimage.Sync(src, mimage.DirStore(dst))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("sync after drawing on one chunk copied %d files, want 2", n)
+	}
+
+	copied, err := mimage.Load(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copied.Close()
+	if got := copied.At(40, 5); got != red {
+		t.Errorf("synced pixel is %v, want %v", got, red)
+	}
+
+	_, err = mimage.Sync(mimage.DirStore(t.TempDir()), mimage.DirStore(dst))
+	if err == nil {
+		t.Error("syncing from a store without an mimage got no error")
+	}
+}
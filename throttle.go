@@ -0,0 +1,111 @@
+package mimage
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// IOThrottle limits how fast chunks are read & written, all told, to about
+// bytesPerSec (of encoded chunk data), so long renders on shared disks or
+// network storage leave room for everyone else. Zero doesn't limit them.
+func IOThrottle(bytesPerSec int64) Option {
+	return func(m *Mimage) error {
+		if bytesPerSec < 0 {
+			return fmt.Errorf("io throttle must not be negative, given %d", bytesPerSec)
+		}
+		m.ioThrottle = bytesPerSec
+		return nil
+	}
+}
+
+// throttle hands out time to read or write at some rate, each caller being
+// given the time after the last.
+type throttle struct {
+	lock *sync.Mutex
+	rate float64 // bytes per second
+	next time.Time
+}
+
+// wait blocks until n bytes may be read or written.
+func (t *throttle) wait(n int) {
+	if n <= 0 {
+		return
+	}
+	t.lock.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	at := t.next
+	t.next = t.next.Add(time.Duration(float64(n) / t.rate * float64(time.Second)))
+	t.lock.Unlock()
+
+	time.Sleep(time.Until(at))
+}
+
+// throttledStore is a ChunkStore whose files are read & written no faster
+// than the throttle allows.
+type throttledStore struct {
+	ChunkStore
+	t *throttle
+}
+
+// Open the named file for reading.
+func (s *throttledStore) Open(name string) (io.ReadCloser, error) {
+	f, err := s.ChunkStore.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &throttledReader{ReadCloser: f, t: s.t}, nil
+}
+
+// Create the named file for writing.
+func (s *throttledStore) Create(name string) (io.WriteCloser, error) {
+	f, err := s.ChunkStore.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &throttledWriter{WriteCloser: f, t: s.t}, nil
+}
+
+// throttledReader waits its turn after each read.
+type throttledReader struct {
+	io.ReadCloser
+	t *throttle
+}
+
+// Read from the file.
+func (r *throttledReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.t.wait(n)
+	return n, err
+}
+
+// throttledWriter waits its turn before each write.
+type throttledWriter struct {
+	io.WriteCloser
+	t *throttle
+}
+
+// Write to the file.
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	w.t.wait(len(p))
+	return w.WriteCloser.Write(p)
+}
+
+// setThrottle limits chunks to being read & written at bytesPerSec, if it's
+// not zero. It's expected to be called after setStore, before any chunks are
+// loaded.
+func (c *cache) setThrottle(bytesPerSec int64) {
+	if bytesPerSec == 0 {
+		return
+	}
+	c.chunkLock.Lock()
+	defer c.chunkLock.Unlock()
+	c.store = &throttledStore{
+		ChunkStore: c.store,
+		t:          &throttle{lock: &sync.Mutex{}, rate: float64(bytesPerSec)},
+	}
+}
@@ -0,0 +1,63 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/voidshard/mimage"
+	"github.com/voidshard/mimage/mimagetest"
+)
+
+func TestIOThrottle(t *testing.T) {
+	const rate = 4000 // bytes per second
+	store := mimagetest.NewMemStore()
+	m, err := mimage.New(image.Rect(0, 0, 64, 64), mimage.Storage(store), mimage.ChunkSize(32), mimage.IOThrottle(rate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.DrawEllipse(32, 32, 30, 20)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	err = m.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	took := time.Since(start)
+
+	total := 0
+	names, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		f, err := store.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += len(data)
+	}
+	// the first write doesn't wait, so allow for it
+	if want := time.Duration(float64(total) / rate * float64(time.Second) / 2); took < want {
+		t.Errorf("writing %d bytes at %d a second took %v, want at least %v", total, rate, took, want)
+	}
+
+	if _, err := mimage.New(image.Rect(0, 0, 10, 10), mimage.IOThrottle(-1)); err == nil {
+		t.Error("negative throttle got no error")
+	}
+}
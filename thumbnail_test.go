@@ -0,0 +1,51 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestThumbnail(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 256, 128), mimage.ChunkSize(64))
+	if _, err := m.Thumbnail(0); err == nil {
+		t.Error("size 0 got no error")
+	}
+
+	op := m.Draw()
+	op.SetColor(color.RGBA{255, 0, 0, 255})
+	op.DrawRectangle(0, 0, 128, 128)
+	op.Fill()
+	op.SetColor(color.RGBA{0, 0, 255, 255})
+	op.DrawRectangle(128, 0, 128, 128)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thumb, err := m.Thumbnail(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thumb.Bounds() != image.Rect(0, 0, 32, 16) {
+		t.Fatalf("thumbnail bounds are %v, want 32x16", thumb.Bounds())
+	}
+	if got := thumb.At(4, 8); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("left of thumbnail is %v, want red", got)
+	}
+	if got := thumb.At(28, 8); got != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("right of thumbnail is %v, want blue", got)
+	}
+
+	// small images come back as they are
+	full, err := m.Thumbnail(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.Bounds() != image.Rect(0, 0, 256, 128) {
+		t.Errorf("thumbnail of a small image has bounds %v", full.Bounds())
+	}
+}
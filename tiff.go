@@ -0,0 +1,245 @@
+package mimage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"sort"
+)
+
+// TIFF field types & tags we use, see the TIFF 6.0 spec.
+const (
+	tiffShort    = 3
+	tiffLong     = 4
+	tiffRational = 5
+
+	tagImageWidth       = 256
+	tagImageLength      = 257
+	tagBitsPerSample    = 258
+	tagCompression      = 259
+	tagPhotometric      = 262
+	tagStripOffsets     = 273
+	tagSamplesPerPixel  = 277
+	tagRowsPerStrip     = 278
+	tagStripByteCounts  = 279
+	tagXResolution      = 282
+	tagYResolution      = 283
+	tagPlanarConfig     = 284
+	tagResolutionUnit   = 296
+	tagExtraSamples     = 338
+	tagSampleFormat     = 339
+	tiffSampleFloat     = 3
+	tiffSampleUint      = 1
+	tiffUnassocAlpha    = 2
+	tiffMaxClassicBytes = math.MaxUint32
+)
+
+// tiffStripBytes is roughly how large we make each strip of image data.
+const tiffStripBytes = 64 * 1024
+
+// tiffField is a single IFD entry.
+type tiffField struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte // little endian values
+}
+
+// shortField returns a field holding uint16 values.
+func shortField(tag uint16, values ...uint16) tiffField {
+	data := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint16(data[i*2:], v)
+	}
+	return tiffField{tag: tag, typ: tiffShort, count: uint32(len(values)), data: data}
+}
+
+// longField returns a field holding uint32 values.
+func longField(tag uint16, values ...uint32) tiffField {
+	data := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(data[i*4:], v)
+	}
+	return tiffField{tag: tag, typ: tiffLong, count: uint32(len(values)), data: data}
+}
+
+// rationalField returns a field holding a single num/den value.
+func rationalField(tag uint16, num, den uint32) tiffField {
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint32(data, num)
+	binary.LittleEndian.PutUint32(data[4:], den)
+	return tiffField{tag: tag, typ: tiffRational, count: 1, data: data}
+}
+
+// tiffSamples describes how pixels are laid out in a TIFF.
+type tiffSamples struct {
+	bits   uint16 // per sample, we always write RGBA samples
+	format uint16 // tiffSampleUint or tiffSampleFloat
+}
+
+// bytesPerPixel returns the size of one (RGBA) pixel.
+func (s tiffSamples) bytesPerPixel() int {
+	return int(s.bits) / 8 * 4
+}
+
+// writeTIFF writes a single image little endian, uncompressed TIFF of RGBA
+// (unassociated alpha) samples. Since the data isn't compressed we know where
+// everything goes up front, so the IFD is written first and the pixel data is
+// streamed after it, a row at a time, by calling row(y, buf).
+func writeTIFF(w io.Writer, width, height int, samples tiffSamples, extra []tiffField, row func(y int, buf []byte) error) error {
+	rowBytes := width * samples.bytesPerPixel()
+	total := int64(rowBytes) * int64(height)
+	if total > tiffMaxClassicBytes {
+		return fmt.Errorf("image of %d bytes is too large for a TIFF", total)
+	}
+
+	rowsPerStrip := maxInt(1, tiffStripBytes/maxInt(1, rowBytes))
+	strips := (height + rowsPerStrip - 1) / rowsPerStrip
+	offsets, counts := make([]uint32, strips), make([]uint32, strips)
+
+	fmts := make([]uint16, 4)
+	bits := make([]uint16, 4)
+	for i := range fmts {
+		fmts[i], bits[i] = samples.format, samples.bits
+	}
+
+	fields := append([]tiffField{
+		longField(tagImageWidth, uint32(width)),
+		longField(tagImageLength, uint32(height)),
+		shortField(tagBitsPerSample, bits...),
+		shortField(tagCompression, 1),
+		shortField(tagPhotometric, 2), // RGB
+		longField(tagStripOffsets, offsets...),
+		shortField(tagSamplesPerPixel, 4),
+		longField(tagRowsPerStrip, uint32(rowsPerStrip)),
+		longField(tagStripByteCounts, counts...),
+		shortField(tagPlanarConfig, 1),
+		shortField(tagExtraSamples, tiffUnassocAlpha),
+		shortField(tagSampleFormat, fmts...),
+	}, extra...)
+
+	// default to 72 dpi unless told otherwise
+	if !hasTIFFField(fields, tagXResolution) {
+		fields = append(fields,
+			rationalField(tagXResolution, 72, 1),
+			rationalField(tagYResolution, 72, 1),
+			shortField(tagResolutionUnit, 2),
+		)
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].tag < fields[j].tag })
+
+	// layout: header, IFD, out of line field data, pixel data
+	ifdSize := 2 + 12*len(fields) + 4
+	dataAt := uint32(8 + ifdSize)
+	for _, f := range fields {
+		if len(f.data) > 4 {
+			dataAt += uint32(len(f.data) + len(f.data)%2)
+		}
+	}
+	for i := range offsets {
+		offsets[i] = dataAt + uint32(i*rowsPerStrip*rowBytes)
+		counts[i] = uint32(minInt(rowsPerStrip, height-i*rowsPerStrip) * rowBytes)
+	}
+	for i, f := range fields { // refresh now that the offsets are known
+		switch f.tag {
+		case tagStripOffsets:
+			fields[i] = longField(tagStripOffsets, offsets...)
+		case tagStripByteCounts:
+			fields[i] = longField(tagStripByteCounts, counts...)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	le := binary.LittleEndian
+
+	header := []byte{'I', 'I', 42, 0, 8, 0, 0, 0}
+	bw.Write(header)
+
+	entry := make([]byte, 12)
+	le.PutUint16(entry, uint16(len(fields)))
+	bw.Write(entry[:2])
+	extAt := uint32(8 + ifdSize)
+	for _, f := range fields {
+		le.PutUint16(entry[0:], f.tag)
+		le.PutUint16(entry[2:], f.typ)
+		le.PutUint32(entry[4:], f.count)
+		if len(f.data) <= 4 {
+			copy(entry[8:], []byte{0, 0, 0, 0})
+			copy(entry[8:], f.data)
+		} else {
+			le.PutUint32(entry[8:], extAt)
+			extAt += uint32(len(f.data) + len(f.data)%2)
+		}
+		bw.Write(entry)
+	}
+	bw.Write([]byte{0, 0, 0, 0}) // no next IFD
+
+	for _, f := range fields {
+		if len(f.data) > 4 {
+			bw.Write(f.data)
+			if len(f.data)%2 == 1 {
+				bw.Write([]byte{0})
+			}
+		}
+	}
+
+	buf := make([]byte, rowBytes)
+	for y := 0; y < height; y++ {
+		err := row(y, buf)
+		if err != nil {
+			return err
+		}
+		_, err = bw.Write(buf)
+		if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// hasTIFFField returns if a field with the given tag is in the set.
+func hasTIFFField(fields []tiffField, tag uint16) bool {
+	for _, f := range fields {
+		if f.tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeTIFF writes img as a TIFF with 8 bit samples and alpha unassociated
+// (not premultiplied).
+func encodeTIFF(w io.Writer, img image.Image, extra ...tiffField) error {
+	b := img.Bounds()
+	return writeTIFF(w, b.Dx(), b.Dy(), tiffSamples{bits: 8, format: tiffSampleUint}, extra, func(y int, buf []byte) error {
+		for x := 0; x < b.Dx(); x++ {
+			c := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			buf[x*4], buf[x*4+1], buf[x*4+2], buf[x*4+3] = c.R, c.G, c.B, c.A
+		}
+		return nil
+	})
+}
+
+// encodeFloatTIFF writes img as a TIFF with 32 bit float samples, with colors
+// scaled to 0-1 and alpha unassociated (not premultiplied).
+func encodeFloatTIFF(w io.Writer, img image.Image, extra ...tiffField) error {
+	b := img.Bounds()
+	return writeTIFF(w, b.Dx(), b.Dy(), tiffSamples{bits: 32, format: tiffSampleFloat}, extra, func(y int, buf []byte) error {
+		for x := 0; x < b.Dx(); x++ {
+			r, g, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			px := [4]float32{}
+			if a > 0 {
+				px = [4]float32{float32(r) / float32(a), float32(g) / float32(a), float32(bl) / float32(a), float32(a) / 0xffff}
+			}
+			for i, v := range px {
+				binary.LittleEndian.PutUint32(buf[x*16+i*4:], math.Float32bits(v))
+			}
+		}
+		return nil
+	})
+}
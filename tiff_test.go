@@ -0,0 +1,77 @@
+package mimage_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+// tiffTags reads the (single) IFD of a little endian TIFF, returning the
+// value or offset of each tag.
+func tiffTags(t *testing.T, data []byte) map[uint16]uint32 {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte{'I', 'I', 42, 0}) {
+		t.Fatalf("not a little endian tiff: % x", data[:4])
+	}
+	le := binary.LittleEndian
+	at := le.Uint32(data[4:])
+	tags := map[uint16]uint32{}
+	for i := 0; i < int(le.Uint16(data[at:])); i++ {
+		e := data[at+2+uint32(i)*12:]
+		if le.Uint16(e[2:]) == 3 && le.Uint32(e[4:]) == 1 { // a single short
+			tags[le.Uint16(e)] = uint32(le.Uint16(e[8:]))
+		} else {
+			tags[le.Uint16(e)] = le.Uint32(e[8:])
+		}
+	}
+	return tags
+}
+
+func TestEncodeFloatTIFF(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(color.RGBA{0, 0, 128, 128})
+	op.Clear()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	err = m.EncodeRegion(image.Rect(16, 16, 48, 40), buf, mimage.FormatTIFFFloat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	tags := tiffTags(t, data)
+	for tag, want := range map[uint16]uint32{
+		256: 32, // width
+		257: 24, // height
+		277: 4,  // samples per pixel
+		259: 1,  // no compression
+		338: 2,  // unassociated alpha
+	} {
+		if tags[tag] != want {
+			t.Errorf("tag %d is %d, want %d", tag, tags[tag], want)
+		}
+	}
+	if want := 8 + 32*24*16; len(data) < want {
+		t.Fatalf("file is %d bytes, too small for the pixel data", len(data))
+	}
+
+	// the whole image fits one strip, so its only offset is inline
+	px := data[tags[273]:]
+	got := [4]float32{}
+	for i := range got {
+		got[i] = math.Float32frombits(binary.LittleEndian.Uint32(px[i*4:]))
+	}
+	if got[0] != 0 || got[1] != 0 || math.Abs(float64(got[2]-1)) > 0.01 || math.Abs(float64(got[3]-0.5)) > 0.01 {
+		t.Errorf("first pixel is %v, want straight blue at half alpha", got)
+	}
+}
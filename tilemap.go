@@ -0,0 +1,95 @@
+package mimage
+
+import (
+	"image"
+	"image/draw"
+)
+
+// subImager is implemented by most image types in the standard library.
+type subImager interface {
+	SubImage(r image.Rectangle) image.Image
+}
+
+// tilemap is a queued DrawTilemap call.
+type tilemap struct {
+	tileset      subImager
+	bounds       image.Rectangle
+	tileW, tileH int
+	perRow       int
+	indices      [][]int
+	x, y         int
+}
+
+// tile returns the i-th tile from the tileset.
+func (t *tilemap) tile(i int) image.Image {
+	col, row := i%t.perRow, i/t.perRow
+	min := t.bounds.Min.Add(image.Pt(col*t.tileW, row*t.tileH))
+	return t.tileset.SubImage(image.Rectangle{Min: min, Max: min.Add(image.Pt(t.tileW, t.tileH))})
+}
+
+// render draws only the tiles that overlap the context, where the context origin
+// is at (offX, offY) in world space. Returns if anything was drawn.
+func (t *tilemap) render(dc Canvas, offX, offY int) bool {
+	// first & last rows / cols that land on this chunk
+	c0 := floorDiv(offX-t.x, t.tileW)
+	r0 := floorDiv(offY-t.y, t.tileH)
+	c1 := floorDiv(offX+dc.Width()-t.x, t.tileW)
+	r1 := floorDiv(offY+dc.Height()-t.y, t.tileH)
+
+	drawn := false
+	for row := maxInt(0, r0); row <= r1 && row < len(t.indices); row++ {
+		for col := maxInt(0, c0); col <= c1 && col < len(t.indices[row]); col++ {
+			i := t.indices[row][col]
+			if i < 0 {
+				continue // empty tile
+			}
+			// gg draws images relative to their own bounds, so shift by the
+			// tile min to place it where we want
+			tile := t.tile(i)
+			min := tile.Bounds().Min
+			dc.DrawImage(tile, t.x+col*t.tileW-offX-min.X, t.y+row*t.tileH-offY-min.Y)
+			drawn = true
+		}
+	}
+
+	return drawn
+}
+
+// DrawTilemap draws a grid of tiles with the top left corner at (x,y). Tiles are
+// taken from the tileset, read left to right & top to bottom in tiles of
+// tileW x tileH. indices[row][col] gives the tile to draw at each grid location,
+// where a negative index means leave that location empty.
+//
+// Only tiles that overlap a given chunk are drawn onto it.
+func (o *operation) DrawTilemap(tileset image.Image, tileW, tileH int, indices [][]int, x, y int) {
+	bnds := tileset.Bounds()
+	if tileW <= 0 || tileH <= 0 || bnds.Dx() < tileW || bnds.Dy() < tileH {
+		return
+	}
+
+	sub, ok := tileset.(subImager)
+	if !ok {
+		rgba := image.NewRGBA(bnds)
+		draw.Draw(rgba, bnds, tileset, bnds.Min, draw.Src)
+		sub = rgba
+	}
+
+	cols := 0
+	for _, row := range indices {
+		cols = maxInt(cols, len(row))
+	}
+	o.minMax(float64(x), float64(y))
+	o.minMax(float64(x+cols*tileW), float64(y+len(indices)*tileH))
+
+	t := &tilemap{
+		tileset: sub,
+		bounds:  bnds,
+		tileW:   tileW,
+		tileH:   tileH,
+		perRow:  bnds.Dx() / tileW,
+		indices: indices,
+		x:       x,
+		y:       y,
+	}
+	o.queue.pushArgs(drawTilemap, []interface{}{t})
+}
@@ -0,0 +1,41 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestDrawTilemap(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 100, 100), mimage.ChunkSize(16))
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+
+	// two 8x8 tiles side by side, in a tileset that doesn't start at the origin
+	tileset := image.NewRGBA(image.Rect(10, 10, 26, 18))
+	draw.Draw(tileset, image.Rect(10, 10, 18, 18), image.NewUniform(red), image.Point{}, draw.Src)
+	draw.Draw(tileset, image.Rect(18, 10, 26, 18), image.NewUniform(blue), image.Point{}, draw.Src)
+
+	op := m.Draw()
+	op.DrawTilemap(tileset, 8, 8, [][]int{{0, 1, -1}, {1, 0}}, 20, 20)
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[image.Point]color.RGBA{
+		{20, 20}: red, {27, 27}: red, // straddling chunks
+		{28, 20}: blue, {35, 27}: blue,
+		{36, 20}: {}, // left empty
+		{20, 28}: blue, {28, 35}: red,
+		{36, 28}: {}, // past the end of the row
+		{19, 20}: {}, {20, 36}: {},
+	}
+	for p, c := range want {
+		if got := m.At(p.X, p.Y); got != c {
+			t.Errorf("pixel %v is %v, want %v", p, got, c)
+		}
+	}
+}
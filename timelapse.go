@@ -0,0 +1,375 @@
+package mimage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// timelapseDir holds captured frames, next to the metafile
+	timelapseDir = ".mimage_timelapse"
+
+	// defaultTimelapseSize is the longest side of frames if not given
+	defaultTimelapseSize = 512
+)
+
+// AnimationFormat is a file format for animations.
+type AnimationFormat int
+
+const (
+	AnimatedGIF AnimationFormat = iota
+	AnimatedPNG
+)
+
+// timelapse holds the state of frame capturing.
+type timelapse struct {
+	lock   *sync.Mutex
+	size   int         // longest side of frames
+	factor int         // world pixels per frame pixel
+	proxy  *image.RGBA // the last frame, kept up to date
+}
+
+// StartTimelapse begins capturing a small copy of the image (at most maxSize
+// pixels on its longest side, 512 if not given) after every Do(), which can be
+// exported as an animation with EncodeTimelapse. Frames are kept on disk with
+// the image, and capturing carries on if the image is loaded again.
+//
+// Only the area touched by each operation is re-read to update the frame.
+func (m *Mimage) StartTimelapse(maxSize int) error {
+	if maxSize <= 0 {
+		maxSize = defaultTimelapseSize
+	}
+	err := m.needsDirectory("a timelapse")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Join(m.root, timelapseDir), 0750)
+	if err != nil {
+		return err
+	}
+
+	m.metaLock.Lock()
+	defer m.metaLock.Unlock()
+
+	m.timelapse = newTimelapse(m.bounds, maxSize)
+	return m.writeMetadata()
+}
+
+// StopTimelapse stops capturing frames, frames already captured are kept
+// until ClearTimelapse is called.
+func (m *Mimage) StopTimelapse() error {
+	m.metaLock.Lock()
+	defer m.metaLock.Unlock()
+
+	m.timelapse = nil
+	return m.writeMetadata()
+}
+
+// ClearTimelapse deletes all captured frames.
+func (m *Mimage) ClearTimelapse() error {
+	tl := m.currentTimelapse()
+	if tl != nil {
+		tl.lock.Lock()
+		defer tl.lock.Unlock()
+		tl.proxy = nil // next capture is of the whole image
+	}
+
+	err := os.RemoveAll(filepath.Join(m.root, timelapseDir))
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(filepath.Join(m.root, timelapseDir), 0750)
+}
+
+// CaptureFrame captures a frame of the whole image now, whether or not a
+// timelapse is running. The frame size is that of the running timelapse, or
+// the default if there isn't one.
+func (m *Mimage) CaptureFrame() error {
+	tl := m.currentTimelapse()
+	if tl == nil {
+		tl = newTimelapse(m.bounds, defaultTimelapseSize)
+	}
+	return m.capture(tl, m.bounds)
+}
+
+// newTimelapse returns the state for capturing frames of an image.
+func newTimelapse(bounds image.Rectangle, size int) *timelapse {
+	longest := maxInt(bounds.Dx(), bounds.Dy())
+	return &timelapse{lock: &sync.Mutex{}, size: size, factor: maxInt(1, (longest+size-1)/size)}
+}
+
+// timelapseSize returns the frame size of tl, or 0 if there isn't one.
+func timelapseSize(tl *timelapse) int {
+	if tl == nil {
+		return 0
+	}
+	return tl.size
+}
+
+// currentTimelapse returns the running timelapse, if any.
+func (m *Mimage) currentTimelapse() *timelapse {
+	m.metaLock.Lock()
+	defer m.metaLock.Unlock()
+	return m.timelapse
+}
+
+// captureFrame captures a frame if a timelapse is running, where dirty is
+// the area (in world space) that has changed since the last frame.
+func (m *Mimage) captureFrame(dirty image.Rectangle) error {
+	tl := m.currentTimelapse()
+	if tl == nil || dirty.Empty() {
+		return nil
+	}
+	return m.capture(tl, dirty)
+}
+
+// capture updates the frame of tl within dirty & writes it out as a new frame.
+func (m *Mimage) capture(tl *timelapse, dirty image.Rectangle) error {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+
+	f := tl.factor
+	if tl.proxy == nil {
+		b := m.bounds
+		tl.proxy = image.NewRGBA(image.Rect(0, 0, (b.Dx()+f-1)/f, (b.Dy()+f-1)/f))
+		dirty = m.bounds
+	}
+
+	// grow the dirty area to whole frame pixels, the frame starts at the
+	// top left of the image
+	dirty = dirty.Intersect(m.bounds).Sub(m.bounds.Min)
+	pr := image.Rect(floorDiv(dirty.Min.X, f), floorDiv(dirty.Min.Y, f), floorDiv(dirty.Max.X+f-1, f), floorDiv(dirty.Max.Y+f-1, f))
+	img, err := m.downsample(image.Rect(pr.Min.X*f, pr.Min.Y*f, pr.Max.X*f, pr.Max.Y*f).Add(m.bounds.Min), f)
+	if err != nil {
+		return err
+	}
+	draw.Draw(tl.proxy, pr, img, image.Point{}, draw.Src)
+
+	err = os.MkdirAll(filepath.Join(m.root, timelapseDir), 0750)
+	if err != nil {
+		return err
+	}
+	frames, err := m.timelapseFrames()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(m.root, timelapseDir, fmt.Sprintf("%08d.png", len(frames)))
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(out, tl.proxy)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// timelapseFrames returns the paths of all captured frames in order.
+func (m *Mimage) timelapseFrames() ([]string, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(m.root, timelapseDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".png") {
+			paths = append(paths, filepath.Join(m.root, timelapseDir, info.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// TimelapseFrames returns how many frames have been captured.
+func (m *Mimage) TimelapseFrames() (int, error) {
+	frames, err := m.timelapseFrames()
+	return len(frames), err
+}
+
+// readFrame decodes a captured frame.
+func readFrame(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+// EncodeTimelapse writes all captured frames to w as an animation, showing
+// each frame for the given delay. Frames are read from disk one at a time.
+//
+// GIFs use a single palette built from the last frame.
+func (m *Mimage) EncodeTimelapse(w io.Writer, format AnimationFormat, delay time.Duration) error {
+	frames, err := m.timelapseFrames()
+	if err != nil {
+		return err
+	}
+	if len(frames) == 0 {
+		return fmt.Errorf("no timelapse frames have been captured")
+	}
+
+	switch format {
+	case AnimatedGIF:
+		return encodeGIFFrames(w, frames, delay)
+	case AnimatedPNG:
+		return encodeAPNGFrames(w, frames, delay)
+	}
+	return fmt.Errorf("unknown animation format %d", format)
+}
+
+// encodeGIFFrames writes the frames at the given paths as an animated GIF.
+func encodeGIFFrames(w io.Writer, frames []string, delay time.Duration) error {
+	last, err := readFrame(frames[len(frames)-1])
+	if err != nil {
+		return err
+	}
+	pal := adaptivePalette(last, 256)
+
+	b := last.Bounds()
+	gw, err := newGIFWriter(w, b.Dx(), b.Dy(), pal)
+	if err != nil {
+		return err
+	}
+	err = gw.loop(0)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range frames {
+		img, err := readFrame(path)
+		if err != nil {
+			return err
+		}
+		q, err := newQuantizer(img, PaletteOptions{Palette: pal})
+		if err != nil {
+			return err
+		}
+		err = gw.frame(int(delay/(10*time.Millisecond)), q.row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return gw.close()
+}
+
+// encodeAPNGFrames writes the frames at the given paths as an animated PNG.
+func encodeAPNGFrames(w io.Writer, frames []string, delay time.Duration) error {
+	first, err := readFrame(frames[0])
+	if err != nil {
+		return err
+	}
+	b := first.Bounds()
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("\x89PNG\r\n\x1a\n")
+
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr, uint32(b.Dx()))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(b.Dy()))
+	ihdr[8], ihdr[9] = 8, 6 // bit depth, RGBA
+	err = writePNGChunk(bw, "IHDR", ihdr)
+	if err != nil {
+		return err
+	}
+
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl, uint32(len(frames)))
+	err = writePNGChunk(bw, "acTL", actl) // plays forever
+	if err != nil {
+		return err
+	}
+
+	seq := uint32(0)
+	for i, path := range frames {
+		img := first
+		if i > 0 {
+			img, err = readFrame(path)
+			if err != nil {
+				return err
+			}
+		}
+		if img.Bounds().Size() != b.Size() {
+			return fmt.Errorf("frame %s is a different size to the first frame", path)
+		}
+
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl, seq)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(b.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(b.Dy()))
+		binary.BigEndian.PutUint16(fctl[20:], uint16(delay/time.Millisecond))
+		binary.BigEndian.PutUint16(fctl[22:], 1000)
+		seq++
+		err = writePNGChunk(bw, "fcTL", fctl)
+		if err != nil {
+			return err
+		}
+
+		data, err := deflateRGBA(img)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			err = writePNGChunk(bw, "IDAT", data)
+		} else {
+			fdat := make([]byte, 4, 4+len(data))
+			binary.BigEndian.PutUint32(fdat, seq)
+			seq++
+			err = writePNGChunk(bw, "fdAT", append(fdat, data...))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writePNGChunk(bw, "IEND", nil)
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// deflateRGBA returns the compressed PNG image data (8 bit straight RGBA) for
+// img.
+func deflateRGBA(img image.Image) ([]byte, error) {
+	b := img.Bounds()
+	buf := &bytes.Buffer{}
+	zw := zlib.NewWriter(buf)
+
+	row := make([]byte, 1+b.Dx()*4) // leading filter byte, always 0 (none)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			i := 1 + (x-b.Min.X)*4
+			row[i], row[i+1], row[i+2], row[i+3] = c.R, c.G, c.B, c.A
+		}
+		_, err := zw.Write(row)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := zw.Close()
+	return buf.Bytes(), err
+}
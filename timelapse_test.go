@@ -0,0 +1,142 @@
+package mimage_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestTimelapse(t *testing.T) {
+	dir := t.TempDir()
+	m, err := mimage.New(image.Rect(0, 0, 256, 256), mimage.Directory(dir), mimage.ChunkSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.StartTimelapse(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	op := m.Draw()
+	op.SetColor(red)
+	op.Clear()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// carries on once loaded again, updating only what changed
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = mimage.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	op = m.Draw()
+	op.SetColor(blue)
+	op.DrawRectangle(0, 0, 128, 128)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := m.TimelapseFrames(); err != nil || n != 2 {
+		t.Fatalf("%d frames captured (%v), want 2", n, err)
+	}
+
+	buf := &bytes.Buffer{}
+	err = m.EncodeTimelapse(buf, mimage.AnimatedGIF, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 2 || anim.Delay[0] != 10 {
+		t.Fatalf("gif has %d frames with delay %v, want 2 of 10", len(anim.Image), anim.Delay)
+	}
+	for i, want := range []map[image.Point]color.RGBA{
+		{{10, 10}: red, {50, 50}: red},
+		{{10, 10}: blue, {50, 50}: red},
+	} {
+		frame := anim.Image[i]
+		if b := frame.Bounds(); b != image.Rect(0, 0, 64, 64) {
+			t.Fatalf("frame %d bounds are %v", i, b)
+		}
+		for p, c := range want {
+			if got := color.RGBAModel.Convert(frame.At(p.X, p.Y)); got != c {
+				t.Errorf("frame %d pixel %v is %v, want %v", i, p, got, c)
+			}
+		}
+	}
+
+	// an apng's default image is the first frame
+	buf.Reset()
+	err = m.EncodeTimelapse(buf, mimage.AnimatedPNG, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("acTL")) || bytes.Count(buf.Bytes(), []byte("fcTL")) != 2 {
+		t.Error("png isn't animated with 2 frames")
+	}
+	first, err := png.Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := color.RGBAModel.Convert(first.At(10, 10)); got != red {
+		t.Errorf("first png frame pixel is %v, want %v", got, red)
+	}
+
+	err = m.ClearTimelapse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.EncodeTimelapse(buf, mimage.AnimatedGIF, time.Second); err == nil {
+		t.Error("encoding a cleared timelapse got no error")
+	}
+}
+
+func TestCaptureFrameOffset(t *testing.T) {
+	// away from the origin, frames still start at the image's top left
+	m := newImage(t, image.Rect(1000, 1000, 1256, 1256), mimage.ChunkSize(64))
+	red := color.RGBA{255, 0, 0, 255}
+	err := m.Watermark(solid(image.Rect(0, 0, 256, 256), red), mimage.WatermarkOptions{Placement: mimage.WatermarkTopLeft})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.CaptureFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	err = m.EncodeTimelapse(buf, mimage.AnimatedGIF, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := anim.Image[0]
+	if b := frame.Bounds(); b != image.Rect(0, 0, 256, 256) {
+		t.Fatalf("frame bounds are %v", b)
+	}
+	for _, p := range []image.Point{{0, 0}, {128, 128}, {255, 255}} {
+		if got := color.RGBAModel.Convert(frame.At(p.X, p.Y)); got != red {
+			t.Errorf("frame pixel %v is %v, want %v", p, got, red)
+		}
+	}
+}
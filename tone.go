@@ -0,0 +1,72 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Posterize reduces each color channel to the given number of evenly spaced
+// levels (at least 2), for a flat, poster like look. Alpha is left alone.
+func (m *Mimage) Posterize(levels int) error {
+	if levels < 2 {
+		return fmt.Errorf("posterize needs at least 2 levels, given %d", levels)
+	}
+
+	table := [256]uint8{}
+	step := 255 / float64(levels-1)
+	for i := range table {
+		table[i] = uint8(math.Round(math.Round(float64(i)/step) * step))
+	}
+
+	return m.mapPixels(m.bounds, func(x, y int, c color.RGBA) color.RGBA {
+		if c.A == 0 {
+			return c
+		}
+		s := unpremultiply(c)
+		return premultiply(color.NRGBA{R: table[s.R], G: table[s.G], B: table[s.B], A: s.A})
+	})
+}
+
+// Threshold turns pixels with a luminance of at least value into opaque
+// white & everything else transparent, so the result can be used directly as
+// a mask (see SetMask). Export over SolidBackground(color.Black) for a black
+// & white image.
+func (m *Mimage) Threshold(value uint8) error {
+	return m.mapPixels(m.bounds, func(x, y int, c color.RGBA) color.RGBA {
+		if c.A == 0 {
+			return c
+		}
+		s := unpremultiply(c)
+		l := 0.299*float64(s.R) + 0.587*float64(s.G) + 0.114*float64(s.B)
+		if math.Round(l) >= float64(value) {
+			return color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+		}
+		return color.RGBA{}
+	})
+}
+
+// ReplaceColor sets pixels within r (in world space) close to from to the
+// color to, eg. to recolor every border on a map. Tolerance is how far off
+// (0 exactly, up to 1 anything) a pixel may be, as the distance between
+// straight alpha RGBA colors scaled so that transparent black & opaque white
+// are 1 apart. Alpha counts, so anti-aliased edges need some tolerance.
+func (m *Mimage) ReplaceColor(from, to color.Color, tolerance float64, r image.Rectangle) error {
+	if tolerance < 0 || tolerance > 1 {
+		return fmt.Errorf("tolerance must be between 0 and 1, given %v", tolerance)
+	}
+	f := color.NRGBAModel.Convert(from).(color.NRGBA)
+	t := color.RGBAModel.Convert(to).(color.RGBA)
+	limit := tolerance * tolerance * 4 * 255 * 255 // squared, over 4 channels
+
+	return m.mapPixels(r, func(x, y int, c color.RGBA) color.RGBA {
+		s := unpremultiply(c)
+		dr, dg := float64(s.R)-float64(f.R), float64(s.G)-float64(f.G)
+		db, da := float64(s.B)-float64(f.B), float64(s.A)-float64(f.A)
+		if dr*dr+dg*dg+db*db+da*da > limit {
+			return c
+		}
+		return t
+	})
+}
@@ -0,0 +1,102 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPosterize(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64))
+	if err := m.Posterize(1); err == nil {
+		t.Error("1 level got no error")
+	}
+
+	op := m.Draw()
+	op.SetColor(color.RGBA{100, 200, 30, 255})
+	op.DrawRectangle(0, 0, 32, 64)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// levels of 0, 85, 170 & 255
+	err = m.Posterize(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.At(10, 10), (color.RGBA{85, 170, 0, 255}); got != want {
+		t.Errorf("posterized to %v, want %v", got, want)
+	}
+	if got := m.At(40, 10); got != (color.RGBA{}) {
+		t.Errorf("transparent pixel became %v", got)
+	}
+}
+
+func TestThreshold(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64))
+	op := m.Draw()
+	op.SetColor(color.RGBA{200, 200, 200, 255})
+	op.DrawRectangle(0, 0, 32, 64)
+	op.Fill()
+	op.SetColor(color.RGBA{50, 50, 50, 255})
+	op.DrawRectangle(32, 0, 32, 64)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Threshold(128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.At(10, 10); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("bright pixel became %v, want white", got)
+	}
+	if got := m.At(40, 10); got != (color.RGBA{}) {
+		t.Errorf("dark pixel became %v, want transparent", got)
+	}
+}
+
+func TestReplaceColor(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64))
+	if err := m.ReplaceColor(color.White, color.Black, 2, m.Bounds()); err == nil {
+		t.Error("tolerance of 2 got no error")
+	}
+
+	op := m.Draw()
+	op.SetColor(color.RGBA{200, 0, 0, 255})
+	op.DrawRectangle(0, 0, 64, 20)
+	op.Fill()
+	op.SetColor(color.RGBA{190, 0, 0, 255})
+	op.DrawRectangle(0, 20, 64, 20)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blue := color.RGBA{0, 0, 255, 255}
+	err = m.ReplaceColor(color.RGBA{200, 0, 0, 255}, blue, 0, image.Rect(0, 0, 32, 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pt, want := range map[image.Point]color.RGBA{{10, 10}: blue, {40, 10}: {200, 0, 0, 255}, {10, 30}: {190, 0, 0, 255}} {
+		if got := m.At(pt.X, pt.Y); got != want {
+			t.Errorf("exact replace: pixel %v is %v, want %v", pt, got, want)
+		}
+	}
+
+	// 10 off in one channel is 10/510 apart
+	err = m.ReplaceColor(color.RGBA{200, 0, 0, 255}, blue, 0.05, m.Bounds())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for pt, want := range map[image.Point]color.RGBA{{40, 10}: blue, {10, 30}: blue, {10, 50}: {}} {
+		if got := m.At(pt.X, pt.Y); got != want {
+			t.Errorf("tolerant replace: pixel %v is %v, want %v", pt, got, want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package mimage
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Toroidal makes the image wrap around on itself, so that drawing off one
+// edge carries on from the opposite edge & filters (Hillshade, Sobel ..)
+// read across edges as if the image were tiled. Whatever is drawn then tiles
+// seamlessly, eg. for textures or world maps that wrap east to west.
+//
+// This also sets the edge mode to EdgeWrap (see Edges).
+func Toroidal() Option {
+	return func(m *Mimage) error {
+		m.toroidal = true
+		m.edges = EdgeWrap
+		return nil
+	}
+}
+
+// IsToroidal returns if the image wraps around on itself (see Toroidal).
+func (m *Mimage) IsToroidal() bool { return m.toroidal }
+
+// wraps returns the offsets (whole multiples of the image width & height) at
+// which drawing to r lands on the image, just the zero offset unless the
+// image is toroidal.
+func (m *Mimage) wraps(r image.Rectangle) []image.Point {
+	b := m.bounds
+	if !m.toroidal || r.Empty() || b.Empty() {
+		return []image.Point{{}}
+	}
+
+	// the range of tiles r covers, each being drawn back onto the image
+	w, h := b.Dx(), b.Dy()
+	tx0, tx1 := floorDiv(r.Min.X-b.Min.X, w), floorDiv(r.Max.X-1-b.Min.X, w)
+	ty0, ty1 := floorDiv(r.Min.Y-b.Min.Y, h), floorDiv(r.Max.Y-1-b.Min.Y, h)
+
+	out := []image.Point{}
+	for ty := ty0; ty <= ty1; ty++ {
+		for tx := tx0; tx <= tx1; tx++ {
+			out = append(out, image.Pt(-tx*w, -ty*h))
+		}
+	}
+	return out
+}
+
+// halo returns r grown by n pixels on each side, for filters that read around
+// the pixels they change. Unless the edge mode says otherwise, it's kept
+// within the image.
+func (m *Mimage) halo(r image.Rectangle, n int) image.Rectangle {
+	r = r.Inset(-n)
+	if m.edges == EdgeTransparent {
+		return r.Intersect(m.bounds)
+	}
+	return r
+}
+
+// imageEdged returns the region r like Image, but with pixels outside of the
+// image read according to the edge mode.
+func (m *Mimage) imageEdged(r image.Rectangle) (*image.RGBA, error) {
+	b := m.bounds
+	if r.In(b) || m.edges == EdgeTransparent || b.Empty() {
+		img, err := m.Image(r)
+		if err != nil {
+			return nil, err
+		}
+		return img.(*image.RGBA), nil
+	}
+
+	// split r into pieces along the image edges (& every repeat of them),
+	// each of which comes from one region of the image
+	cuts := func(min, max, lo, size int) []int {
+		out := []int{min}
+		for c := lo + (floorDiv(min-lo, size)+1)*size; c < max; c += size {
+			out = append(out, c)
+		}
+		return append(out, max)
+	}
+	xs := cuts(r.Min.X, r.Max.X, b.Min.X, b.Dx())
+	ys := cuts(r.Min.Y, r.Max.Y, b.Min.Y, b.Dy())
+
+	out := image.NewRGBA(r.Sub(r.Min))
+	for j := 0; j < len(ys)-1; j++ {
+		for i := 0; i < len(xs)-1; i++ {
+			piece := image.Rect(xs[i], ys[j], xs[i+1], ys[j+1])
+			if piece.In(b) {
+				img, err := m.Image(piece)
+				if err != nil {
+					return nil, err
+				}
+				draw.Draw(out, piece.Sub(r.Min), img, image.Point{}, draw.Src)
+				continue
+			}
+
+			// where the piece's corners come from bounds all of its pixels
+			x0, y0, _ := m.edgePoint(piece.Min.X, piece.Min.Y)
+			x1, y1, _ := m.edgePoint(piece.Max.X-1, piece.Max.Y-1)
+			src := image.Rect(minInt(x0, x1), minInt(y0, y1), maxInt(x0, x1)+1, maxInt(y0, y1)+1)
+			img, err := m.Image(src)
+			if err != nil {
+				return nil, err
+			}
+			rgba := img.(*image.RGBA)
+			for y := piece.Min.Y; y < piece.Max.Y; y++ {
+				for x := piece.Min.X; x < piece.Max.X; x++ {
+					sx, sy, _ := m.edgePoint(x, y)
+					out.SetRGBA(x-r.Min.X, y-r.Min.Y, rgba.RGBAAt(sx-src.Min.X, sy-src.Min.Y))
+				}
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,84 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestToroidal(t *testing.T) {
+	dir := t.TempDir()
+	m, err := mimage.New(image.Rect(0, 0, 64, 64), mimage.Directory(dir), mimage.ChunkSize(32), mimage.Toroidal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.IsToroidal() || m.EdgeMode() != mimage.EdgeWrap {
+		t.Errorf("toroidal %v edge mode %d, want true & wrapping", m.IsToroidal(), m.EdgeMode())
+	}
+
+	red := color.RGBA{255, 0, 0, 255}
+	op := m.Draw()
+	op.SetColor(red)
+	// off the right edge & off the top left corner
+	op.DrawRectangle(56, 10, 16, 8)
+	op.DrawRectangle(-4, -4, 8, 8)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for pt, want := range map[image.Point]color.RGBA{
+		{60, 12}: red, {2, 12}: red, {9, 12}: {}, {50, 12}: {},
+		{0, 0}: red, {63, 0}: red, {0, 63}: red, {63, 63}: red, {59, 59}: {},
+	} {
+		if got := m.At(pt.X, pt.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", pt, got, want)
+		}
+	}
+
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := mimage.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	if !loaded.IsToroidal() {
+		t.Error("reloaded image isn't toroidal")
+	}
+}
+
+func TestToroidalFilter(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32), mimage.Toroidal())
+	op := m.Draw()
+	op.SetColor(color.Black)
+	op.Clear()
+	op.SetColor(color.White)
+	op.DrawRectangle(32, 0, 32, 64)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edges, err := m.Sobel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer edges.Close()
+
+	// the image wraps from white back to black, so there's an edge there too
+	for _, x := range []int{0, 31, 32, 63} {
+		if got := edges.At(x, 10).(color.RGBA).R; got != 255 {
+			t.Errorf("sobel at x=%d is %d, want 255", x, got)
+		}
+	}
+	if got := edges.At(10, 10).(color.RGBA).R; got != 0 {
+		t.Errorf("sobel at x=10 is %d, want 0", got)
+	}
+}
@@ -0,0 +1,116 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"sync"
+)
+
+// ContentBounds returns the smallest rectangle (in world space) holding every
+// pixel that isn't entirely transparent, which is empty if there are none.
+// Chunks that have never been drawn on are skipped without being loaded.
+func (m *Mimage) ContentBounds() (image.Rectangle, error) {
+	return m.contentWithin(m.bounds, 1)
+}
+
+// contentWithin returns the smallest rectangle (in world space) holding every
+// pixel within r with at least the given alpha, skipping chunks that have
+// never been drawn on.
+func (m *Mimage) contentWithin(r image.Rectangle, minAlpha uint8) (image.Rectangle, error) {
+	r = r.Intersect(m.bounds)
+	found := image.Rectangle{}
+	lock := &sync.Mutex{}
+	errs := make(chan error)
+	work := m.chunksWithin(r)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < m.routines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for coords := range work {
+				if !m.cache.exists(coords[0], coords[1]) {
+					continue
+				}
+				c, err := m.chunkContent(coords[0], coords[1], r, minAlpha)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				lock.Lock()
+				found = found.Union(c)
+				lock.Unlock()
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(errs)
+	}()
+
+	return found, checkErrors(errs)
+}
+
+// chunkContent returns the bounding box (in world space) of the pixels of a
+// chunk within r with at least the given alpha.
+func (m *Mimage) chunkContent(cx, cy int, r image.Rectangle, minAlpha uint8) (image.Rectangle, error) {
+	ctx, err := m.cache.Load(cx, cy)
+	defer ctx.Done()
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+
+	cb := m.chunkBounds(cx, cy)
+	area := cb.Intersect(r)
+	img := ctx.Img.Image().(*image.RGBA)
+
+	minX, minY, maxX, maxY := area.Max.X, area.Max.Y, area.Min.X, area.Min.Y
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		i := img.PixOffset(area.Min.X-cb.Min.X, y-cb.Min.Y)
+		for x := area.Min.X; x < area.Max.X; x++ {
+			if img.Pix[i+3] >= minAlpha {
+				minX, minY = minInt(minX, x), minInt(minY, y)
+				maxX, maxY = maxInt(maxX, x+1), maxInt(maxY, y+1)
+			}
+			i += 4
+		}
+	}
+	if minX >= maxX {
+		return image.Rectangle{}, nil
+	}
+	return image.Rect(minX, minY, maxX, maxY), nil
+}
+
+// Trim returns a new Mimage holding just the part of this image that isn't
+// transparent (see ContentBounds), with its top left at (0,0).
+func (m *Mimage) Trim() (*Mimage, error) {
+	content, err := m.ContentBounds()
+	if err != nil {
+		return nil, err
+	}
+	if content.Empty() {
+		return nil, fmt.Errorf("image is entirely transparent")
+	}
+
+	bounds := content.Sub(content.Min)
+	out, err := New(bounds, ChunkSize(m.chunkSize), OperationRoutines(m.routines))
+	if err != nil {
+		return nil, err
+	}
+
+	return out, out.eachChunk(bounds, func(ctx *context) error {
+		cb := out.chunkBounds(ctx.X, ctx.Y)
+		r := cb.Intersect(bounds)
+		if r.Empty() {
+			return nil
+		}
+
+		src, err := m.Image(r.Add(content.Min))
+		if err != nil {
+			return err
+		}
+		draw.Draw(ctx.Img.Image().(*image.RGBA), r.Sub(cb.Min), src, image.Point{}, draw.Src)
+		ctx.setEdited()
+		return nil
+	})
+}
@@ -0,0 +1,55 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestTrim(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 128, 128), mimage.ChunkSize(32))
+	if _, err := m.Trim(); err == nil {
+		t.Error("trimming an empty image got no error")
+	}
+
+	// reading doesn't count as content
+	_, err := m.Image(m.Bounds())
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := color.RGBA{255, 0, 0, 255}
+	op := m.Draw()
+	op.SetColor(red)
+	op.DrawRectangle(20, 30, 10, 5)
+	op.DrawRectangle(70, 90, 20, 10)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := m.ContentBounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := image.Rect(20, 30, 90, 100)
+	if content != want {
+		t.Errorf("content bounds are %v, want %v", content, want)
+	}
+
+	trimmed, err := m.Trim()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer trimmed.Close()
+	if trimmed.Bounds() != image.Rect(0, 0, 70, 70) {
+		t.Errorf("trimmed bounds are %v", trimmed.Bounds())
+	}
+	for pt, c := range map[image.Point]color.RGBA{{0, 0}: red, {9, 4}: red, {69, 69}: red, {10, 0}: {}, {30, 30}: {}} {
+		if got := trimmed.At(pt.X, pt.Y); got != c {
+			t.Errorf("trimmed pixel %v is %v, want %v", pt, got, c)
+		}
+	}
+}
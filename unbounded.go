@@ -0,0 +1,49 @@
+package mimage
+
+import (
+	"image"
+)
+
+// Unbounded makes an image with no fixed size; drawing anywhere creates the
+// chunks it reaches & Bounds() reports the extent of the chunks that exist
+// so far (starting from the rectangle given to New, which may be empty).
+// Handy for procedural generation when the final size isn't known up front.
+//
+// An unbounded image can't also be Toroidal.
+func Unbounded() Option {
+	return func(m *Mimage) error {
+		m.unbounded = true
+		return nil
+	}
+}
+
+// IsUnbounded returns if the image grows as it is drawn on (see Unbounded).
+func (m *Mimage) IsUnbounded() bool { return m.unbounded }
+
+// drawable returns the part of r (in world space) that drawing may change.
+func (m *Mimage) drawable(r image.Rectangle) image.Rectangle {
+	if m.unbounded {
+		return r
+	}
+	return r.Intersect(m.bounds)
+}
+
+// grow extends the bounds of an unbounded image to take in all chunks that r
+// (in world space) touches.
+func (m *Mimage) grow(r image.Rectangle) error {
+	if !m.unbounded || r.Empty() {
+		return nil
+	}
+	min := m.chunkBounds(floorDiv(r.Min.X, m.chunkSize), floorDiv(r.Min.Y, m.chunkSize)).Min
+	max := m.chunkBounds(floorDiv(r.Max.X-1, m.chunkSize), floorDiv(r.Max.Y-1, m.chunkSize)).Max
+	grown := m.bounds.Union(image.Rectangle{Min: min, Max: max})
+	if grown == m.bounds {
+		return nil
+	}
+
+	m.metaLock.Lock()
+	defer m.metaLock.Unlock()
+
+	m.bounds = grown
+	return m.writeMetadata()
+}
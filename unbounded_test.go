@@ -0,0 +1,69 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestUnbounded(t *testing.T) {
+	if _, err := mimage.New(image.Rectangle{}, mimage.Directory(t.TempDir()), mimage.Unbounded(), mimage.Toroidal()); err == nil {
+		t.Error("unbounded & toroidal got no error")
+	}
+
+	dir := t.TempDir()
+	m, err := mimage.New(image.Rectangle{}, mimage.Directory(dir), mimage.ChunkSize(32), mimage.Unbounded())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.IsUnbounded() || !m.Bounds().Empty() {
+		t.Fatalf("unbounded %v bounds %v, want true & empty", m.IsUnbounded(), m.Bounds())
+	}
+
+	red := color.RGBA{255, 0, 0, 255}
+	op := m.Draw()
+	op.SetColor(red)
+	op.DrawRectangle(-100, -100, 10, 10)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := image.Rect(-128, -128, -64, -64); m.Bounds() != want {
+		t.Errorf("bounds are %v, want %v", m.Bounds(), want)
+	}
+
+	op.DrawRectangle(90, 90, 5, 5)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := image.Rect(-128, -128, 96, 96)
+	if m.Bounds() != want {
+		t.Errorf("bounds are %v, want %v", m.Bounds(), want)
+	}
+	for pt, c := range map[image.Point]color.RGBA{{-100, -100}: red, {-91, -91}: red, {92, 92}: red, {-89, -89}: {}, {0, 0}: {}} {
+		if got := m.At(pt.X, pt.Y); got != c {
+			t.Errorf("pixel %v is %v, want %v", pt, got, c)
+		}
+	}
+
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := mimage.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	if !loaded.IsUnbounded() || loaded.Bounds() != want {
+		t.Errorf("reloaded unbounded %v bounds %v, want true & %v", loaded.IsUnbounded(), loaded.Bounds(), want)
+	}
+	if got := loaded.At(92, 92); got != red {
+		t.Errorf("reloaded pixel is %v, want %v", got, red)
+	}
+}
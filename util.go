@@ -20,3 +20,28 @@ func checkErrors(errs <-chan error) error {
 
 	return ferr
 }
+
+// floorDiv returns a / b rounded towards negative infinity.
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+// maxInt returns the larger of a & b.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// minInt returns the smaller of a & b.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,68 @@
+package mimage
+
+import (
+	"image/color"
+	"math"
+)
+
+// scaleColor multiplies the given color channels by f (0-1), leaving alpha alone
+// when keepAlpha is set.
+func scaleColor(c color.RGBA, f float64, keepAlpha bool) color.RGBA {
+	s := func(v uint8) uint8 { return uint8(math.Round(float64(v) * f)) }
+	out := color.RGBA{s(c.R), s(c.G), s(c.B), c.A}
+	if !keepAlpha {
+		out.A = s(c.A)
+	}
+	return out
+}
+
+// RadialFalloff darkens the image with distance from center (in world space).
+// Within the inner radius nothing changes, beyond the outer radius colors are
+// multiplied by (1 - strength), with a smooth transition in between.
+func (m *Mimage) RadialFalloff(center Point, inner, outer, strength float64) error {
+	strength = math.Max(0, math.Min(1, strength))
+	if strength == 0 {
+		return nil
+	}
+
+	return m.mapPixels(m.bounds, func(x, y int, c color.RGBA) color.RGBA {
+		d := math.Hypot(float64(x)+0.5-center.X, float64(y)+0.5-center.Y)
+		if d <= inner {
+			return c
+		}
+		t := 1.0
+		if outer > inner {
+			t = smoothstep((d - inner) / (outer - inner))
+		}
+		return scaleColor(c, 1-strength*t, true)
+	})
+}
+
+// Vignette darkens the image towards the corners, with strength 0-1 being how
+// dark the corners end up (1 is black).
+func (m *Mimage) Vignette(strength float64) error {
+	b := m.bounds
+	center := Point{X: float64(b.Min.X+b.Max.X) / 2, Y: float64(b.Min.Y+b.Max.Y) / 2}
+	radius := math.Hypot(float64(b.Dx()), float64(b.Dy())) / 2
+	return m.RadialFalloff(center, radius*0.5, radius, strength)
+}
+
+// EdgeFade fades the image out to transparent over the given distance (pixels)
+// from each edge.
+func (m *Mimage) EdgeFade(width float64) error {
+	if width <= 0 {
+		return nil
+	}
+	b := m.bounds
+
+	return m.mapPixels(b, func(x, y int, c color.RGBA) color.RGBA {
+		d := math.Min(
+			math.Min(float64(x-b.Min.X), float64(b.Max.X-1-x)),
+			math.Min(float64(y-b.Min.Y), float64(b.Max.Y-1-y)),
+		) + 0.5
+		if d >= width {
+			return c
+		}
+		return scaleColor(c, smoothstep(d/width), false)
+	})
+}
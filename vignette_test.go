@@ -0,0 +1,77 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+// whiteImage returns a 128x128 image filled with white.
+func whiteImage(t *testing.T) *mimage.Mimage {
+	t.Helper()
+	m := newImage(t, image.Rect(0, 0, 128, 128), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(color.White)
+	op.Clear()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestRadialFalloff(t *testing.T) {
+	m := whiteImage(t)
+	err := m.RadialFalloff(mimage.Point{X: 64, Y: 64}, 20, 40, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := m.At(64, 64); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("center pixel is %v, want it unchanged", got)
+	}
+	if got := m.At(64, 110); got != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("pixel beyond the outer radius is %v, want black", got)
+	}
+	mid := m.At(64, 94).(color.RGBA)
+	if mid.R == 0 || mid.R == 255 || mid.A != 255 {
+		t.Errorf("pixel between radii is %v, want it partly darkened", mid)
+	}
+}
+
+func TestVignette(t *testing.T) {
+	m := whiteImage(t)
+	err := m.Vignette(0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	center, corner := m.At(64, 64).(color.RGBA), m.At(0, 0).(color.RGBA)
+	if center.R != 255 {
+		t.Errorf("center pixel is %v, want it unchanged", center)
+	}
+	if corner.R < 120 || corner.R > 140 || corner.A != 255 {
+		t.Errorf("corner pixel is %v, want it half as bright", corner)
+	}
+}
+
+func TestEdgeFade(t *testing.T) {
+	m := whiteImage(t)
+	err := m.EdgeFade(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := m.At(64, 64); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("center pixel is %v, want it unchanged", got)
+	}
+	edge, near := m.At(0, 64).(color.RGBA), m.At(5, 64).(color.RGBA)
+	if edge.A > 10 || near.A <= edge.A || near.A == 255 {
+		t.Errorf("pixels fading to the edge are %v then %v", near, edge)
+	}
+	if got := m.At(127, 127).(color.RGBA); got.A > 10 {
+		t.Errorf("corner pixel is %v, want it transparent", got)
+	}
+}
@@ -0,0 +1,105 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// VoronoiSite is a seed point (in world space) of a Voronoi cell & the color
+// its cell is filled with.
+type VoronoiSite struct {
+	X, Y  float64
+	Color color.Color
+}
+
+// Voronoi fills the pixels within r (in world space) with the Voronoi cells
+// of the given sites, each pixel (by its center) taking the color of the
+// nearest site, replacing whatever was there, eg. for regions, biomes or
+// plates in procedural maps. Sites may be outside of r (or the image). If
+// the image is toroidal (see Toroidal) distances wrap around the image, so
+// cells tile seamlessly.
+//
+// Each chunk is filled in parallel directly in world space, only checking
+// the sites that could be nearest to some pixel of it, so the image needn't
+// fit in memory.
+//
+// Every site needs a color & a finite position.
+func (m *Mimage) Voronoi(sites []VoronoiSite, r image.Rectangle) error {
+	if len(sites) == 0 {
+		return fmt.Errorf("at least one site is required")
+	}
+	for i, s := range sites {
+		if s.Color == nil {
+			return fmt.Errorf("site %d has no color", i)
+		}
+		if math.IsNaN(s.X) || math.IsNaN(s.Y) || math.IsInf(s.X, 0) || math.IsInf(s.Y, 0) {
+			return fmt.Errorf("site %d is at (%v,%v), which isn't a point", i, s.X, s.Y)
+		}
+	}
+	r = r.Intersect(m.bounds)
+	if r.Empty() {
+		return nil
+	}
+
+	colors := make([]color.RGBA, len(sites))
+	for i, s := range sites {
+		colors[i] = color.RGBAModel.Convert(s.Color).(color.RGBA)
+	}
+
+	return m.eachChunk(r, func(ctx *context) error {
+		cb := m.chunkBounds(ctx.X, ctx.Y)
+		area := cb.Intersect(r)
+		if area.Empty() {
+			return nil
+		}
+
+		// a pixel's nearest site is no further than the site nearest the
+		// center plus half the diagonal, so sites beyond that (& another
+		// half diagonal) can't be nearest to anything here
+		cx, cy := float64(area.Min.X+area.Max.X)/2, float64(area.Min.Y+area.Max.Y)/2
+		half := math.Hypot(float64(area.Dx()), float64(area.Dy())) / 2
+		nearest := math.Inf(1)
+		for _, s := range sites {
+			nearest = math.Min(nearest, m.siteDistance(s, cx, cy))
+		}
+		near := []int{}
+		for i, s := range sites {
+			if m.siteDistance(s, cx, cy) <= nearest+2*half {
+				near = append(near, i)
+			}
+		}
+
+		img := ctx.Img.Image().(*image.RGBA)
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			i := img.PixOffset(area.Min.X-cb.Min.X, y-cb.Min.Y)
+			for x := area.Min.X; x < area.Max.X; x++ {
+				best, bestDist := near[0], math.Inf(1)
+				for _, n := range near {
+					d := m.siteDistance(sites[n], float64(x)+0.5, float64(y)+0.5)
+					if d < bestDist {
+						best, bestDist = n, d
+					}
+				}
+				c := colors[best]
+				img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+				i += 4
+			}
+		}
+		ctx.setEdited()
+		return nil
+	})
+}
+
+// siteDistance returns the distance from (x,y) to the site, the shortest way
+// around if the image is toroidal.
+func (m *Mimage) siteDistance(s VoronoiSite, x, y float64) float64 {
+	dx, dy := x-s.X, y-s.Y
+	if m.toroidal {
+		w, h := float64(m.bounds.Dx()), float64(m.bounds.Dy())
+		dx -= w * math.Round(dx/w)
+		dy -= h * math.Round(dy/h)
+	}
+	return math.Hypot(dx, dy)
+}
@@ -0,0 +1,41 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestVoronoi(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 100, 100), mimage.ChunkSize(32))
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+
+	err := m.Voronoi([]mimage.VoronoiSite{{X: 10, Y: 50, Color: red}, {X: 90, Y: 50, Color: blue}}, m.Bounds())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.At(20, 5); got != red {
+		t.Errorf("pixel nearest the first site is %v, want %v", got, red)
+	}
+	if got := m.At(80, 95); got != blue {
+		t.Errorf("pixel nearest the second site is %v, want %v", got, blue)
+	}
+}
+
+func TestVoronoiInvalidSites(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 100, 100), mimage.ChunkSize(32))
+	for _, sites := range [][]mimage.VoronoiSite{
+		nil,
+		{{X: 10, Y: 10, Color: color.White}, {X: 50, Y: 50}},
+		{{X: math.NaN(), Y: 10, Color: color.White}},
+		{{X: 10, Y: math.Inf(1), Color: color.White}},
+	} {
+		err := m.Voronoi(sites, m.Bounds())
+		if err == nil {
+			t.Errorf("sites %v got no error", sites)
+		}
+	}
+}
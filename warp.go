@@ -0,0 +1,93 @@
+package mimage
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// warpTo creates a new Mimage with the given bounds, where each pixel is taken
+// from this image at the point given by inverse (which maps a point in the new
+// image back to a point in this one).
+//
+// Each chunk of the new image is written once, source chunks are loaded as they
+// are sampled.
+func (m *Mimage) warpTo(bounds image.Rectangle, inverse func(x, y float64) (float64, float64), filter ResampleFilter) (*Mimage, error) {
+	out, err := New(bounds, ChunkSize(m.chunkSize), OperationRoutines(m.routines))
+	if err != nil {
+		return nil, err
+	}
+
+	return out, out.eachChunk(bounds, func(ctx *context) error {
+		src := newSampler(m)
+		defer src.release()
+
+		cb := out.chunkBounds(ctx.X, ctx.Y)
+		dst := ctx.Img.Image().(*image.RGBA)
+		r := cb.Intersect(bounds)
+
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				sx, sy := inverse(float64(x)+0.5, float64(y)+0.5)
+				dst.SetRGBA(x-cb.Min.X, y-cb.Min.Y, src.sample(sx, sy, filter))
+			}
+		}
+		ctx.setEdited()
+
+		return src.err
+	})
+}
+
+// RotateArbitrary returns a new Mimage containing this image rotated about its
+// center by the given angle (radians, clockwise). The new image is sized to fit
+// the whole rotated image, areas not covered by it are transparent.
+func (m *Mimage) RotateArbitrary(angle float64, filter ResampleFilter) (*Mimage, error) {
+	sin, cos := math.Sin(angle), math.Cos(angle)
+	w, h := float64(m.Width()), float64(m.Height())
+
+	nw := math.Abs(w*cos) + math.Abs(h*sin)
+	nh := math.Abs(w*sin) + math.Abs(h*cos)
+	bounds := image.Rect(0, 0, int(math.Ceil(nw-1e-9)), int(math.Ceil(nh-1e-9)))
+
+	// centers of the old & new images
+	cx, cy := float64(m.bounds.Min.X)+w/2, float64(m.bounds.Min.Y)+h/2
+	ncx, ncy := float64(bounds.Dx())/2, float64(bounds.Dy())/2
+
+	return m.warpTo(bounds, func(x, y float64) (float64, float64) {
+		dx, dy := x-ncx, y-ncy
+		return cx + dx*cos + dy*sin, cy - dx*sin + dy*cos
+	}, filter)
+}
+
+// Warp returns a new Mimage containing this image transformed by the given
+// affine or perspective matrix. The new image is sized to fit the transformed
+// image, with the top left moved to (0,0).
+//
+// Each pixel of the new image is mapped back through the inverse of the
+// transform, so only the source chunks needed for each new chunk are loaded.
+func (m *Mimage) Warp(transform Matrix3, filter ResampleFilter) (*Mimage, error) {
+	inverse, ok := transform.Inverse()
+	if !ok {
+		return nil, fmt.Errorf("transform is not invertible")
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range []image.Point{m.bounds.Min, {m.bounds.Max.X, m.bounds.Min.Y}, m.bounds.Max, {m.bounds.Min.X, m.bounds.Max.Y}} {
+		x, y, w := transform.Apply(float64(p.X), float64(p.Y))
+		if w <= 0 {
+			return nil, fmt.Errorf("transform maps (%d,%d) to infinity", p.X, p.Y)
+		}
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+	bounds := image.Rect(0, 0, int(math.Ceil(maxX-minX)), int(math.Ceil(maxY-minY)))
+	if bounds.Empty() {
+		return nil, fmt.Errorf("transform results in an empty image")
+	}
+
+	return m.warpTo(bounds, func(x, y float64) (float64, float64) {
+		sx, sy, _ := inverse.Apply(x+minX, y+minY)
+		return sx, sy
+	}, filter)
+}
@@ -0,0 +1,103 @@
+package mimage
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// WatermarkPlacement is where a watermark is placed on the image.
+type WatermarkPlacement int
+
+const (
+	// WatermarkTile repeats the watermark across the whole image.
+	WatermarkTile WatermarkPlacement = iota
+	WatermarkTopLeft
+	WatermarkTopRight
+	WatermarkBottomLeft
+	WatermarkBottomRight
+	WatermarkCenter
+)
+
+// WatermarkOptions configures how a watermark is applied.
+type WatermarkOptions struct {
+	Placement WatermarkPlacement
+
+	// Opacity of the watermark from 0-1, if not given 1 is used.
+	Opacity float64
+
+	// Margin is the space (in pixels) between the watermark and the edge of
+	// the image, or for WatermarkTile the space between each watermark.
+	Margin int
+}
+
+// watermarkOrigins returns the top left corner of each watermark that
+// overlaps r (in world space).
+func (m *Mimage) watermarkOrigins(size image.Point, opts WatermarkOptions, r image.Rectangle) []image.Point {
+	b := m.bounds
+	switch opts.Placement {
+	case WatermarkTopLeft:
+		return []image.Point{b.Min.Add(image.Pt(opts.Margin, opts.Margin))}
+	case WatermarkTopRight:
+		return []image.Point{{b.Max.X - opts.Margin - size.X, b.Min.Y + opts.Margin}}
+	case WatermarkBottomLeft:
+		return []image.Point{{b.Min.X + opts.Margin, b.Max.Y - opts.Margin - size.Y}}
+	case WatermarkBottomRight:
+		return []image.Point{b.Max.Sub(size).Sub(image.Pt(opts.Margin, opts.Margin))}
+	case WatermarkCenter:
+		return []image.Point{{b.Min.X + (b.Dx()-size.X)/2, b.Min.Y + (b.Dy()-size.Y)/2}}
+	}
+
+	// tiled; work out which tiles land in r
+	stepX, stepY := size.X+opts.Margin, size.Y+opts.Margin
+	start := b.Min.Add(image.Pt(opts.Margin, opts.Margin))
+	x0 := maxInt(0, floorDiv(r.Min.X-start.X, stepX))
+	y0 := maxInt(0, floorDiv(r.Min.Y-start.Y, stepY))
+
+	origins := []image.Point{}
+	for y := start.Y + y0*stepY; y < r.Max.Y; y += stepY {
+		for x := start.X + x0*stepX; x < r.Max.X; x += stepX {
+			origins = append(origins, image.Pt(x, y))
+		}
+	}
+	return origins
+}
+
+// Watermark draws img over the massive image (see WatermarkOptions). Chunks are
+// processed in parallel and only chunks a watermark lands on are loaded.
+func (m *Mimage) Watermark(img image.Image, opts WatermarkOptions) error {
+	if opts.Opacity <= 0 {
+		opts.Opacity = 1
+	}
+	mask := image.NewUniform(color.Alpha{uint8(math.Round(math.Min(1, opts.Opacity) * 255))})
+
+	src := img.Bounds()
+	size := src.Size()
+	if size.X <= 0 || size.Y <= 0 {
+		return nil
+	}
+
+	area := m.bounds
+	if opts.Placement != WatermarkTile {
+		at := m.watermarkOrigins(size, opts, m.bounds)[0]
+		area = image.Rectangle{Min: at, Max: at.Add(size)}
+	}
+
+	return m.eachChunk(area, func(ctx *context) error {
+		cb := m.chunkBounds(ctx.X, ctx.Y)
+		dst := ctx.Img.Image().(*image.RGBA)
+
+		for _, at := range m.watermarkOrigins(size, opts, cb) {
+			r := image.Rectangle{Min: at, Max: at.Add(size)}.Intersect(cb)
+			if r.Empty() {
+				continue
+			}
+			sp := src.Min.Add(r.Min.Sub(at))
+			draw.DrawMask(dst, r.Sub(cb.Min), img, sp, mask, image.Point{}, draw.Over)
+			ctx.setEdited()
+		}
+
+		return nil
+	})
+}
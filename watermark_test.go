@@ -0,0 +1,66 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestWatermarkCorner(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 128, 128), mimage.ChunkSize(32))
+	red := color.RGBA{255, 0, 0, 255}
+
+	err := m.Watermark(solid(image.Rect(0, 0, 10, 10), red), mimage.WatermarkOptions{
+		Placement: mimage.WatermarkBottomRight,
+		Margin:    4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for p, want := range map[image.Point]color.RGBA{
+		{114, 114}: red,
+		{123, 123}: red,
+		{124, 124}: {},
+		{113, 113}: {},
+		{5, 5}:     {},
+	} {
+		if got := m.At(p.X, p.Y); got != want {
+			t.Errorf("pixel %v is %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestWatermarkTile(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 128, 128), mimage.ChunkSize(32))
+	white := color.RGBA{255, 255, 255, 255}
+	op := m.Draw()
+	op.SetColor(white)
+	op.Clear()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// tiles every 10+5 pixels, at half opacity
+	err = m.Watermark(solid(image.Rect(0, 0, 10, 10), color.RGBA{0, 0, 0, 255}), mimage.WatermarkOptions{
+		Opacity: 0.5,
+		Margin:  5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []image.Point{{5, 5}, {20, 20}, {35, 5}, {110, 110}} {
+		if got := m.At(p.X, p.Y).(color.RGBA); got.R < 120 || got.R > 135 {
+			t.Errorf("watermarked pixel %v is %v, want it half covered", p, got)
+		}
+	}
+	for _, p := range []image.Point{{2, 2}, {17, 5}, {5, 17}} {
+		if got := m.At(p.X, p.Y); got != white {
+			t.Errorf("pixel %v between tiles is %v, want %v", p, got, white)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package mimage
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ellipseSegments is how many lines approximate an ellipse that a transform
+// has turned or sheared
+const ellipseSegments = 64
+
+// PixelToWorld returns the world (georeferenced CRS) coordinates of the pixel
+// coordinates (x,y), see SetGeoReference.
+func (m *Mimage) PixelToWorld(x, y float64) (float64, float64, error) {
+	g := m.GeoReference()
+	if g == nil {
+		return 0, 0, fmt.Errorf("image is not georeferenced")
+	}
+	wx, wy, _ := g.Transform.Apply(x-float64(m.bounds.Min.X), y-float64(m.bounds.Min.Y))
+	return wx, wy, nil
+}
+
+// WorldToPixel returns the pixel coordinates of the world (georeferenced CRS)
+// coordinates (x,y), see SetGeoReference.
+func (m *Mimage) WorldToPixel(x, y float64) (float64, float64, error) {
+	t, err := m.worldToPixel()
+	if err != nil {
+		return 0, 0, err
+	}
+	px, py, _ := t.Apply(x, y)
+	return px, py, nil
+}
+
+// worldToPixel returns the matrix mapping world to pixel coordinates.
+func (m *Mimage) worldToPixel() (Matrix3, error) {
+	g := m.GeoReference()
+	if g == nil {
+		return Matrix3{}, fmt.Errorf("image is not georeferenced")
+	}
+	inv, ok := g.Transform.Inverse()
+	if !ok {
+		return Matrix3{}, fmt.Errorf("geo transform is not invertible")
+	}
+	return TranslateMatrix(float64(m.bounds.Min.X), float64(m.bounds.Min.Y)).Multiply(inv), nil
+}
+
+// WriteWorldFile writes a world file (eg. .pgw, .jgw, .tfw) for the region
+// r of the image exported to a file, so that GIS tools can place formats
+// that can't hold a georeference themselves.
+func (m *Mimage) WriteWorldFile(w io.Writer, r image.Rectangle) error {
+	g := m.geoFor(r.Min)
+	if g == nil {
+		return fmt.Errorf("image is not georeferenced")
+	}
+
+	// world files give the center of the top left pixel
+	t := g.Transform
+	cx, cy, _ := t.Apply(0.5, 0.5)
+	for _, v := range []float64{t[0], t[3], t[1], t[4], cx, cy} {
+		_, err := fmt.Fprintln(w, strconv.FormatFloat(v, 'f', -1, 64))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseWorldFile reads a world file, returning the transform from pixel to
+// world coordinates for use in a GeoReference.
+func ParseWorldFile(r io.Reader) (Matrix3, error) {
+	v := []float64{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(line, 64)
+		if err != nil {
+			return Matrix3{}, fmt.Errorf("invalid world file line %q", line)
+		}
+		v = append(v, f)
+	}
+	if err := scanner.Err(); err != nil {
+		return Matrix3{}, err
+	}
+	if len(v) != 6 {
+		return Matrix3{}, fmt.Errorf("world file should have 6 values, found %d", len(v))
+	}
+
+	a, d, b, e, c, f := v[0], v[1], v[2], v[3], v[4], v[5]
+	// from the center of the top left pixel to its corner
+	return Matrix3{a, b, c - a/2 - b/2, d, e, f - d/2 - e/2, 0, 0, 1}, nil
+}
+
+// worldOperation is an Operation taking world (georeferenced CRS) coordinates.
+type worldOperation struct {
+	Operation
+	t Matrix3 // world -> pixel
+}
+
+// DrawWorld returns an operation like Draw() but for which coordinates are
+// world (georeferenced CRS) coordinates, see SetGeoReference.
+//
+// Points, rectangles, ellipses, images, macros and rotation pivots are
+// converted; lengths (line widths, stamp spacing) are still in pixels as are
+// the tilemap, grid, scatter region & repeat offsets. Rectangles & ellipses
+// are drawn as shapes through their transformed outline if the image isn't
+// north up.
+func (m *Mimage) DrawWorld() (Operation, error) {
+	t, err := m.worldToPixel()
+	if err != nil {
+		return nil, err
+	}
+	return &worldOperation{Operation: m.Draw(), t: t}, nil
+}
+
+// pt returns the pixel coordinates of world (x,y)
+func (w *worldOperation) pt(x, y float64) (float64, float64) {
+	px, py, _ := w.t.Apply(x, y)
+	return px, py
+}
+
+// axisAligned returns if the transform only scales & moves.
+func (w *worldOperation) axisAligned() bool {
+	return w.t[1] == 0 && w.t[3] == 0
+}
+
+// SetPixel sets the pixel at world (x,y)
+func (w *worldOperation) SetPixel(x, y int) {
+	px, py := w.pt(float64(x), float64(y))
+	w.Operation.SetPixel(int(math.Floor(px)), int(math.Floor(py)))
+}
+
+// MoveTo moves the pen to world (x,y)
+func (w *worldOperation) MoveTo(x, y float64) {
+	w.Operation.MoveTo(w.pt(x, y))
+}
+
+// LineTo draws (or will draw on stroke) to world (x,y)
+func (w *worldOperation) LineTo(x, y float64) {
+	w.Operation.LineTo(w.pt(x, y))
+}
+
+// DrawRectangle draws a rectangle with a corner at world (x,y) of world
+// width w & height h.
+func (w *worldOperation) DrawRectangle(x, y, width, height float64) {
+	x0, y0 := w.pt(x, y)
+	if w.axisAligned() {
+		x1, y1 := w.pt(x+width, y+height)
+		w.Operation.DrawRectangle(math.Min(x0, x1), math.Min(y0, y1), math.Abs(x1-x0), math.Abs(y1-y0))
+		return
+	}
+	w.Operation.MoveTo(x0, y0)
+	w.Operation.LineTo(w.pt(x+width, y))
+	w.Operation.LineTo(w.pt(x+width, y+height))
+	w.Operation.LineTo(w.pt(x, y+height))
+	w.Operation.ClosePath()
+}
+
+// DrawEllipse draws an ellipse at world (x,y) with world axis lengths rx, ry
+func (w *worldOperation) DrawEllipse(x, y, rx, ry float64) {
+	if w.axisAligned() {
+		px, py := w.pt(x, y)
+		w.Operation.DrawEllipse(px, py, math.Abs(rx*w.t[0]), math.Abs(ry*w.t[4]))
+		return
+	}
+	for i := 0; i < ellipseSegments; i++ {
+		a := 2 * math.Pi * float64(i) / ellipseSegments
+		px, py := w.pt(x+rx*math.Cos(a), y+ry*math.Sin(a))
+		if i == 0 {
+			w.Operation.MoveTo(px, py)
+		} else {
+			w.Operation.LineTo(px, py)
+		}
+	}
+	w.Operation.ClosePath()
+}
+
+// RotateAbout rotates around world (x,y) by the given angle (radians),
+// counter clockwise in world space if the y axis points up (north up).
+func (w *worldOperation) RotateAbout(angle, x, y float64) {
+	px, py := w.pt(x, y)
+	if w.t[0]*w.t[4]-w.t[1]*w.t[3] < 0 {
+		angle = -angle // the y axis is flipped
+	}
+	w.Operation.RotateAbout(angle, px, py)
+}
+
+// DrawImage draws in with its top left corner at world (x,y), unscaled.
+func (w *worldOperation) DrawImage(in image.Image, x, y int) {
+	px, py := w.pt(float64(x), float64(y))
+	w.Operation.DrawImage(in, int(math.Round(px)), int(math.Round(py)))
+}
+
+// DrawImageOp draws in with its top left corner at world (x,y), unscaled,
+// combined with what's there using mode & opacity.
+func (w *worldOperation) DrawImageOp(in image.Image, x, y int, mode CompositeMode, opacity float64) {
+	px, py := w.pt(float64(x), float64(y))
+	w.Operation.DrawImageOp(in, int(math.Round(px)), int(math.Round(py)), mode, opacity)
+}
+
+// worldRect returns the pixel rectangle holding the world rectangle r.
+func (w *worldOperation) worldRect(r image.Rectangle) image.Rectangle {
+	x0, y0 := w.pt(float64(r.Min.X), float64(r.Min.Y))
+	x1, y1 := w.pt(float64(r.Max.X), float64(r.Max.Y))
+	return image.Rect(int(math.Round(x0)), int(math.Round(y0)), int(math.Round(x1)), int(math.Round(y1)))
+}
+
+// DrawImageScaled draws in stretched over the world rectangle dst (which is
+// kept axis aligned in pixel space).
+func (w *worldOperation) DrawImageScaled(in image.Image, dst image.Rectangle, filter ResampleFilter) {
+	w.Operation.DrawImageScaled(in, w.worldRect(dst), filter)
+}
+
+// DrawNineSlice draws in stretched over the world rectangle dst (which is
+// kept axis aligned in pixel space) without distorting its edges.
+func (w *worldOperation) DrawNineSlice(in image.Image, center image.Rectangle, dst image.Rectangle, filter ResampleFilter) {
+	w.Operation.DrawNineSlice(in, center, w.worldRect(dst), filter)
+}
+
+// DrawImageTransformed draws in placed by t, which maps points of the image
+// to world space.
+func (w *worldOperation) DrawImageTransformed(in image.Image, t Matrix3) {
+	w.Operation.DrawImageTransformed(in, w.t.Multiply(t))
+}
+
+// StampAlongPath stamps along a path of world points.
+func (w *worldOperation) StampAlongPath(img image.Image, points []Point, spacing float64, jitter StampJitter) {
+	pts := make([]Point, len(points))
+	for i, p := range points {
+		pts[i].X, pts[i].Y = w.pt(p.X, p.Y)
+	}
+	w.Operation.StampAlongPath(img, pts, spacing, jitter)
+}
+
+// ApplyMacro draws a macro with its origin at world (x,y), where scale is in
+// pixels per macro unit.
+func (w *worldOperation) ApplyMacro(m *Macro, x, y, scale float64) {
+	px, py := w.pt(x, y)
+	w.Operation.ApplyMacro(m, px, py, scale)
+}
@@ -0,0 +1,106 @@
+package mimage_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestPixelWorld(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64))
+	if _, _, err := m.PixelToWorld(0, 0); err == nil {
+		t.Error("expected an error without a georeference")
+	}
+	if _, err := m.DrawWorld(); err == nil {
+		t.Error("expected an error drawing without a georeference")
+	}
+
+	err := m.SetGeoReference(&mimage.GeoReference{Transform: mimage.NorthUpTransform(500, 1000, 2, 4)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wx, wy, err := m.PixelToWorld(10, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wx != 520 || wy != 920 {
+		t.Errorf("pixel (10,20) is world (%v,%v), want (520,920)", wx, wy)
+	}
+	px, py, err := m.WorldToPixel(wx, wy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(px-10) > 1e-9 || math.Abs(py-20) > 1e-9 {
+		t.Errorf("world (%v,%v) is pixel (%v,%v), want (10,20)", wx, wy, px, py)
+	}
+}
+
+func TestWorldFile(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64))
+	err := m.SetGeoReference(&mimage.GeoReference{Transform: mimage.NorthUpTransform(500, 1000, 2, 4)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	err = m.WriteWorldFile(buf, image.Rect(10, 20, 30, 40))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the center of the region's top left pixel
+	want := "2\n0\n0\n-4\n521\n918\n"
+	if buf.String() != want {
+		t.Errorf("world file is %q, want %q", buf.String(), want)
+	}
+
+	tr, err := mimage.ParseWorldFile(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr != mimage.NorthUpTransform(520, 920, 2, 4) {
+		t.Errorf("parsed transform %v", tr)
+	}
+
+	for _, bad := range []string{"1\n2\n3\n", "1\n0\n0\n-1\nten\n0\n"} {
+		if _, err := mimage.ParseWorldFile(strings.NewReader(bad)); err == nil {
+			t.Errorf("world file %q got no error", bad)
+		}
+	}
+}
+
+func TestDrawWorld(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64))
+	err := m.SetGeoReference(&mimage.GeoReference{Transform: mimage.NorthUpTransform(500, 1000, 2, 4)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	op, err := m.DrawWorld()
+	if err != nil {
+		t.Fatal(err)
+	}
+	op.SetColor(color.RGBA{255, 0, 0, 255})
+	// world (520,920) to (540,880) is pixels (10,20) to (20,30)
+	op.DrawRectangle(520, 920, 20, -40)
+	op.Fill()
+	err = op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pt := range []image.Point{{10, 20}, {19, 29}} {
+		if got := m.At(pt.X, pt.Y); got != (color.RGBA{255, 0, 0, 255}) {
+			t.Errorf("pixel %v is %v, want red", pt, got)
+		}
+	}
+	for _, pt := range []image.Point{{9, 20}, {20, 29}, {15, 31}} {
+		if got := m.At(pt.X, pt.Y); got != (color.RGBA{}) {
+			t.Errorf("pixel %v is %v, want empty", pt, got)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package mimage
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+// maxWriters is the most routines writing chunks out behind (see
+// WriteBehind)
+const maxWriters = 4
+
+// WriteBehind has chunks evicted with EvictLRU written out by routines in the
+// background, rather than by whichever routine loaded the chunk that pushed
+// them out, so encoding & writing overlaps with drawing. At most n chunks wait
+// to be written (on top of MaxChunks) before loading waits for them.
+//
+// With EvictIdle every chunk is already written out by a routine of its own,
+// so it can only be used with EvictLRU (see MaxChunks).
+func WriteBehind(n int) Option {
+	return func(m *Mimage) error {
+		if n < 0 {
+			return fmt.Errorf("write behind must not be negative, given %d", n)
+		}
+		m.writeBehind = n
+		return nil
+	}
+}
+
+// setWriteBehind starts the routines writing chunks out behind, with up to n
+// waiting. It's expected to be called once, before any chunks are loaded.
+func (c *cache) setWriteBehind(n int) {
+	if n == 0 {
+		return
+	}
+	c.chunkLock.Lock()
+	defer c.chunkLock.Unlock()
+
+	c.writes = make(chan *context, n)
+	for i := 0; i < minInt(n, maxWriters); i++ {
+		go c.writeBehind()
+	}
+}
+
+// queueWrites hands chunks evict picked to be written behind, waiting if
+// too many already are. The caller is expected to hold chunkLock, which is
+// released.
+func (c *cache) queueWrites(victims []*context) {
+	for _, ctx := range victims {
+		atomic.StoreInt32(&ctx.queued, 1)
+		ctx.queuedAt = ctx.used
+	}
+	c.chunkLock.Unlock()
+
+	for _, ctx := range victims {
+		c.writes <- ctx
+	}
+}
+
+// writeBehind writes out & unloads chunks as they're queued, unless they've
+// been used again since.
+func (c *cache) writeBehind() {
+	for {
+		var ctx *context
+		select {
+		case ctx = <-c.writes:
+		case <-c.stop:
+			return
+		}
+
+		c.chunkLock.Lock()
+		if atomic.LoadInt32(&ctx.users) > 0 || ctx.used != ctx.queuedAt {
+			atomic.StoreInt32(&ctx.queued, 0)
+			c.chunkLock.Unlock()
+			continue // it's wanted after all, evict will pick it again if not
+		}
+		ctx.unloadLock.Lock() // no one is using it, so this doesn't wait
+		c.chunkLock.Unlock()
+
+		err := ctx.unloadImage()
+		atomic.StoreInt32(&ctx.queued, 0)
+		ctx.unloadLock.Unlock()
+		if err != nil {
+			log.Printf("failed to unload image to disk %s: %v\n", ctx.key, err)
+		}
+	}
+}
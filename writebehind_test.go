@@ -0,0 +1,63 @@
+package mimage_test
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestWriteBehind(t *testing.T) {
+	before := runtime.NumGoroutine()
+	dir := t.TempDir()
+	m, err := mimage.New(image.Rect(0, 0, 128, 128), mimage.Directory(dir), mimage.ChunkSize(32), mimage.MaxChunks(2), mimage.WriteBehind(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// chunks are pushed out & written behind as others are drawn
+	red := color.RGBA{255, 0, 0, 255}
+	for y := 0; y < 128; y += 32 {
+		for x := 0; x < 128; x += 32 {
+			op := m.Draw()
+			op.SetColor(red)
+			op.DrawRectangle(float64(x), float64(y), 10, 10)
+			op.Fill()
+			err := op.Do()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for y := 0; y < 128; y += 32 {
+		for x := 0; x < 128; x += 32 {
+			if got := m.At(x+5, y+5); got != red {
+				t.Fatalf("pixel (%d,%d) is %v, want %v", x+5, y+5, got, red)
+			}
+		}
+	}
+
+	// the writers stop with the image
+	err = m.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := settledGoroutines(before); n > before {
+		t.Errorf("%d goroutines running after Close, %d before", n, before)
+	}
+
+	loaded, err := mimage.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	if got := loaded.At(100, 100); got != red {
+		t.Errorf("reloaded pixel is %v, want %v", got, red)
+	}
+
+	if _, err := mimage.New(image.Rect(0, 0, 10, 10), mimage.WriteBehind(-1)); err == nil {
+		t.Error("negative write behind got no error")
+	}
+}
@@ -0,0 +1,134 @@
+package mimage
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// zarrZlibLevel is how hard we compress zarr chunks
+const zarrZlibLevel = 6
+
+// zarrArray is the .zarray metadata of a Zarr v2 array.
+type zarrArray struct {
+	ZarrFormat         int                    `json:"zarr_format"`
+	Shape              []int                  `json:"shape"`
+	Chunks             []int                  `json:"chunks"`
+	Dtype              string                 `json:"dtype"`
+	Compressor         map[string]interface{} `json:"compressor"`
+	FillValue          int                    `json:"fill_value"`
+	Order              string                 `json:"order"`
+	Filters            []interface{}          `json:"filters"`
+	DimensionSeparator string                 `json:"dimension_separator"`
+}
+
+// ExportZarr writes the image to the directory dir as a Zarr (v2) array of
+// shape (height, width, 4) holding 8 bit straight (not premultiplied) RGBA,
+// chunked like the image itself, so that scientific Python tools (zarr, dask,
+// napari) can read it lazily, eg.
+//
+//	dask.array.from_zarr("/path/to/dir")
+//
+// Chunks are zlib compressed & entirely transparent chunks are left out (Zarr
+// reads them as zeros). The image bounds, and georeference if there is one,
+// are written as attributes.
+func (m *Mimage) ExportZarr(dir string) error {
+	err := os.MkdirAll(dir, 0750)
+	if err != nil {
+		return err
+	}
+
+	cs := m.chunkSize
+	w, h := m.bounds.Dx(), m.bounds.Dy()
+	meta, err := json.Marshal(&zarrArray{
+		ZarrFormat:         2,
+		Shape:              []int{h, w, 4},
+		Chunks:             []int{cs, cs, 4},
+		Dtype:              "|u1",
+		Compressor:         map[string]interface{}{"id": "zlib", "level": zarrZlibLevel},
+		Order:              "C",
+		DimensionSeparator: ".",
+	})
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, ".zarray"), meta, 0640)
+	if err != nil {
+		return err
+	}
+
+	attrs := map[string]interface{}{
+		"bounds": []int{m.bounds.Min.X, m.bounds.Min.Y, m.bounds.Max.X, m.bounds.Max.Y},
+	}
+	if g := m.GeoReference(); g != nil {
+		attrs["transform"] = g.Transform
+		attrs["crs"] = g.CRS
+	}
+	data, err := json.Marshal(attrs)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, ".zattrs"), data, 0640)
+	if err != nil {
+		return err
+	}
+
+	// zarr chunks start from the top left of the image
+	for row := 0; row*cs < h; row++ {
+		for col := 0; col*cs < w; col++ {
+			r := image.Rect(col*cs, row*cs, (col+1)*cs, (row+1)*cs).Add(m.bounds.Min)
+			err = m.writeZarrChunk(filepath.Join(dir, fmt.Sprintf("%d.%d.0", row, col)), r)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeZarrChunk writes the region r (a whole chunk, padded with zeros past
+// the edge of the image) to path, unless it's entirely transparent.
+func (m *Mimage) writeZarrChunk(path string, r image.Rectangle) error {
+	img := image.NewNRGBA(r.Sub(r.Min))
+	in, err := m.ImageNRGBA(r.Intersect(m.bounds))
+	if err != nil {
+		return err
+	}
+	draw.Draw(img, in.Bounds(), in, image.Point{}, draw.Src)
+
+	empty := true
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] != 0 {
+			empty = false
+			break
+		}
+	}
+	if empty {
+		err = os.Remove(path) // in case we're overwriting an older export
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	zw, err := zlib.NewWriterLevel(buf, zarrZlibLevel)
+	if err != nil {
+		return err
+	}
+	_, err = zw.Write(img.Pix)
+	if err != nil {
+		return err
+	}
+	err = zw.Close()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0640)
+}
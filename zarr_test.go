@@ -0,0 +1,82 @@
+package mimage_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/voidshard/mimage"
+)
+
+func TestExportZarr(t *testing.T) {
+	m := newImage(t, image.Rect(0, 0, 64, 64), mimage.ChunkSize(32))
+	op := m.Draw()
+	op.SetColor(color.RGBA{0, 0, 128, 128})
+	op.DrawRectangle(40, 8, 8, 8)
+	op.Fill()
+	err := op.Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "out.zarr")
+	err = m.ExportZarr(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, ".zarray"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := struct {
+		Shape  []int
+		Chunks []int
+		Dtype  string
+	}{}
+	err = json.Unmarshal(data, &meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meta.Shape) != 3 || meta.Shape[0] != 64 || meta.Shape[1] != 64 || meta.Shape[2] != 4 || meta.Chunks[0] != 32 || meta.Dtype != "|u1" {
+		t.Errorf("unexpected metadata %s", data)
+	}
+
+	// transparent chunks are left out
+	for _, name := range []string{"0.0.0", "1.0.0", "1.1.0"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("chunk %s written, expected it to be left out", name)
+		}
+	}
+
+	f, err := os.Open(filepath.Join(dir, "0.1.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pix, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pix) != 32*32*4 {
+		t.Fatalf("chunk holds %d bytes, want %d", len(pix), 32*32*4)
+	}
+	// (42,10) is (10,10) in the chunk, written straight (not premultiplied)
+	i := (10*32 + 10) * 4
+	if !bytes.Equal(pix[i:i+4], []byte{0, 0, 255, 128}) {
+		t.Errorf("pixel is %v, want [0 0 255 128]", pix[i:i+4])
+	}
+	if !bytes.Equal(pix[:4], []byte{0, 0, 0, 0}) {
+		t.Errorf("pixel is %v, want transparent", pix[:4])
+	}
+}